@@ -19,13 +19,17 @@ var (
 	PrintVersion = flag.Bool("version", false, "print version and exit")
 	PrintHelp    = flag.Bool("help", false, "print help and exit")
 	LogDir       = flag.String("log-dir", "./logs", "specify the log directory")
+	// ReencryptChannelKeys 按当前主密钥重新加密所有渠道密钥后退出，用于主密钥轮换
+	ReencryptChannelKeys = flag.Bool("reencrypt-channel-keys", false, "re-encrypt all channel keys with the current master key and exit")
+	// ApplyConfigPath 启动时应用的声明式配置包（YAML / JSON）
+	ApplyConfigPath = flag.String("apply-config", "", "apply a declarative config bundle (YAML or JSON) on startup")
 )
 
 func printHelp() {
 	fmt.Println("NewAPI(Based OneAPI) " + Version + " - The next-generation LLM gateway and AI asset management system supports multiple languages.")
 	fmt.Println("Original Project: OneAPI by JustSong - https://github.com/songquanpeng/one-api")
 	fmt.Println("Maintainer: QuantumNous - https://github.com/QuantumNous/new-api")
-	fmt.Println("Usage: newapi [--port <port>] [--log-dir <log directory>] [--version] [--help]")
+	fmt.Println("Usage: newapi [--port <port>] [--log-dir <log directory>] [--reencrypt-channel-keys] [--apply-config <config file>] [--version] [--help]")
 }
 
 func InitEnv() {
@@ -61,6 +65,9 @@ func InitEnv() {
 	} else {
 		CryptoSecret = SessionSecret
 	}
+	if err := InitSecretEncryption(); err != nil {
+		log.Fatal("failed to initialize channel key encryption: " + err.Error())
+	}
 	if os.Getenv("SQLITE_PATH") != "" {
 		SQLitePath = os.Getenv("SQLITE_PATH")
 	}
@@ -161,6 +168,10 @@ func initConstantEnv() {
 	constant.TaskQueryLimit = GetEnvOrDefault("TASK_QUERY_LIMIT", 1000)
 	// 异步任务超时时间（分钟），超过此时间未完成的任务将被标记为失败并退款。0 表示禁用。
 	constant.TaskTimeoutMinutes = GetEnvOrDefault("TASK_TIMEOUT_MINUTES", 1440)
+	// 生成图片的本地存储目录（上游只返回图片字节而客户端要求 url 时使用），为空则使用磁盘缓存目录
+	constant.ImageStoragePath = GetEnvOrDefaultString("IMAGE_STORAGE_PATH", "")
+	// 本地存储图片的保留时间（小时）
+	constant.ImageStorageRetentionHours = GetEnvOrDefault("IMAGE_STORAGE_RETENTION_HOURS", 24)
 
 	soraPatchStr := GetEnvOrDefaultString("TASK_PRICE_PATCH", "")
 	if soraPatchStr != "" {
@@ -0,0 +1,446 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrJSONSchemaUnsupported schema 使用了校验器不支持的关键字
+var ErrJSONSchemaUnsupported = errors.New("unsupported json schema keyword")
+
+// 支持校验的关键字：
+//
+//	通用：type、enum、const、nullable、$ref（仅限 # 开头的本地引用）、allOf、anyOf、oneOf
+//	对象：properties、required、additionalProperties、minProperties、maxProperties
+//	数组：items（单个 schema）、minItems、maxItems、uniqueItems
+//	字符串：minLength、maxLength、pattern
+//	数值：minimum、maximum、exclusiveMinimum、exclusiveMaximum（数值形式）、multipleOf
+//
+// 另有仅作说明、不参与校验的注解关键字。其余关键字（not、if/then/else、patternProperties、prefixItems 等）
+// 会使 schema 被整体拒绝，避免静默放过本应失败的数据。
+var supportedJSONSchemaKeywords = map[string]bool{
+	"type": true, "enum": true, "const": true, "nullable": true, "$ref": true,
+	"allOf": true, "anyOf": true, "oneOf": true,
+	"properties": true, "required": true, "additionalProperties": true, "minProperties": true, "maxProperties": true,
+	"items": true, "minItems": true, "maxItems": true, "uniqueItems": true,
+	"minLength": true, "maxLength": true, "pattern": true,
+	"minimum": true, "maximum": true, "exclusiveMinimum": true, "exclusiveMaximum": true, "multipleOf": true,
+}
+
+var annotationJSONSchemaKeywords = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true, "$defs": true, "definitions": true,
+	"title": true, "description": true, "default": true, "examples": true, "format": true,
+	"deprecated": true, "readOnly": true, "writeOnly": true, "strict": true,
+}
+
+// ValidateJSONSchema 使用 JSON Schema 的子集校验数据，支持的关键字见 supportedJSONSchemaKeywords。
+// schema 含不支持的关键字时返回包装 ErrJSONSchemaUnsupported 的错误。schema 与 value 均应为 Unmarshal 到 any 的结果。
+func ValidateJSONSchema(schema any, value any) error {
+	root, ok := schema.(map[string]any)
+	if !ok {
+		return nil
+	}
+	if err := CheckJSONSchemaSupported(root); err != nil {
+		return err
+	}
+	v := &jsonSchemaValidator{root: root}
+	return v.validate(root, value, "$", 0)
+}
+
+// CheckJSONSchemaSupported 检查 schema 及其全部子 schema 是否只使用了支持的关键字
+func CheckJSONSchemaSupported(schema any) error {
+	return checkJSONSchemaKeywords(schema, "#", 0)
+}
+
+func checkJSONSchemaKeywords(schema any, path string, depth int) error {
+	node, ok := schema.(map[string]any)
+	if !ok {
+		return nil
+	}
+	if depth > maxJSONSchemaDepth {
+		return fmt.Errorf("%s: schema nesting too deep", path)
+	}
+	keys := make([]string, 0, len(node))
+	for key := range node {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		raw := node[key]
+		if annotationJSONSchemaKeywords[key] {
+			continue
+		}
+		if !supportedJSONSchemaKeywords[key] {
+			return fmt.Errorf("%s: %w %q", path, ErrJSONSchemaUnsupported, key)
+		}
+		switch key {
+		case "items":
+			if _, isList := raw.([]any); isList {
+				return fmt.Errorf("%s: %w %q (tuple form)", path, ErrJSONSchemaUnsupported, key)
+			}
+		case "exclusiveMinimum", "exclusiveMaximum":
+			if _, isBool := raw.(bool); isBool {
+				return fmt.Errorf("%s: %w %q (boolean form)", path, ErrJSONSchemaUnsupported, key)
+			}
+		case "$ref":
+			if ref, _ := raw.(string); ref != "#" && !strings.HasPrefix(ref, "#/") {
+				return fmt.Errorf("%s: %w %q (%s)", path, ErrJSONSchemaUnsupported, key, ref)
+			}
+		}
+	}
+	for _, key := range []string{"items", "additionalProperties"} {
+		if err := checkJSONSchemaKeywords(node[key], path+"/"+key, depth+1); err != nil {
+			return err
+		}
+	}
+	for _, key := range []string{"allOf", "anyOf", "oneOf"} {
+		list, _ := node[key].([]any)
+		for i, sub := range list {
+			if err := checkJSONSchemaKeywords(sub, fmt.Sprintf("%s/%s/%d", path, key, i), depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	for _, key := range []string{"properties", "$defs", "definitions"} {
+		children, _ := node[key].(map[string]any)
+		names := make([]string, 0, len(children))
+		for name := range children {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := checkJSONSchemaKeywords(children[name], path+"/"+key+"/"+name, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type jsonSchemaValidator struct {
+	root map[string]any
+}
+
+const maxJSONSchemaDepth = 64
+
+func (v *jsonSchemaValidator) validate(schema map[string]any, value any, path string, depth int) error {
+	if depth > maxJSONSchemaDepth {
+		return fmt.Errorf("%s: schema nesting too deep", path)
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, err := v.resolveRef(ref)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return v.validate(resolved, value, path, depth+1)
+	}
+
+	if nullable, _ := schema["nullable"].(bool); nullable && value == nil {
+		return nil
+	}
+	if err := checkJSONSchemaType(schema["type"], value, path); err != nil {
+		return err
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		matched := false
+		for _, candidate := range enum {
+			if jsonValuesEqual(candidate, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: value is not one of the allowed enum values", path)
+		}
+	}
+	if constValue, ok := schema["const"]; ok && !jsonValuesEqual(constValue, value) {
+		return fmt.Errorf("%s: value does not match const", path)
+	}
+
+	switch typed := value.(type) {
+	case map[string]any:
+		if err := v.validateObject(schema, typed, path, depth); err != nil {
+			return err
+		}
+	case []any:
+		if err := v.validateArray(schema, typed, path, depth); err != nil {
+			return err
+		}
+	case string:
+		if err := validateJSONSchemaString(schema, typed, path); err != nil {
+			return err
+		}
+	case float64, json.Number:
+		if err := validateJSONSchemaNumber(schema, toFloat64(typed), path); err != nil {
+			return err
+		}
+	}
+
+	if allOf, ok := schema["allOf"].([]any); ok {
+		for _, sub := range allOf {
+			if subSchema, ok := sub.(map[string]any); ok {
+				if err := v.validate(subSchema, value, path, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok && len(anyOf) > 0 {
+		if v.countMatches(anyOf, value, path, depth) == 0 {
+			return fmt.Errorf("%s: value does not match any schema in anyOf", path)
+		}
+	}
+	if oneOf, ok := schema["oneOf"].([]any); ok && len(oneOf) > 0 {
+		if matches := v.countMatches(oneOf, value, path, depth); matches != 1 {
+			return fmt.Errorf("%s: value must match exactly one schema in oneOf, matched %d", path, matches)
+		}
+	}
+	return nil
+}
+
+func (v *jsonSchemaValidator) countMatches(schemas []any, value any, path string, depth int) int {
+	matches := 0
+	for _, sub := range schemas {
+		subSchema, ok := sub.(map[string]any)
+		if !ok {
+			continue
+		}
+		if v.validate(subSchema, value, path, depth+1) == nil {
+			matches++
+		}
+	}
+	return matches
+}
+
+func (v *jsonSchemaValidator) resolveRef(ref string) (map[string]any, error) {
+	if ref == "#" {
+		return v.root, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+	var current any = v.root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		node, ok := current.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+		current, ok = node[part]
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+	}
+	resolved, ok := current.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("unresolvable $ref %q", ref)
+	}
+	return resolved, nil
+}
+
+func (v *jsonSchemaValidator) validateObject(schema map[string]any, obj map[string]any, path string, depth int) error {
+	if required, ok := schema["required"].([]any); ok {
+		for _, item := range required {
+			name, ok := item.(string)
+			if !ok {
+				continue
+			}
+			if _, exists := obj[name]; !exists {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+	}
+	properties, _ := schema["properties"].(map[string]any)
+	// 按 key 排序以保证错误信息稳定
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		childPath := path + "." + key
+		if propSchema, ok := properties[key].(map[string]any); ok {
+			if err := v.validate(propSchema, obj[key], childPath, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return fmt.Errorf("%s: additional property %q is not allowed", path, key)
+			}
+		case map[string]any:
+			if err := v.validate(additional, obj[key], childPath, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	if minProps, ok := toInt(schema["minProperties"]); ok && len(obj) < minProps {
+		return fmt.Errorf("%s: expected at least %d properties", path, minProps)
+	}
+	if maxProps, ok := toInt(schema["maxProperties"]); ok && len(obj) > maxProps {
+		return fmt.Errorf("%s: expected at most %d properties", path, maxProps)
+	}
+	return nil
+}
+
+func (v *jsonSchemaValidator) validateArray(schema map[string]any, arr []any, path string, depth int) error {
+	if minItems, ok := toInt(schema["minItems"]); ok && len(arr) < minItems {
+		return fmt.Errorf("%s: expected at least %d items", path, minItems)
+	}
+	if maxItems, ok := toInt(schema["maxItems"]); ok && len(arr) > maxItems {
+		return fmt.Errorf("%s: expected at most %d items", path, maxItems)
+	}
+	if itemSchema, ok := schema["items"].(map[string]any); ok {
+		for i, item := range arr {
+			if err := v.validate(itemSchema, item, fmt.Sprintf("%s[%d]", path, i), depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := 0; i < len(arr); i++ {
+			for j := i + 1; j < len(arr); j++ {
+				if jsonValuesEqual(arr[i], arr[j]) {
+					return fmt.Errorf("%s: items must be unique", path)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func validateJSONSchemaString(schema map[string]any, s string, path string) error {
+	length := len([]rune(s))
+	if minLength, ok := toInt(schema["minLength"]); ok && length < minLength {
+		return fmt.Errorf("%s: string shorter than %d", path, minLength)
+	}
+	if maxLength, ok := toInt(schema["maxLength"]); ok && length > maxLength {
+		return fmt.Errorf("%s: string longer than %d", path, maxLength)
+	}
+	if pattern, ok := schema["pattern"].(string); ok && pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err == nil && !re.MatchString(s) {
+			return fmt.Errorf("%s: string does not match pattern %q", path, pattern)
+		}
+	}
+	return nil
+}
+
+func validateJSONSchemaNumber(schema map[string]any, n float64, path string) error {
+	if minimum, ok := toFloat(schema["minimum"]); ok && n < minimum {
+		return fmt.Errorf("%s: value must be >= %v", path, minimum)
+	}
+	if maximum, ok := toFloat(schema["maximum"]); ok && n > maximum {
+		return fmt.Errorf("%s: value must be <= %v", path, maximum)
+	}
+	if exclusiveMin, ok := toFloat(schema["exclusiveMinimum"]); ok && n <= exclusiveMin {
+		return fmt.Errorf("%s: value must be > %v", path, exclusiveMin)
+	}
+	if exclusiveMax, ok := toFloat(schema["exclusiveMaximum"]); ok && n >= exclusiveMax {
+		return fmt.Errorf("%s: value must be < %v", path, exclusiveMax)
+	}
+	if multipleOf, ok := toFloat(schema["multipleOf"]); ok && multipleOf > 0 {
+		quotient := n / multipleOf
+		if math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+			return fmt.Errorf("%s: value must be a multiple of %v", path, multipleOf)
+		}
+	}
+	return nil
+}
+
+func checkJSONSchemaType(typeDef any, value any, path string) error {
+	var types []string
+	switch t := typeDef.(type) {
+	case string:
+		types = []string{t}
+	case []any:
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+	default:
+		return nil
+	}
+	if len(types) == 0 {
+		return nil
+	}
+	actual := jsonValueType(value)
+	for _, expected := range types {
+		if expected == actual {
+			return nil
+		}
+		if expected == "number" && actual == "integer" {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: expected type %s, got %s", path, strings.Join(types, "|"), actual)
+}
+
+func jsonValueType(value any) string {
+	switch typed := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case float64, json.Number:
+		n := toFloat64(typed)
+		if n == math.Trunc(n) && !math.IsInf(n, 0) {
+			return "integer"
+		}
+		return "number"
+	default:
+		return "unknown"
+	}
+}
+
+func jsonValuesEqual(a, b any) bool {
+	aNum, aIsNum := toFloat(a)
+	bNum, bIsNum := toFloat(b)
+	if aIsNum || bIsNum {
+		return aIsNum && bIsNum && aNum == bNum
+	}
+	aBytes, errA := Marshal(a)
+	bBytes, errB := Marshal(b)
+	return errA == nil && errB == nil && string(aBytes) == string(bBytes)
+}
+
+func toFloat64(value any) float64 {
+	n, _ := toFloat(value)
+	return n
+}
+
+func toFloat(value any) (float64, bool) {
+	switch typed := value.(type) {
+	case float64:
+		return typed, true
+	case json.Number:
+		n, err := typed.Float64()
+		return n, err == nil
+	case int:
+		return float64(typed), true
+	default:
+		return 0, false
+	}
+}
+
+func toInt(value any) (int, bool) {
+	n, ok := toFloat(value)
+	if !ok {
+		return 0, false
+	}
+	return int(n), true
+}
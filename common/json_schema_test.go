@@ -0,0 +1,84 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func mustUnmarshalAny(t *testing.T, s string) any {
+	t.Helper()
+	var v any
+	require.NoError(t, UnmarshalJsonStr(s, &v))
+	return v
+}
+
+func TestValidateJSONSchema(t *testing.T) {
+	schema := mustUnmarshalAny(t, `{
+		"type": "object",
+		"properties": {
+			"city": {"type": "string", "minLength": 1},
+			"unit": {"type": "string", "enum": ["c", "f"]},
+			"days": {"type": "integer", "minimum": 1, "maximum": 7},
+			"tags": {"type": "array", "items": {"$ref": "#/$defs/tag"}}
+		},
+		"required": ["city"],
+		"additionalProperties": false,
+		"$defs": {"tag": {"type": "string"}}
+	}`)
+
+	cases := []struct {
+		name    string
+		value   string
+		wantErr string
+	}{
+		{name: "valid", value: `{"city":"Paris","unit":"c","days":3,"tags":["a"]}`},
+		{name: "missing required", value: `{"unit":"c"}`, wantErr: `missing required property "city"`},
+		{name: "wrong type", value: `{"city":1}`, wantErr: "expected type string"},
+		{name: "enum", value: `{"city":"Paris","unit":"k"}`, wantErr: "enum"},
+		{name: "integer", value: `{"city":"Paris","days":1.5}`, wantErr: "expected type integer"},
+		{name: "maximum", value: `{"city":"Paris","days":9}`, wantErr: "<= 7"},
+		{name: "additional", value: `{"city":"Paris","extra":true}`, wantErr: `additional property "extra"`},
+		{name: "ref items", value: `{"city":"Paris","tags":[1]}`, wantErr: "$.tags[0]"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateJSONSchema(schema, mustUnmarshalAny(t, tc.value))
+			if tc.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
+
+func TestValidateJSONSchema_AnyOfAndNullable(t *testing.T) {
+	schema := mustUnmarshalAny(t, `{"anyOf":[{"type":"string"},{"type":"null"}]}`)
+	require.NoError(t, ValidateJSONSchema(schema, nil))
+	require.NoError(t, ValidateJSONSchema(schema, "x"))
+	require.Error(t, ValidateJSONSchema(schema, 1.0))
+
+	nullable := mustUnmarshalAny(t, `{"type":"string","nullable":true}`)
+	require.NoError(t, ValidateJSONSchema(nullable, nil))
+}
+
+func TestValidateJSONSchema_RejectsUnsupportedKeywords(t *testing.T) {
+	cases := map[string]string{
+		"not":               `{"type":"object","properties":{"a":{"not":{"type":"string"}}}}`,
+		"patternProperties": `{"type":"object","patternProperties":{"^x":{"type":"string"}}}`,
+		"tuple items":       `{"type":"array","items":[{"type":"string"}]}`,
+		"remote ref":        `{"$ref":"https://example.com/schema.json"}`,
+		"nested in defs":    `{"$defs":{"a":{"if":{"type":"string"}}}}`,
+	}
+	for name, raw := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateJSONSchema(mustUnmarshalAny(t, raw), map[string]any{})
+			require.ErrorIs(t, err, ErrJSONSchemaUnsupported)
+		})
+	}
+
+	annotated := mustUnmarshalAny(t, `{"$schema":"https://json-schema.org/draft/2020-12/schema","title":"t","type":"string","format":"date"}`)
+	require.NoError(t, ValidateJSONSchema(annotated, "2024-01-01"))
+}
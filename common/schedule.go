@@ -0,0 +1,144 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ActiveSchedule 类 cron 的生效时间表达式，五个字段依次为 分 时 日 月 周（0-6，0 为周日，7 同样表示周日），
+// 每个字段支持 *、数值、范围 a-b、列表 a,b 与步长 */n、a-b/n。
+// 多个表达式以 | 分隔，满足任意一个即生效，例如工作日白天加周末全天：
+//
+//	0-59 9-17 * * 1-5 | * * * * 0,6
+type ActiveSchedule struct {
+	rules    []scheduleRule
+	location *time.Location
+}
+
+type scheduleRule struct {
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+}
+
+var scheduleFieldRanges = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+var activeScheduleCache sync.Map // expr + "@" + timezone -> *ActiveSchedule
+
+// ParseActiveSchedule 解析生效时间表达式，timezone 为 IANA 时区名，为空时使用服务器本地时区
+func ParseActiveSchedule(expr string, timezone string) (*ActiveSchedule, error) {
+	location := time.Local
+	if timezone = strings.TrimSpace(timezone); timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+		}
+		location = loc
+	}
+	schedule := &ActiveSchedule{location: location}
+	for _, part := range strings.Split(expr, "|") {
+		fields := strings.Fields(part)
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("invalid schedule %q: expected 5 fields", strings.TrimSpace(part))
+		}
+		var masks [5]uint64
+		for i, field := range fields {
+			mask, err := parseScheduleField(field, scheduleFieldRanges[i][0], scheduleFieldRanges[i][1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid schedule %q: %w", strings.TrimSpace(part), err)
+			}
+			masks[i] = mask
+		}
+		// 周日既可以写 0 也可以写 7
+		if masks[4]&(1<<7) != 0 {
+			masks[4] |= 1
+		}
+		schedule.rules = append(schedule.rules, scheduleRule{
+			minutes:  masks[0],
+			hours:    masks[1],
+			days:     masks[2],
+			months:   masks[3],
+			weekdays: masks[4],
+		})
+	}
+	return schedule, nil
+}
+
+func parseScheduleField(field string, min int, max int) (uint64, error) {
+	var mask uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", item)
+			}
+			step = n
+		}
+		start, end := min, max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if start, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("invalid value %q", item)
+			}
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("invalid value %q", item)
+				}
+			} else if hasStep {
+				end = max
+			}
+		}
+		if start < min || end > max || start > end {
+			return 0, fmt.Errorf("value %q out of range %d-%d", item, min, max)
+		}
+		for v := start; v <= end; v += step {
+			mask |= 1 << uint(v)
+		}
+	}
+	return mask, nil
+}
+
+// Active 判断给定时间是否处于生效时间内
+func (s *ActiveSchedule) Active(t time.Time) bool {
+	if s == nil {
+		return true
+	}
+	t = t.In(s.location)
+	for _, rule := range s.rules {
+		if rule.minutes&(1<<uint(t.Minute())) != 0 &&
+			rule.hours&(1<<uint(t.Hour())) != 0 &&
+			rule.days&(1<<uint(t.Day())) != 0 &&
+			rule.months&(1<<uint(t.Month())) != 0 &&
+			rule.weekdays&(1<<uint(t.Weekday())) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// IsScheduleActive 判断表达式在给定时间是否生效，解析结果会被缓存。表达式为空或无法解析时视为始终生效
+func IsScheduleActive(expr string, timezone string, t time.Time) bool {
+	if strings.TrimSpace(expr) == "" {
+		return true
+	}
+	cacheKey := expr + "@" + timezone
+	if cached, ok := activeScheduleCache.Load(cacheKey); ok {
+		return cached.(*ActiveSchedule).Active(t)
+	}
+	schedule, err := ParseActiveSchedule(expr, timezone)
+	if err != nil {
+		SysError("failed to parse active schedule: " + err.Error())
+		schedule = nil
+	}
+	activeScheduleCache.Store(cacheKey, schedule)
+	return schedule.Active(t)
+}
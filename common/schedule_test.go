@@ -0,0 +1,47 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestActiveSchedule(t *testing.T) {
+	t.Parallel()
+
+	schedule, err := ParseActiveSchedule("* 9-17 * * 1-5 | 0-29 * * * 7", "Asia/Shanghai")
+	require.NoError(t, err)
+
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	require.NoError(t, err)
+	// 2026-10-19 为周一
+	require.True(t, schedule.Active(time.Date(2026, 10, 19, 9, 0, 0, 0, shanghai)))
+	require.True(t, schedule.Active(time.Date(2026, 10, 19, 17, 59, 0, 0, shanghai)))
+	require.False(t, schedule.Active(time.Date(2026, 10, 19, 18, 0, 0, 0, shanghai)))
+	// 同一时刻换算为 UTC 后仍按配置的时区判断
+	require.True(t, schedule.Active(time.Date(2026, 10, 19, 1, 30, 0, 0, time.UTC)))
+	// 周六不生效，周日仅前半小时生效（7 等同于 0）
+	require.False(t, schedule.Active(time.Date(2026, 10, 24, 10, 0, 0, 0, shanghai)))
+	require.True(t, schedule.Active(time.Date(2026, 10, 25, 10, 15, 0, 0, shanghai)))
+	require.False(t, schedule.Active(time.Date(2026, 10, 25, 10, 45, 0, 0, shanghai)))
+
+	stepped, err := ParseActiveSchedule("*/15 22-23,0-6 * * *", "")
+	require.NoError(t, err)
+	require.True(t, stepped.Active(time.Date(2026, 10, 19, 23, 45, 0, 0, time.Local)))
+	require.False(t, stepped.Active(time.Date(2026, 10, 19, 23, 46, 0, 0, time.Local)))
+	require.False(t, stepped.Active(time.Date(2026, 10, 19, 12, 0, 0, 0, time.Local)))
+}
+
+func TestParseActiveSchedule_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "* 18-9 * * *", "*/0 * * * *", "a * * * *"} {
+		_, err := ParseActiveSchedule(expr, "")
+		require.Error(t, err, expr)
+	}
+	_, err := ParseActiveSchedule("* * * * *", "Mars/Olympus")
+	require.Error(t, err)
+
+	require.True(t, IsScheduleActive("", "", time.Now()))
+}
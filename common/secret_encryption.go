@@ -0,0 +1,284 @@
+package common
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 上游密钥（渠道 key、多 key 列表、Vertex 服务账号 JSON、Codex OAuth 凭证等）的信封加密。
+// 每个值使用随机生成的数据密钥（DEK）以 AES-256-GCM 加密，DEK 再由主密钥加密后与密文一起存储：
+//
+//	enc:v1:<主密钥 ID>:<base64(被加密的 DEK)>:<base64(密文)>
+//
+// 未配置主密钥时不加密；没有 enc: 前缀的值视为明文，读取时原样返回，因此可以在不停机的情况下逐步迁移。
+
+const secretEncryptionPrefix = "enc:v1:"
+
+type secretMasterKey struct {
+	id   string
+	aead cipher.AEAD
+	// lookupKey 由主密钥派生，用于计算查找哈希，避免可对哈希做离线字典攻击
+	lookupKey []byte
+}
+
+var (
+	currentSecretMasterKey *secretMasterKey
+	secretMasterKeys       = map[string]*secretMasterKey{}
+)
+
+// InitSecretEncryption 从环境变量加载主密钥：
+// CHANNEL_KEY_ENCRYPTION_KEY / CHANNEL_KEY_ENCRYPTION_KEY_FILE 为当前主密钥，
+// CHANNEL_KEY_ENCRYPTION_OLD_KEYS（逗号分隔）/ CHANNEL_KEY_ENCRYPTION_OLD_KEYS_FILE（每行一个）为轮换前的旧主密钥，仅用于解密
+func InitSecretEncryption() error {
+	current, err := readSecretFromEnv("CHANNEL_KEY_ENCRYPTION_KEY")
+	if err != nil {
+		return err
+	}
+	var oldKeys []string
+	if v := os.Getenv("CHANNEL_KEY_ENCRYPTION_OLD_KEYS"); v != "" {
+		oldKeys = append(oldKeys, strings.Split(v, ",")...)
+	}
+	if path := os.Getenv("CHANNEL_KEY_ENCRYPTION_OLD_KEYS_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read CHANNEL_KEY_ENCRYPTION_OLD_KEYS_FILE: %w", err)
+		}
+		oldKeys = append(oldKeys, strings.Split(string(data), "\n")...)
+	}
+	return SetSecretMasterKeys(current, oldKeys...)
+}
+
+func readSecretFromEnv(name string) (string, error) {
+	if v := os.Getenv(name); v != "" {
+		return v, nil
+	}
+	if path := os.Getenv(name + "_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s_FILE: %w", name, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return "", nil
+}
+
+// SetSecretMasterKeys 设置当前主密钥与旧主密钥，current 为空表示不再加密新写入的值
+func SetSecretMasterKeys(current string, oldKeys ...string) error {
+	keys := map[string]*secretMasterKey{}
+	var currentKey *secretMasterKey
+	for i, raw := range append([]string{current}, oldKeys...) {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		key, err := newSecretMasterKey(raw)
+		if err != nil {
+			return err
+		}
+		keys[key.id] = key
+		if i == 0 {
+			currentKey = key
+		}
+	}
+	currentSecretMasterKey = currentKey
+	secretMasterKeys = keys
+	return nil
+}
+
+// newSecretMasterKey 主密钥可以是任意长度的字符串，经 SHA-256 派生为 AES-256 密钥
+func newSecretMasterKey(raw string) (*secretMasterKey, error) {
+	derived := Sha256Raw([]byte(raw))
+	aead, err := newSecretAEAD(derived)
+	if err != nil {
+		return nil, err
+	}
+	return &secretMasterKey{
+		id:        hex.EncodeToString(Sha256Raw(derived))[:8],
+		aead:      aead,
+		lookupKey: hmacSha256(derived, []byte("secret-lookup-hash")),
+	}, nil
+}
+
+func newSecretAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// SecretEncryptionEnabled 是否配置了当前主密钥
+func SecretEncryptionEnabled() bool {
+	return currentSecretMasterKey != nil
+}
+
+// SecretMasterKeyId 当前主密钥 ID，未启用时为空
+func SecretMasterKeyId() string {
+	if currentSecretMasterKey == nil {
+		return ""
+	}
+	return currentSecretMasterKey.id
+}
+
+// IsEncryptedSecret 判断值是否为加密格式
+func IsEncryptedSecret(value string) bool {
+	return strings.HasPrefix(value, secretEncryptionPrefix)
+}
+
+// EncryptedSecretKeyId 返回加密值使用的主密钥 ID，明文返回空
+func EncryptedSecretKeyId(value string) string {
+	if !IsEncryptedSecret(value) {
+		return ""
+	}
+	keyId, _, _ := strings.Cut(strings.TrimPrefix(value, secretEncryptionPrefix), ":")
+	return keyId
+}
+
+// SecretNeedsReencrypt 判断存储的值是否需要按当前主密钥重新加密（明文待加密、旧主密钥待轮换、或已关闭加密待解密）
+func SecretNeedsReencrypt(value string) bool {
+	if value == "" {
+		return false
+	}
+	return EncryptedSecretKeyId(value) != SecretMasterKeyId()
+}
+
+// EncryptSecret 使用当前主密钥加密，未启用加密或空值原样返回。
+// 带 enc: 前缀的值必须是可由已配置主密钥解密的信封，此时原样返回；否则返回错误，
+// 避免把无法解密的密文（或恰好带前缀的明文）当作已加密值写回
+func EncryptSecret(plaintext string) (string, error) {
+	masterKey := currentSecretMasterKey
+	if plaintext == "" {
+		return plaintext, nil
+	}
+	if IsEncryptedSecret(plaintext) {
+		if _, err := DecryptSecret(plaintext); err != nil {
+			return "", fmt.Errorf("refusing to store unverifiable encrypted secret: %w", err)
+		}
+		return plaintext, nil
+	}
+	if masterKey == nil {
+		return plaintext, nil
+	}
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	dataAEAD, err := newSecretAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	wrappedDEK, err := sealSecret(masterKey.aead, dek)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := sealSecret(dataAEAD, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return secretEncryptionPrefix + masterKey.id + ":" +
+		base64.StdEncoding.EncodeToString(wrappedDEK) + ":" +
+		base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// DecryptSecret 解密 EncryptSecret 的结果，明文原样返回
+func DecryptSecret(value string) (string, error) {
+	if !IsEncryptedSecret(value) {
+		return value, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(value, secretEncryptionPrefix), ":")
+	if len(parts) != 3 {
+		return "", errors.New("invalid encrypted secret format")
+	}
+	masterKey, ok := secretMasterKeys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("master key %s for encrypted secret is not configured", parts[0])
+	}
+	wrappedDEK, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted secret: %w", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted secret: %w", err)
+	}
+	dek, err := openSecret(masterKey.aead, wrappedDEK)
+	if err != nil {
+		return "", err
+	}
+	dataAEAD, err := newSecretAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := openSecret(dataAEAD, ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// ReencryptSecret 解密后按当前主密钥重新加密；当前未启用加密时返回明文
+func ReencryptSecret(value string) (string, error) {
+	plaintext, err := DecryptSecret(value)
+	if err != nil {
+		return "", err
+	}
+	return EncryptSecret(plaintext)
+}
+
+// SecretLookupHash 密钥的查找哈希，用于在密文存储下按完整 key 精确搜索。
+// 启用加密时为以当前主密钥派生密钥计算的 HMAC-SHA256，轮换主密钥后需重新计算（见 ReencryptChannelKeys）；
+// 未启用加密时 key 本身以明文存储，退化为 SHA-256
+func SecretLookupHash(plaintext string) string {
+	return secretLookupHashWith(currentSecretMasterKey, plaintext)
+}
+
+// SecretLookupHashCandidates 返回明文在未加密及各已配置主密钥下可能的查找哈希，用于迁移旧哈希
+func SecretLookupHashCandidates(plaintext string) []string {
+	candidates := []string{secretLookupHashWith(nil, plaintext)}
+	for _, key := range secretMasterKeys {
+		candidates = append(candidates, secretLookupHashWith(key, plaintext))
+	}
+	return candidates
+}
+
+func secretLookupHashWith(key *secretMasterKey, plaintext string) string {
+	if key == nil {
+		return hex.EncodeToString(Sha256Raw([]byte(plaintext)))
+	}
+	return hex.EncodeToString(hmacSha256(key.lookupKey, []byte(plaintext)))
+}
+
+func hmacSha256(key []byte, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func sealSecret(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func openSecret(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("invalid encrypted secret: ciphertext too short")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return plaintext, nil
+}
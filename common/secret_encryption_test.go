@@ -0,0 +1,89 @@
+package common
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretEncryption_RoundTripAndRotation(t *testing.T) {
+	t.Cleanup(func() { _ = SetSecretMasterKeys("") })
+
+	// 未配置主密钥时保持明文
+	require.NoError(t, SetSecretMasterKeys(""))
+	plain, err := EncryptSecret("sk-test")
+	require.NoError(t, err)
+	require.Equal(t, "sk-test", plain)
+
+	require.NoError(t, SetSecretMasterKeys("old-master-key"))
+	oldKeyId := SecretMasterKeyId()
+	encrypted, err := EncryptSecret("sk-test\nsk-second")
+	require.NoError(t, err)
+	require.True(t, IsEncryptedSecret(encrypted))
+	require.NotContains(t, encrypted, "sk-test")
+	require.False(t, SecretNeedsReencrypt(encrypted))
+	require.True(t, SecretNeedsReencrypt("sk-plain"))
+
+	// 已加密的值不会被重复加密
+	again, err := EncryptSecret(encrypted)
+	require.NoError(t, err)
+	require.Equal(t, encrypted, again)
+
+	// 轮换后旧密文仍可解密，并可重新加密为新主密钥
+	require.NoError(t, SetSecretMasterKeys("new-master-key", "old-master-key"))
+	require.NotEqual(t, oldKeyId, SecretMasterKeyId())
+	require.True(t, SecretNeedsReencrypt(encrypted))
+	decrypted, err := DecryptSecret(encrypted)
+	require.NoError(t, err)
+	require.Equal(t, "sk-test\nsk-second", decrypted)
+	rotated, err := ReencryptSecret(encrypted)
+	require.NoError(t, err)
+	require.Equal(t, SecretMasterKeyId(), EncryptedSecretKeyId(rotated))
+
+	// 缺少旧主密钥时无法解密
+	require.NoError(t, SetSecretMasterKeys("new-master-key"))
+	_, err = DecryptSecret(encrypted)
+	require.Error(t, err)
+}
+
+func TestSecretEncryption_RejectsForgedEnvelope(t *testing.T) {
+	t.Cleanup(func() { _ = SetSecretMasterKeys("") })
+	require.NoError(t, SetSecretMasterKeys("master-key"))
+
+	// 仅带前缀、无法通过认证的值不会被当作已加密值原样存储
+	_, err := EncryptSecret("enc:v1:deadbeef:AAAA:AAAA")
+	require.Error(t, err)
+
+	encrypted, err := EncryptSecret("sk-test")
+	require.NoError(t, err)
+	parts := strings.Split(encrypted, ":")
+	parts[len(parts)-1] = base64.StdEncoding.EncodeToString([]byte("tampered-ciphertext-with-nonce"))
+	_, err = EncryptSecret(strings.Join(parts, ":"))
+	require.Error(t, err)
+
+	// 未启用加密时同样拒绝带前缀的明文，否则读取时会被误判为密文
+	require.NoError(t, SetSecretMasterKeys(""))
+	_, err = EncryptSecret(encrypted)
+	require.Error(t, err)
+}
+
+func TestSecretLookupHash_KeyedByMasterKey(t *testing.T) {
+	t.Cleanup(func() { _ = SetSecretMasterKeys("") })
+
+	require.NoError(t, SetSecretMasterKeys(""))
+	unkeyed := SecretLookupHash("sk-test")
+	require.Equal(t, hex.EncodeToString(Sha256Raw([]byte("sk-test"))), unkeyed)
+
+	require.NoError(t, SetSecretMasterKeys("master-a"))
+	hashA := SecretLookupHash("sk-test")
+	require.NotEqual(t, unkeyed, hashA)
+	require.Len(t, hashA, 64)
+
+	require.NoError(t, SetSecretMasterKeys("master-b", "master-a"))
+	hashB := SecretLookupHash("sk-test")
+	require.NotEqual(t, hashA, hashB)
+	require.ElementsMatch(t, []string{unkeyed, hashA, hashB}, SecretLookupHashCandidates("sk-test"))
+}
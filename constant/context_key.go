@@ -64,6 +64,17 @@ const (
 	// It is not returned to end users, but can be persisted into consume/error logs for debugging.
 	ContextKeyAdminRejectReason ContextKey = "admin_reject_reason"
 
+	// ContextKeyOutputValidationFailures stores output validation failures of previous attempts (retries share the context)
+	ContextKeyOutputValidationFailures ContextKey = "output_validation_failures"
+	// ContextKeyOutputValidationResult stores the output validation result of the final attempt for the consume log
+	ContextKeyOutputValidationResult ContextKey = "output_validation_result"
+
+	// ContextKeyTrafficSplitModel stores the upstream model chosen by a traffic split rule, applied before channel model mapping
+	ContextKeyTrafficSplitModel ContextKey = "traffic_split_model"
+
+	// ContextKeyModelAliasFrom stores the requested model name when it was redirected to its successor by the model alias registry
+	ContextKeyModelAliasFrom ContextKey = "model_alias_from"
+
 	// ContextKeyLanguage stores the user's language preference for i18n
 	ContextKeyLanguage ContextKey = "language"
 )
@@ -0,0 +1,205 @@
+package controller
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/shopspring/decimal"
+)
+
+// ChannelBalanceFetcher 查询渠道上游余额并写回渠道，返回值统一换算为美元
+type ChannelBalanceFetcher interface {
+	FetchBalance(channel *model.Channel) (float64, error)
+}
+
+type ChannelBalanceFetcherFunc func(channel *model.Channel) (float64, error)
+
+func (f ChannelBalanceFetcherFunc) FetchBalance(channel *model.Channel) (float64, error) {
+	return f(channel)
+}
+
+const balanceApiOpenAI = "openai"
+
+var channelBalanceFetchers = map[int]ChannelBalanceFetcher{
+	constant.ChannelTypeOpenAI:      ChannelBalanceFetcherFunc(updateChannelOpenAIBalance),
+	constant.ChannelTypeCustom:      ChannelBalanceFetcherFunc(updateChannelOpenAIBalance),
+	constant.ChannelTypeAIProxy:     ChannelBalanceFetcherFunc(updateChannelAIProxyBalance),
+	constant.ChannelTypeAPI2GPT:     ChannelBalanceFetcherFunc(updateChannelAPI2GPTBalance),
+	constant.ChannelTypeAIGC2D:      ChannelBalanceFetcherFunc(updateChannelAIGC2DBalance),
+	constant.ChannelTypeSiliconFlow: ChannelBalanceFetcherFunc(updateChannelSiliconFlowBalance),
+	constant.ChannelTypeDeepSeek:    ChannelBalanceFetcherFunc(updateChannelDeepSeekBalance),
+	constant.ChannelTypeOpenRouter:  ChannelBalanceFetcherFunc(updateChannelOpenRouterBalance),
+	constant.ChannelTypeMoonshot:    ChannelBalanceFetcherFunc(updateChannelMoonshotBalance),
+	constant.ChannelTypeZhipu:       ChannelBalanceFetcherFunc(updateChannelZhipuBalance),
+	constant.ChannelTypeZhipu_v4:    ChannelBalanceFetcherFunc(updateChannelZhipuBalance),
+	constant.ChannelTypeVolcEngine:  ChannelBalanceFetcherFunc(updateChannelVolcEngineBalance),
+}
+
+// RegisterChannelBalanceFetcher 注册或替换渠道类型的余额查询实现
+func RegisterChannelBalanceFetcher(channelType int, fetcher ChannelBalanceFetcher) {
+	channelBalanceFetchers[channelType] = fetcher
+}
+
+// getChannelBalanceFetcher 渠道设置了 balance_api=openai 时使用 OpenAI 兼容的 /dashboard/billing 接口，否则按渠道类型选择
+func getChannelBalanceFetcher(channel *model.Channel) ChannelBalanceFetcher {
+	if channel.GetOtherSettings().BalanceApi == balanceApiOpenAI {
+		return ChannelBalanceFetcherFunc(updateChannelOpenAIBalance)
+	}
+	return channelBalanceFetchers[channel.Type]
+}
+
+func cnyToUsd(cny float64) float64 {
+	return decimal.NewFromFloat(cny).Div(decimal.NewFromFloat(operation_setting.Price)).InexactFloat64()
+}
+
+type ZhipuAccountReportResponse struct {
+	Code    int    `json:"code"`
+	Msg     string `json:"msg"`
+	Success bool   `json:"success"`
+	Data    struct {
+		Balance          float64 `json:"balance"`
+		AvailableBalance float64 `json:"availableBalance"`
+	} `json:"data"`
+}
+
+func updateChannelZhipuBalance(channel *model.Channel) (float64, error) {
+	url := "https://open.bigmodel.cn/api/biz/account/query-customer-account-report"
+	headers := http.Header{}
+	headers.Add("Authorization", channel.Key)
+	body, err := GetResponseBody("GET", url, channel, headers)
+	if err != nil {
+		return 0, err
+	}
+	response := ZhipuAccountReportResponse{}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return 0, err
+	}
+	if !response.Success || response.Code != 200 {
+		return 0, fmt.Errorf("code: %d, message: %s", response.Code, response.Msg)
+	}
+	balanceCny := response.Data.AvailableBalance
+	if balanceCny == 0 {
+		balanceCny = response.Data.Balance
+	}
+	balance := cnyToUsd(balanceCny)
+	channel.UpdateBalance(balance)
+	return balance, nil
+}
+
+type VolcEngineBalanceResponse struct {
+	ResponseMetadata struct {
+		Error *struct {
+			Code    string `json:"Code"`
+			Message string `json:"Message"`
+		} `json:"Error"`
+	} `json:"ResponseMetadata"`
+	Result struct {
+		AvailableBalance string `json:"AvailableBalance"`
+	} `json:"Result"`
+}
+
+// updateChannelVolcEngineBalance 方舟 API Key 无法查询账户余额，需要在渠道设置 balance_credential 中填写 AccessKey|SecretKey
+func updateChannelVolcEngineBalance(channel *model.Channel) (float64, error) {
+	credential, err := channel.GetBalanceCredential()
+	if err != nil {
+		return 0, fmt.Errorf("balance_credential cannot be decrypted: %w", err)
+	}
+	accessKey, secretKey, ok := strings.Cut(credential, "|")
+	if !ok || accessKey == "" || secretKey == "" {
+		return 0, errors.New("请在渠道设置中填写 balance_credential（AccessKey|SecretKey）")
+	}
+	req, err := http.NewRequest("GET", "https://open.volcengineapi.com/?Action=QueryBalanceAcct&Version=2022-01-01", nil)
+	if err != nil {
+		return 0, err
+	}
+	signVolcEngineRequest(req, strings.TrimSpace(accessKey), strings.TrimSpace(secretKey), "cn-beijing", "billing", time.Now())
+	body, err := GetResponseBody(req.Method, req.URL.String(), channel, req.Header)
+	if err != nil {
+		return 0, err
+	}
+	response := VolcEngineBalanceResponse{}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return 0, err
+	}
+	if response.ResponseMetadata.Error != nil {
+		return 0, fmt.Errorf("code: %s, message: %s", response.ResponseMetadata.Error.Code, response.ResponseMetadata.Error.Message)
+	}
+	balanceCny, err := strconv.ParseFloat(response.Result.AvailableBalance, 64)
+	if err != nil {
+		return 0, err
+	}
+	balance := cnyToUsd(balanceCny)
+	channel.UpdateBalance(balance)
+	return balance, nil
+}
+
+// signVolcEngineRequest 火山引擎 OpenAPI 签名（HMAC-SHA256），仅用于无请求体的 GET 请求
+func signVolcEngineRequest(req *http.Request, accessKey string, secretKey string, region string, serviceName string, now time.Time) {
+	payloadHash := sha256.Sum256(nil)
+	hexPayloadHash := hex.EncodeToString(payloadHash[:])
+	xDate := now.UTC().Format("20060102T150405Z")
+	shortDate := now.UTC().Format("20060102")
+
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	queryParts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			queryParts = append(queryParts, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	signedHeaders := "host;x-content-sha256;x-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		strings.Join(queryParts, "&"),
+		"host:" + req.URL.Host + "\nx-content-sha256:" + hexPayloadHash + "\nx-date:" + xDate + "\n",
+		signedHeaders,
+		hexPayloadHash,
+	}, "\n")
+	hashedCanonicalRequest := sha256.Sum256([]byte(canonicalRequest))
+	credentialScope := fmt.Sprintf("%s/%s/%s/request", shortDate, region, serviceName)
+	stringToSign := fmt.Sprintf("HMAC-SHA256\n%s\n%s\n%s", xDate, credentialScope, hex.EncodeToString(hashedCanonicalRequest[:]))
+
+	signingKey := []byte(secretKey)
+	for _, part := range []string{shortDate, region, serviceName, "request"} {
+		signingKey = volcHmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(volcHmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Date", xDate)
+	req.Header.Set("X-Content-Sha256", hexPayloadHash)
+	req.Header.Set("Authorization", fmt.Sprintf("HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, credentialScope, signedHeaders, signature))
+}
+
+func volcHmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
@@ -14,7 +16,6 @@ import (
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
-	"github.com/QuantumNous/new-api/types"
 
 	"github.com/shopspring/decimal"
 
@@ -356,43 +357,19 @@ func updateChannelMoonshotBalance(channel *model.Channel) (float64, error) {
 	return availableBalanceUsd, nil
 }
 
-func updateChannelBalance(channel *model.Channel) (float64, error) {
-	baseURL := constant.ChannelBaseURLs[channel.Type]
-	if channel.GetBaseURL() == "" {
-		channel.BaseURL = &baseURL
-	}
-	switch channel.Type {
-	case constant.ChannelTypeOpenAI:
-		if channel.GetBaseURL() != "" {
-			baseURL = channel.GetBaseURL()
-		}
-	case constant.ChannelTypeAzure:
-		return 0, errors.New("尚未实现")
-	case constant.ChannelTypeCustom:
-		baseURL = channel.GetBaseURL()
-	//case common.ChannelTypeOpenAISB:
-	//	return updateChannelOpenAISBBalance(channel)
-	case constant.ChannelTypeAIProxy:
-		return updateChannelAIProxyBalance(channel)
-	case constant.ChannelTypeAPI2GPT:
-		return updateChannelAPI2GPTBalance(channel)
-	case constant.ChannelTypeAIGC2D:
-		return updateChannelAIGC2DBalance(channel)
-	case constant.ChannelTypeSiliconFlow:
-		return updateChannelSiliconFlowBalance(channel)
-	case constant.ChannelTypeDeepSeek:
-		return updateChannelDeepSeekBalance(channel)
-	case constant.ChannelTypeOpenRouter:
-		return updateChannelOpenRouterBalance(channel)
-	case constant.ChannelTypeMoonshot:
-		return updateChannelMoonshotBalance(channel)
-	default:
-		return 0, errors.New("尚未实现")
+func updateChannelOpenAIBalance(channel *model.Channel) (float64, error) {
+	baseURL := channel.GetBaseURL()
+	if baseURL == "" {
+		baseURL = constant.ChannelBaseURLs[channel.Type]
 	}
 	url := fmt.Sprintf("%s/v1/dashboard/billing/subscription", baseURL)
 
 	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(channel.Key))
 	if err != nil {
+		// 部分兼容接口只提供 credit_grants
+		if balance, grantsErr := updateChannelCloseAIBalance(channel); grantsErr == nil {
+			return balance, nil
+		}
 		return 0, err
 	}
 	subscription := OpenAISubscriptionResponse{}
@@ -421,6 +398,18 @@ func updateChannelBalance(channel *model.Channel) (float64, error) {
 	return balance, nil
 }
 
+func updateChannelBalance(channel *model.Channel) (float64, error) {
+	baseURL := constant.ChannelBaseURLs[channel.Type]
+	if channel.GetBaseURL() == "" {
+		channel.BaseURL = &baseURL
+	}
+	fetcher := getChannelBalanceFetcher(channel)
+	if fetcher == nil {
+		return 0, errors.New("尚未实现")
+	}
+	return fetcher.FetchBalance(channel)
+}
+
 func UpdateChannelBalance(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -456,6 +445,7 @@ func updateAllChannelsBalance() error {
 	if err != nil {
 		return err
 	}
+	changed := false
 	for _, channel := range channels {
 		if channel.Status != common.ChannelStatusEnabled {
 			continue
@@ -463,21 +453,21 @@ func updateAllChannelsBalance() error {
 		if channel.ChannelInfo.IsMultiKey {
 			continue // skip multi-key channels
 		}
-		// TODO: support Azure
-		//if channel.Type != common.ChannelTypeOpenAI && channel.Type != common.ChannelTypeCustom {
-		//	continue
-		//}
+		if getChannelBalanceFetcher(channel) == nil {
+			continue
+		}
 		balance, err := updateChannelBalance(channel)
 		if err != nil {
-			continue
-		} else {
-			// err is nil & balance <= 0 means quota is used up
-			if balance <= 0 {
-				service.DisableChannel(*types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, "", channel.GetAutoBan()), "余额不足")
-			}
+			common.SysLog(fmt.Sprintf("failed to update balance of channel #%d: %s", channel.Id, err.Error()))
+		} else if service.CheckChannelLowBalance(channel, balance) {
+			changed = true
 		}
 		time.Sleep(common.RequestInterval)
 	}
+	// 优先级变化需要重建渠道缓存中的排序
+	if changed {
+		model.InitChannelCache()
+	}
 	return nil
 }
 
@@ -495,11 +485,30 @@ func UpdateAllChannelsBalance(c *gin.Context) {
 	return
 }
 
-func AutomaticallyUpdateChannels(frequency int) {
-	for {
-		time.Sleep(time.Duration(frequency) * time.Minute)
-		common.SysLog("updating all channels")
-		_ = updateAllChannelsBalance()
-		common.SysLog("channels update done")
+var autoUpdateChannelsOnce sync.Once
+
+// AutomaticallyUpdateChannels 按监控设置定时查询渠道余额，仅在主节点运行
+func AutomaticallyUpdateChannels() {
+	if !common.IsMasterNode {
+		return
 	}
+	autoUpdateChannelsOnce.Do(func() {
+		for {
+			if !operation_setting.GetMonitorSetting().AutoUpdateBalanceEnabled {
+				time.Sleep(1 * time.Minute)
+				continue
+			}
+			frequency := operation_setting.GetMonitorSetting().AutoUpdateBalanceMinutes
+			if frequency < 1 {
+				frequency = 1
+			}
+			time.Sleep(time.Duration(int(math.Round(frequency))) * time.Minute)
+			if !operation_setting.GetMonitorSetting().AutoUpdateBalanceEnabled {
+				continue
+			}
+			common.SysLog("updating all channels")
+			_ = updateAllChannelsBalance()
+			common.SysLog("channels update done")
+		}
+	})
 }
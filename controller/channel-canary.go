@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// canaryFailureRounds 渠道连续未通过金丝雀校验的轮数，channelId -> int
+var canaryFailureRounds sync.Map
+
+// runChannelCanaries 对渠道的每个模型运行匹配的金丝雀用例并记录结果
+func runChannelCanaries(channel *model.Channel) []*model.ChannelCanaryResult {
+	setting := operation_setting.GetCanarySetting()
+	var results []*model.ChannelCanaryResult
+	for _, modelName := range channel.GetModels() {
+		modelName = strings.TrimSpace(modelName)
+		for _, canary := range operation_setting.MatchCanaryCases(modelName) {
+			tik := time.Now()
+			result := runChannelTest(channel, modelName, string(constant.EndpointTypeOpenAI), canary.Stream, channelTestOptions{canary: &canary})
+			record := &model.ChannelCanaryResult{
+				CreatedAt: common.GetTimestamp(),
+				ChannelId: channel.Id,
+				ModelName: modelName,
+				CaseName:  canary.Name,
+				IsStream:  canary.Stream,
+				Latency:   time.Since(tik).Milliseconds(),
+			}
+			if result.localErr != nil {
+				record.Error = result.localErr.Error()
+			} else {
+				record.Reachable = true
+				var expectedModels []string
+				if setting.CheckModelIdentity {
+					expectedModels = []string{result.upstreamModel, result.originModel}
+				}
+				if err := service.EvaluateCanaryResponse(canary, result.respBody, expectedModels); err != nil {
+					record.Error = err.Error()
+				} else {
+					record.Passed = true
+				}
+			}
+			results = append(results, record)
+			time.Sleep(common.RequestInterval)
+		}
+	}
+	if err := model.RecordChannelCanaryResults(results); err != nil {
+		common.SysError(fmt.Sprintf("failed to record canary results of channel #%d: %s", channel.Id, err.Error()))
+	}
+	return results
+}
+
+// checkChannelCanaries 运行金丝雀用例，返回输出校验失败（请求成功但结果不正确）的原因，全部通过或无适用用例时返回空字符串。
+// 连通性失败不计入，由常规测试处理
+func checkChannelCanaries(channel *model.Channel) string {
+	var failures []string
+	for _, result := range runChannelCanaries(channel) {
+		if result.Reachable && !result.Passed {
+			failures = append(failures, fmt.Sprintf("%s/%s: %s", result.ModelName, result.CaseName, result.Error))
+		}
+	}
+	if len(failures) == 0 {
+		canaryFailureRounds.Delete(channel.Id)
+		return ""
+	}
+	rounds := 1
+	if value, ok := canaryFailureRounds.Load(channel.Id); ok {
+		rounds = value.(int) + 1
+	}
+	canaryFailureRounds.Store(channel.Id, rounds)
+	return strings.Join(failures, "; ")
+}
+
+// shouldDisableForCanary 连续失败轮数达到阈值时返回 true
+func shouldDisableForCanary(channelId int) bool {
+	threshold := operation_setting.GetCanarySetting().FailureThreshold
+	if threshold <= 0 {
+		return false
+	}
+	value, ok := canaryFailureRounds.Load(channelId)
+	return ok && value.(int) >= threshold
+}
+
+func cleanupChannelCanaryResults() {
+	days := operation_setting.GetCanarySetting().HistoryDays
+	if days <= 0 {
+		return
+	}
+	if _, err := model.DeleteChannelCanaryResultsBefore(time.Now().AddDate(0, 0, -days).Unix()); err != nil {
+		common.SysError("failed to clean up canary results: " + err.Error())
+	}
+}
+
+// RunChannelCanary 立即对指定渠道运行金丝雀用例，不影响渠道状态
+func RunChannelCanary(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	channel, err := model.GetChannelById(channelId, true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if len(channel.GetModels()) == 0 {
+		common.ApiErrorMsg(c, "渠道未配置模型")
+		return
+	}
+	results := runChannelCanaries(channel)
+	if len(results) == 0 {
+		common.ApiErrorMsg(c, "没有适用于该渠道的金丝雀用例，请检查金丝雀设置是否启用")
+		return
+	}
+	common.ApiSuccess(c, results)
+}
+
+// GetChannelCanaryPassRates 按渠道与模型返回金丝雀通过率
+func GetChannelCanaryPassRates(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	rates, err := model.GetChannelCanaryPassRates(channelId, startTimestamp, endTimestamp)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, rates)
+}
+
+func GetChannelCanaryResults(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	results, total, err := model.GetChannelCanaryResults(channelId, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(results)
+	common.ApiSuccess(c, pageInfo)
+}
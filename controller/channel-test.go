@@ -40,6 +40,10 @@ type testResult struct {
 	context     *gin.Context
 	localErr    error
 	newAPIError *types.NewAPIError
+	// 以下字段仅在请求成功时填充，用于金丝雀校验
+	respBody      []byte
+	originModel   string
+	upstreamModel string
 }
 
 func normalizeChannelTestEndpoint(channel *model.Channel, modelName, endpointType string) string {
@@ -56,7 +60,21 @@ func normalizeChannelTestEndpoint(channel *model.Channel, modelName, endpointTyp
 	return normalized
 }
 
+// channelTestOptions 测试的可选参数
+type channelTestOptions struct {
+	// canary 不为空时按金丝雀用例构造 Chat Completions 请求并读取完整响应
+	canary *operation_setting.CanaryCase
+	// keyIndex 不为空时固定使用多 Key 渠道中的该 key，而不是由 GetNextEnabledKey 选择
+	keyIndex *int
+}
+
 func testChannel(channel *model.Channel, testModel string, endpointType string, isStream bool) testResult {
+	return runChannelTest(channel, testModel, endpointType, isStream, channelTestOptions{})
+}
+
+// runChannelTest 发送测试请求
+func runChannelTest(channel *model.Channel, testModel string, endpointType string, isStream bool, opts channelTestOptions) testResult {
+	canary := opts.canary
 	tik := time.Now()
 	var unsupportedTestChannelTypes = []int{
 		constant.ChannelTypeMidjourney,
@@ -158,7 +176,22 @@ func testChannel(channel *model.Channel, testModel string, endpointType string,
 	group, _ := model.GetUserGroup(1, false)
 	c.Set("group", group)
 
-	newAPIError := middleware.SetupContextForSelectedChannel(c, channel, testModel)
+	selectedChannel := channel
+	if opts.keyIndex != nil {
+		keys := channel.GetKeys()
+		if *opts.keyIndex < 0 || *opts.keyIndex >= len(keys) {
+			return testResult{
+				localErr: fmt.Errorf("key index %d out of range", *opts.keyIndex),
+			}
+		}
+		// 使用只包含该 key 的副本，避免影响轮询索引，且已禁用的 key 也可以测试
+		keyChannel := *channel
+		keyChannel.Key = keys[*opts.keyIndex]
+		keyChannel.Keys = nil
+		keyChannel.ChannelInfo.IsMultiKey = false
+		selectedChannel = &keyChannel
+	}
+	newAPIError := middleware.SetupContextForSelectedChannel(c, selectedChannel, testModel)
 	if newAPIError != nil {
 		return testResult{
 			context:     c,
@@ -166,6 +199,10 @@ func testChannel(channel *model.Channel, testModel string, endpointType string,
 			newAPIError: newAPIError,
 		}
 	}
+	if opts.keyIndex != nil {
+		common.SetContextKey(c, constant.ContextKeyChannelIsMultiKey, true)
+		common.SetContextKey(c, constant.ContextKeyChannelMultiKeyIndex, *opts.keyIndex)
+	}
 
 	// Determine relay format based on endpoint type or request path
 	var relayFormat types.RelayFormat
@@ -217,7 +254,20 @@ func testChannel(channel *model.Channel, testModel string, endpointType string,
 		}
 	}
 
-	request := buildTestRequest(testModel, endpointType, channel, isStream)
+	var request dto.Request
+	if canary != nil {
+		canaryRequest, err := service.BuildCanaryRequest(testModel, *canary)
+		if err != nil {
+			return testResult{
+				context:     c,
+				localErr:    err,
+				newAPIError: types.NewError(err, types.ErrorCodeInvalidRequest),
+			}
+		}
+		request = canaryRequest
+	} else {
+		request = buildTestRequest(testModel, endpointType, channel, isStream)
+	}
 
 	info, err := relaycommon.GenRelayInfo(c, relayFormat, request, nil)
 
@@ -451,7 +501,7 @@ func testChannel(channel *model.Channel, testModel string, endpointType string,
 		}
 	}
 	result := w.Result()
-	respBody, err := readTestResponseBody(result.Body, isStream)
+	respBody, err := readTestResponseBody(result.Body, isStream && canary == nil)
 	if err != nil {
 		return testResult{
 			context:     c,
@@ -498,9 +548,12 @@ func testChannel(channel *model.Channel, testModel string, endpointType string,
 	})
 	common.SysLog(fmt.Sprintf("testing channel #%d, response: \n%s", channel.Id, string(respBody)))
 	return testResult{
-		context:     c,
-		localErr:    nil,
-		newAPIError: nil,
+		context:       c,
+		localErr:      nil,
+		newAPIError:   nil,
+		respBody:      respBody,
+		originModel:   info.OriginModelName,
+		upstreamModel: testModel,
 	}
 }
 
@@ -782,6 +835,101 @@ func TestChannel(c *gin.Context) {
 	})
 }
 
+// channelKeyTestConcurrency 测试多 Key 渠道时同时测试的 key 数量
+const channelKeyTestConcurrency = 5
+
+type ChannelKeyTestResult struct {
+	Index      int    `json:"index"`
+	KeyPreview string `json:"key_preview"`
+	Success    bool   `json:"success"`
+	Latency    int64  `json:"latency"` // 毫秒
+	Message    string `json:"message,omitempty"`
+	// Status 测试后 key 的状态，Action 为本次测试对 key 状态的调整：disabled / enabled
+	Status int    `json:"status"`
+	Action string `json:"action,omitempty"`
+}
+
+// TestChannelKeys 并发测试多 Key 渠道的每个 key，并按结果自动禁用失败的 key、重新启用恢复的 key（手动禁用的 key 不会被启用）
+func TestChannelKeys(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	channel, err := model.GetChannelById(channelId, true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if !channel.ChannelInfo.IsMultiKey {
+		common.ApiErrorMsg(c, "该渠道不是多密钥模式")
+		return
+	}
+	testModel := c.Query("model")
+	endpointType := c.Query("endpoint_type")
+	isStream, _ := strconv.ParseBool(c.Query("stream"))
+
+	keys := channel.GetKeys()
+	results := make([]ChannelKeyTestResult, len(keys))
+	apiErrors := make([]*types.NewAPIError, len(keys))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, channelKeyTestConcurrency)
+	for i := range keys {
+		wg.Add(1)
+		sem <- struct{}{}
+		gopool.Go(func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			keyIndex := i
+			tik := time.Now()
+			result := runChannelTest(channel, testModel, endpointType, isStream, channelTestOptions{keyIndex: &keyIndex})
+			keyResult := ChannelKeyTestResult{
+				Index:      i,
+				KeyPreview: keyPreview(keys[i]),
+				Latency:    time.Since(tik).Milliseconds(),
+			}
+			if result.newAPIError != nil {
+				keyResult.Message = result.newAPIError.Error()
+				apiErrors[i] = result.newAPIError
+			} else if result.localErr != nil {
+				keyResult.Message = result.localErr.Error()
+			} else {
+				keyResult.Success = true
+			}
+			results[i] = keyResult
+		})
+	}
+	wg.Wait()
+
+	// 状态更新需要读改写 channel_info，逐个执行避免相互覆盖
+	for i, key := range keys {
+		status := common.ChannelStatusEnabled
+		if s, ok := channel.ChannelInfo.MultiKeyStatusList[i]; ok {
+			status = s
+		}
+		result := &results[i]
+		if err := model.RecordChannelKeyTest(channel.Id, key, result.Latency, result.Message); err != nil {
+			common.SysError(fmt.Sprintf("failed to record key test of channel #%d: %s", channel.Id, err.Error()))
+		}
+		channelError := *types.NewChannelError(channel.Id, channel.Type, channel.Name, true, key, channel.GetAutoBan())
+		switch {
+		case status == common.ChannelStatusEnabled && apiErrors[i] != nil && channel.GetAutoBan() && service.ShouldDisableChannel(channel.Type, apiErrors[i]):
+			service.DisableChannel(channelError, fmt.Sprintf("密钥测试失败：%s", apiErrors[i].ErrorWithStatusCode()))
+			status = common.ChannelStatusAutoDisabled
+			result.Action = "disabled"
+		case result.Success && service.ShouldEnableChannel(nil, status):
+			service.EnableChannel(channel.Id, key, channel.Name)
+			status = common.ChannelStatusEnabled
+			result.Action = "enabled"
+		}
+		result.Status = status
+	}
+	model.InitChannelCache()
+	common.ApiSuccess(c, results)
+}
+
 var testAllChannelsLock sync.Mutex
 var testAllChannelsRunning bool = false
 
@@ -841,8 +989,17 @@ func testAllChannels(notify bool) error {
 				processChannelError(result.context, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(result.context, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
 			}
 
+			// 连通后再校验输出正确性，未通过的渠道不会被重新启用
+			canaryFailure := ""
+			if newAPIError == nil && result.localErr == nil && operation_setting.GetCanarySetting().Enabled {
+				canaryFailure = checkChannelCanaries(channel)
+				if canaryFailure != "" && isChannelEnabled && channel.GetAutoBan() && shouldDisableForCanary(channel.Id) {
+					service.DisableChannel(*types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, "", channel.GetAutoBan()), "金丝雀校验失败："+canaryFailure)
+				}
+			}
+
 			// enable channel
-			if !isChannelEnabled && service.ShouldEnableChannel(newAPIError, channel.Status) {
+			if !isChannelEnabled && canaryFailure == "" && service.ShouldEnableChannel(newAPIError, channel.Status) {
 				service.EnableChannel(channel.Id, common.GetContextKeyString(result.context, constant.ContextKeyChannelKey), channel.Name)
 			}
 
@@ -850,6 +1007,10 @@ func testAllChannels(notify bool) error {
 			time.Sleep(common.RequestInterval)
 		}
 
+		if operation_setting.GetCanarySetting().Enabled {
+			cleanupChannelCanaryResults()
+		}
+
 		if notify {
 			service.NotifyRootUser(dto.NotifyTypeChannelTest, "通道测试完成", "所有通道测试已完成")
 		}
@@ -62,6 +62,7 @@ func parseStatusFilter(statusParam string) int {
 }
 
 func clearChannelInfo(channel *model.Channel) {
+	channel.MaskBalanceCredential()
 	if channel.ChannelInfo.IsMultiKey {
 		channel.ChannelInfo.MultiKeyDisabledReason = nil
 		channel.ChannelInfo.MultiKeyDisabledTime = nil
@@ -439,6 +440,24 @@ func validateChannel(channel *model.Channel, isAdd bool) error {
 		return fmt.Errorf("渠道额外设置[channel setting] 格式错误：%s", err.Error())
 	}
 
+	// 校验生效时间与成本价
+	if channel.OtherSettings != "" {
+		var otherSettings dto.ChannelOtherSettings
+		if err := common.UnmarshalJsonStr(channel.OtherSettings, &otherSettings); err == nil {
+			if otherSettings.ActiveSchedule != "" {
+				if _, err := common.ParseActiveSchedule(otherSettings.ActiveSchedule, otherSettings.ActiveTimezone); err != nil {
+					return fmt.Errorf("渠道生效时间[active_schedule] 格式错误：%s", err.Error())
+				}
+			}
+			for modelName, price := range otherSettings.CostPrices {
+				if price.Input < 0 || price.Output < 0 || price.CacheRead < 0 || price.CacheWrite < 0 ||
+					price.PerRequest < 0 || price.PerImage < 0 || price.PerSecond < 0 {
+					return fmt.Errorf("渠道成本价[cost_prices] 模型 %s 的价格不能为负数", modelName)
+				}
+			}
+		}
+	}
+
 	// 如果是添加操作，检查 channel 和 key 是否为空
 	if isAdd {
 		if channel == nil || channel.Key == "" {
@@ -867,6 +886,7 @@ func UpdateChannel(c *gin.Context) {
 
 	// Always copy the original ChannelInfo so that fields like IsMultiKey and MultiKeySize are retained.
 	channel.ChannelInfo = originChannel.ChannelInfo
+	channel.KeepBalanceCredential(originChannel)
 
 	// If the request explicitly specifies a new MultiKeyMode, apply it on top of the original info.
 	if channel.MultiKeyMode != nil && *channel.MultiKeyMode != "" {
@@ -1236,6 +1256,16 @@ type KeyStatus struct {
 	DisabledTime int64  `json:"disabled_time,omitempty"`
 	Reason       string `json:"reason,omitempty"`
 	KeyPreview   string `json:"key_preview"` // first 10 chars of key for identification
+	// Stat 用量与最近错误，尚无记录时为空
+	Stat *model.ChannelKeyStat `json:"stat,omitempty"`
+}
+
+// keyPreview 返回 key 的前 10 个字符用于识别
+func keyPreview(key string) string {
+	if len(key) > 10 {
+		return key[:10] + "..."
+	}
+	return key
 }
 
 // ManageMultiKeys handles multi-key management operations
@@ -1285,6 +1315,13 @@ func ManageMultiKeys(c *gin.Context) {
 		// Statistics for all keys (unchanged by filtering)
 		var enabledCount, manualDisabledCount, autoDisabledCount int
 
+		// 单 key 的用量、最近错误与测试记录
+		keyStats, err := model.GetChannelKeyStats(channel.Id)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+
 		// Build all key status data first
 		var allKeyStatusList []KeyStatus
 		for i, key := range keys {
@@ -1317,18 +1354,13 @@ func ManageMultiKeys(c *gin.Context) {
 				}
 			}
 
-			// Create key preview (first 10 chars)
-			keyPreview := key
-			if len(key) > 10 {
-				keyPreview = key[:10] + "..."
-			}
-
 			allKeyStatusList = append(allKeyStatusList, KeyStatus{
 				Index:        i,
 				Status:       status,
 				DisabledTime: disabledTime,
 				Reason:       reason,
-				KeyPreview:   keyPreview,
+				KeyPreview:   keyPreview(key),
+				Stat:         keyStats[common.SecretLookupHash(key)],
 			})
 		}
 
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetChannelKeyEncryptionStatus 查看渠道 key 的加密状态
+func GetChannelKeyEncryptionStatus(c *gin.Context) {
+	status, err := model.GetChannelKeyEncryptionStatus()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    status,
+	})
+}
+
+// RotateChannelKeyEncryption 按当前主密钥重新加密所有渠道 key。
+// 轮换主密钥时，将新密钥配置到 CHANNEL_KEY_ENCRYPTION_KEY、旧密钥移入 CHANNEL_KEY_ENCRYPTION_OLD_KEYS 并重启后调用
+func RotateChannelKeyEncryption(c *gin.Context) {
+	updated, failed, err := model.ReencryptChannelKeys()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.InitChannelCache()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"updated": updated,
+			"failed":  failed,
+		},
+	})
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportConfigBundle 导出声明式配置包，format 支持 yaml（默认）与 json
+func ExportConfigBundle(c *gin.Context) {
+	format := c.DefaultQuery("format", "yaml")
+	if format != "yaml" && format != "json" {
+		common.ApiErrorMsg(c, "format 仅支持 yaml 或 json")
+		return
+	}
+	bundle, err := service.ExportConfigBundle()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	data, err := service.MarshalConfigBundle(bundle, format)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	contentType := "application/x-yaml"
+	if format == "json" {
+		contentType = "application/json"
+	}
+	filename := fmt.Sprintf("new-api-config-%s.%s", time.Now().Format("20060102150405"), format)
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Data(http.StatusOK, contentType, data)
+}
+
+func readConfigBundle(c *gin.Context) (*service.ConfigBundle, error) {
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		return nil, err
+	}
+	data, err := storage.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return service.ParseConfigBundle(data)
+}
+
+// DiffConfigBundle 比较请求体中的配置包与当前实例，返回变更计划，不做任何修改
+func DiffConfigBundle(c *gin.Context) {
+	applyConfigBundle(c, true)
+}
+
+// ApplyConfigBundle 应用请求体中的配置包，dry_run=true 时等同于 diff
+func ApplyConfigBundle(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+	applyConfigBundle(c, dryRun)
+}
+
+func applyConfigBundle(c *gin.Context, dryRun bool) {
+	bundle, err := readConfigBundle(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	plan, err := service.ApplyConfigBundle(bundle, dryRun)
+	if err != nil {
+		if plan != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+				"data":    plan,
+			})
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    plan,
+	})
+}
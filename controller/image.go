@@ -1,9 +1,35 @@
 package controller
 
 import (
+	"net/http"
+
+	"github.com/QuantumNous/new-api/service"
+
 	"github.com/gin-gonic/gin"
 )
 
+// GetImage 返回本地存储的生成图片，链接需带有效签名
 func GetImage(c *gin.Context) {
-
+	name := c.Param("name")
+	if !service.VerifyGeneratedImageSignature(name, c.Query("expires"), c.Query("signature")) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": gin.H{
+				"message": "invalid or expired image link",
+				"type":    "invalid_request_error",
+			},
+		})
+		return
+	}
+	path, err := service.GetGeneratedImagePath(name)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"message": "image not found or expired",
+				"type":    "invalid_request_error",
+			},
+		})
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.File(path)
 }
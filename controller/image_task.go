@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const imageTaskObject = "image.generation.task"
+
+// minImageTaskSyncWaitSeconds 同步等待的最短时间，避免配置为 0 时提交后立即超时
+const minImageTaskSyncWaitSeconds = 10
+
+// ShouldRelayImageTask 生图请求是否走任务流水线：显式 async=true，
+// 或选中渠道的上游仅提供异步接口（同步请求提交任务后等待结果）
+func ShouldRelayImageTask(c *gin.Context) bool {
+	if c.Query("async") == "true" {
+		return true
+	}
+	adaptor := relay.GetImageTaskAdaptor(relay.GetTaskPlatform(c))
+	return adaptor != nil && adaptor.WaitImageTaskOnSync()
+}
+
+// RelayImageTask 提交生图任务；async=true 时立即返回任务，否则等待任务完成后按 OpenAI 生图格式返回
+func RelayImageTask(c *gin.Context) {
+	relayInfo, err := relaycommon.GenRelayInfo(c, types.RelayFormatTask, nil, nil)
+	if err != nil {
+		respondImageTaskError(c, service.TaskErrorWrapperLocal(err, "gen_relay_info_failed", http.StatusInternalServerError))
+		return
+	}
+
+	// 生图任务不排队，超出并发上限直接拒绝
+	reserved, err := reserveTaskSlot(c, relayInfo)
+	if err != nil {
+		if errors.Is(err, service.ErrTaskConcurrencyLimit) {
+			respondImageTaskError(c, service.TaskErrorWrapperLocal(err, taskConcurrencyExceededCode, http.StatusTooManyRequests))
+		} else {
+			respondImageTaskError(c, service.TaskErrorWrapperLocal(err, reserveTaskSlotFailedCode, http.StatusInternalServerError))
+		}
+		return
+	}
+
+	task, taskErr := submitTaskWithRetry(c, relayInfo, reserved)
+	if taskErr != nil {
+		respondImageTaskError(c, taskErr)
+		return
+	}
+
+	if c.Query("async") == "true" {
+		c.JSON(http.StatusOK, buildImageTaskResponse(task))
+		return
+	}
+	waitImageTask(c, task)
+}
+
+// waitImageTask 同步等待任务结果，超时后任务仍在后台由轮询完成，可通过任务 ID 查询
+func waitImageTask(c *gin.Context, task *model.Task) {
+	settings := system_setting.GetImageTaskSettings()
+	timeout := time.Duration(max(settings.SyncWaitTimeoutSeconds, minImageTaskSyncWaitSeconds)) * time.Second
+	interval := time.Duration(max(settings.SyncPollIntervalSeconds, 1)) * time.Second
+
+	task, err := service.WaitTaskResult(c.Request.Context(), task, timeout, interval)
+	if errors.Is(err, service.ErrTaskWaitTimeout) {
+		respondImageError(c, types.NewErrorWithStatusCode(
+			fmt.Errorf("image generation task %s is still in progress, query it via GET /v1/images/generations/%s", task.TaskID, task.TaskID),
+			types.ErrorCode("image_task_timeout"), http.StatusGatewayTimeout, types.ErrOptionWithSkipRetry()))
+		return
+	}
+	if err != nil {
+		// 客户端已断开，任务继续由轮询完成
+		logger.LogWarn(c, fmt.Sprintf("stop waiting image task %s: %s", task.TaskID, err.Error()))
+		return
+	}
+	if task.Status == model.TaskStatusFailure {
+		respondImageError(c, types.NewErrorWithStatusCode(
+			fmt.Errorf("image generation task %s failed: %s", task.TaskID, task.FailReason),
+			types.ErrorCode("image_task_failed"), http.StatusBadGateway, types.ErrOptionWithSkipRetry()))
+		return
+	}
+
+	imageResp, err := convertImageTaskResult(task)
+	if err != nil {
+		respondImageError(c, types.NewErrorWithStatusCode(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError, types.ErrOptionWithSkipRetry()))
+		return
+	}
+	if req, reqErr := relaycommon.GetImageTaskRequest(c); reqErr == nil && req.ResponseFormat == "b64_json" {
+		if err = fillImageB64Json(imageResp); err != nil {
+			respondImageError(c, types.NewErrorWithStatusCode(err, types.ErrorCodeBadResponse, http.StatusInternalServerError, types.ErrOptionWithSkipRetry()))
+			return
+		}
+	}
+	c.JSON(http.StatusOK, imageResp)
+}
+
+// RelayImageTaskFetch 查询生图任务，完成后附带生成的图片
+func RelayImageTaskFetch(c *gin.Context) {
+	task, exist, err := model.GetByTaskId(c.GetInt("id"), c.Param("task_id"))
+	if err != nil {
+		respondImageTaskError(c, service.TaskErrorWrapper(err, "get_task_failed", http.StatusInternalServerError))
+		return
+	}
+	if !exist || task.Action != constant.TaskActionImageGenerate {
+		respondImageTaskError(c, service.TaskErrorWrapperLocal(errors.New("task_not_exist"), "task_not_exist", http.StatusNotFound))
+		return
+	}
+	c.JSON(http.StatusOK, buildImageTaskResponse(task))
+}
+
+func buildImageTaskResponse(task *model.Task) *dto.ImageTaskResponse {
+	resp := &dto.ImageTaskResponse{
+		ID:        task.TaskID,
+		Object:    imageTaskObject,
+		Model:     task.Properties.OriginModelName,
+		Status:    task.Status.ToVideoStatus(),
+		Progress:  task.Progress,
+		CreatedAt: task.CreatedAt,
+	}
+	switch task.Status {
+	case model.TaskStatusSuccess:
+		resp.CompletedAt = task.FinishTime
+		imageResp, err := convertImageTaskResult(task)
+		if err != nil {
+			common.SysError(fmt.Sprintf("convert image task %s result error: %s", task.TaskID, err.Error()))
+			break
+		}
+		resp.Data = imageResp.Data
+	case model.TaskStatusFailure:
+		resp.CompletedAt = task.FinishTime
+		resp.Error = &dto.ImageTaskError{
+			Code:    "image_task_failed",
+			Message: task.FailReason,
+		}
+	}
+	return resp
+}
+
+// convertImageTaskResult 由任务结果构造 OpenAI 生图响应，已转存的图片替换为网关签名链接
+func convertImageTaskResult(task *model.Task) (*dto.ImageResponse, error) {
+	adaptor := relay.GetImageTaskAdaptor(task.Platform)
+	if adaptor == nil {
+		return nil, fmt.Errorf("platform %s does not support image generation tasks", task.Platform)
+	}
+	archived := *task
+	archived.Data = service.RewriteTaskMediaURLs(task, task.Data)
+	imageResp, err := adaptor.ConvertToImageResponse(&archived)
+	if err != nil {
+		return nil, err
+	}
+	if len(imageResp.Data) == 0 {
+		return nil, errors.New("no image in task result")
+	}
+	return imageResp, nil
+}
+
+func fillImageB64Json(imageResp *dto.ImageResponse) error {
+	for i := range imageResp.Data {
+		_, data, err := service.GetImageFromUrl(imageResp.Data[i].Url)
+		if err != nil {
+			return fmt.Errorf("failed to download image: %w", err)
+		}
+		imageResp.Data[i].B64Json = data
+		imageResp.Data[i].Url = ""
+	}
+	return nil
+}
+
+// respondImageTaskError 生图接口以 OpenAI 错误格式输出任务错误
+func respondImageTaskError(c *gin.Context, taskErr *dto.TaskError) {
+	respondImageError(c, types.NewErrorWithStatusCode(errors.New(taskErr.Message), types.ErrorCode(taskErr.Code), taskErr.StatusCode, types.ErrOptionWithSkipRetry()))
+}
+
+func respondImageError(c *gin.Context, apiErr *types.NewAPIError) {
+	c.JSON(apiErr.StatusCode, gin.H{
+		"error": apiErr.ToOpenAIError(),
+	})
+}
@@ -113,6 +113,7 @@ func GetLogsStat(c *gin.Context) {
 		"message": "",
 		"data": gin.H{
 			"quota": stat.Quota,
+			"cost":  stat.Cost,
 			"rpm":   stat.Rpm,
 			"tpm":   stat.Tpm,
 		},
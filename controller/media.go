@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMediaContent 通过签名链接下载已转存的媒体文件，签名即凭证，无需登录
+func GetMediaContent(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || !service.VerifyMediaSignature(id, c.Query("expires"), c.Query("signature")) {
+		videoProxyError(c, http.StatusForbidden, "invalid_request_error", "invalid or expired media link")
+		return
+	}
+	obj, err := model.GetMediaObjectById(id)
+	if err != nil || (obj.ExpiresAt > 0 && obj.ExpiresAt < common.GetTimestamp()) {
+		videoProxyError(c, http.StatusNotFound, "invalid_request_error", "media not found or expired")
+		return
+	}
+	if !serveMediaObject(c, obj) {
+		videoProxyError(c, http.StatusNotFound, "invalid_request_error", "media not found or expired")
+	}
+}
+
+// serveMediaObject 从存储读取文件写入响应，文件无法打开时返回 false 且不写入任何内容
+func serveMediaObject(c *gin.Context, obj *model.MediaObject) bool {
+	reader, err := service.OpenMedia(c.Request.Context(), obj)
+	if err != nil {
+		logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to open media #%d: %s", obj.Id, err.Error()))
+		return false
+	}
+	defer reader.Close()
+
+	contentType := obj.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Writer.Header().Set("Content-Type", contentType)
+	c.Writer.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
+	c.Writer.Header().Set("Cache-Control", "private, max-age=3600")
+	c.Writer.Header().Set("Last-Modified", time.Unix(obj.CreatedAt, 0).UTC().Format(http.TimeFormat))
+	c.Writer.WriteHeader(http.StatusOK)
+	if _, err = io.Copy(c.Writer, reader); err != nil {
+		logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to stream media #%d: %s", obj.Id, err.Error()))
+	}
+	return true
+}
@@ -200,7 +200,9 @@ func ListModels(c *gin.Context, modelType int) {
 				})
 			}
 		}
+		userOpenAiModels = appendModelAliases(userOpenAiModels)
 	}
+	userOpenAiModels = annotateModelAliases(userOpenAiModels)
 
 	switch modelType {
 	case constant.ChannelTypeAnthropic:
@@ -287,3 +289,53 @@ func RetrieveModel(c *gin.Context, modelType int) {
 		})
 	}
 }
+
+// appendModelAliases 追加后继模型可用、但自身未在列表中的别名，使旧模型名仍可被发现
+func appendModelAliases(models []dto.OpenAIModels) []dto.OpenAIModels {
+	aliases := model.GetModelAliases()
+	if len(aliases) == 0 {
+		return models
+	}
+	listed := make(map[string]bool, len(models))
+	for _, m := range models {
+		listed[m.Id] = true
+	}
+	for name, alias := range aliases {
+		if listed[name] || !listed[alias.Target] {
+			continue
+		}
+		models = append(models, dto.OpenAIModels{
+			Id:                     name,
+			Object:                 "model",
+			Created:                1626777600,
+			OwnedBy:                "custom",
+			SupportedEndpointTypes: model.GetModelSupportEndpointTypes(alias.Target),
+		})
+	}
+	return models
+}
+
+// annotateModelAliases 标注弃用模型的下线时间与后继模型，并隐藏已下线且不再重定向的模型
+func annotateModelAliases(models []dto.OpenAIModels) []dto.OpenAIModels {
+	aliases := model.GetModelAliases()
+	if len(aliases) == 0 {
+		return models
+	}
+	now := common.GetTimestamp()
+	result := models[:0]
+	for _, m := range models {
+		alias, ok := aliases[m.Id]
+		if !ok {
+			result = append(result, m)
+			continue
+		}
+		if _, ok := alias.Resolve(now); !ok {
+			continue
+		}
+		m.Deprecated = alias.SunsetTime > 0
+		m.SunsetTime = alias.SunsetTime
+		m.Successor = alias.Target
+		result = append(result, m)
+	}
+	return result
+}
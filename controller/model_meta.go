@@ -88,6 +88,10 @@ func CreateModelMeta(c *gin.Context) {
 		common.ApiErrorMsg(c, "模型名称不能为空")
 		return
 	}
+	if msg := validateModelAlias(&m); msg != "" {
+		common.ApiErrorMsg(c, msg)
+		return
+	}
 	// 名称冲突检查
 	if dup, err := model.IsModelNameDuplicated(0, m.ModelName); err != nil {
 		common.ApiError(c, err)
@@ -105,6 +109,34 @@ func CreateModelMeta(c *gin.Context) {
 	common.ApiSuccess(c, &m)
 }
 
+// validateModelAlias 校验别名/弃用配置，返回错误信息，合法时返回空字符串
+func validateModelAlias(m *model.Model) string {
+	m.AliasTarget = strings.TrimSpace(m.AliasTarget)
+	if m.AliasTarget == "" {
+		m.SunsetTime = 0
+		m.SunsetAction = ""
+		return ""
+	}
+	if m.NameRule != model.NameRuleExact {
+		return "仅精确匹配的模型可以设置别名"
+	}
+	if m.AliasTarget == m.ModelName {
+		return "别名目标不能是模型自身"
+	}
+	if target := model.GetModelAlias(m.AliasTarget); target != nil && target.Target == m.ModelName {
+		return "别名目标不能指向本模型的别名"
+	}
+	switch m.SunsetAction {
+	case "", model.ModelSunsetActionRedirect, model.ModelSunsetActionError:
+	default:
+		return "下线动作只能是 redirect 或 error"
+	}
+	if m.SunsetTime < 0 {
+		return "下线时间无效"
+	}
+	return ""
+}
+
 // UpdateModelMeta 更新模型
 func UpdateModelMeta(c *gin.Context) {
 	statusOnly := c.Query("status_only") == "true"
@@ -126,6 +158,10 @@ func UpdateModelMeta(c *gin.Context) {
 			return
 		}
 	} else {
+		if msg := validateModelAlias(&m); msg != "" {
+			common.ApiErrorMsg(c, msg)
+			return
+		}
 		// 名称冲突检查
 		if dup, err := model.IsModelNameDuplicated(m.Id, m.ModelName); err != nil {
 			common.ApiError(c, err)
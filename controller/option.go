@@ -206,6 +206,24 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "ImageSizeRatio":
+		err = ratio_setting.UpdateImageSizeRatioByJSONString(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "图片尺寸倍率设置失败: " + err.Error(),
+			})
+			return
+		}
+	case "ModelTimeRatio":
+		err = ratio_setting.UpdateModelTimeRatioByJSONString(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "时段价格倍率设置失败: " + err.Error(),
+			})
+			return
+		}
 	case "AudioRatio":
 		err = ratio_setting.UpdateAudioRatioByJSONString(option.Value.(string))
 		if err != nil {
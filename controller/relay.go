@@ -6,6 +6,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -34,7 +35,7 @@ import (
 func relayHandler(c *gin.Context, info *relaycommon.RelayInfo) *types.NewAPIError {
 	var err *types.NewAPIError
 	switch info.RelayMode {
-	case relayconstant.RelayModeImagesGenerations, relayconstant.RelayModeImagesEdits:
+	case relayconstant.RelayModeImagesGenerations, relayconstant.RelayModeImagesEdits, relayconstant.RelayModeImagesVariations:
 		err = relay.ImageHelper(c, info)
 	case relayconstant.RelayModeAudioSpeech:
 		fallthrough
@@ -177,6 +178,8 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 	}()
 
+	shadow := newShadowTraffic(c, relayInfo, relayFormat)
+
 	retryParam := &service.RetryParam{
 		Ctx:        c,
 		TokenGroup: relayInfo.TokenGroup,
@@ -221,6 +224,7 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 
 		if newAPIError == nil {
 			relayInfo.LastError = nil
+			shadow.Dispatch(c, relayInfo, channel.Id)
 			return
 		}
 
@@ -234,6 +238,10 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 	}
 
+	if newAPIError != nil {
+		service.RecordTrafficSplitFailure(c)
+	}
+
 	useChannel := c.GetStringSlice("use_channel")
 	if len(useChannel) > 1 {
 		retryLogStr := fmt.Sprintf("重试：%s", strings.Trim(strings.Join(strings.Fields(fmt.Sprint(useChannel)), "->"), "[]"))
@@ -334,6 +342,10 @@ func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) b
 	if _, ok := c.Get("specific_channel_id"); ok {
 		return false
 	}
+	if openaiErr.GetErrorCode() == types.ErrorCodeOutputValidationFailed {
+		// 输出校验失败，换下一个渠道重新生成
+		return true
+	}
 	code := openaiErr.StatusCode
 	if code >= 200 && code < 300 {
 		return false
@@ -351,6 +363,9 @@ func processChannelError(c *gin.Context, channelError types.ChannelError, err *t
 	logger.LogError(c, fmt.Sprintf("channel error (channel #%d, status code: %d): %s", channelError.ChannelId, err.StatusCode, err.Error()))
 	// 不要使用context获取渠道信息，异步处理时可能会出现渠道信息不一致的情况
 	// do not use context to get channel info, there may be inconsistent channel info when processing asynchronously
+	if channelError.IsMultiKey {
+		model.RecordChannelKeyError(channelError.ChannelId, channelError.UsingKey, err.ErrorWithStatusCode())
+	}
 	if service.ShouldDisableChannel(channelError.ChannelType, err) && channelError.AutoBan {
 		gopool.Go(func() {
 			service.DisableChannel(channelError, err.ErrorWithStatusCode())
@@ -383,6 +398,7 @@ func processChannelError(c *gin.Context, channelError types.ChannelError, err *t
 			adminInfo["multi_key_index"] = common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex)
 		}
 		service.AppendChannelAffinityAdminInfo(c, adminInfo)
+		service.AppendTrafficSplitAdminInfo(c, adminInfo)
 		other["admin_info"] = adminInfo
 		startTime := common.GetContextKeyTime(c, constant.ContextKeyRequestStartTime)
 		if startTime.IsZero() {
@@ -408,6 +424,15 @@ func RelayMidjourney(c *gin.Context) {
 
 	var mjErr *dto.MidjourneyResponse
 	switch relayInfo.RelayMode {
+	case relayconstant.RelayModeMidjourneyImagine, relayconstant.RelayModeMidjourneyDescribe,
+		relayconstant.RelayModeMidjourneyBlend, relayconstant.RelayModeMidjourneyChange,
+		relayconstant.RelayModeMidjourneySimpleChange, relayconstant.RelayModeMidjourneyAction,
+		relayconstant.RelayModeMidjourneyModal, relayconstant.RelayModeMidjourneyShorten,
+		relayconstant.RelayModeMidjourneyEdits, relayconstant.RelayModeMidjourneyVideo:
+		// 生成类任务走统一的 TaskAdaptor 流程，任务存入 tasks 表
+		c.Set("platform", string(constant.TaskPlatformMidjourney))
+		RelayTask(c)
+		return
 	case relayconstant.RelayModeMidjourneyNotify:
 		mjErr = relay.RelayMidjourneyNotify(c)
 	case relayconstant.RelayModeMidjourneyTaskFetch, relayconstant.RelayModeMidjourneyTaskFetchByCondition:
@@ -476,6 +501,20 @@ func RelayTaskFetch(c *gin.Context) {
 	}
 }
 
+// RelayTaskQuery 统一任务查询接口，返回通用 TaskDto 格式，不需要选择渠道
+func RelayTaskQuery(c *gin.Context) {
+	if taskErr := relay.RelayTaskFetch(c, relayconstant.RelayModeVideoFetchByID); taskErr != nil {
+		respondTaskError(c, taskErr)
+	}
+}
+
+// RelayTaskCancel 取消任务，上游确认取消后退还预扣额度
+func RelayTaskCancel(c *gin.Context) {
+	if taskErr := relay.RelayTaskCancel(c); taskErr != nil {
+		respondTaskError(c, taskErr)
+	}
+}
+
 func RelayTask(c *gin.Context) {
 	relayInfo, err := relaycommon.GenRelayInfo(c, types.RelayFormatTask, nil, nil)
 	if err != nil {
@@ -492,12 +531,40 @@ func RelayTask(c *gin.Context) {
 		return
 	}
 
+	reserved, err := reserveTaskSlot(c, relayInfo)
+	if err != nil {
+		if !errors.Is(err, service.ErrTaskConcurrencyLimit) {
+			respondTaskError(c, service.TaskErrorWrapperLocal(err, reserveTaskSlotFailedCode, http.StatusInternalServerError))
+			return
+		}
+		if taskErr := enqueueTask(c, relayInfo, err); taskErr != nil {
+			respondTaskError(c, taskErr)
+		}
+		return
+	}
+
+	if _, taskErr := submitTaskWithRetry(c, relayInfo, reserved); taskErr != nil {
+		respondTaskError(c, taskErr)
+	}
+}
+
+// submitTaskWithRetry 选择渠道并提交任务，失败时按重试策略切换渠道；成功后结算、记录日志并写入任务。
+// reserved 为并发检查时写入的占位任务，成功时原地更新，失败时删除
+func submitTaskWithRetry(c *gin.Context, relayInfo *relaycommon.RelayInfo, reserved *model.Task) (*model.Task, *dto.TaskError) {
 	var result *relay.TaskSubmitResult
 	var taskErr *dto.TaskError
 	defer func() {
-		if taskErr != nil && relayInfo.Billing != nil {
+		if taskErr == nil {
+			return
+		}
+		if relayInfo.Billing != nil {
 			relayInfo.Billing.Refund(c)
 		}
+		if reserved != nil {
+			if err := model.DeleteReservedTask(reserved.ID); err != nil {
+				common.SysError(fmt.Sprintf("release reserved task %s error: %v", reserved.TaskID, err))
+			}
+		}
 	}()
 
 	retryParam := &service.RetryParam{
@@ -563,47 +630,87 @@ func RelayTask(c *gin.Context) {
 		logger.LogInfo(c, retryLogStr)
 	}
 
+	if taskErr != nil {
+		return nil, taskErr
+	}
+
 	// ── 成功：结算 + 日志 + 插入任务 ──
-	if taskErr == nil {
-		if settleErr := service.SettleBilling(c, relayInfo, result.Quota); settleErr != nil {
-			common.SysError("settle task billing error: " + settleErr.Error())
-		}
-		service.LogTaskConsumption(c, relayInfo)
-
-		task := model.InitTask(result.Platform, relayInfo)
-		task.PrivateData.UpstreamTaskID = result.UpstreamTaskID
-		task.PrivateData.BillingSource = relayInfo.BillingSource
-		task.PrivateData.SubscriptionId = relayInfo.SubscriptionId
-		task.PrivateData.TokenId = relayInfo.TokenId
-		task.PrivateData.BillingContext = &model.TaskBillingContext{
-			ModelPrice:      relayInfo.PriceData.ModelPrice,
-			GroupRatio:      relayInfo.PriceData.GroupRatioInfo.GroupRatio,
-			ModelRatio:      relayInfo.PriceData.ModelRatio,
-			OtherRatios:     relayInfo.PriceData.OtherRatios,
-			OriginModelName: relayInfo.OriginModelName,
-			PerCallBilling:  common.StringsContains(constant.TaskPricePatches, relayInfo.OriginModelName),
-		}
-		task.Quota = result.Quota
-		task.Data = result.TaskData
-		task.Action = relayInfo.Action
-		if insertErr := task.Insert(); insertErr != nil {
-			common.SysError("insert task error: " + insertErr.Error())
+	if settleErr := service.SettleBilling(c, relayInfo, result.Quota); settleErr != nil {
+		common.SysError("settle task billing error: " + settleErr.Error())
+	}
+	service.LogTaskConsumption(c, relayInfo)
+
+	task := model.InitTask(result.Platform, relayInfo)
+	fillSubmittedTask(c, task, relayInfo, result)
+	if reserved != nil {
+		task.ID = reserved.ID
+		task.CreatedAt = reserved.CreatedAt
+		if _, updateErr := task.UpdateWithStatus(model.TaskStatusNotStart); updateErr != nil {
+			common.SysError("update reserved task error: " + updateErr.Error())
 		}
+		return task, nil
+	}
+	if insertErr := task.Insert(); insertErr != nil {
+		common.SysError("insert task error: " + insertErr.Error())
 	}
+	return task, nil
+}
 
-	if taskErr != nil {
-		respondTaskError(c, taskErr)
+// fillSubmittedTask 将提交成功的上游任务信息与计费上下文写入任务
+func fillSubmittedTask(c *gin.Context, task *model.Task, relayInfo *relaycommon.RelayInfo, result *relay.TaskSubmitResult) {
+	task.PrivateData.UpstreamTaskID = result.UpstreamTaskID
+	task.PrivateData.BillingSource = relayInfo.BillingSource
+	task.PrivateData.SubscriptionId = relayInfo.SubscriptionId
+	task.PrivateData.TokenId = relayInfo.TokenId
+	task.PrivateData.BillingContext = &model.TaskBillingContext{
+		ModelPrice:      relayInfo.PriceData.ModelPrice,
+		GroupRatio:      relayInfo.PriceData.GroupRatioInfo.GroupRatio,
+		ModelRatio:      relayInfo.PriceData.ModelRatio,
+		OtherRatios:     relayInfo.PriceData.OtherRatios,
+		OriginModelName: relayInfo.OriginModelName,
+		PerCallBilling:  common.StringsContains(constant.TaskPricePatches, relayInfo.OriginModelName),
+	}
+	task.Quota = result.Quota
+	task.Data = result.TaskData
+	task.Action = relayInfo.Action
+	if service.ShouldStoreTaskResubmitRequest(relayInfo.OriginModelName) {
+		task.PrivateData.ResubmitRequest = buildTaskResubmitRequest(c)
+	}
+	if relayInfo.CallbackURL != "" {
+		// 已注册上游回调，宽限期内不轮询
+		task.NextPollAt = service.GetTaskCallbackDeadline(time.Now().Unix())
 	}
 }
 
 // respondTaskError 统一输出 Task 错误响应（含 429 限流提示改写）
 func respondTaskError(c *gin.Context, taskErr *dto.TaskError) {
-	if taskErr.StatusCode == http.StatusTooManyRequests {
+	if taskErr.StatusCode == http.StatusTooManyRequests && taskErr.Code != taskConcurrencyExceededCode {
 		taskErr.Message = "当前分组上游负载已饱和，请稍后再试"
 	}
+	if c.GetString("platform") == string(constant.TaskPlatformMidjourney) {
+		respondMidjourneyTaskError(c, taskErr)
+		return
+	}
 	c.JSON(taskErr.StatusCode, taskErr)
 }
 
+// respondMidjourneyTaskError 以旧版 /mj 接口的错误格式输出，code 优先使用上游返回的提交状态码
+func respondMidjourneyTaskError(c *gin.Context, taskErr *dto.TaskError) {
+	code, err := strconv.Atoi(taskErr.Code)
+	if err != nil {
+		code = constant.MjRequestError
+	}
+	statusCode := http.StatusBadRequest
+	if taskErr.StatusCode == http.StatusTooManyRequests {
+		statusCode = http.StatusTooManyRequests
+	}
+	c.JSON(statusCode, gin.H{
+		"description": taskErr.Message,
+		"type":        "upstream_error",
+		"code":        code,
+	})
+}
+
 func shouldRetryTaskRelay(c *gin.Context, channelId int, taskErr *dto.TaskError, retryTimes int) bool {
 	if taskErr == nil {
 		return false
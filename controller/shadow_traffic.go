@@ -0,0 +1,250 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// 主渠道输出最多旁路复制 1MB 用于比较
+const shadowCaptureLimit = 1 << 20
+
+var shadowTrafficInflight atomic.Int64
+
+// shadowTraffic 一次被抽中镜像的请求：在主渠道成功返回后，把相同请求异步发送到候选渠道，
+// 候选渠道的响应只用于对比，不返回给客户端、不计费
+type shadowTraffic struct {
+	rule        operation_setting.ShadowTrafficRule
+	relayFormat types.RelayFormat
+	method      string
+	url         string
+	header      http.Header
+	keys        map[string]any
+	capture     *service.CaptureWriter
+}
+
+func isShadowTrafficSupported(relayFormat types.RelayFormat, relayMode int) bool {
+	switch relayFormat {
+	case types.RelayFormatClaude, types.RelayFormatGemini, types.RelayFormatOpenAIResponses,
+		types.RelayFormatEmbedding, types.RelayFormatRerank:
+		return true
+	case types.RelayFormatOpenAI:
+		return relayMode == relayconstant.RelayModeChatCompletions || relayMode == relayconstant.RelayModeCompletions
+	}
+	return false
+}
+
+// newShadowTraffic 按分组/模型匹配镜像规则并抽样，未抽中时返回 nil。
+// 需要在请求转发前调用，以便旁路复制主渠道的输出
+func newShadowTraffic(c *gin.Context, relayInfo *relaycommon.RelayInfo, relayFormat types.RelayFormat) *shadowTraffic {
+	if !isShadowTrafficSupported(relayFormat, relayInfo.RelayMode) {
+		return nil
+	}
+	rule := operation_setting.MatchShadowTrafficRule(relayInfo.UsingGroup, relayInfo.OriginModelName)
+	if rule == nil || rand.Float64()*100 >= rule.Percentage {
+		return nil
+	}
+	shadow := &shadowTraffic{
+		rule:        *rule,
+		relayFormat: relayFormat,
+		method:      c.Request.Method,
+		url:         c.Request.URL.String(),
+		header:      c.Request.Header.Clone(),
+		keys:        make(map[string]any, len(c.Keys)),
+	}
+	for k, v := range c.Keys {
+		if k == common.KeyBodyStorage || k == common.KeyRequestBody {
+			continue
+		}
+		shadow.keys[k] = v
+	}
+	if rule.CompareOutput {
+		// 透传主渠道输出并旁路复制一份，超出上限时放弃比较
+		shadow.capture = service.NewCaptureWriter(c.Writer, true, shadowCaptureLimit)
+		c.Writer = shadow.capture
+	}
+	return shadow
+}
+
+// Dispatch 主渠道成功后调用，异步发送镜像请求
+func (s *shadowTraffic) Dispatch(c *gin.Context, relayInfo *relaycommon.RelayInfo, primaryChannelId int) {
+	if s == nil || primaryChannelId == s.rule.ChannelId {
+		return
+	}
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		return
+	}
+	body, err := storage.Bytes()
+	if err != nil {
+		return
+	}
+	// 超出并发上限时丢弃本次镜像，避免候选渠道变慢时堆积
+	maxConcurrency := int64(operation_setting.GetShadowTrafficSetting().MaxConcurrency)
+	if inflight := shadowTrafficInflight.Add(1); maxConcurrency > 0 && inflight > maxConcurrency {
+		shadowTrafficInflight.Add(-1)
+		return
+	}
+	record := &model.ShadowComparison{
+		CreatedAt:          common.GetTimestamp(),
+		UsingGroup:         relayInfo.UsingGroup,
+		ModelName:          relayInfo.OriginModelName,
+		IsStream:           relayInfo.IsStream,
+		PrimaryChannelId:   primaryChannelId,
+		CandidateChannelId: s.rule.ChannelId,
+		PrimaryLatency:     time.Since(relayInfo.StartTime).Milliseconds(),
+		Similarity:         -1,
+	}
+	if relayInfo.FinalUsage != nil {
+		record.PrimaryPromptTokens = relayInfo.FinalUsage.PromptTokens
+		record.PrimaryCompletionTokens = relayInfo.FinalUsage.CompletionTokens
+	}
+	var primaryOutput []byte
+	if s.capture != nil && !s.capture.Overflow() {
+		primaryOutput = s.capture.Bytes()
+	}
+	body = bytes.Clone(body)
+	priceData := relayInfo.PriceData
+	gopool.Go(func() {
+		defer shadowTrafficInflight.Add(-1)
+		defer func() {
+			if r := recover(); r != nil {
+				common.SysError(fmt.Sprintf("shadow traffic to channel #%d panic: %v", s.rule.ChannelId, r))
+			}
+		}()
+		s.run(body, priceData, record, primaryOutput)
+	})
+}
+
+func (s *shadowTraffic) run(body []byte, priceData types.PriceData, record *model.ShadowComparison, primaryOutput []byte) {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	req, err := http.NewRequestWithContext(context.Background(), s.method, s.url, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header = s.header
+	c.Request = req
+	for k, v := range s.keys {
+		c.Set(k, v)
+	}
+	common.SetContextKey(c, constant.ContextKeyRequestStartTime, time.Now())
+	defer common.CleanupBodyStorage(c)
+
+	startTime := time.Now()
+	usage, newAPIError := s.relay(c, priceData)
+	record.CandidateLatency = time.Since(startTime).Milliseconds()
+	record.CandidateStatusCode = recorder.Code
+	if newAPIError != nil {
+		record.CandidateStatusCode = newAPIError.StatusCode
+		record.CandidateError = newAPIError.Error()
+	} else {
+		record.CandidateSuccess = true
+		if usage != nil {
+			record.CandidatePromptTokens = usage.PromptTokens
+			record.CandidateCompletionTokens = usage.CompletionTokens
+		}
+		if primaryOutput != nil {
+			record.Similarity = service.TextSimilarity(service.ExtractResponseText(primaryOutput), service.ExtractResponseText(recorder.Body.Bytes()))
+		}
+	}
+	if err := model.RecordShadowComparison(record); err != nil {
+		common.SysError("failed to record shadow comparison: " + err.Error())
+	}
+}
+
+// relay 与主请求走相同的转发流程，RelayInfo.IsShadow 使结算阶段只记录用量而不扣费、不写日志
+func (s *shadowTraffic) relay(c *gin.Context, priceData types.PriceData) (*dto.Usage, *types.NewAPIError) {
+	channel, err := model.CacheGetChannel(s.rule.ChannelId)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeGetChannelFailed)
+	}
+	request, err := helper.GetAndValidateRequest(c, s.relayFormat)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeInvalidRequest)
+	}
+	relayInfo, err := relaycommon.GenRelayInfo(c, s.relayFormat, request, nil)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeGenRelayInfoFailed)
+	}
+	relayInfo.IsShadow = true
+	relayInfo.PriceData = priceData
+	if newAPIError := middleware.SetupContextForSelectedChannel(c, channel, relayInfo.OriginModelName); newAPIError != nil {
+		return nil, newAPIError
+	}
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeReadRequestBodyFailed)
+	}
+	c.Request.Body = io.NopCloser(storage)
+
+	var newAPIError *types.NewAPIError
+	switch s.relayFormat {
+	case types.RelayFormatClaude:
+		newAPIError = relay.ClaudeHelper(c, relayInfo)
+	case types.RelayFormatGemini:
+		newAPIError = geminiRelayHandler(c, relayInfo)
+	default:
+		newAPIError = relayHandler(c, relayInfo)
+	}
+	return relayInfo.FinalUsage, newAPIError
+}
+
+// GetShadowComparisonReport 按候选渠道与模型汇总影子流量对比结果
+func GetShadowComparisonReport(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	reports, err := model.GetShadowComparisonReport(channelId, startTimestamp, endTimestamp)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, reports)
+}
+
+func GetShadowComparisons(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	records, total, err := model.GetShadowComparisons(channelId, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(records)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func DeleteShadowComparisons(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	deleted, err := model.DeleteShadowComparisons(channelId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, deleted)
+}
@@ -1,11 +1,16 @@
 package controller
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
 	"strconv"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/relay"
 	"github.com/QuantumNous/new-api/service"
@@ -19,6 +24,39 @@ func UpdateTaskBulk() {
 	service.TaskPollingLoop()
 }
 
+// GetTaskPollingStats 返回当前节点的任务轮询状态与积压情况
+func GetTaskPollingStats(c *gin.Context) {
+	common.ApiSuccess(c, service.GetTaskPollingStats())
+}
+
+// TaskCallback 接收上游推送的任务状态回调
+func TaskCallback(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Param("channel_id"))
+	if err != nil {
+		common.ApiErrorMsg(c, "invalid channel id")
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 10<<20))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	err = service.HandleTaskCallback(c, channelId, c.Param("task_id"), c.Query("signature"), body)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"success": true, "message": ""})
+	case errors.Is(err, service.ErrTaskCallbackInvalidSignature):
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": err.Error()})
+	case errors.Is(err, service.ErrTaskCallbackTaskNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": err.Error()})
+	case errors.Is(err, service.ErrTaskCallbackNotSupported):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+	default:
+		logger.LogError(c, fmt.Sprintf("handle task callback failed: %s", err.Error()))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+	}
+}
+
 func GetAllTask(c *gin.Context) {
 	pageInfo := common.GetPageQuery(c)
 
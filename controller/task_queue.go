@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	taskConcurrencyExceededCode = "task_concurrency_exceeded"
+	reserveTaskSlotFailedCode   = "reserve_task_slot_failed"
+)
+
+// reserveTaskSlot 在检查并发上限的同一事务中写入占位任务并预先确定公开任务 ID，
+// 避免并发提交同时通过检查；未开启并发限制时返回 nil。达到上限时返回的错误匹配 service.ErrTaskConcurrencyLimit
+func reserveTaskSlot(c *gin.Context, relayInfo *relaycommon.RelayInfo) (*model.Task, error) {
+	if relayInfo.PublicTaskID == "" {
+		relayInfo.PublicTaskID = model.GenerateTaskID()
+	}
+	platform := constant.TaskPlatform(c.GetString("platform"))
+	if platform == "" {
+		platform = relay.GetTaskPlatform(c)
+	}
+	task := model.InitTask(platform, relayInfo)
+	task.PrivateData.TokenId = relayInfo.TokenId
+	reserved, err := service.ReserveTaskSlot(task, relayInfo.UserGroup, c.GetInt("token_task_limit"))
+	if err != nil || !reserved {
+		return nil, err
+	}
+	return task, nil
+}
+
+// enqueueTask 进行中的任务数超出上限时，在允许排队的情况下保存请求并返回排队中的任务，否则返回拒绝错误
+func enqueueTask(c *gin.Context, relayInfo *relaycommon.RelayInfo, limitErr error) *dto.TaskError {
+	reject := func(err error) *dto.TaskError {
+		return service.TaskErrorWrapperLocal(err, taskConcurrencyExceededCode, http.StatusTooManyRequests)
+	}
+	platform := constant.TaskPlatform(c.GetString("platform"))
+	if platform == "" {
+		platform = relay.GetTaskPlatform(c)
+	}
+	// suno 任务按批次轮询，且响应需要上游任务 ID，不支持排队
+	if platform == constant.TaskPlatformSuno {
+		return reject(limitErr)
+	}
+	if err := service.CheckTaskQueue(relayInfo.UserId); err != nil {
+		if errors.Is(err, service.ErrTaskQueueDisabled) {
+			return reject(limitErr)
+		}
+		return reject(err)
+	}
+	req := buildTaskResubmitRequest(c)
+	if req == nil {
+		// multipart 请求无法保存后重放
+		return reject(limitErr)
+	}
+
+	task := model.InitTask(platform, relayInfo)
+	task.ChannelId = 0
+	task.PrivateData.Key = ""
+	task.PrivateData.TokenId = relayInfo.TokenId
+	task.PrivateData.ResubmitRequest = req
+	task.Status = model.TaskStatusQueued
+	task.Progress = "0%"
+	task.QueuedAt = time.Now().Unix()
+	task.Action = req.Action
+	if err := task.Insert(); err != nil {
+		return service.TaskErrorWrapperLocal(err, "insert_task_failed", http.StatusInternalServerError)
+	}
+
+	if platform == constant.TaskPlatformMidjourney {
+		c.JSON(http.StatusOK, dto.MidjourneyResponse{
+			Code:        1,
+			Description: "排队中",
+			Result:      task.TaskID,
+		})
+		return nil
+	}
+	video := dto.NewOpenAIVideo()
+	video.ID = task.TaskID
+	video.TaskID = task.TaskID
+	video.Model = relayInfo.OriginModelName
+	video.CreatedAt = task.CreatedAt
+	c.JSON(http.StatusOK, video)
+	return nil
+}
+
+// DispatchQueuedTask 以任务所属令牌的身份提交排队任务保存的原始请求，沿用排队时的公开任务 ID（注入到 service.DispatchQueuedTaskFunc）
+func DispatchQueuedTask(ctx context.Context, task *model.Task) error {
+	req := task.PrivateData.ResubmitRequest
+	if req == nil {
+		return errors.New("original request not stored")
+	}
+	modelName := task.Properties.OriginModelName
+
+	token, err := model.GetTokenById(task.TokenId)
+	if err != nil {
+		return err
+	}
+	if token.Status != common.TokenStatusEnabled {
+		return errors.New("令牌已不可用")
+	}
+	userCache, err := model.GetUserCache(task.UserId)
+	if err != nil {
+		return err
+	}
+	if userCache.Status != common.UserStatusEnabled {
+		return errors.New("用户已被封禁")
+	}
+	channel, err := pickResubmitChannel(task.Group, modelName, nil)
+	if err != nil {
+		return err
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = (&http.Request{
+		Method: http.MethodPost,
+		URL:    &url.URL{Path: req.Path},
+		Body:   io.NopCloser(bytes.NewReader(req.Body)),
+		Header: make(http.Header),
+	}).WithContext(ctx)
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	if err = middleware.SetupContextForToken(c, token); err != nil {
+		return err
+	}
+	userCache.WriteContext(c)
+	common.SetContextKey(c, constant.ContextKeyUserId, task.UserId)
+	common.SetContextKey(c, constant.ContextKeyUsingGroup, task.Group)
+	common.SetContextKey(c, constant.ContextKeyTokenGroup, task.Group)
+	common.SetContextKey(c, constant.ContextKeyOriginalModel, modelName)
+	if req.Action != "" {
+		c.Set("action", req.Action)
+	}
+	// mj 等按路由确定的平台需要显式指定，按渠道类型确定的平台由所选渠道决定
+	if _, convErr := strconv.Atoi(string(task.Platform)); convErr != nil && task.Platform != "" {
+		c.Set("platform", string(task.Platform))
+	}
+	if apiErr := middleware.SetupContextForSelectedChannel(c, channel, modelName); apiErr != nil {
+		return apiErr
+	}
+
+	relayInfo, err := relaycommon.GenRelayInfo(c, types.RelayFormatTask, nil, nil)
+	if err != nil {
+		return err
+	}
+	relayInfo.PublicTaskID = task.TaskID
+	if taskErr := relay.ResolveOriginTask(c, relayInfo); taskErr != nil {
+		return errors.New(taskErr.Message)
+	}
+	result, taskErr := relay.RelayTaskSubmit(c, relayInfo)
+	if taskErr != nil {
+		if relayInfo.Billing != nil {
+			relayInfo.Billing.Refund(c)
+		}
+		return fmt.Errorf("channel #%d: %s", channel.Id, taskErr.Message)
+	}
+
+	if settleErr := service.SettleBilling(c, relayInfo, result.Quota); settleErr != nil {
+		common.SysError("settle task billing error: " + settleErr.Error())
+	}
+	service.LogTaskConsumption(c, relayInfo)
+
+	submitted := model.InitTask(result.Platform, relayInfo)
+	task.PrivateData.ResubmitRequest = nil
+	fillSubmittedTask(c, task, relayInfo, result)
+	task.PrivateData.Key = submitted.PrivateData.Key
+	task.Properties = submitted.Properties
+	task.ChannelId = channel.Id
+	task.Platform = result.Platform
+	task.SubmitTime = submitted.SubmitTime
+	if relayInfo.CallbackURL == "" {
+		task.NextPollAt = 0
+	}
+	if _, err = task.UpdateWithStatus(model.TaskStatusSubmitted); err != nil {
+		common.SysError(fmt.Sprintf("update dispatched task %s error: %v", task.TaskID, err))
+	}
+	return nil
+}
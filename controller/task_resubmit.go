@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// resubmitChannelDraws 每个优先级随机抽取渠道的次数，用于跳过已尝试过的渠道
+const resubmitChannelDraws = 5
+
+// buildTaskResubmitRequest 保存原始请求以便失败后重新提交；multipart 请求无法重放，不保存
+func buildTaskResubmitRequest(c *gin.Context) *model.TaskResubmitRequest {
+	if !strings.HasPrefix(c.GetHeader("Content-Type"), "application/json") {
+		return nil
+	}
+	bodyStorage, err := common.GetBodyStorage(c)
+	if err != nil {
+		return nil
+	}
+	body, err := bodyStorage.Bytes()
+	if err != nil || len(body) == 0 {
+		return nil
+	}
+	return &model.TaskResubmitRequest{
+		Path:   c.Request.URL.Path,
+		Action: c.GetString("action"),
+		Body:   body,
+	}
+}
+
+// ResubmitTask 将失败任务保存的原始请求提交到其他可用渠道，沿用原公开任务 ID，不重复预扣费（注入到 service.ResubmitTaskFunc）
+func ResubmitTask(ctx context.Context, task *model.Task, excludeChannelIds []int) (*service.TaskResubmitResult, error) {
+	req := task.PrivateData.ResubmitRequest
+	if req == nil {
+		return nil, errors.New("original request not stored")
+	}
+	modelName := task.Properties.OriginModelName
+
+	channel, err := pickResubmitChannel(task.Group, modelName, excludeChannelIds)
+	if err != nil {
+		return nil, err
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = (&http.Request{
+		Method: http.MethodPost,
+		URL:    &url.URL{Path: req.Path},
+		Body:   io.NopCloser(bytes.NewReader(req.Body)),
+		Header: make(http.Header),
+	}).WithContext(ctx)
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	userCache, err := model.GetUserCache(task.UserId)
+	if err != nil {
+		return nil, err
+	}
+	userCache.WriteContext(c)
+	common.SetContextKey(c, constant.ContextKeyUserId, task.UserId)
+	common.SetContextKey(c, constant.ContextKeyUsingGroup, task.Group)
+	common.SetContextKey(c, constant.ContextKeyTokenGroup, task.Group)
+	common.SetContextKey(c, constant.ContextKeyTokenId, task.PrivateData.TokenId)
+	common.SetContextKey(c, constant.ContextKeyOriginalModel, modelName)
+	if req.Action != "" {
+		c.Set("action", req.Action)
+	}
+	if apiErr := middleware.SetupContextForSelectedChannel(c, channel, modelName); apiErr != nil {
+		return nil, apiErr
+	}
+
+	relayInfo, err := relaycommon.GenRelayInfo(c, types.RelayFormatTask, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	relayInfo.PublicTaskID = task.TaskID
+	relayInfo.Resubmit = true
+	result, taskErr := relay.RelayTaskSubmit(c, relayInfo)
+	if taskErr != nil {
+		return nil, fmt.Errorf("channel #%d: %s", channel.Id, taskErr.Message)
+	}
+
+	newTask := model.InitTask(result.Platform, relayInfo)
+	return &service.TaskResubmitResult{
+		ChannelId:      channel.Id,
+		Platform:       result.Platform,
+		UpstreamTaskID: result.UpstreamTaskID,
+		Key:            newTask.PrivateData.Key,
+		Data:           result.TaskData,
+	}, nil
+}
+
+// pickResubmitChannel 按优先级从高到低选择一个未尝试过的可用渠道
+func pickResubmitChannel(group string, modelName string, excludeChannelIds []int) (*model.Channel, error) {
+	for retry := 0; retry <= common.RetryTimes; retry++ {
+		for i := 0; i < resubmitChannelDraws; i++ {
+			channel, err := model.GetRandomSatisfiedChannel(group, modelName, retry)
+			if err != nil || channel == nil {
+				break
+			}
+			if !slices.Contains(excludeChannelIds, channel.Id) {
+				return channel, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no other available channel for model %s in group %s", modelName, group)
+}
@@ -3,6 +3,7 @@ package controller
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
@@ -44,6 +45,10 @@ func GetUserQuotaDates(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	// 上游成本仅管理员可见
+	for _, date := range dates {
+		date.Cost = 0
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -51,3 +56,32 @@ func GetUserQuotaDates(c *gin.Context) {
 	})
 	return
 }
+
+// GetMarginReport 按渠道/模型/分组/天返回收入、上游成本与毛利，dimensions 以逗号分隔，为空时使用全部维度
+func GetMarginReport(c *gin.Context) {
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	channelId, _ := strconv.Atoi(c.Query("channel"))
+	var dimensions []string
+	if raw := c.Query("dimensions"); raw != "" {
+		dimensions = strings.Split(raw, ",")
+	}
+	reports, err := model.GetMarginReport(startTimestamp, endTimestamp, channelId, c.Query("model_name"), c.Query("group"), dimensions)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, reports)
+}
+
+// GetTrafficSplitReport 分流规则各分支的请求数、错误率、平均耗时与额度/成本
+func GetTrafficSplitReport(c *gin.Context) {
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	reports, err := model.GetTrafficSplitReport(c.Query("rule"), startTimestamp, endTimestamp)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, reports)
+}
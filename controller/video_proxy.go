@@ -1,8 +1,10 @@
 package controller
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,6 +15,7 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel/task/taskcommon"
 	"github.com/QuantumNous/new-api/service"
 
 	"github.com/gin-gonic/gin"
@@ -53,12 +56,79 @@ func VideoProxy(c *gin.Context) {
 		return
 	}
 
-	channel, err := model.CacheGetChannel(task.ChannelId)
+	// 已转存到网关存储的视频直接从存储读取，不再依赖上游链接
+	if obj := service.GetTaskArchivedVideo(task); obj != nil {
+		if serveMediaObject(c, obj) {
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+	req, client, dataURL, proxyErr := buildTaskVideoRequest(ctx, task)
+	if proxyErr != nil {
+		logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to build video request for task %s: %s", taskID, proxyErr.Error()))
+		videoProxyError(c, proxyErr.status, "server_error", proxyErr.message)
+		return
+	}
+
+	if dataURL != "" {
+		if err := writeVideoDataURL(c, dataURL); err != nil {
+			logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to decode video data URL for task %s: %s", taskID, err.Error()))
+			videoProxyError(c, http.StatusBadGateway, "server_error", "Failed to fetch video content")
+		}
+		return
+	}
+
+	videoURL := req.URL.String()
+	resp, err := client.Do(req)
 	if err != nil {
-		logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to get channel for task %s: %s", taskID, err.Error()))
-		videoProxyError(c, http.StatusInternalServerError, "server_error", "Failed to retrieve channel information")
+		logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to fetch video from %s: %s", videoURL, err.Error()))
+		videoProxyError(c, http.StatusBadGateway, "server_error", "Failed to fetch video content")
 		return
 	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		logger.LogError(c.Request.Context(), fmt.Sprintf("Upstream returned status %d for %s", resp.StatusCode, videoURL))
+		videoProxyError(c, http.StatusBadGateway, "server_error",
+			fmt.Sprintf("Upstream service returned status %d", resp.StatusCode))
+		return
+	}
+
+	for key, values := range resp.Header {
+		for _, value := range values {
+			c.Writer.Header().Add(key, value)
+		}
+	}
+
+	c.Writer.Header().Set("Cache-Control", "public, max-age=86400")
+	c.Writer.WriteHeader(resp.StatusCode)
+	if _, err = io.Copy(c.Writer, resp.Body); err != nil {
+		logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to stream video content: %s", err.Error()))
+	}
+}
+
+// videoRequestError 构建上游视频请求失败时返回给客户端的状态码与信息
+type videoRequestError struct {
+	status  int
+	message string
+	err     error
+}
+
+func (e *videoRequestError) Error() string {
+	if e.err != nil {
+		return fmt.Sprintf("%s: %s", e.message, e.err.Error())
+	}
+	return e.message
+}
+
+// buildTaskVideoRequest 按渠道类型构建获取任务视频内容的上游请求；结果为 data URL 时返回 dataURL 而不构建请求
+func buildTaskVideoRequest(ctx context.Context, task *model.Task) (*http.Request, *http.Client, string, *videoRequestError) {
+	channel, err := model.CacheGetChannel(task.ChannelId)
+	if err != nil {
+		return nil, nil, "", &videoRequestError{http.StatusInternalServerError, "Failed to retrieve channel information", err}
+	}
 	baseURL := channel.GetBaseURL()
 	if baseURL == "" {
 		baseURL = "https://api.openai.com"
@@ -68,41 +138,29 @@ func VideoProxy(c *gin.Context) {
 	proxy := channel.GetSetting().Proxy
 	client, err := service.GetHttpClientWithProxy(proxy)
 	if err != nil {
-		logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to create proxy client for task %s: %s", taskID, err.Error()))
-		videoProxyError(c, http.StatusInternalServerError, "server_error", "Failed to create proxy client")
-		return
+		return nil, nil, "", &videoRequestError{http.StatusInternalServerError, "Failed to create proxy client", err}
 	}
 
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
-	defer cancel()
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "", nil)
 	if err != nil {
-		logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to create request: %s", err.Error()))
-		videoProxyError(c, http.StatusInternalServerError, "server_error", "Failed to create proxy request")
-		return
+		return nil, nil, "", &videoRequestError{http.StatusInternalServerError, "Failed to create proxy request", err}
 	}
 
 	switch channel.Type {
 	case constant.ChannelTypeGemini:
 		apiKey := task.PrivateData.Key
 		if apiKey == "" {
-			logger.LogError(c.Request.Context(), fmt.Sprintf("Missing stored API key for Gemini task %s", taskID))
-			videoProxyError(c, http.StatusInternalServerError, "server_error", "API key not stored for task")
-			return
+			return nil, nil, "", &videoRequestError{http.StatusInternalServerError, "API key not stored for task", nil}
 		}
 		videoURL, err = getGeminiVideoURL(channel, task, apiKey)
 		if err != nil {
-			logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to resolve Gemini video URL for task %s: %s", taskID, err.Error()))
-			videoProxyError(c, http.StatusBadGateway, "server_error", "Failed to resolve Gemini video URL")
-			return
+			return nil, nil, "", &videoRequestError{http.StatusBadGateway, "Failed to resolve Gemini video URL", err}
 		}
 		req.Header.Set("x-goog-api-key", apiKey)
 	case constant.ChannelTypeVertexAi:
 		videoURL, err = getVertexVideoURL(channel, task)
 		if err != nil {
-			logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to resolve Vertex video URL for task %s: %s", taskID, err.Error()))
-			videoProxyError(c, http.StatusBadGateway, "server_error", "Failed to resolve Vertex video URL")
-			return
+			return nil, nil, "", &videoRequestError{http.StatusBadGateway, "Failed to resolve Vertex video URL", err}
 		}
 	case constant.ChannelTypeOpenAI, constant.ChannelTypeSora:
 		videoURL = fmt.Sprintf("%s/v1/videos/%s/content", baseURL, task.GetUpstreamTaskID())
@@ -110,68 +168,74 @@ func VideoProxy(c *gin.Context) {
 	default:
 		// Video URL is stored in PrivateData.ResultURL (fallback to FailReason for old data)
 		videoURL = task.GetResultURL()
+		if videoURL == taskcommon.BuildProxyURL(task.TaskID) {
+			// 代理链接指向本接口自身，无法从上游获取
+			return nil, nil, "", &videoRequestError{http.StatusBadGateway, "Failed to fetch video content", errors.New("no upstream video url")}
+		}
 	}
 
 	videoURL = strings.TrimSpace(videoURL)
 	if videoURL == "" {
-		logger.LogError(c.Request.Context(), fmt.Sprintf("Video URL is empty for task %s", taskID))
-		videoProxyError(c, http.StatusBadGateway, "server_error", "Failed to fetch video content")
-		return
+		return nil, nil, "", &videoRequestError{http.StatusBadGateway, "Failed to fetch video content", errors.New("video url is empty")}
 	}
-
 	if strings.HasPrefix(videoURL, "data:") {
-		if err := writeVideoDataURL(c, videoURL); err != nil {
-			logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to decode video data URL for task %s: %s", taskID, err.Error()))
-			videoProxyError(c, http.StatusBadGateway, "server_error", "Failed to fetch video content")
-		}
-		return
+		return nil, nil, videoURL, nil
 	}
 
 	req.URL, err = url.Parse(videoURL)
 	if err != nil {
-		logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to parse URL %s: %s", videoURL, err.Error()))
-		videoProxyError(c, http.StatusInternalServerError, "server_error", "Failed to create proxy request")
-		return
+		return nil, nil, "", &videoRequestError{http.StatusInternalServerError, "Failed to create proxy request", err}
 	}
+	return req, client, "", nil
+}
 
+// OpenTaskVideoContent 获取任务视频内容，供媒体转存使用（注入到 service.OpenTaskMediaFunc）
+func OpenTaskVideoContent(ctx context.Context, task *model.Task) (io.ReadCloser, string, error) {
+	req, client, dataURL, proxyErr := buildTaskVideoRequest(ctx, task)
+	if proxyErr != nil {
+		return nil, "", proxyErr
+	}
+	if dataURL != "" {
+		mimeType, videoBytes, err := decodeVideoDataURL(dataURL)
+		if err != nil {
+			return nil, "", err
+		}
+		return io.NopCloser(bytes.NewReader(videoBytes)), mimeType, nil
+	}
 	resp, err := client.Do(req)
 	if err != nil {
-		logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to fetch video from %s: %s", videoURL, err.Error()))
-		videoProxyError(c, http.StatusBadGateway, "server_error", "Failed to fetch video content")
-		return
+		return nil, "", err
 	}
-	defer resp.Body.Close()
-
 	if resp.StatusCode != http.StatusOK {
-		logger.LogError(c.Request.Context(), fmt.Sprintf("Upstream returned status %d for %s", resp.StatusCode, videoURL))
-		videoProxyError(c, http.StatusBadGateway, "server_error",
-			fmt.Sprintf("Upstream service returned status %d", resp.StatusCode))
-		return
+		resp.Body.Close()
+		return nil, "", fmt.Errorf("upstream returned status %d", resp.StatusCode)
 	}
+	return resp.Body, resp.Header.Get("Content-Type"), nil
+}
 
-	for key, values := range resp.Header {
-		for _, value := range values {
-			c.Writer.Header().Add(key, value)
-		}
+func writeVideoDataURL(c *gin.Context, dataURL string) error {
+	mimeType, videoBytes, err := decodeVideoDataURL(dataURL)
+	if err != nil {
+		return err
 	}
 
+	c.Writer.Header().Set("Content-Type", mimeType)
 	c.Writer.Header().Set("Cache-Control", "public, max-age=86400")
-	c.Writer.WriteHeader(resp.StatusCode)
-	if _, err = io.Copy(c.Writer, resp.Body); err != nil {
-		logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to stream video content: %s", err.Error()))
-	}
+	c.Writer.WriteHeader(http.StatusOK)
+	_, err = c.Writer.Write(videoBytes)
+	return err
 }
 
-func writeVideoDataURL(c *gin.Context, dataURL string) error {
+func decodeVideoDataURL(dataURL string) (string, []byte, error) {
 	parts := strings.SplitN(dataURL, ",", 2)
 	if len(parts) != 2 {
-		return fmt.Errorf("invalid data url")
+		return "", nil, fmt.Errorf("invalid data url")
 	}
 
 	header := parts[0]
 	payload := parts[1]
 	if !strings.HasPrefix(header, "data:") || !strings.Contains(header, ";base64") {
-		return fmt.Errorf("unsupported data url")
+		return "", nil, fmt.Errorf("unsupported data url")
 	}
 
 	mimeType := strings.TrimPrefix(header, "data:")
@@ -184,13 +248,8 @@ func writeVideoDataURL(c *gin.Context, dataURL string) error {
 	if err != nil {
 		videoBytes, err = base64.RawStdEncoding.DecodeString(payload)
 		if err != nil {
-			return err
+			return "", nil, err
 		}
 	}
-
-	c.Writer.Header().Set("Content-Type", mimeType)
-	c.Writer.Header().Set("Cache-Control", "public, max-age=86400")
-	c.Writer.WriteHeader(http.StatusOK)
-	_, err = c.Writer.Write(videoBytes)
-	return err
+	return mimeType, videoBytes, nil
 }
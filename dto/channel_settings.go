@@ -7,6 +7,8 @@ type ChannelSettings struct {
 	PassThroughBodyEnabled bool   `json:"pass_through_body_enabled,omitempty"`
 	SystemPrompt           string `json:"system_prompt,omitempty"`
 	SystemPromptOverride   bool   `json:"system_prompt_override,omitempty"`
+	EmulateTools           bool   `json:"emulate_tools,omitempty"`       // 上游不支持原生工具调用时，使用提示词协议模拟
+	EmulateCompletions     bool   `json:"emulate_completions,omitempty"` // 上游不支持 /v1/completions 时，转换为对话请求
 }
 
 type VertexKeyType string
@@ -24,22 +26,41 @@ const (
 )
 
 type ChannelOtherSettings struct {
-	AzureResponsesVersion                 string        `json:"azure_responses_version,omitempty"`
-	VertexKeyType                         VertexKeyType `json:"vertex_key_type,omitempty"` // "json" or "api_key"
-	OpenRouterEnterprise                  *bool         `json:"openrouter_enterprise,omitempty"`
-	ClaudeBetaQuery                       bool          `json:"claude_beta_query,omitempty"`         // Claude 渠道是否强制追加 ?beta=true
-	AllowServiceTier                      bool          `json:"allow_service_tier,omitempty"`        // 是否允许 service_tier 透传（默认过滤以避免额外计费）
-	AllowInferenceGeo                     bool          `json:"allow_inference_geo,omitempty"`       // 是否允许 inference_geo 透传（仅 Claude，默认过滤以满足数据驻留合规
-	AllowSafetyIdentifier                 bool          `json:"allow_safety_identifier,omitempty"`   // 是否允许 safety_identifier 透传（默认过滤以保护用户隐私）
-	DisableStore                          bool          `json:"disable_store,omitempty"`             // 是否禁用 store 透传（默认允许透传，禁用后可能导致 Codex 无法使用）
-	AllowIncludeObfuscation               bool          `json:"allow_include_obfuscation,omitempty"` // 是否允许 stream_options.include_obfuscation 透传（默认过滤以避免关闭流混淆保护）
-	AwsKeyType                            AwsKeyType    `json:"aws_key_type,omitempty"`
-	UpstreamModelUpdateCheckEnabled       bool          `json:"upstream_model_update_check_enabled,omitempty"`        // 是否检测上游模型更新
-	UpstreamModelUpdateAutoSyncEnabled    bool          `json:"upstream_model_update_auto_sync_enabled,omitempty"`    // 是否自动同步上游模型更新
-	UpstreamModelUpdateLastCheckTime      int64         `json:"upstream_model_update_last_check_time,omitempty"`      // 上次检测时间
-	UpstreamModelUpdateLastDetectedModels []string      `json:"upstream_model_update_last_detected_models,omitempty"` // 上次检测到的可加入模型
-	UpstreamModelUpdateLastRemovedModels  []string      `json:"upstream_model_update_last_removed_models,omitempty"`  // 上次检测到的可删除模型
-	UpstreamModelUpdateIgnoredModels      []string      `json:"upstream_model_update_ignored_models,omitempty"`       // 手动忽略的模型
+	AzureResponsesVersion                 string                      `json:"azure_responses_version,omitempty"`
+	VertexKeyType                         VertexKeyType               `json:"vertex_key_type,omitempty"` // "json" or "api_key"
+	OpenRouterEnterprise                  *bool                       `json:"openrouter_enterprise,omitempty"`
+	ClaudeBetaQuery                       bool                        `json:"claude_beta_query,omitempty"`         // Claude 渠道是否强制追加 ?beta=true
+	AllowServiceTier                      bool                        `json:"allow_service_tier,omitempty"`        // 是否允许 service_tier 透传（默认过滤以避免额外计费）
+	AllowInferenceGeo                     bool                        `json:"allow_inference_geo,omitempty"`       // 是否允许 inference_geo 透传（仅 Claude，默认过滤以满足数据驻留合规
+	AllowSafetyIdentifier                 bool                        `json:"allow_safety_identifier,omitempty"`   // 是否允许 safety_identifier 透传（默认过滤以保护用户隐私）
+	DisableStore                          bool                        `json:"disable_store,omitempty"`             // 是否禁用 store 透传（默认允许透传，禁用后可能导致 Codex 无法使用）
+	AllowIncludeObfuscation               bool                        `json:"allow_include_obfuscation,omitempty"` // 是否允许 stream_options.include_obfuscation 透传（默认过滤以避免关闭流混淆保护）
+	AwsKeyType                            AwsKeyType                  `json:"aws_key_type,omitempty"`
+	UpstreamModelUpdateCheckEnabled       bool                        `json:"upstream_model_update_check_enabled,omitempty"`        // 是否检测上游模型更新
+	UpstreamModelUpdateAutoSyncEnabled    bool                        `json:"upstream_model_update_auto_sync_enabled,omitempty"`    // 是否自动同步上游模型更新
+	UpstreamModelUpdateLastCheckTime      int64                       `json:"upstream_model_update_last_check_time,omitempty"`      // 上次检测时间
+	UpstreamModelUpdateLastDetectedModels []string                    `json:"upstream_model_update_last_detected_models,omitempty"` // 上次检测到的可加入模型
+	UpstreamModelUpdateLastRemovedModels  []string                    `json:"upstream_model_update_last_removed_models,omitempty"`  // 上次检测到的可删除模型
+	UpstreamModelUpdateIgnoredModels      []string                    `json:"upstream_model_update_ignored_models,omitempty"`       // 手动忽略的模型
+	OutputValidationEnabled               bool                        `json:"output_validation_enabled,omitempty"`                  // 是否校验工具调用参数与 json_schema 结构化输出
+	ActiveSchedule                        string                      `json:"active_schedule,omitempty"`                            // 生效时间（类 cron 表达式），不在生效时间内的渠道不参与选择，详见 common.ActiveSchedule
+	ActiveTimezone                        string                      `json:"active_timezone,omitempty"`                            // 生效时间使用的时区，如 Asia/Shanghai，为空时使用服务器时区
+	CostPrices                            map[string]ChannelCostPrice `json:"cost_prices,omitempty"`                                // 上游成本价，key 为模型名（优先匹配上游模型名），"*" 对其他模型生效
+	BalanceApi                            string                      `json:"balance_api,omitempty"`                                // 余额查询方式，"openai" 表示使用 OpenAI 兼容的 /dashboard/billing 接口，为空时按渠道类型选择
+	BalanceCredential                     string                      `json:"balance_credential,omitempty"`                         // 查询余额所需的额外凭证，如火山引擎的 AccessKey|SecretKey
+	LowBalanceThreshold                   float64                     `json:"low_balance_threshold,omitempty"`                      // 低余额阈值（美元），为 0 时使用全局设置
+	LowBalanceOriginalPriority            *int64                      `json:"low_balance_original_priority,omitempty"`              // 因余额不足被降低优先级前的原优先级，余额恢复后还原
+}
+
+// ChannelCostPrice 渠道上游成本价，单位为美元，token 价格按每百万 token 计
+type ChannelCostPrice struct {
+	Input      float64 `json:"input,omitempty"`
+	Output     float64 `json:"output,omitempty"`
+	CacheRead  float64 `json:"cache_read,omitempty"`  // 未配置时按输入价格计算
+	CacheWrite float64 `json:"cache_write,omitempty"` // 未配置时按输入价格计算
+	PerRequest float64 `json:"per_request,omitempty"`
+	PerImage   float64 `json:"per_image,omitempty"`
+	PerSecond  float64 `json:"per_second,omitempty"` // 按生成时长计费的任务（视频、音频等）
 }
 
 func (s *ChannelOtherSettings) IsOpenRouterEnterprise() bool {
@@ -103,6 +103,8 @@ type ClaudeMessageSource struct {
 	MediaType string `json:"media_type,omitempty"`
 	Data      any    `json:"data,omitempty"`
 	Url       string `json:"url,omitempty"`
+	// document 的 content 类型来源
+	Content any `json:"content,omitempty"`
 }
 
 type ClaudeMessage struct {
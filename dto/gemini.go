@@ -484,10 +484,16 @@ type GeminiImageInstance struct {
 }
 
 type GeminiImageParameters struct {
-	SampleCount      int    `json:"sampleCount,omitempty"`
-	AspectRatio      string `json:"aspectRatio,omitempty"`
-	PersonGeneration string `json:"personGeneration,omitempty"`
-	ImageSize        string `json:"imageSize,omitempty"`
+	SampleCount      int                       `json:"sampleCount,omitempty"`
+	AspectRatio      string                    `json:"aspectRatio,omitempty"`
+	PersonGeneration string                    `json:"personGeneration,omitempty"`
+	ImageSize        string                    `json:"imageSize,omitempty"`
+	AddWatermark     *bool                     `json:"addWatermark,omitempty"`
+	OutputOptions    *GeminiImageOutputOptions `json:"outputOptions,omitempty"`
+}
+
+type GeminiImageOutputOptions struct {
+	MimeType string `json:"mimeType,omitempty"`
 }
 
 type GeminiImageResponse struct {
@@ -157,6 +157,9 @@ func (i *ImageRequest) GetTokenCountMeta() *types.TokenCountMeta {
 		CombineText:     i.Prompt,
 		MaxTokens:       1584,
 		ImagePriceRatio: sizeRatio * qualityRatio * float64(n),
+		ImageSize:       i.Size,
+		ImageQuality:    i.Quality,
+		ImageCount:      int(n),
 	}
 }
 
@@ -180,3 +183,22 @@ type ImageData struct {
 	B64Json       string `json:"b64_json"`
 	RevisedPrompt string `json:"revised_prompt"`
 }
+
+// ImageTaskResponse 异步生图任务，/v1/images/generations?async=true 提交后返回，
+// 查询时任务完成则 Data 为生成的图片，失败则 Error 为失败原因
+type ImageTaskResponse struct {
+	ID          string          `json:"id"`
+	Object      string          `json:"object"`
+	Model       string          `json:"model"`
+	Status      string          `json:"status"`
+	Progress    string          `json:"progress,omitempty"`
+	CreatedAt   int64           `json:"created_at"`
+	CompletedAt int64           `json:"completed_at,omitempty"`
+	Data        []ImageData     `json:"data,omitempty"`
+	Error       *ImageTaskError `json:"error,omitempty"`
+}
+
+type ImageTaskError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
@@ -60,11 +60,15 @@ type GeneralOpenAIRequest struct {
 	// ServiceTier specifies upstream service level and may affect billing.
 	// This field is filtered by default and can be enabled via channel setting allow_service_tier.
 	ServiceTier json.RawMessage `json:"service_tier,omitempty"`
-	LogProbs    *bool           `json:"logprobs,omitempty"`
+	// chat 为 bool，legacy completions 为 int
+	LogProbs    json.RawMessage `json:"logprobs,omitempty"`
 	TopLogProbs *int            `json:"top_logprobs,omitempty"`
-	Dimensions  *int            `json:"dimensions,omitempty"`
-	Modalities  json.RawMessage `json:"modalities,omitempty"`
-	Audio       json.RawMessage `json:"audio,omitempty"`
+	// legacy completions
+	Echo       json.RawMessage `json:"echo,omitempty"`
+	BestOf     json.RawMessage `json:"best_of,omitempty"`
+	Dimensions *int            `json:"dimensions,omitempty"`
+	Modalities json.RawMessage `json:"modalities,omitempty"`
+	Audio      json.RawMessage `json:"audio,omitempty"`
 	// 安全标识符，用于帮助 OpenAI 检测可能违反使用政策的应用程序用户
 	// 注意：此字段会向 OpenAI 发送用户标识信息，默认过滤，可通过 allow_safety_identifier 开启
 	SafetyIdentifier json.RawMessage `json:"safety_identifier,omitempty"`
@@ -432,6 +436,14 @@ func (m *Message) GetPrefix() bool {
 	return *m.Prefix
 }
 
+// GetReasoningContent 返回推理内容，兼容 reasoning_content 与 reasoning 两种字段
+func (m *Message) GetReasoningContent() string {
+	if m.ReasoningContent != "" {
+		return m.ReasoningContent
+	}
+	return m.Reasoning
+}
+
 func (m *Message) SetPrefix(prefix bool) {
 	m.Prefix = &prefix
 }
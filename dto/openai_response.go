@@ -219,6 +219,42 @@ type CompletionsStreamResponse struct {
 	} `json:"choices"`
 }
 
+// TextCompletionResponse 是 legacy /v1/completions 的响应与流式分块（object 为 text_completion）
+type TextCompletionResponse struct {
+	Id                string                 `json:"id"`
+	Object            string                 `json:"object"`
+	Created           int64                  `json:"created"`
+	Model             string                 `json:"model"`
+	SystemFingerprint *string                `json:"system_fingerprint,omitempty"`
+	Choices           []TextCompletionChoice `json:"choices"`
+	Usage             *Usage                 `json:"usage,omitempty"`
+}
+
+type TextCompletionChoice struct {
+	Text         string                  `json:"text"`
+	Index        int                     `json:"index"`
+	Logprobs     *TextCompletionLogprobs `json:"logprobs"`
+	FinishReason *string                 `json:"finish_reason"`
+}
+
+type TextCompletionLogprobs struct {
+	Tokens        []string             `json:"tokens"`
+	TokenLogprobs []float64            `json:"token_logprobs"`
+	TopLogprobs   []map[string]float64 `json:"top_logprobs"`
+	TextOffset    []int                `json:"text_offset"`
+}
+
+// ChatCompletionLogprobs 是 chat completions 中 choices[].logprobs 的结构
+type ChatCompletionLogprobs struct {
+	Content []ChatCompletionTokenLogprob `json:"content"`
+}
+
+type ChatCompletionTokenLogprob struct {
+	Token       string                       `json:"token"`
+	Logprob     float64                      `json:"logprob"`
+	TopLogprobs []ChatCompletionTokenLogprob `json:"top_logprobs,omitempty"`
+}
+
 type Usage struct {
 	PromptTokens         int    `json:"prompt_tokens"`
 	CompletionTokens     int    `json:"completion_tokens"`
@@ -9,6 +9,10 @@ type OpenAIModels struct {
 	Created                int                     `json:"created"`
 	OwnedBy                string                  `json:"owned_by"`
 	SupportedEndpointTypes []constant.EndpointType `json:"supported_endpoint_types"`
+	// 模型别名/弃用信息，Successor 为后继模型，SunsetTime 为下线时间
+	Deprecated bool   `json:"deprecated,omitempty"`
+	SunsetTime int64  `json:"sunset_time,omitempty"`
+	Successor  string `json:"successor,omitempty"`
 }
 
 type AnthropicModel struct {
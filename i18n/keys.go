@@ -302,6 +302,7 @@ const (
 	MsgDistributorNoAvailableChannel  = "distributor.no_available_channel"
 	MsgDistributorInvalidMidjourney   = "distributor.invalid_midjourney_request"
 	MsgDistributorInvalidParseModel   = "distributor.invalid_request_parse_model"
+	MsgDistributorModelRetired        = "distributor.model_retired"
 )
 
 // Custom OAuth provider related messages
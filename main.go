@@ -91,12 +91,21 @@ func main() {
 		go model.SyncChannelCache(common.SyncFrequency)
 	}
 
+	// 加密存量的明文渠道 key
+	gopool.Go(model.MigrateChannelKeyEncryption)
+
 	// 热更新配置
 	go model.SyncOptions(common.SyncFrequency)
 
 	// 数据看板
 	go model.UpdateQuotaData()
 
+	// 多 Key 渠道的单 key 统计
+	go model.UpdateChannelKeyStats()
+
+	// 分流分支统计
+	go model.UpdateTrafficSplitStats()
+
 	// 榜单缓存初始化和定时刷新
 	if common.IsMasterNode {
 		model.InitLeaderboardCache()
@@ -110,13 +119,7 @@ func main() {
 	setting.SetRefreshTokenCountFunc(model.GetTokenUsed24h)
 	setting.StartDynamicRatioScheduler()
 
-	if os.Getenv("CHANNEL_UPDATE_FREQUENCY") != "" {
-		frequency, err := strconv.Atoi(os.Getenv("CHANNEL_UPDATE_FREQUENCY"))
-		if err != nil {
-			common.FatalLog("failed to parse CHANNEL_UPDATE_FREQUENCY: " + err.Error())
-		}
-		go controller.AutomaticallyUpdateChannels(frequency)
-	}
+	go controller.AutomaticallyUpdateChannels()
 
 	go controller.AutomaticallyTestChannels()
 
@@ -134,14 +137,35 @@ func main() {
 		}
 		return a
 	}
+	service.OpenTaskMediaFunc = controller.OpenTaskVideoContent
+	service.ResubmitTaskFunc = controller.ResubmitTask
+	service.DispatchQueuedTaskFunc = controller.DispatchQueuedTask
+
+	// 转存媒体文件的保留期清理
+	service.StartMediaRetentionTask()
 
 	// Channel upstream model update check task
 	controller.StartChannelUpstreamModelUpdateTask()
 
-	if common.IsMasterNode && constant.UpdateTask {
+	// 已结束的旧版 Midjourney 记录迁移到统一任务表
+	if common.IsMasterNode {
 		gopool.Go(func() {
-			controller.UpdateMidjourneyTaskBulk()
+			migrated, err := model.MigrateMidjourneyHistory()
+			if err != nil {
+				common.SysError("migrate midjourney history failed: " + err.Error())
+			} else if migrated > 0 {
+				common.SysLog(fmt.Sprintf("migrated %d midjourney tasks to the task table", migrated))
+			}
 		})
+	}
+
+	if constant.UpdateTask {
+		if common.IsMasterNode {
+			gopool.Go(func() {
+				controller.UpdateMidjourneyTaskBulk()
+			})
+		}
+		// 异步任务轮询在所有节点启动，未开启分布式轮询时仅主节点实际执行
 		gopool.Go(func() {
 			controller.UpdateTaskBulk()
 		})
@@ -286,11 +310,28 @@ func InitResources() error {
 		return err
 	}
 
+	if *common.ReencryptChannelKeys {
+		updated, failed, err := model.ReencryptChannelKeys()
+		if err != nil {
+			common.FatalLog("failed to re-encrypt channel keys: " + err.Error())
+		}
+		common.SysLog(fmt.Sprintf("channel keys re-encrypted: %d updated, %d failed", updated, failed))
+		os.Exit(0)
+	}
+
 	model.CheckSetup()
 
 	// Initialize options, should after model.InitDB()
 	model.InitOptionMap()
 
+	if *common.ApplyConfigPath != "" {
+		err = applyConfigOnStartup(*common.ApplyConfigPath)
+		if err != nil {
+			common.FatalLog("failed to apply config bundle: " + err.Error())
+			return err
+		}
+	}
+
 	// 清理旧的磁盘缓存文件
 	common.CleanupOldCacheFiles()
 
@@ -332,3 +373,31 @@ func InitResources() error {
 
 	return nil
 }
+
+// applyConfigOnStartup 启动时应用声明式配置包，多节点部署时仅由主节点执行
+func applyConfigOnStartup(path string) error {
+	if !common.IsMasterNode {
+		common.SysLog("skip applying config bundle on slave node")
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	bundle, err := service.ParseConfigBundle(data)
+	if err != nil {
+		return err
+	}
+	plan, err := service.ApplyConfigBundle(bundle, false)
+	if err != nil {
+		return err
+	}
+	for _, warning := range plan.Warnings {
+		common.SysLog("config bundle: " + warning)
+	}
+	for _, change := range plan.Changes {
+		common.SysLog(fmt.Sprintf("config bundle: %s %s %s", change.Action, change.Kind, change.Name))
+	}
+	common.SysLog(fmt.Sprintf("config bundle %s applied with %d changes", path, len(plan.Changes)))
+	return nil
+}
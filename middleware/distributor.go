@@ -51,6 +51,9 @@ func Distribute() func(c *gin.Context) {
 				abortWithOpenAiMessage(c, http.StatusForbidden, i18n.T(c, i18n.MsgDistributorChannelDisabled))
 				return
 			}
+			if !applyModelAlias(c, modelRequest) {
+				return
+			}
 		} else {
 			// Select a channel for the user
 			// check token model mapping
@@ -73,6 +76,9 @@ func Distribute() func(c *gin.Context) {
 					return
 				}
 			}
+			if !applyModelAlias(c, modelRequest) {
+				return
+			}
 
 			if shouldSelectChannel {
 				if modelRequest.Model == "" {
@@ -99,7 +105,18 @@ func Distribute() func(c *gin.Context) {
 					}
 				}
 
-				if preferredChannelID, found := service.GetPreferredChannelByAffinity(c, modelRequest.Model, usingGroup); found {
+				// 分流分支指定了渠道时优先使用，渠道不可用时回退到常规选择
+				if split := service.SelectTrafficSplitArm(c, modelRequest.Model, usingGroup); split != nil && split.ChannelId > 0 {
+					if group, ok := getTrafficSplitChannelGroup(c, usingGroup, split, modelRequest.Model); ok {
+						channel, _ = model.CacheGetChannel(split.ChannelId)
+						selectGroup = group
+						if usingGroup == "auto" {
+							common.SetContextKey(c, constant.ContextKeyAutoGroup, group)
+						}
+					}
+				}
+
+				if preferredChannelID, found := service.GetPreferredChannelByAffinity(c, modelRequest.Model, usingGroup); found && channel == nil {
 					preferred, err := model.CacheGetChannel(preferredChannelID)
 					if err == nil && preferred != nil {
 						if preferred.Status != common.ChannelStatusEnabled {
@@ -164,6 +181,25 @@ func Distribute() func(c *gin.Context) {
 	}
 }
 
+// getTrafficSplitChannelGroup 返回分流分支指定的渠道在哪个分组下可用于请求的模型或分支模型
+func getTrafficSplitChannelGroup(c *gin.Context, usingGroup string, split *service.TrafficSplitSelection, modelName string) (string, bool) {
+	channel, err := model.CacheGetChannel(split.ChannelId)
+	if err != nil || channel == nil || channel.Status != common.ChannelStatusEnabled {
+		return "", false
+	}
+	groups := []string{usingGroup}
+	if usingGroup == "auto" {
+		groups = service.GetUserAutoGroup(common.GetContextKeyString(c, constant.ContextKeyUserGroup))
+	}
+	for _, group := range groups {
+		if model.IsChannelEnabledForGroupModel(group, modelName, channel.Id) ||
+			(split.Model != "" && model.IsChannelEnabledForGroupModel(group, split.Model, channel.Id)) {
+			return group, true
+		}
+	}
+	return "", false
+}
+
 // getModelFromRequest 从请求中读取模型信息
 // 根据 Content-Type 自动处理：
 // - application/json
@@ -302,6 +338,11 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 				modelRequest.Model = req.Model
 			}
 		}
+	} else if strings.HasPrefix(c.Request.URL.Path, "/v1/images/variations") {
+		if req, err := getModelFromRequest(c); err == nil && req.Model != "" {
+			modelRequest.Model = req.Model
+		}
+		modelRequest.Model = common.GetStringIfEmpty(modelRequest.Model, "dall-e-2")
 	}
 	if strings.HasPrefix(c.Request.URL.Path, "/v1/audio") {
 		relayMode := relayconstant.RelayModeAudioSpeech
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// applyModelAlias 按全局模型别名表处理请求模型：
+// 下线前继续使用原模型并通过 Deprecation/Sunset/Warning 响应头提示，下线后重定向到后继模型或返回 410。
+// 返回 false 表示请求已被中止
+func applyModelAlias(c *gin.Context, modelRequest *ModelRequest) bool {
+	if modelRequest == nil || modelRequest.Model == "" {
+		return true
+	}
+	alias := model.GetModelAlias(modelRequest.Model)
+	if alias == nil {
+		return true
+	}
+	now := common.GetTimestamp()
+	if alias.SunsetTime > 0 {
+		c.Header("Deprecation", "true")
+		c.Header("Sunset", time.Unix(alias.SunsetTime, 0).UTC().Format(http.TimeFormat))
+	}
+	resolved, ok := alias.Resolve(now)
+	if !ok {
+		abortWithOpenAiMessage(c, http.StatusGone, i18n.T(c, i18n.MsgDistributorModelRetired, map[string]any{"Model": alias.Model, "Target": alias.Target}), types.ErrorCodeModelRetired)
+		return false
+	}
+	if resolved == alias.Model {
+		c.Header("Warning", fmt.Sprintf(`299 - "model %s is deprecated and will be retired, please migrate to %s"`, alias.Model, alias.Target))
+		return true
+	}
+	if alias.SunsetTime > 0 {
+		c.Header("Warning", fmt.Sprintf(`299 - "model %s has been retired, requests are served by %s"`, alias.Model, alias.Target))
+	}
+	common.SetContextKey(c, constant.ContextKeyModelAliasFrom, alias.Model)
+	modelRequest.Model = resolved
+	return true
+}
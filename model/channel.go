@@ -21,7 +21,7 @@ import (
 type Channel struct {
 	Id                 int     `json:"id"`
 	Type               int     `json:"type" gorm:"default:0"`
-	Key                string  `json:"key" gorm:"not null"`
+	Key                string  `json:"key" gorm:"not null;serializer:channel_key"` // 配置主密钥后加密存储，见 channel_key_encryption.go
 	OpenAIOrganization *string `json:"openai_organization"`
 	TestModel          *string `json:"test_model"`
 	Status             int     `json:"status" gorm:"default:1"`
@@ -52,6 +52,7 @@ type Channel struct {
 	ChannelInfo ChannelInfo `json:"channel_info" gorm:"type:json"`
 
 	OtherSettings string `json:"settings" gorm:"column:settings"` // 其他设置，存储azure版本等不需要检索的信息，详见dto.ChannelOtherSettings
+	KeyHash       string `json:"-" gorm:"type:varchar(64);index"` // key 的查找哈希，key 加密存储时用于精确搜索
 
 	// cache info
 	Keys []string `json:"-" gorm:"-"`
@@ -103,6 +104,9 @@ func (channel *Channel) GetKeys() []string {
 }
 
 func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
+	if channel.KeyUnavailable() {
+		return "", 0, types.NewError(fmt.Errorf("channel #%d key cannot be decrypted, check CHANNEL_KEY_ENCRYPTION_KEY", channel.Id), types.ErrorCodeChannelNoAvailableKey)
+	}
 	// If not in multi-key mode, return the original key string directly.
 	if !channel.ChannelInfo.IsMultiKey {
 		return channel.Key, 0, nil
@@ -323,11 +327,11 @@ func SearchChannels(keyword string, group string, model string, idSort bool) ([]
 			// sqlite, PostgreSQL
 			groupCondition = `(',' || ` + commonGroupCol + ` || ',') LIKE ?`
 		}
-		whereClause = "(id = ? OR name LIKE ? OR " + commonKeyCol + " = ? OR " + baseURLCol + " LIKE ?) AND " + modelsCol + ` LIKE ? AND ` + groupCondition
-		args = append(args, common.String2Int(keyword), "%"+keyword+"%", keyword, "%"+keyword+"%", "%"+model+"%", "%,"+group+",%")
+		whereClause = "(id = ? OR name LIKE ? OR " + commonKeyCol + " = ? OR key_hash = ? OR " + baseURLCol + " LIKE ?) AND " + modelsCol + ` LIKE ? AND ` + groupCondition
+		args = append(args, common.String2Int(keyword), "%"+keyword+"%", keyword, common.SecretLookupHash(keyword), "%"+keyword+"%", "%"+model+"%", "%,"+group+",%")
 	} else {
-		whereClause = "(id = ? OR name LIKE ? OR " + commonKeyCol + " = ? OR " + baseURLCol + " LIKE ?) AND " + modelsCol + " LIKE ?"
-		args = append(args, common.String2Int(keyword), "%"+keyword+"%", keyword, "%"+keyword+"%", "%"+model+"%")
+		whereClause = "(id = ? OR name LIKE ? OR " + commonKeyCol + " = ? OR key_hash = ? OR " + baseURLCol + " LIKE ?) AND " + modelsCol + " LIKE ?"
+		args = append(args, common.String2Int(keyword), "%"+keyword+"%", keyword, common.SecretLookupHash(keyword), "%"+keyword+"%", "%"+model+"%")
 	}
 
 	// 执行查询
@@ -355,6 +359,23 @@ func GetChannelById(id int, selectAll bool) (*Channel, error) {
 	return channel, nil
 }
 
+// GetChannelByIdTx 在事务中读取完整渠道（含 key）
+func GetChannelByIdTx(tx *gorm.DB, id int) (*Channel, error) {
+	channel := &Channel{}
+	if err := tx.First(channel, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return channel, nil
+}
+
+// InsertChannelTx 在事务中创建渠道及其 abilities
+func InsertChannelTx(tx *gorm.DB, channel *Channel) error {
+	if err := tx.Create(channel).Error; err != nil {
+		return err
+	}
+	return channel.AddAbilities(tx)
+}
+
 func BatchInsertChannels(channels []Channel) error {
 	if len(channels) == 0 {
 		return nil
@@ -504,6 +525,22 @@ func (channel *Channel) Update() error {
 	return err
 }
 
+// UpdateColumns 按列更新渠道（包括零值）并同步 abilities，用于声明式配置导入
+func (channel *Channel) UpdateColumns(columns ...string) error {
+	if err := DB.Model(channel).Select(columns).Updates(channel).Error; err != nil {
+		return err
+	}
+	return channel.UpdateAbilities(nil)
+}
+
+// UpdateColumnsTx 在事务中更新指定列并重建 abilities
+func (channel *Channel) UpdateColumnsTx(tx *gorm.DB, columns ...string) error {
+	if err := tx.Model(channel).Select(columns).Updates(channel).Error; err != nil {
+		return err
+	}
+	return channel.UpdateAbilities(tx)
+}
+
 func (channel *Channel) UpdateResponseTime(responseTime int64) {
 	err := DB.Model(channel).Select("response_time", "test_time").Updates(Channel{
 		TestTime:     common.GetTimestamp(),
@@ -531,7 +568,21 @@ func (channel *Channel) Delete() error {
 		return err
 	}
 	err = channel.DeleteAbilities()
-	return err
+	if err != nil {
+		return err
+	}
+	return DeleteChannelKeyStats(channel.Id)
+}
+
+// DeleteTx 在事务中删除渠道及其 abilities 与多 Key 统计
+func (channel *Channel) DeleteTx(tx *gorm.DB) error {
+	if err := tx.Delete(channel).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("channel_id = ?", channel.Id).Delete(&Ability{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("channel_id = ?", channel.Id).Delete(&ChannelKeyStat{}).Error
 }
 
 var channelStatusLock sync.Mutex
@@ -570,6 +621,22 @@ func CleanupChannelPollingLocks() {
 	})
 }
 
+const multiKeyAllDisabledReason = "All keys are disabled"
+
+// isMultiKeyStatusUnchanged 判断 key 是否已处于目标状态
+func isMultiKeyStatusUnchanged(channel *Channel, usingKey string, status int) bool {
+	for i, key := range channel.GetKeys() {
+		if key == usingKey {
+			current, ok := channel.ChannelInfo.MultiKeyStatusList[i]
+			if !ok {
+				current = common.ChannelStatusEnabled
+			}
+			return current == status
+		}
+	}
+	return false
+}
+
 func handlerMultiKeyUpdate(channel *Channel, usingKey string, status int, reason string) {
 	keys := channel.GetKeys()
 	if len(keys) == 0 {
@@ -587,6 +654,10 @@ func handlerMultiKeyUpdate(channel *Channel, usingKey string, status int, reason
 		}
 		if status == common.ChannelStatusEnabled {
 			delete(channel.ChannelInfo.MultiKeyStatusList, keyIndex)
+			// 因所有 key 被禁用而自动禁用的渠道，在有 key 恢复后重新启用
+			if channel.Status == common.ChannelStatusAutoDisabled && channel.GetOtherInfo()["status_reason"] == multiKeyAllDisabledReason {
+				channel.Status = common.ChannelStatusEnabled
+			}
 		} else {
 			channel.ChannelInfo.MultiKeyStatusList[keyIndex] = status
 			if channel.ChannelInfo.MultiKeyDisabledReason == nil {
@@ -601,7 +672,7 @@ func handlerMultiKeyUpdate(channel *Channel, usingKey string, status int, reason
 		if len(channel.ChannelInfo.MultiKeyStatusList) >= channel.ChannelInfo.MultiKeySize {
 			channel.Status = common.ChannelStatusAutoDisabled
 			info := channel.GetOtherInfo()
-			info["status_reason"] = "All keys are disabled"
+			info["status_reason"] = multiKeyAllDisabledReason
 			info["status_time"] = common.GetTimestamp()
 			channel.SetOtherInfo(info)
 		}
@@ -648,11 +719,11 @@ func UpdateChannelStatus(channelId int, usingKey string, status int, reason stri
 	if err != nil {
 		return false
 	} else {
-		if channel.Status == status {
-			return false
-		}
-
 		if channel.ChannelInfo.IsMultiKey {
+			// 多 Key 渠道按 key 判断，渠道启用时仍可重新启用单个 key
+			if isMultiKeyStatusUnchanged(channel, usingKey, status) {
+				return false
+			}
 			beforeStatus := channel.Status
 			// Protect map writes with the same per-channel lock used by readers
 			pollingLock := GetChannelPollingLock(channelId)
@@ -663,6 +734,9 @@ func UpdateChannelStatus(channelId int, usingKey string, status int, reason stri
 				shouldUpdateAbilities = true
 			}
 		} else {
+			if channel.Status == status {
+				return false
+			}
 			info := channel.GetOtherInfo()
 			info["status_reason"] = reason
 			info["status_time"] = common.GetTimestamp()
@@ -818,11 +892,11 @@ func SearchTags(keyword string, group string, model string, idSort bool) ([]*str
 			// sqlite, PostgreSQL
 			groupCondition = `(',' || ` + commonGroupCol + ` || ',') LIKE ?`
 		}
-		whereClause = "(id = ? OR name LIKE ? OR " + commonKeyCol + " = ? OR " + baseURLCol + " LIKE ?) AND " + modelsCol + ` LIKE ? AND ` + groupCondition
-		args = append(args, common.String2Int(keyword), "%"+keyword+"%", keyword, "%"+keyword+"%", "%"+model+"%", "%,"+group+",%")
+		whereClause = "(id = ? OR name LIKE ? OR " + commonKeyCol + " = ? OR key_hash = ? OR " + baseURLCol + " LIKE ?) AND " + modelsCol + ` LIKE ? AND ` + groupCondition
+		args = append(args, common.String2Int(keyword), "%"+keyword+"%", keyword, common.SecretLookupHash(keyword), "%"+keyword+"%", "%"+model+"%", "%,"+group+",%")
 	} else {
-		whereClause = "(id = ? OR name LIKE ? OR " + commonKeyCol + " = ? OR " + baseURLCol + " LIKE ?) AND " + modelsCol + " LIKE ?"
-		args = append(args, common.String2Int(keyword), "%"+keyword+"%", keyword, "%"+keyword+"%", "%"+model+"%")
+		whereClause = "(id = ? OR name LIKE ? OR " + commonKeyCol + " = ? OR key_hash = ? OR " + baseURLCol + " LIKE ?) AND " + modelsCol + " LIKE ?"
+		args = append(args, common.String2Int(keyword), "%"+keyword+"%", keyword, common.SecretLookupHash(keyword), "%"+keyword+"%", "%"+model+"%")
 	}
 
 	subQuery := baseQuery.Where(whereClause, args...).
@@ -1006,3 +1080,17 @@ func CountChannelsGroupByType() (map[int64]int64, error) {
 	}
 	return counts, nil
 }
+
+// UpdateChannelPriorityAndOtherSettings 更新渠道优先级与其他设置，并同步 abilities 中的优先级
+func UpdateChannelPriorityAndOtherSettings(channelId int, priority int64, otherSettings string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Channel{}).Where("id = ?", channelId).Updates(map[string]interface{}{
+			"priority": priority,
+			"settings": otherSettings,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&Ability{}).Where("channel_id = ?", channelId).Update("priority", priority).Error
+	})
+}
@@ -35,6 +35,7 @@ func InitChannelCache() {
 		groups[ability.Group] = true
 	}
 	newGroup2model2channels := make(map[string]map[string][]int)
+	newChannelSchedules := make(map[int]*common.ActiveSchedule)
 	for group := range groups {
 		newGroup2model2channels[group] = make(map[string][]int)
 	}
@@ -42,6 +43,9 @@ func InitChannelCache() {
 		if channel.Status != common.ChannelStatusEnabled {
 			continue // skip disabled channels
 		}
+		if schedule := channel.getActiveSchedule(); schedule != nil {
+			newChannelSchedules[channel.Id] = schedule
+		}
 		groups := strings.Split(channel.Group, ",")
 		for _, group := range groups {
 			models := strings.Split(channel.Models, ",")
@@ -66,6 +70,7 @@ func InitChannelCache() {
 
 	channelSyncLock.Lock()
 	group2model2channels = newGroup2model2channels
+	channelSchedules = newChannelSchedules
 	//channelsIDM = newChannelId2channel
 	for i, channel := range newChannelId2channel {
 		if channel.ChannelInfo.IsMultiKey {
@@ -111,6 +116,9 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 		channels = group2model2channels[group][normalizedModel]
 	}
 
+	// 过滤不在生效时间内的渠道
+	channels = filterActiveChannelIds(channels, time.Now())
+
 	if len(channels) == 0 {
 		return nil, nil
 	}
@@ -0,0 +1,88 @@
+package model
+
+// ChannelCanaryResult 一次金丝雀用例的校验结果
+type ChannelCanaryResult struct {
+	Id        int    `json:"id" gorm:"primaryKey;autoIncrement"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint;index"`
+	ChannelId int    `json:"channel_id" gorm:"index"`
+	ModelName string `json:"model_name" gorm:"type:varchar(255);index"`
+	CaseName  string `json:"case_name" gorm:"type:varchar(255)"`
+	IsStream  bool   `json:"is_stream"`
+	Passed    bool   `json:"passed"`
+	// Reachable 为 false 表示请求本身失败（连通性问题），为 true 且 Passed 为 false 表示输出校验失败
+	Reachable bool   `json:"reachable"`
+	Latency   int64  `json:"latency"` // 毫秒
+	Error     string `json:"error" gorm:"type:text"`
+}
+
+func (ChannelCanaryResult) TableName() string {
+	return "channel_canary_results"
+}
+
+// ChannelCanaryPassRate 按渠道与模型汇总的通过率
+type ChannelCanaryPassRate struct {
+	ChannelId  int     `json:"channel_id"`
+	ModelName  string  `json:"model_name"`
+	Total      int64   `json:"total"`
+	Passed     int64   `json:"passed"`
+	Unreached  int64   `json:"unreached"`
+	PassRate   float64 `json:"pass_rate"`
+	AvgLatency float64 `json:"avg_latency"`
+	LastRunAt  int64   `json:"last_run_at"`
+}
+
+func RecordChannelCanaryResults(results []*ChannelCanaryResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+	return DB.Create(&results).Error
+}
+
+// GetChannelCanaryPassRates 汇总通过率，channelId 为 0 时不过滤渠道
+func GetChannelCanaryPassRates(channelId int, startTimestamp int64, endTimestamp int64) ([]*ChannelCanaryPassRate, error) {
+	var rates []*ChannelCanaryPassRate
+	tx := DB.Model(&ChannelCanaryResult{}).Select(
+		"channel_id, model_name, COUNT(*) AS total, "+
+			"SUM(CASE WHEN passed = ? THEN 1 ELSE 0 END) AS passed, "+
+			"SUM(CASE WHEN reachable = ? THEN 0 ELSE 1 END) AS unreached, "+
+			"AVG(latency) AS avg_latency, MAX(created_at) AS last_run_at",
+		true, true,
+	)
+	if channelId > 0 {
+		tx = tx.Where("channel_id = ?", channelId)
+	}
+	if startTimestamp > 0 {
+		tx = tx.Where("created_at >= ?", startTimestamp)
+	}
+	if endTimestamp > 0 {
+		tx = tx.Where("created_at <= ?", endTimestamp)
+	}
+	err := tx.Group("channel_id, model_name").Order("channel_id, model_name").Scan(&rates).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, rate := range rates {
+		if rate.Total > 0 {
+			rate.PassRate = float64(rate.Passed) / float64(rate.Total)
+		}
+	}
+	return rates, nil
+}
+
+func GetChannelCanaryResults(channelId int, startIdx int, num int) (results []*ChannelCanaryResult, total int64, err error) {
+	tx := DB.Model(&ChannelCanaryResult{})
+	if channelId > 0 {
+		tx = tx.Where("channel_id = ?", channelId)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&results).Error
+	return results, total, err
+}
+
+// DeleteChannelCanaryResultsBefore 清除早于指定时间的校验记录
+func DeleteChannelCanaryResultsBefore(timestamp int64) (int64, error) {
+	result := DB.Where("created_at < ?", timestamp).Delete(&ChannelCanaryResult{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetChannelCanaryPassRates(t *testing.T) {
+	truncateTables(t)
+
+	require.NoError(t, RecordChannelCanaryResults([]*ChannelCanaryResult{
+		{CreatedAt: 100, ChannelId: 1, ModelName: "gpt-4o", Passed: true, Reachable: true, Latency: 100},
+		{CreatedAt: 200, ChannelId: 1, ModelName: "gpt-4o", Passed: false, Reachable: true, Latency: 300},
+		{CreatedAt: 300, ChannelId: 1, ModelName: "gpt-4o", Passed: false, Reachable: false, Latency: 200},
+		{CreatedAt: 300, ChannelId: 2, ModelName: "gpt-4o", Passed: true, Reachable: true, Latency: 50},
+	}))
+
+	rates, err := GetChannelCanaryPassRates(1, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, rates, 1)
+	require.Equal(t, int64(3), rates[0].Total)
+	require.Equal(t, int64(1), rates[0].Passed)
+	require.Equal(t, int64(1), rates[0].Unreached)
+	require.InDelta(t, 1.0/3, rates[0].PassRate, 1e-9)
+	require.InDelta(t, 200, rates[0].AvgLatency, 1e-9)
+	require.Equal(t, int64(300), rates[0].LastRunAt)
+
+	deleted, err := DeleteChannelCanaryResultsBefore(250)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), deleted)
+
+	results, total, err := GetChannelCanaryResults(0, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), total)
+	require.Len(t, results, 2)
+}
@@ -0,0 +1,317 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const channelKeyReencryptBatchSize = 200
+
+func init() {
+	schema.RegisterSerializer("channel_key", channelKeySerializer{})
+}
+
+// channelKeySerializer 写入时按当前主密钥加密 channels.key，读取时透明解密，明文行原样读取
+type channelKeySerializer struct{}
+
+func (channelKeySerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var stored string
+	switch v := dbValue.(type) {
+	case nil:
+	case []byte:
+		stored = string(v)
+	case string:
+		stored = v
+	default:
+		return fmt.Errorf("unsupported channel key type %T", dbValue)
+	}
+	plaintext, err := common.DecryptSecret(stored)
+	if err != nil {
+		// 单个渠道无法解密时不影响其他渠道加载：保留原密文，渠道因 key 不可用而无法被选中，
+		// 写回时 EncryptSecret 会拒绝无法校验的密文，不会覆盖数据库中的原值
+		common.SysError("failed to decrypt channel key: " + err.Error())
+		plaintext = stored
+	}
+	field.ReflectValueOf(ctx, dst).SetString(plaintext)
+	return nil
+}
+
+func (channelKeySerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	plaintext, _ := fieldValue.(string)
+	return common.EncryptSecret(plaintext)
+}
+
+// BeforeSave 维护 key 的查找哈希，使密文存储下仍可按完整 key 搜索渠道，并加密设置中的余额查询凭证
+func (channel *Channel) BeforeSave(tx *gorm.DB) error {
+	if err := channel.encryptBalanceCredential(); err != nil {
+		return err
+	}
+	if channel.Key == "" {
+		return nil
+	}
+	plaintext, err := common.DecryptSecret(channel.Key)
+	if err != nil {
+		return fmt.Errorf("channel key cannot be decrypted: %w", err)
+	}
+	channel.KeyHash = common.SecretLookupHash(plaintext)
+	return nil
+}
+
+// BalanceCredentialMask 渠道接口返回的余额查询凭证占位值，编辑时原样提交表示不修改
+const BalanceCredentialMask = "********"
+
+const balanceCredentialSetting = "balance_credential"
+
+// encryptBalanceCredential settings 中的 balance_credential（如火山引擎 AccessKey|SecretKey）与 key 一样加密存储，
+// 读取后仍保持密文，仅在查询余额时通过 GetBalanceCredential 解密
+func (channel *Channel) encryptBalanceCredential() error {
+	settings, credential := channel.balanceCredentialSettings()
+	if credential == "" || common.IsEncryptedSecret(credential) {
+		return nil
+	}
+	encrypted, err := common.EncryptSecret(credential)
+	if err != nil {
+		return err
+	}
+	if encrypted == credential {
+		return nil
+	}
+	return channel.setBalanceCredential(settings, encrypted)
+}
+
+func (channel *Channel) balanceCredentialSettings() (map[string]interface{}, string) {
+	if channel.OtherSettings == "" {
+		return nil, ""
+	}
+	var settings map[string]interface{}
+	if err := common.UnmarshalJsonStr(channel.OtherSettings, &settings); err != nil {
+		return nil, ""
+	}
+	credential, _ := settings[balanceCredentialSetting].(string)
+	return settings, credential
+}
+
+func (channel *Channel) setBalanceCredential(settings map[string]interface{}, credential string) error {
+	settings[balanceCredentialSetting] = credential
+	data, err := common.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	channel.OtherSettings = string(data)
+	return nil
+}
+
+// GetBalanceCredential 解密余额查询凭证，未加密的存量值原样返回
+func (channel *Channel) GetBalanceCredential() (string, error) {
+	_, credential := channel.balanceCredentialSettings()
+	return common.DecryptSecret(credential)
+}
+
+// MaskBalanceCredential 将余额查询凭证替换为占位值，用于渠道接口响应
+func (channel *Channel) MaskBalanceCredential() {
+	if settings, credential := channel.balanceCredentialSettings(); credential != "" {
+		_ = channel.setBalanceCredential(settings, BalanceCredentialMask)
+	}
+}
+
+// KeepBalanceCredential 编辑渠道时提交的是占位值，沿用 origin 中已保存的凭证
+func (channel *Channel) KeepBalanceCredential(origin *Channel) {
+	settings, credential := channel.balanceCredentialSettings()
+	if credential != BalanceCredentialMask {
+		return
+	}
+	_, originCredential := origin.balanceCredentialSettings()
+	if originCredential == "" {
+		delete(settings, balanceCredentialSetting)
+		data, err := common.Marshal(settings)
+		if err == nil {
+			channel.OtherSettings = string(data)
+		}
+		return
+	}
+	_ = channel.setBalanceCredential(settings, originCredential)
+}
+
+// KeyUnavailable key 仍为密文，说明读取时解密失败（主密钥缺失或数据损坏），渠道不可用
+func (channel *Channel) KeyUnavailable() bool {
+	return common.IsEncryptedSecret(channel.Key)
+}
+
+// UpdateChannelKey 仅更新渠道 key，按列更新不会经过序列化器，因此在这里加密
+func UpdateChannelKey(channelId int, key string) error {
+	encrypted, err := common.EncryptSecret(key)
+	if err != nil {
+		return err
+	}
+	return DB.Model(&Channel{}).Where("id = ?", channelId).Updates(map[string]interface{}{
+		"key":      encrypted,
+		"key_hash": common.SecretLookupHash(key),
+	}).Error
+}
+
+// channelKeyRow 读取 channels.key 的原始存储值，不经过解密
+type channelKeyRow struct {
+	Id      int
+	Key     string
+	KeyHash string
+}
+
+func (channelKeyRow) TableName() string {
+	return "channels"
+}
+
+type ChannelKeyEncryptionStatus struct {
+	Enabled     bool           `json:"enabled"`
+	MasterKeyId string         `json:"master_key_id"`
+	Total       int            `json:"total"`
+	Plaintext   int            `json:"plaintext"`
+	Current     int            `json:"current"`
+	ByKeyId     map[string]int `json:"by_key_id"`
+}
+
+func iterateChannelKeyRows(fn func(row *channelKeyRow) error) error {
+	lastId := 0
+	for {
+		var rows []*channelKeyRow
+		err := DB.Model(&channelKeyRow{}).Select("id", "key", "key_hash").
+			Where("id > ?", lastId).Order("id").Limit(channelKeyReencryptBatchSize).Find(&rows).Error
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+		if len(rows) < channelKeyReencryptBatchSize {
+			return nil
+		}
+		lastId = rows[len(rows)-1].Id
+	}
+}
+
+// GetChannelKeyEncryptionStatus 统计渠道 key 的加密状态
+func GetChannelKeyEncryptionStatus() (*ChannelKeyEncryptionStatus, error) {
+	status := &ChannelKeyEncryptionStatus{
+		Enabled:     common.SecretEncryptionEnabled(),
+		MasterKeyId: common.SecretMasterKeyId(),
+		ByKeyId:     make(map[string]int),
+	}
+	err := iterateChannelKeyRows(func(row *channelKeyRow) error {
+		if row.Key == "" {
+			return nil
+		}
+		status.Total++
+		keyId := common.EncryptedSecretKeyId(row.Key)
+		switch {
+		case keyId == "":
+			status.Plaintext++
+		case keyId == status.MasterKeyId:
+			status.Current++
+			status.ByKeyId[keyId]++
+		default:
+			status.ByKeyId[keyId]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// ReencryptChannelKeys 将所有渠道 key 按当前主密钥重新加密（明文加密、旧主密钥轮换，未启用加密时解密为明文），
+// 并重新计算随主密钥变化的查找哈希。按 id 分批处理，更新条件带上原值，与并发的渠道编辑互不覆盖，可在服务运行期间执行
+func ReencryptChannelKeys() (updated int, failed int, err error) {
+	err = iterateChannelKeyRows(func(row *channelKeyRow) error {
+		if row.Key == "" {
+			return nil
+		}
+		plaintext, decryptErr := common.DecryptSecret(row.Key)
+		if decryptErr != nil {
+			common.SysError(fmt.Sprintf("failed to decrypt key of channel #%d: %s", row.Id, decryptErr.Error()))
+			failed++
+			return nil
+		}
+		keyHash := common.SecretLookupHash(plaintext)
+		if !common.SecretNeedsReencrypt(row.Key) && row.KeyHash == keyHash {
+			return nil
+		}
+		encrypted := row.Key
+		if common.SecretNeedsReencrypt(row.Key) {
+			var encryptErr error
+			if encrypted, encryptErr = common.EncryptSecret(plaintext); encryptErr != nil {
+				return encryptErr
+			}
+		}
+		result := DB.Model(&channelKeyRow{}).Where("id = ? AND "+commonKeyCol+" = ?", row.Id, row.Key).Updates(map[string]interface{}{
+			"key":      encrypted,
+			"key_hash": keyHash,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			updated++
+			return migrateChannelKeyStatHashes(row.Id, plaintext)
+		}
+		return nil
+	})
+	return updated, failed, err
+}
+
+// migrateChannelKeyStatHashes 查找哈希随主密钥变化后，将多 Key 统计记录迁移到新哈希下
+func migrateChannelKeyStatHashes(channelId int, plaintext string) error {
+	for _, key := range (&Channel{Key: plaintext}).GetKeys() {
+		keyHash := common.SecretLookupHash(key)
+		var stale []string
+		for _, candidate := range common.SecretLookupHashCandidates(key) {
+			if candidate != keyHash {
+				stale = append(stale, candidate)
+			}
+		}
+		if len(stale) == 0 {
+			continue
+		}
+		err := DB.Model(&ChannelKeyStat{}).Where("channel_id = ? AND key_hash IN ?", channelId, stale).
+			Update("key_hash", keyHash).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateChannelKeyEncryption 启动时在后台加密存量明文 key，迁移期间明文与密文行均可正常读取
+func MigrateChannelKeyEncryption() {
+	if !common.IsMasterNode {
+		return
+	}
+	status, err := GetChannelKeyEncryptionStatus()
+	if err != nil {
+		common.SysError("failed to check channel key encryption status: " + err.Error())
+		return
+	}
+	if !status.Enabled && status.Plaintext < status.Total {
+		// 存在密文但未配置主密钥，不自动解密，需要管理员显式执行轮换
+		common.SysError("encrypted channel keys found but CHANNEL_KEY_ENCRYPTION_KEY is not set")
+		return
+	}
+	if !status.Enabled {
+		return
+	}
+	updated, failed, err := ReencryptChannelKeys()
+	if err != nil {
+		common.SysError("failed to encrypt channel keys: " + err.Error())
+		return
+	}
+	if updated > 0 || failed > 0 {
+		common.SysLog(fmt.Sprintf("channel key encryption migrated: %d updated, %d failed", updated, failed))
+	}
+}
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelKeyEncryptedAtRest(t *testing.T) {
+	initCol()
+	require.NoError(t, common.SetSecretMasterKeys("test-master-key"))
+	t.Cleanup(func() { _ = common.SetSecretMasterKeys("") })
+
+	channel := &Channel{Name: "encrypted-key-channel", Key: "sk-encrypted-at-rest"}
+	require.NoError(t, DB.Create(channel).Error)
+	t.Cleanup(func() { DB.Delete(&Channel{}, channel.Id) })
+
+	var row channelKeyRow
+	require.NoError(t, DB.Model(&channelKeyRow{}).Where("id = ?", channel.Id).First(&row).Error)
+	require.True(t, common.IsEncryptedSecret(row.Key))
+	require.Equal(t, common.SecretLookupHash("sk-encrypted-at-rest"), row.KeyHash)
+
+	loaded, err := GetChannelById(channel.Id, true)
+	require.NoError(t, err)
+	require.Equal(t, "sk-encrypted-at-rest", loaded.Key)
+
+	// 存量明文行可正常读取，轮换后被加密
+	require.NoError(t, DB.Model(&channelKeyRow{}).Where("id = ?", channel.Id).Updates(map[string]interface{}{"key": "sk-legacy", "key_hash": ""}).Error)
+	loaded, err = GetChannelById(channel.Id, true)
+	require.NoError(t, err)
+	require.Equal(t, "sk-legacy", loaded.Key)
+
+	require.NoError(t, common.SetSecretMasterKeys("rotated-master-key", "test-master-key"))
+	updated, failed, err := ReencryptChannelKeys()
+	require.NoError(t, err)
+	require.Equal(t, 0, failed)
+	require.GreaterOrEqual(t, updated, 1)
+	require.NoError(t, DB.Model(&channelKeyRow{}).Where("id = ?", channel.Id).First(&row).Error)
+	require.Equal(t, common.SecretMasterKeyId(), common.EncryptedSecretKeyId(row.Key))
+
+	loaded, err = GetChannelById(channel.Id, true)
+	require.NoError(t, err)
+	require.Equal(t, "sk-legacy", loaded.Key)
+}
+
+func TestChannelKeyDecryptFailureKeepsCiphertext(t *testing.T) {
+	initCol()
+	require.NoError(t, common.SetSecretMasterKeys("lost-master-key"))
+	t.Cleanup(func() { _ = common.SetSecretMasterKeys("") })
+
+	channel := &Channel{Name: "undecryptable-key-channel", Key: "sk-undecryptable"}
+	require.NoError(t, DB.Create(channel).Error)
+	t.Cleanup(func() { DB.Delete(&Channel{}, channel.Id) })
+	var before channelKeyRow
+	require.NoError(t, DB.Model(&channelKeyRow{}).Where("id = ?", channel.Id).First(&before).Error)
+
+	// 主密钥丢失：读取时保留密文，渠道不可用
+	require.NoError(t, common.SetSecretMasterKeys("other-master-key"))
+	loaded, err := GetChannelById(channel.Id, true)
+	require.NoError(t, err)
+	require.Equal(t, before.Key, loaded.Key)
+	require.True(t, loaded.KeyUnavailable())
+	_, _, apiErr := loaded.GetNextEnabledKey()
+	require.NotNil(t, apiErr)
+
+	// 写回时拒绝，数据库中的密文不会被覆盖或置空
+	loaded.Remark = common.GetPointer("edited")
+	require.Error(t, loaded.UpdateColumns("remark", "key", "key_hash"))
+	var after channelKeyRow
+	require.NoError(t, DB.Model(&channelKeyRow{}).Where("id = ?", channel.Id).First(&after).Error)
+	require.Equal(t, before.Key, after.Key)
+	require.Equal(t, before.KeyHash, after.KeyHash)
+}
+
+func TestChannelBalanceCredentialEncryptedAtRest(t *testing.T) {
+	initCol()
+	require.NoError(t, common.SetSecretMasterKeys("test-master-key"))
+	t.Cleanup(func() { _ = common.SetSecretMasterKeys("") })
+
+	channel := &Channel{Name: "volc-balance-channel", Key: "sk-volc", OtherSettings: `{"balance_credential":"AK|SK","balance_api":"volcengine"}`}
+	require.NoError(t, DB.Create(channel).Error)
+	t.Cleanup(func() { DB.Delete(&Channel{}, channel.Id) })
+
+	var stored string
+	require.NoError(t, DB.Model(&Channel{}).Select("settings").Where("id = ?", channel.Id).Scan(&stored).Error)
+	require.NotContains(t, stored, "AK|SK")
+	require.Contains(t, stored, `"balance_api":"volcengine"`)
+
+	loaded, err := GetChannelById(channel.Id, true)
+	require.NoError(t, err)
+	credential, err := loaded.GetBalanceCredential()
+	require.NoError(t, err)
+	require.Equal(t, "AK|SK", credential)
+
+	// 接口响应中为占位值，原样提交时沿用已保存的凭证
+	loaded.MaskBalanceCredential()
+	require.Equal(t, BalanceCredentialMask, loaded.GetOtherSettings().BalanceCredential)
+	origin, err := GetChannelById(channel.Id, true)
+	require.NoError(t, err)
+	loaded.KeepBalanceCredential(origin)
+	require.NoError(t, DB.Model(loaded).Updates(loaded).Error)
+	loaded, err = GetChannelById(channel.Id, true)
+	require.NoError(t, err)
+	credential, err = loaded.GetBalanceCredential()
+	require.NoError(t, err)
+	require.Equal(t, "AK|SK", credential)
+
+	// 通过 Updates 写入的新明文凭证同样被加密
+	loaded.OtherSettings = `{"balance_credential":"AK2|SK2"}`
+	require.NoError(t, DB.Model(loaded).Updates(loaded).Error)
+	require.NoError(t, DB.Model(&Channel{}).Select("settings").Where("id = ?", channel.Id).Scan(&stored).Error)
+	require.NotContains(t, stored, "AK2|SK2")
+	loaded, err = GetChannelById(channel.Id, true)
+	require.NoError(t, err)
+	credential, err = loaded.GetBalanceCredential()
+	require.NoError(t, err)
+	require.Equal(t, "AK2|SK2", credential)
+}
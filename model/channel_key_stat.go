@@ -0,0 +1,212 @@
+package model
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+// channelKeyErrorHistorySize 每个 key 保留的最近错误条数
+const channelKeyErrorHistorySize = 10
+
+const (
+	ChannelKeyErrorSourceRelay = "relay"
+	ChannelKeyErrorSourceTest  = "test"
+)
+
+type ChannelKeyError struct {
+	Time    int64  `json:"time"`
+	Source  string `json:"source"` // relay / test
+	Message string `json:"message"`
+}
+
+type ChannelKeyErrors []ChannelKeyError
+
+// Value implements driver.Valuer interface
+func (e ChannelKeyErrors) Value() (driver.Value, error) {
+	data, err := common.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner interface
+func (e *ChannelKeyErrors) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	}
+	if len(data) == 0 {
+		*e = nil
+		return nil
+	}
+	return common.Unmarshal(data, e)
+}
+
+// ChannelKeyStat 多 Key 渠道中单个 key 的用量、最近错误与最近一次测试结果。
+// 以 key 的哈希而不是索引标识，删除 key 导致索引变化后记录仍能对应
+type ChannelKeyStat struct {
+	ChannelId       int              `json:"channel_id" gorm:"primaryKey;autoIncrement:false"`
+	KeyHash         string           `json:"-" gorm:"primaryKey;type:varchar(64)"`
+	RequestCount    int              `json:"request_count" gorm:"default:0"`
+	UsedQuota       int64            `json:"used_quota" gorm:"bigint;default:0"`
+	ErrorCount      int              `json:"error_count" gorm:"default:0"`
+	LastUsedTime    int64            `json:"last_used_time" gorm:"bigint"`
+	RecentErrors    ChannelKeyErrors `json:"recent_errors" gorm:"type:text"`
+	LastTestTime    int64            `json:"last_test_time" gorm:"bigint"`
+	LastTestLatency int64            `json:"last_test_latency"` // 毫秒
+	LastTestError   string           `json:"last_test_error" gorm:"type:text"`
+}
+
+func (ChannelKeyStat) TableName() string {
+	return "channel_key_stats"
+}
+
+type channelKeyStatId struct {
+	channelId int
+	keyHash   string
+}
+
+// 请求用量与错误先在内存中累计，由 UpdateChannelKeyStats 定期写入数据库
+var channelKeyStatCache = make(map[channelKeyStatId]*ChannelKeyStat)
+var channelKeyStatCacheLock sync.Mutex
+
+func getChannelKeyStatCache(channelId int, key string) *ChannelKeyStat {
+	id := channelKeyStatId{channelId: channelId, keyHash: common.SecretLookupHash(key)}
+	stat, ok := channelKeyStatCache[id]
+	if !ok {
+		stat = &ChannelKeyStat{ChannelId: id.channelId, KeyHash: id.keyHash}
+		channelKeyStatCache[id] = stat
+	}
+	return stat
+}
+
+func appendChannelKeyErrors(errs ChannelKeyErrors, more ...ChannelKeyError) ChannelKeyErrors {
+	errs = append(errs, more...)
+	if len(errs) > channelKeyErrorHistorySize {
+		errs = errs[len(errs)-channelKeyErrorHistorySize:]
+	}
+	return errs
+}
+
+// RecordChannelKeyUsage 记录多 Key 渠道中某个 key 的一次请求
+func RecordChannelKeyUsage(channelId int, key string, quota int) {
+	if key == "" {
+		return
+	}
+	channelKeyStatCacheLock.Lock()
+	defer channelKeyStatCacheLock.Unlock()
+	stat := getChannelKeyStatCache(channelId, key)
+	stat.RequestCount++
+	stat.UsedQuota += int64(quota)
+	stat.LastUsedTime = common.GetTimestamp()
+}
+
+// RecordChannelKeyError 记录多 Key 渠道中某个 key 的一次上游错误
+func RecordChannelKeyError(channelId int, key string, message string) {
+	if key == "" {
+		return
+	}
+	channelKeyStatCacheLock.Lock()
+	defer channelKeyStatCacheLock.Unlock()
+	stat := getChannelKeyStatCache(channelId, key)
+	stat.ErrorCount++
+	stat.RecentErrors = appendChannelKeyErrors(stat.RecentErrors, ChannelKeyError{
+		Time:    common.GetTimestamp(),
+		Source:  ChannelKeyErrorSourceRelay,
+		Message: message,
+	})
+}
+
+// RecordChannelKeyTest 立即保存某个 key 的测试结果，message 为空表示测试通过
+func RecordChannelKeyTest(channelId int, key string, latency int64, message string) error {
+	now := common.GetTimestamp()
+	stat := &ChannelKeyStat{
+		ChannelId:       channelId,
+		KeyHash:         common.SecretLookupHash(key),
+		LastTestTime:    now,
+		LastTestLatency: latency,
+		LastTestError:   message,
+	}
+	if message != "" {
+		stat.RecentErrors = ChannelKeyErrors{{Time: now, Source: ChannelKeyErrorSourceTest, Message: message}}
+	}
+	return saveChannelKeyStat(stat)
+}
+
+// saveChannelKeyStat 将增量合并到数据库中已有的记录
+func saveChannelKeyStat(delta *ChannelKeyStat) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		existing := &ChannelKeyStat{}
+		err := tx.Where("channel_id = ? AND key_hash = ?", delta.ChannelId, delta.KeyHash).First(existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tx.Create(delta).Error
+		}
+		if err != nil {
+			return err
+		}
+		updates := map[string]interface{}{
+			"request_count": gorm.Expr("request_count + ?", delta.RequestCount),
+			"used_quota":    gorm.Expr("used_quota + ?", delta.UsedQuota),
+			"error_count":   gorm.Expr("error_count + ?", delta.ErrorCount),
+		}
+		if delta.LastUsedTime > 0 {
+			updates["last_used_time"] = delta.LastUsedTime
+		}
+		if len(delta.RecentErrors) > 0 {
+			updates["recent_errors"] = appendChannelKeyErrors(existing.RecentErrors, delta.RecentErrors...)
+		}
+		if delta.LastTestTime > 0 {
+			updates["last_test_time"] = delta.LastTestTime
+			updates["last_test_latency"] = delta.LastTestLatency
+			updates["last_test_error"] = delta.LastTestError
+		}
+		return tx.Model(&ChannelKeyStat{}).Where("channel_id = ? AND key_hash = ?", delta.ChannelId, delta.KeyHash).Updates(updates).Error
+	})
+}
+
+func SaveChannelKeyStatCache() {
+	channelKeyStatCacheLock.Lock()
+	cache := channelKeyStatCache
+	channelKeyStatCache = make(map[channelKeyStatId]*ChannelKeyStat)
+	channelKeyStatCacheLock.Unlock()
+	for _, stat := range cache {
+		if err := saveChannelKeyStat(stat); err != nil {
+			common.SysError(fmt.Sprintf("failed to save key stat of channel #%d: %s", stat.ChannelId, err.Error()))
+		}
+	}
+}
+
+func UpdateChannelKeyStats() {
+	for {
+		time.Sleep(time.Minute)
+		SaveChannelKeyStatCache()
+	}
+}
+
+// GetChannelKeyStats 返回渠道各 key 的统计，key hash -> stat
+func GetChannelKeyStats(channelId int) (map[string]*ChannelKeyStat, error) {
+	var stats []*ChannelKeyStat
+	if err := DB.Where("channel_id = ?", channelId).Find(&stats).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[string]*ChannelKeyStat, len(stats))
+	for _, stat := range stats {
+		result[stat.KeyHash] = stat
+	}
+	return result, nil
+}
+
+func DeleteChannelKeyStats(channelId int) error {
+	return DB.Where("channel_id = ?", channelId).Delete(&ChannelKeyStat{}).Error
+}
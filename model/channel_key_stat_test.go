@@ -0,0 +1,81 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelKeyStats(t *testing.T) {
+	truncateTables(t)
+
+	RecordChannelKeyUsage(1, "sk-a", 100)
+	RecordChannelKeyUsage(1, "sk-a", 50)
+	RecordChannelKeyError(1, "sk-b", "status_code=401, invalid key")
+	SaveChannelKeyStatCache()
+	RecordChannelKeyUsage(1, "sk-a", 10)
+	SaveChannelKeyStatCache()
+	for i := 0; i < channelKeyErrorHistorySize; i++ {
+		require.NoError(t, RecordChannelKeyTest(1, "sk-b", 20, fmt.Sprintf("error %d", i)))
+	}
+
+	stats, err := GetChannelKeyStats(1)
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+
+	a := stats[common.SecretLookupHash("sk-a")]
+	require.NotNil(t, a)
+	require.Equal(t, 3, a.RequestCount)
+	require.Equal(t, int64(160), a.UsedQuota)
+	require.Zero(t, a.ErrorCount)
+
+	b := stats[common.SecretLookupHash("sk-b")]
+	require.NotNil(t, b)
+	require.Equal(t, 1, b.ErrorCount)
+	require.Equal(t, int64(20), b.LastTestLatency)
+	require.Equal(t, fmt.Sprintf("error %d", channelKeyErrorHistorySize-1), b.LastTestError)
+	// 仅保留最近的错误，最早的 relay 错误已被挤出
+	require.Len(t, b.RecentErrors, channelKeyErrorHistorySize)
+	require.Equal(t, ChannelKeyErrorSourceTest, b.RecentErrors[0].Source)
+	require.Equal(t, "error 0", b.RecentErrors[0].Message)
+}
+
+func TestUpdateChannelStatusMultiKey(t *testing.T) {
+	truncateTables(t)
+
+	channel := &Channel{
+		Id:     1,
+		Name:   "multi",
+		Key:    "sk-a\nsk-b",
+		Status: common.ChannelStatusEnabled,
+		ChannelInfo: ChannelInfo{
+			IsMultiKey:   true,
+			MultiKeySize: 2,
+		},
+	}
+	require.NoError(t, DB.Create(channel).Error)
+
+	require.True(t, UpdateChannelStatus(1, "sk-a", common.ChannelStatusAutoDisabled, "invalid key"))
+	require.False(t, UpdateChannelStatus(1, "sk-a", common.ChannelStatusAutoDisabled, "invalid key"))
+	require.True(t, UpdateChannelStatus(1, "sk-b", common.ChannelStatusAutoDisabled, "invalid key"))
+	got, err := GetChannelById(1, true)
+	require.NoError(t, err)
+	require.Equal(t, common.ChannelStatusAutoDisabled, got.Status)
+
+	// 恢复任意一个 key 后，因全部 key 被禁用而停用的渠道重新启用
+	require.True(t, UpdateChannelStatus(1, "sk-b", common.ChannelStatusEnabled, ""))
+	got, err = GetChannelById(1, true)
+	require.NoError(t, err)
+	require.Equal(t, common.ChannelStatusEnabled, got.Status)
+	require.Equal(t, map[int]int{0: common.ChannelStatusAutoDisabled}, got.ChannelInfo.MultiKeyStatusList)
+
+	// 渠道启用时仍可重新启用单个 key
+	require.True(t, UpdateChannelStatus(1, "sk-a", common.ChannelStatusEnabled, ""))
+	require.False(t, UpdateChannelStatus(1, "sk-a", common.ChannelStatusEnabled, ""))
+	got, err = GetChannelById(1, true)
+	require.NoError(t, err)
+	require.Empty(t, got.ChannelInfo.MultiKeyStatusList)
+}
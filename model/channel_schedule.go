@@ -0,0 +1,76 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+var channelSchedules map[int]*common.ActiveSchedule // 设置了生效时间的渠道，随渠道缓存一起刷新
+
+// getActiveSchedule 解析渠道的生效时间，未设置或无法解析时返回 nil（始终生效）
+func (channel *Channel) getActiveSchedule() *common.ActiveSchedule {
+	if channel.OtherSettings == "" {
+		return nil
+	}
+	var settings dto.ChannelOtherSettings
+	if err := common.UnmarshalJsonStr(channel.OtherSettings, &settings); err != nil || settings.ActiveSchedule == "" {
+		return nil
+	}
+	schedule, err := common.ParseActiveSchedule(settings.ActiveSchedule, settings.ActiveTimezone)
+	if err != nil {
+		common.SysError(fmt.Sprintf("invalid active schedule of channel #%d: %s", channel.Id, err.Error()))
+		return nil
+	}
+	return schedule
+}
+
+// filterActiveChannelIds 过滤掉当前不在生效时间内的渠道，调用方需持有 channelSyncLock
+func filterActiveChannelIds(channelIds []int, now time.Time) []int {
+	if len(channelSchedules) == 0 {
+		return channelIds
+	}
+	active := make([]int, 0, len(channelIds))
+	for _, channelId := range channelIds {
+		if schedule, ok := channelSchedules[channelId]; ok && !schedule.Active(now) {
+			continue
+		}
+		active = append(active, channelId)
+	}
+	return active
+}
+
+// filterActiveAbilities 未启用内存缓存时，从数据库读取渠道设置过滤不在生效时间内的渠道
+func filterActiveAbilities(abilities []Ability, now time.Time) ([]Ability, error) {
+	if len(abilities) == 0 {
+		return abilities, nil
+	}
+	channelIds := make([]int, 0, len(abilities))
+	for _, ability := range abilities {
+		channelIds = append(channelIds, ability.ChannelId)
+	}
+	var channels []*Channel
+	err := DB.Model(&Channel{}).Select("id", "settings").
+		Where("id IN ? AND settings LIKE ?", channelIds, "%active_schedule%").Find(&channels).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(channels) == 0 {
+		return abilities, nil
+	}
+	inactive := make(map[int]bool)
+	for _, channel := range channels {
+		if schedule := channel.getActiveSchedule(); schedule != nil && !schedule.Active(now) {
+			inactive[channel.Id] = true
+		}
+	}
+	active := make([]Ability, 0, len(abilities))
+	for _, ability := range abilities {
+		if !inactive[ability.ChannelId] {
+			active = append(active, ability)
+		}
+	}
+	return active, nil
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// ClusterLease 多节点部署下的数据库租约，用于在未启用 Redis 时做主节点选举
+type ClusterLease struct {
+	Name      string `json:"name" gorm:"primaryKey;type:varchar(64)"`
+	Owner     string `json:"owner" gorm:"type:varchar(128)"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// TryAcquireClusterLease 尝试获取或续期租约：租约不存在、已过期或本身由 owner 持有时成功
+func TryAcquireClusterLease(name string, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl).Unix()
+	err := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&ClusterLease{
+		Name:      name,
+		Owner:     owner,
+		ExpiresAt: expiresAt,
+	}).Error
+	if err != nil {
+		return false, err
+	}
+	result := DB.Model(&ClusterLease{}).
+		Where("name = ? AND (owner = ? OR expires_at < ?)", name, owner, now.Unix()).
+		Updates(map[string]any{"owner": owner, "expires_at": expiresAt})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+	// MySQL 对值未变化的行返回 0，这里回查一次确认持有者
+	var lease ClusterLease
+	if err = DB.Where("name = ?", name).First(&lease).Error; err != nil {
+		return false, err
+	}
+	return lease.Owner == owner && lease.ExpiresAt >= now.Unix(), nil
+}
+
+// ReleaseClusterLease 释放由 owner 持有的租约
+func ReleaseClusterLease(name string, owner string) error {
+	return DB.Where("name = ? AND owner = ?", name, owner).Delete(&ClusterLease{}).Error
+}
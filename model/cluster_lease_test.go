@@ -0,0 +1,67 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterLease_AcquireRenewAndExpire(t *testing.T) {
+	truncateTables(t)
+
+	ok, err := TryAcquireClusterLease("leader", "node-a", time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// 其他节点在租约有效期内无法获取
+	ok, err = TryAcquireClusterLease("leader", "node-b", time.Minute)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// 持有者可以续期
+	ok, err = TryAcquireClusterLease("leader", "node-a", time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// 租约过期后可被其他节点接管
+	require.NoError(t, DB.Model(&ClusterLease{}).Where("name = ?", "leader").Update("expires_at", time.Now().Unix()-1).Error)
+	ok, err = TryAcquireClusterLease("leader", "node-b", time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	require.NoError(t, ReleaseClusterLease("leader", "node-b"))
+	ok, err = TryAcquireClusterLease("leader", "node-a", time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestClaimTaskPoll_OnlyOneNodeWins(t *testing.T) {
+	truncateTables(t)
+
+	now := time.Now().Unix()
+	due := &Task{TaskID: "task_due", Status: TaskStatusInProgress, Progress: "30%"}
+	later := &Task{TaskID: "task_later", Status: TaskStatusInProgress, Progress: "30%", NextPollAt: now + 60}
+	done := &Task{TaskID: "task_done", Status: TaskStatusSuccess, Progress: "100%"}
+	insertTask(t, due)
+	insertTask(t, later)
+	insertTask(t, done)
+
+	tasks := GetDueUnfinishedTasks(now, 10)
+	require.Len(t, tasks, 1)
+	require.Equal(t, "task_due", tasks[0].TaskID)
+
+	won, err := ClaimTaskPoll(due.ID, 0, now+15)
+	require.NoError(t, err)
+	require.True(t, won)
+	// 另一节点持有的是旧的 next_poll_at，认领失败
+	won, err = ClaimTaskPoll(due.ID, 0, now+15)
+	require.NoError(t, err)
+	require.False(t, won)
+
+	require.Empty(t, GetDueUnfinishedTasks(now, 10))
+
+	counts, err := CountUnfinishedTasksByPlatform()
+	require.NoError(t, err)
+	require.Equal(t, int64(2), counts[""])
+}
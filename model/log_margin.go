@@ -0,0 +1,94 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MarginReport 按渠道/模型/分组/天汇总的收入（用户消耗额度）、上游成本与毛利，单位均为额度
+type MarginReport struct {
+	Day         int64   `json:"day,omitempty"` // 当天 00:00（UTC）的时间戳
+	ChannelId   int     `json:"channel_id,omitempty"`
+	ModelName   string  `json:"model_name,omitempty"`
+	UsingGroup  string  `json:"group,omitempty"`
+	Count       int     `json:"count"`
+	CostedCount int     `json:"costed_count"` // 配置了成本价的请求数，其余请求成本记为 0
+	Revenue     int     `json:"revenue"`
+	Cost        int     `json:"cost"`
+	Margin      int     `json:"margin"`
+	MarginRate  float64 `json:"margin_rate"`
+}
+
+// MarginReportDimensions 可用的汇总维度
+var MarginReportDimensions = []string{"channel", "model", "group", "day"}
+
+func marginReportColumn(dimension string) (string, error) {
+	switch dimension {
+	case "channel":
+		return "channel_id", nil
+	case "model":
+		return "model_name", nil
+	case "group":
+		return logGroupCol, nil
+	case "day":
+		return "(created_at - created_at % 86400)", nil
+	}
+	return "", fmt.Errorf("unsupported dimension %q", dimension)
+}
+
+var marginReportAliases = map[string]string{
+	"channel": "channel_id",
+	"model":   "model_name",
+	"group":   "using_group",
+	"day":     "day",
+}
+
+// GetMarginReport 从消费日志汇总收入与成本，dimensions 为空时按全部维度汇总
+func GetMarginReport(startTimestamp int64, endTimestamp int64, channelId int, modelName string, group string, dimensions []string) ([]*MarginReport, error) {
+	if len(dimensions) == 0 {
+		dimensions = MarginReportDimensions
+	}
+	selects := make([]string, 0, len(dimensions)+4)
+	groups := make([]string, 0, len(dimensions))
+	for _, dimension := range dimensions {
+		column, err := marginReportColumn(dimension)
+		if err != nil {
+			return nil, err
+		}
+		selects = append(selects, column+" AS "+marginReportAliases[dimension])
+		groups = append(groups, marginReportAliases[dimension])
+	}
+	selects = append(selects, "COUNT(*) AS count",
+		"SUM(CASE WHEN cost > 0 THEN 1 ELSE 0 END) AS costed_count",
+		"SUM(quota) AS revenue",
+		"SUM(cost) AS cost")
+
+	tx := LOG_DB.Table("logs").Select(strings.Join(selects, ", ")).Where("type = ?", LogTypeConsume)
+	if startTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", endTimestamp)
+	}
+	if channelId != 0 {
+		tx = tx.Where("channel_id = ?", channelId)
+	}
+	if modelName != "" {
+		tx = tx.Where("model_name = ?", modelName)
+	}
+	if group != "" {
+		tx = tx.Where(logGroupCol+" = ?", group)
+	}
+	var reports []*MarginReport
+	err := tx.Group(strings.Join(groups, ", ")).Order(strings.Join(groups, ", ")).Scan(&reports).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, report := range reports {
+		report.Margin = report.Revenue - report.Cost
+		if report.Revenue > 0 {
+			report.MarginRate = float64(report.Margin) / float64(report.Revenue)
+		}
+	}
+	return reports, nil
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetMarginReport(t *testing.T) {
+	truncateTables(t)
+
+	logs := []*Log{
+		{Type: LogTypeConsume, CreatedAt: 86400 + 10, ChannelId: 1, ModelName: "gpt-4o", Group: "default", Quota: 1000, Cost: 600},
+		{Type: LogTypeConsume, CreatedAt: 86400 + 20, ChannelId: 1, ModelName: "gpt-4o", Group: "default", Quota: 500, Cost: 0},
+		{Type: LogTypeConsume, CreatedAt: 2*86400 + 5, ChannelId: 1, ModelName: "gpt-4o", Group: "vip", Quota: 300, Cost: 400},
+		{Type: LogTypeConsume, CreatedAt: 86400 + 30, ChannelId: 2, ModelName: "claude", Group: "default", Quota: 200, Cost: 100},
+		{Type: LogTypeTopup, CreatedAt: 86400 + 40, ChannelId: 1, ModelName: "gpt-4o", Group: "default", Quota: 99999},
+	}
+	for _, log := range logs {
+		require.NoError(t, LOG_DB.Create(log).Error)
+	}
+
+	reports, err := GetMarginReport(0, 0, 0, "", "", nil)
+	require.NoError(t, err)
+	require.Len(t, reports, 3)
+	first := reports[0]
+	require.Equal(t, int64(86400), first.Day)
+	require.Equal(t, 1, first.ChannelId)
+	require.Equal(t, "gpt-4o", first.ModelName)
+	require.Equal(t, "default", first.UsingGroup)
+	require.Equal(t, 2, first.Count)
+	require.Equal(t, 1, first.CostedCount)
+	require.Equal(t, 1500, first.Revenue)
+	require.Equal(t, 600, first.Cost)
+	require.Equal(t, 900, first.Margin)
+	require.InDelta(t, 0.6, first.MarginRate, 1e-9)
+
+	reports, err = GetMarginReport(0, 0, 1, "", "", []string{"channel"})
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	require.Equal(t, 1800, reports[0].Revenue)
+	require.Equal(t, 1000, reports[0].Cost)
+	require.Equal(t, 800, reports[0].Margin)
+
+	reports, err = GetMarginReport(0, 0, 0, "", "vip", []string{"group"})
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	require.Equal(t, -100, reports[0].Margin)
+
+	_, err = GetMarginReport(0, 0, 0, "", "", []string{"token"})
+	require.Error(t, err)
+}
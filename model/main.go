@@ -283,6 +283,12 @@ func migrateDB() error {
 		&SubscriptionPreConsumeRecord{},
 		&CustomOAuthProvider{},
 		&UserOAuthBinding{},
+		&ShadowComparison{},
+		&ChannelCanaryResult{},
+		&ChannelKeyStat{},
+		&TrafficSplitStat{},
+		&MediaObject{},
+		&ClusterLease{},
 	)
 	if err != nil {
 		return err
@@ -334,6 +340,12 @@ func migrateDBFast() error {
 		{&SubscriptionPreConsumeRecord{}, "SubscriptionPreConsumeRecord"},
 		{&CustomOAuthProvider{}, "CustomOAuthProvider"},
 		{&UserOAuthBinding{}, "UserOAuthBinding"},
+		{&ShadowComparison{}, "ShadowComparison"},
+		{&ChannelCanaryResult{}, "ChannelCanaryResult"},
+		{&ChannelKeyStat{}, "ChannelKeyStat"},
+		{&TrafficSplitStat{}, "TrafficSplitStat"},
+		{&MediaObject{}, "MediaObject"},
+		{&ClusterLease{}, "ClusterLease"},
 	}
 	// 动态计算migration数量，确保errChan缓冲区足够大
 	errChan := make(chan error, len(migrations))
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"github.com/QuantumNous/new-api/common"
+)
+
+const (
+	MediaKindVideo = "video"
+	MediaKindImage = "image"
+	MediaKindAudio = "audio"
+)
+
+// MediaObject 转存到网关存储的生成结果（视频/图片/音频），通过签名链接对外提供下载
+type MediaObject struct {
+	Id          int64  `json:"id"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	TaskId      string `json:"task_id" gorm:"type:varchar(191);index"` // 公开 task ID，图片接口转存时为空
+	Kind        string `json:"kind" gorm:"type:varchar(16)"`
+	Backend     string `json:"backend" gorm:"type:varchar(16)"`
+	StorageKey  string `json:"-" gorm:"type:varchar(255)"`
+	SourceURL   string `json:"-" gorm:"type:text"` // 上游原始链接，用于在响应中替换为签名链接
+	ContentType string `json:"content_type" gorm:"type:varchar(128)"`
+	Size        int64  `json:"size" gorm:"bigint"`
+	CreatedAt   int64  `json:"created_at" gorm:"bigint"`
+	ExpiresAt   int64  `json:"expires_at" gorm:"bigint;index"` // 0 表示永久保留
+}
+
+func (m *MediaObject) Insert() error {
+	if m.CreatedAt == 0 {
+		m.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(m).Error
+}
+
+func GetMediaObjectById(id int64) (*MediaObject, error) {
+	var m MediaObject
+	if err := DB.First(&m, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func GetMediaObjectsByTaskId(taskId string) ([]*MediaObject, error) {
+	var objects []*MediaObject
+	if taskId == "" {
+		return objects, nil
+	}
+	err := DB.Where("task_id = ?", taskId).Order("id").Find(&objects).Error
+	return objects, err
+}
+
+// GetUserMediaUsage 返回用户当前占用的存储字节数
+func GetUserMediaUsage(userId int) (int64, error) {
+	var usage int64
+	err := DB.Model(&MediaObject{}).Where("user_id = ?", userId).Select("COALESCE(SUM(size), 0)").Scan(&usage).Error
+	return usage, err
+}
+
+// GetExpiredMediaObjects 返回已过保留期的文件，每次最多 limit 条
+func GetExpiredMediaObjects(now int64, limit int) ([]*MediaObject, error) {
+	var objects []*MediaObject
+	err := DB.Where("expires_at > 0 AND expires_at <= ?", now).Order("id").Limit(limit).Find(&objects).Error
+	return objects, err
+}
+
+func DeleteMediaObjectById(id int64) error {
+	return DB.Delete(&MediaObject{}, "id = ?", id).Error
+}
@@ -1,5 +1,13 @@
 package model
 
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+)
+
 type Midjourney struct {
 	Id          int    `json:"id"`
 	Code        int    `json:"code"`
@@ -218,3 +226,132 @@ func CountAllUserTask(userId int, queryParams TaskQueryParams) int64 {
 	_ = query.Count(&total).Error
 	return total
 }
+
+// midjourneyMigrationBatchSize 每批迁移的旧版 MJ 记录数
+const midjourneyMigrationBatchSize = 500
+
+// MigrateMidjourneyHistory 将已结束的旧版 midjourneys 记录复制到 tasks 表，以便通过统一任务接口查询。
+// 公开任务 ID 与上游任务 ID 均沿用 mj_id，已迁移过的记录会跳过，可重复执行；
+// 未结束的记录仍由旧版轮询处理，结束后在下次执行时迁移。原表数据保留不删除。
+func MigrateMidjourneyHistory() (int, error) {
+	migrated := 0
+	lastId := 0
+	for {
+		var rows []*Midjourney
+		err := DB.Where("id > ? AND status IN ? AND mj_id <> ''", lastId, []string{"SUCCESS", "FAILURE"}).
+			Order("id").Limit(midjourneyMigrationBatchSize).Find(&rows).Error
+		if err != nil {
+			return migrated, err
+		}
+		if len(rows) == 0 {
+			return migrated, nil
+		}
+		lastId = rows[len(rows)-1].Id
+
+		mjIds := make([]string, 0, len(rows))
+		for _, row := range rows {
+			mjIds = append(mjIds, row.MjId)
+		}
+		var existing []string
+		err = DB.Model(&Task{}).Where("platform = ? AND task_id IN ?", constant.TaskPlatformMidjourney, mjIds).
+			Pluck("task_id", &existing).Error
+		if err != nil {
+			return migrated, err
+		}
+		skip := make(map[string]bool, len(existing))
+		for _, id := range existing {
+			skip[id] = true
+		}
+
+		tasks := make([]*Task, 0, len(rows))
+		for _, row := range rows {
+			if skip[row.MjId] {
+				continue
+			}
+			// 同一批次内可能存在重复的 mj_id（如任务已存在时重复提交），只迁移第一条
+			skip[row.MjId] = true
+			tasks = append(tasks, row.toTask())
+		}
+		if len(tasks) > 0 {
+			if err = DB.CreateInBatches(tasks, 100).Error; err != nil {
+				return migrated, err
+			}
+			migrated += len(tasks)
+		}
+	}
+}
+
+// toTask 转换为统一任务记录，旧表时间为毫秒，tasks 表为秒
+func (midjourney *Midjourney) toTask() *Task {
+	status := TaskStatus(TaskStatusSuccess)
+	if midjourney.Status == "FAILURE" {
+		status = TaskStatusFailure
+	}
+	modelName := "mj_" + strings.ToLower(midjourney.Action)
+	if midjourney.Action == constant.MjActionSwapFace {
+		modelName = "swap_face"
+	}
+	resultURL := midjourney.ImageUrl
+	if midjourney.Action == constant.MjActionVideo && midjourney.VideoUrl != "" {
+		resultURL = midjourney.VideoUrl
+	}
+
+	data := dto.MidjourneyDto{
+		MjId:        midjourney.MjId,
+		Action:      midjourney.Action,
+		Prompt:      midjourney.Prompt,
+		PromptEn:    midjourney.PromptEn,
+		Description: midjourney.Description,
+		State:       midjourney.State,
+		SubmitTime:  midjourney.SubmitTime,
+		StartTime:   midjourney.StartTime,
+		FinishTime:  midjourney.FinishTime,
+		ImageUrl:    midjourney.ImageUrl,
+		VideoUrl:    midjourney.VideoUrl,
+		Status:      midjourney.Status,
+		Progress:    midjourney.Progress,
+		FailReason:  midjourney.FailReason,
+	}
+	if midjourney.Buttons != "" {
+		var buttons []dto.ActionButton
+		if common.Unmarshal([]byte(midjourney.Buttons), &buttons) == nil {
+			data.Buttons = buttons
+		}
+	}
+	if midjourney.VideoUrls != "" {
+		_ = common.Unmarshal([]byte(midjourney.VideoUrls), &data.VideoUrls)
+	}
+	if midjourney.Properties != "" {
+		var properties dto.Properties
+		if common.Unmarshal([]byte(midjourney.Properties), &properties) == nil {
+			data.Properties = &properties
+		}
+	}
+	dataBytes, _ := common.Marshal(data)
+
+	return &Task{
+		CreatedAt:  midjourney.SubmitTime / 1000,
+		UpdatedAt:  midjourney.FinishTime / 1000,
+		TaskID:     midjourney.MjId,
+		Platform:   constant.TaskPlatformMidjourney,
+		UserId:     midjourney.UserId,
+		ChannelId:  midjourney.ChannelId,
+		Quota:      midjourney.Quota,
+		Action:     midjourney.Action,
+		Status:     status,
+		FailReason: midjourney.FailReason,
+		SubmitTime: midjourney.SubmitTime / 1000,
+		StartTime:  midjourney.StartTime / 1000,
+		FinishTime: midjourney.FinishTime / 1000,
+		Progress:   midjourney.Progress,
+		Properties: Properties{
+			Input:           midjourney.Prompt,
+			OriginModelName: modelName,
+		},
+		PrivateData: TaskPrivateData{
+			UpstreamTaskID: midjourney.MjId,
+			ResultURL:      resultURL,
+		},
+		Data: dataBytes,
+	}
+}
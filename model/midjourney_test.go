@@ -0,0 +1,47 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateMidjourneyHistory_CopiesFinishedOnce(t *testing.T) {
+	truncateTables(t)
+
+	rows := []*Midjourney{
+		{UserId: 1, Action: constant.MjActionImagine, MjId: "1001", Prompt: "a cat", Status: "SUCCESS", Progress: "100%",
+			ImageUrl: "https://cdn.example.com/1001.png", SubmitTime: 1700000000000, FinishTime: 1700000060000, ChannelId: 3, Quota: 500},
+		{UserId: 1, Action: constant.MjActionUpscale, MjId: "1002", Status: "FAILURE", FailReason: "banned", Quota: 100},
+		{UserId: 1, Action: constant.MjActionImagine, MjId: "1003", Status: "IN_PROGRESS"},
+		{UserId: 1, Action: constant.MjActionImagine, MjId: "", Status: "FAILURE"},
+	}
+	for _, row := range rows {
+		require.NoError(t, row.Insert())
+	}
+
+	migrated, err := MigrateMidjourneyHistory()
+	require.NoError(t, err)
+	require.Equal(t, 2, migrated)
+
+	task, exist, err := GetByTaskId(1, "1001")
+	require.NoError(t, err)
+	require.True(t, exist)
+	require.Equal(t, constant.TaskPlatform(constant.TaskPlatformMidjourney), task.Platform)
+	require.EqualValues(t, TaskStatusSuccess, task.Status)
+	require.Equal(t, "https://cdn.example.com/1001.png", task.GetResultURL())
+	require.Equal(t, "1001", task.GetUpstreamTaskID())
+	require.Equal(t, "mj_imagine", task.Properties.OriginModelName)
+	require.Equal(t, int64(1700000000), task.SubmitTime)
+
+	// 未结束的任务结束后再次执行只迁移新结束的记录
+	require.NoError(t, DB.Model(&Midjourney{}).Where("mj_id = ?", "1003").Update("status", "SUCCESS").Error)
+	migrated, err = MigrateMidjourneyHistory()
+	require.NoError(t, err)
+	require.Equal(t, 1, migrated)
+
+	var count int64
+	require.NoError(t, DB.Model(&Task{}).Count(&count).Error)
+	require.Equal(t, int64(3), count)
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+const (
+	ModelSunsetActionRedirect = "redirect"
+	ModelSunsetActionError    = "error"
+)
+
+// ModelAlias 模型别名/弃用记录，来自模型元数据中设置了 alias_target 的模型
+type ModelAlias struct {
+	Model        string
+	Target       string
+	SunsetTime   int64
+	SunsetAction string
+}
+
+// IsRetired 是否已过下线时间，未设置下线时间的别名始终视为已下线（直接重定向）
+func (a *ModelAlias) IsRetired(now int64) bool {
+	return a.SunsetTime <= 0 || now >= a.SunsetTime
+}
+
+// Resolve 返回请求实际使用的模型名，已下线且动作为 error 时 ok 为 false
+func (a *ModelAlias) Resolve(now int64) (modelName string, ok bool) {
+	if !a.IsRetired(now) {
+		return a.Model, true
+	}
+	if a.SunsetTime > 0 && a.SunsetAction == ModelSunsetActionError {
+		return "", false
+	}
+	return a.Target, true
+}
+
+var (
+	modelAliasMap      = make(map[string]*ModelAlias)
+	modelAliasLock     sync.RWMutex
+	modelAliasLoadLock sync.Mutex
+	lastModelAliasLoad time.Time
+)
+
+func refreshModelAliases() {
+	var metas []Model
+	err := DB.Where("alias_target <> ? AND name_rule = ? AND status = ?", "", NameRuleExact, 1).Find(&metas).Error
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to load model aliases: %s", err.Error()))
+		return
+	}
+	aliases := make(map[string]*ModelAlias, len(metas))
+	for _, meta := range metas {
+		if meta.AliasTarget == meta.ModelName {
+			continue
+		}
+		aliases[meta.ModelName] = &ModelAlias{
+			Model:        meta.ModelName,
+			Target:       meta.AliasTarget,
+			SunsetTime:   meta.SunsetTime,
+			SunsetAction: meta.SunsetAction,
+		}
+	}
+	modelAliasLock.Lock()
+	modelAliasMap = aliases
+	lastModelAliasLoad = time.Now()
+	modelAliasLock.Unlock()
+}
+
+// GetModelAliases 返回全部别名，缓存每分钟刷新一次，修改模型元数据时立即刷新
+func GetModelAliases() map[string]*ModelAlias {
+	modelAliasLock.RLock()
+	stale := time.Since(lastModelAliasLoad) > time.Minute
+	aliases := modelAliasMap
+	modelAliasLock.RUnlock()
+	if stale && modelAliasLoadLock.TryLock() {
+		defer modelAliasLoadLock.Unlock()
+		refreshModelAliases()
+		modelAliasLock.RLock()
+		aliases = modelAliasMap
+		modelAliasLock.RUnlock()
+	}
+	return aliases
+}
+
+func GetModelAlias(modelName string) *ModelAlias {
+	return GetModelAliases()[modelName]
+}
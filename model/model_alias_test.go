@@ -0,0 +1,49 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelAliasResolve(t *testing.T) {
+	truncateTables(t)
+
+	metas := []*Model{
+		{ModelName: "gpt-old", AliasTarget: "gpt-new", Status: 1},
+		{ModelName: "gpt-legacy", AliasTarget: "gpt-new", SunsetTime: 2000, Status: 1},
+		{ModelName: "gpt-gone", AliasTarget: "gpt-new", SunsetTime: 2000, SunsetAction: ModelSunsetActionError, Status: 1},
+		{ModelName: "gpt-prefix", AliasTarget: "gpt-new", NameRule: NameRulePrefix, Status: 1},
+		{ModelName: "gpt-disabled", AliasTarget: "gpt-new", Status: 0},
+	}
+	for _, m := range metas {
+		require.NoError(t, DB.Create(m).Error)
+	}
+	// status 默认值为 1，需显式更新为禁用
+	require.NoError(t, DB.Model(&Model{}).Where("model_name = ?", "gpt-disabled").Update("status", 0).Error)
+	refreshModelAliases()
+
+	aliases := GetModelAliases()
+	require.Len(t, aliases, 3)
+	require.Nil(t, GetModelAlias("gpt-prefix"))
+	require.Nil(t, GetModelAlias("gpt-disabled"))
+
+	name, ok := GetModelAlias("gpt-old").Resolve(1000)
+	require.True(t, ok)
+	require.Equal(t, "gpt-new", name)
+
+	// 下线前仍使用原模型，下线后重定向
+	legacy := GetModelAlias("gpt-legacy")
+	name, ok = legacy.Resolve(1999)
+	require.True(t, ok)
+	require.Equal(t, "gpt-legacy", name)
+	name, ok = legacy.Resolve(2000)
+	require.True(t, ok)
+	require.Equal(t, "gpt-new", name)
+
+	gone := GetModelAlias("gpt-gone")
+	_, ok = gone.Resolve(1999)
+	require.True(t, ok)
+	_, ok = gone.Resolve(2001)
+	require.False(t, ok)
+}
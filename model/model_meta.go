@@ -39,6 +39,12 @@ type Model struct {
 	QuotaTypes    []int          `json:"quota_types,omitempty" gorm:"-"`
 	NameRule      int            `json:"name_rule" gorm:"default:0"`
 
+	// AliasTarget 不为空时该模型名为别名，请求会重定向到目标模型（仅精确匹配规则生效）；
+	// 设置 SunsetTime 时，在下线时间前仍使用原模型并返回弃用提示，下线后按 SunsetAction 重定向或拒绝
+	AliasTarget  string `json:"alias_target,omitempty" gorm:"type:varchar(128);default:''"`
+	SunsetTime   int64  `json:"sunset_time,omitempty" gorm:"bigint;default:0"`
+	SunsetAction string `json:"sunset_action,omitempty" gorm:"type:varchar(16);default:''"`
+
 	MatchedModels []string `json:"matched_models,omitempty" gorm:"-"`
 	MatchedCount  int      `json:"matched_count,omitempty" gorm:"-"`
 }
@@ -77,7 +83,7 @@ func (mi *Model) Update() error {
 	mi.UpdatedTime = common.GetTimestamp()
 	// 使用 Select 强制更新所有字段，包括零值
 	return DB.Model(&Model{}).Where("id = ?", mi.Id).
-		Select("model_name", "description", "icon", "tags", "vendor_id", "endpoints", "status", "sync_official", "name_rule", "updated_time").
+		Select("model_name", "description", "icon", "tags", "vendor_id", "endpoints", "status", "sync_official", "name_rule", "alias_target", "sunset_time", "sunset_action", "updated_time").
 		Updates(mi).Error
 }
 
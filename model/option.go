@@ -13,6 +13,8 @@ import (
 	"github.com/QuantumNous/new-api/setting/quota_limit"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"gorm.io/gorm"
 )
 
 type Option struct {
@@ -150,6 +152,8 @@ func InitOptionMap() {
 	common.OptionMap["UserUsableGroups"] = setting.UserUsableGroups2JSONString()
 	common.OptionMap["CompletionRatio"] = ratio_setting.CompletionRatio2JSONString()
 	common.OptionMap["ImageRatio"] = ratio_setting.ImageRatio2JSONString()
+	common.OptionMap["ImageSizeRatio"] = ratio_setting.ImageSizeRatio2JSONString()
+	common.OptionMap["ModelTimeRatio"] = ratio_setting.ModelTimeRatio2JSONString()
 	common.OptionMap["AudioRatio"] = ratio_setting.AudioRatio2JSONString()
 	common.OptionMap["AudioCompletionRatio"] = ratio_setting.AudioCompletionRatio2JSONString()
 	common.OptionMap["TopUpLink"] = common.TopUpLink
@@ -223,6 +227,21 @@ func UpdateOption(key string, value string) error {
 	return updateOptionMap(key, value)
 }
 
+// SaveOptionTx 在事务中保存配置项，不更新内存中的 OptionMap，提交后需调用 RefreshOptionMap
+func SaveOptionTx(tx *gorm.DB, key string, value string) error {
+	option := Option{Key: key}
+	if err := tx.FirstOrCreate(&option, Option{Key: key}).Error; err != nil {
+		return err
+	}
+	option.Value = value
+	return tx.Save(&option).Error
+}
+
+// RefreshOptionMap 将已保存的配置项同步到内存
+func RefreshOptionMap(key string, value string) error {
+	return updateOptionMap(key, value)
+}
+
 func updateOptionMap(key string, value string) (err error) {
 	common.OptionMapRWMutex.Lock()
 	defer common.OptionMapRWMutex.Unlock()
@@ -512,6 +531,10 @@ func updateOptionMap(key string, value string) (err error) {
 		err = ratio_setting.UpdateCreateCacheRatioByJSONString(value)
 	case "ImageRatio":
 		err = ratio_setting.UpdateImageRatioByJSONString(value)
+	case "ImageSizeRatio":
+		err = ratio_setting.UpdateImageSizeRatioByJSONString(value)
+	case "ModelTimeRatio":
+		err = ratio_setting.UpdateModelTimeRatioByJSONString(value)
 	case "AudioRatio":
 		err = ratio_setting.UpdateAudioRatioByJSONString(value)
 	case "AudioCompletionRatio":
@@ -33,6 +33,8 @@ type Pricing struct {
 	EnableGroup            []string                `json:"enable_groups"`
 	SupportedEndpointTypes []constant.EndpointType `json:"supported_endpoint_types"`
 	PricingVersion         string                  `json:"pricing_version,omitempty"`
+	AliasTarget            string                  `json:"alias_target,omitempty"`
+	SunsetTime             int64                   `json:"sunset_time,omitempty"`
 }
 
 type PricingVendor struct {
@@ -291,6 +293,10 @@ func updatePricing() {
 			pricing.Icon = meta.Icon
 			pricing.Tags = meta.Tags
 			pricing.VendorID = meta.VendorID
+			if meta.NameRule == NameRuleExact && meta.ModelName == model {
+				pricing.AliasTarget = meta.AliasTarget
+				pricing.SunsetTime = meta.SunsetTime
+			}
 		}
 		modelPrice, findPrice := ratio_setting.GetModelPrice(model, false)
 		if findPrice {
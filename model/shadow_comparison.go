@@ -0,0 +1,107 @@
+package model
+
+// ShadowComparison 一次镜像请求的对比记录
+type ShadowComparison struct {
+	Id                        int     `json:"id" gorm:"primaryKey;autoIncrement"`
+	CreatedAt                 int64   `json:"created_at" gorm:"bigint;index"`
+	UsingGroup                string  `json:"group" gorm:"type:varchar(64);index"`
+	ModelName                 string  `json:"model_name" gorm:"type:varchar(255);index"`
+	IsStream                  bool    `json:"is_stream"`
+	PrimaryChannelId          int     `json:"primary_channel_id"`
+	CandidateChannelId        int     `json:"candidate_channel_id" gorm:"index"`
+	PrimaryLatency            int64   `json:"primary_latency"` // 毫秒
+	CandidateLatency          int64   `json:"candidate_latency"`
+	CandidateSuccess          bool    `json:"candidate_success"`
+	CandidateStatusCode       int     `json:"candidate_status_code"`
+	CandidateError            string  `json:"candidate_error" gorm:"type:text"`
+	PrimaryPromptTokens       int     `json:"primary_prompt_tokens"`
+	PrimaryCompletionTokens   int     `json:"primary_completion_tokens"`
+	CandidatePromptTokens     int     `json:"candidate_prompt_tokens"`
+	CandidateCompletionTokens int     `json:"candidate_completion_tokens"`
+	Similarity                float64 `json:"similarity"` // 0-1，未比较时为 -1
+}
+
+func (ShadowComparison) TableName() string {
+	return "shadow_comparisons"
+}
+
+// ShadowComparisonReport 按候选渠道与模型汇总的对比报告
+type ShadowComparisonReport struct {
+	CandidateChannelId           int     `json:"candidate_channel_id"`
+	ModelName                    string  `json:"model_name"`
+	Total                        int64   `json:"total"`
+	Errors                       int64   `json:"errors"`
+	ErrorRate                    float64 `json:"error_rate"`
+	AvgPrimaryLatency            float64 `json:"avg_primary_latency"`
+	AvgCandidateLatency          float64 `json:"avg_candidate_latency"`
+	AvgPrimaryPromptTokens       float64 `json:"avg_primary_prompt_tokens"`
+	AvgPrimaryCompletionTokens   float64 `json:"avg_primary_completion_tokens"`
+	AvgCandidatePromptTokens     float64 `json:"avg_candidate_prompt_tokens"`
+	AvgCandidateCompletionTokens float64 `json:"avg_candidate_completion_tokens"`
+	Compared                     int64   `json:"compared"`
+	AvgSimilarity                float64 `json:"avg_similarity"`
+}
+
+func RecordShadowComparison(record *ShadowComparison) error {
+	return DB.Create(record).Error
+}
+
+// GetShadowComparisonReport 汇总对比结果，candidateChannelId 为 0 时不过滤渠道。
+// 延迟与 token 用量只统计候选渠道成功的请求
+func GetShadowComparisonReport(candidateChannelId int, startTimestamp int64, endTimestamp int64) ([]*ShadowComparisonReport, error) {
+	var reports []*ShadowComparisonReport
+	tx := DB.Model(&ShadowComparison{}).Select(
+		"candidate_channel_id, model_name, COUNT(*) AS total, "+
+			"SUM(CASE WHEN candidate_success = ? THEN 0 ELSE 1 END) AS errors, "+
+			"AVG(CASE WHEN candidate_success = ? THEN primary_latency END) AS avg_primary_latency, "+
+			"AVG(CASE WHEN candidate_success = ? THEN candidate_latency END) AS avg_candidate_latency, "+
+			"AVG(CASE WHEN candidate_success = ? THEN primary_prompt_tokens END) AS avg_primary_prompt_tokens, "+
+			"AVG(CASE WHEN candidate_success = ? THEN primary_completion_tokens END) AS avg_primary_completion_tokens, "+
+			"AVG(CASE WHEN candidate_success = ? THEN candidate_prompt_tokens END) AS avg_candidate_prompt_tokens, "+
+			"AVG(CASE WHEN candidate_success = ? THEN candidate_completion_tokens END) AS avg_candidate_completion_tokens, "+
+			"SUM(CASE WHEN similarity >= 0 THEN 1 ELSE 0 END) AS compared, "+
+			"AVG(CASE WHEN similarity >= 0 THEN similarity END) AS avg_similarity",
+		true, true, true, true, true, true, true,
+	)
+	if candidateChannelId > 0 {
+		tx = tx.Where("candidate_channel_id = ?", candidateChannelId)
+	}
+	if startTimestamp > 0 {
+		tx = tx.Where("created_at >= ?", startTimestamp)
+	}
+	if endTimestamp > 0 {
+		tx = tx.Where("created_at <= ?", endTimestamp)
+	}
+	err := tx.Group("candidate_channel_id, model_name").Order("candidate_channel_id, model_name").Scan(&reports).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, report := range reports {
+		if report.Total > 0 {
+			report.ErrorRate = float64(report.Errors) / float64(report.Total)
+		}
+	}
+	return reports, nil
+}
+
+func GetShadowComparisons(candidateChannelId int, startIdx int, num int) (records []*ShadowComparison, total int64, err error) {
+	tx := DB.Model(&ShadowComparison{})
+	if candidateChannelId > 0 {
+		tx = tx.Where("candidate_channel_id = ?", candidateChannelId)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&records).Error
+	return records, total, err
+}
+
+// DeleteShadowComparisons 清除对比记录，candidateChannelId 为 0 时清除全部
+func DeleteShadowComparisons(candidateChannelId int) (int64, error) {
+	tx := DB.Where("1 = 1")
+	if candidateChannelId > 0 {
+		tx = DB.Where("candidate_channel_id = ?", candidateChannelId)
+	}
+	result := tx.Delete(&ShadowComparison{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetShadowComparisonReport(t *testing.T) {
+	truncateTables(t)
+
+	records := []*ShadowComparison{
+		{CreatedAt: 100, ModelName: "gpt-4o", CandidateChannelId: 2, CandidateSuccess: true, PrimaryLatency: 100, CandidateLatency: 300, CandidateCompletionTokens: 10, Similarity: 0.8},
+		{CreatedAt: 200, ModelName: "gpt-4o", CandidateChannelId: 2, CandidateSuccess: true, PrimaryLatency: 300, CandidateLatency: 500, CandidateCompletionTokens: 30, Similarity: -1},
+		{CreatedAt: 300, ModelName: "gpt-4o", CandidateChannelId: 2, CandidateSuccess: false, CandidateLatency: 9000, Similarity: -1},
+		{CreatedAt: 300, ModelName: "gpt-4o", CandidateChannelId: 3, CandidateSuccess: true, Similarity: 0.2},
+	}
+	for _, record := range records {
+		require.NoError(t, RecordShadowComparison(record))
+	}
+
+	reports, err := GetShadowComparisonReport(2, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	report := reports[0]
+	require.Equal(t, int64(3), report.Total)
+	require.Equal(t, int64(1), report.Errors)
+	require.InDelta(t, 1.0/3, report.ErrorRate, 1e-9)
+	require.InDelta(t, 200, report.AvgPrimaryLatency, 1e-9)
+	require.InDelta(t, 400, report.AvgCandidateLatency, 1e-9)
+	require.InDelta(t, 20, report.AvgCandidateCompletionTokens, 1e-9)
+	require.Equal(t, int64(1), report.Compared)
+	require.InDelta(t, 0.8, report.AvgSimilarity, 1e-9)
+
+	reports, err = GetShadowComparisonReport(0, 250, 0)
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+
+	deleted, err := DeleteShadowComparisons(3)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+	_, total, err := GetShadowComparisons(0, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), total)
+}
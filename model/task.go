@@ -10,6 +10,9 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	commonRelay "github.com/QuantumNous/new-api/relay/common"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TaskStatus string
@@ -59,7 +62,15 @@ type Task struct {
 	FinishTime int64                 `json:"finish_time" gorm:"index"`
 	Progress   string                `json:"progress" gorm:"type:varchar(20);index"`
 	Properties Properties            `json:"properties" gorm:"type:json"`
-	Username   string                `json:"username,omitempty" gorm:"-"`
+	// NextPollAt 下次允许轮询的时间，同时作为多节点轮询的租约
+	NextPollAt int64 `json:"-" gorm:"index;default:0"`
+	// QueuedAt 在网关排队等待提交的开始时间，非 0 表示尚未提交到上游，不参与轮询
+	QueuedAt int64 `json:"-" gorm:"index;default:0"`
+	// TokenId 提交任务使用的令牌，用于按令牌统计进行中的任务数
+	TokenId int `json:"-" gorm:"index;default:0"`
+	// ResubmitCount 已认领的重新提交次数，作为重新提交认领的 CAS 版本号
+	ResubmitCount int    `json:"-" gorm:"default:0"`
+	Username      string `json:"username,omitempty" gorm:"-"`
 	// 禁止返回给用户，内部可能包含key等隐私信息
 	PrivateData TaskPrivateData `json:"-" gorm:"column:private_data;type:json"`
 	Data        json.RawMessage `json:"data" gorm:"type:json"`
@@ -105,6 +116,25 @@ type TaskPrivateData struct {
 	SubscriptionId int                 `json:"subscription_id,omitempty"` // 订阅 ID，用于订阅退款
 	TokenId        int                 `json:"token_id,omitempty"`        // 令牌 ID，用于令牌额度退款
 	BillingContext *TaskBillingContext `json:"billing_context,omitempty"` // 计费参数快照（用于轮询阶段重新计算）
+	// 自动重新提交：原始请求与历次失败的尝试
+	ResubmitRequest *TaskResubmitRequest `json:"resubmit_request,omitempty"`
+	Attempts        []TaskAttempt        `json:"attempts,omitempty"`
+}
+
+// TaskResubmitRequest 保存的原始提交请求，用于失败后换渠道重新提交
+type TaskResubmitRequest struct {
+	Path   string          `json:"path"`
+	Action string          `json:"action,omitempty"`
+	Body   json.RawMessage `json:"body"`
+}
+
+// TaskAttempt 一次失败的提交尝试
+type TaskAttempt struct {
+	ChannelId      int    `json:"channel_id"`
+	UpstreamTaskID string `json:"upstream_task_id"`
+	SubmitTime     int64  `json:"submit_time"`
+	FailedAt       int64  `json:"failed_at"`
+	Reason         string `json:"reason"`
 }
 
 // TaskBillingContext 记录任务提交时的计费参数，以便轮询阶段可以重新计算额度。
@@ -150,10 +180,15 @@ func (p *TaskPrivateData) Scan(val interface{}) error {
 }
 
 func (p TaskPrivateData) Value() (driver.Value, error) {
-	if (p == TaskPrivateData{}) {
+	// 所有字段均为 omitempty，序列化为空对象即零值
+	b, err := common.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	if string(b) == "{}" {
 		return nil, nil
 	}
-	return common.Marshal(p)
+	return b, nil
 }
 
 // SyncTaskQueryParams 用于包含所有搜索条件的结构体，可以根据需求添加更多字段
@@ -173,8 +208,10 @@ func InitTask(platform constant.TaskPlatform, relayInfo *commonRelay.RelayInfo)
 	properties := Properties{}
 	privateData := TaskPrivateData{}
 	if relayInfo != nil && relayInfo.ChannelMeta != nil {
+		// 多 key 渠道需记录提交时使用的 key，查询任务时沿用
 		if relayInfo.ChannelMeta.ChannelType == constant.ChannelTypeGemini ||
-			relayInfo.ChannelMeta.ChannelType == constant.ChannelTypeVertexAi {
+			relayInfo.ChannelMeta.ChannelType == constant.ChannelTypeVertexAi ||
+			relayInfo.ChannelMeta.ChannelIsMultiKey {
 			privateData.Key = relayInfo.ChannelMeta.ApiKey
 		}
 		if relayInfo.UpstreamModelName != "" {
@@ -201,6 +238,7 @@ func InitTask(platform constant.TaskPlatform, relayInfo *commonRelay.RelayInfo)
 		Status:      TaskStatusNotStart,
 		Progress:    "0%",
 		ChannelId:   relayInfo.ChannelId,
+		TokenId:     relayInfo.TokenId,
 		Platform:    platform,
 		Properties:  properties,
 		PrivateData: privateData,
@@ -293,6 +331,7 @@ func GetTimedOutUnfinishedTasks(cutoffUnix int64, limit int) []*Task {
 	var tasks []*Task
 	err := DB.Where("progress != ?", "100%").
 		Where("status NOT IN ?", []string{TaskStatusFailure, TaskStatusSuccess}).
+		Where("queued_at = ?", 0).
 		Where("submit_time < ?", cutoffUnix).
 		Order("submit_time").
 		Limit(limit).
@@ -314,6 +353,72 @@ func GetAllUnFinishSyncTasks(limit int) []*Task {
 	return tasks
 }
 
+// GetDueUnfinishedTasks 获取已到轮询时间的未完成任务，按到期先后排序
+func GetDueUnfinishedTasks(now int64, limit int) []*Task {
+	var tasks []*Task
+	err := DB.Where("progress != ?", "100%").
+		Where("status != ?", TaskStatusFailure).
+		Where("status != ?", TaskStatusSuccess).
+		Where("queued_at = ?", 0).
+		Where("next_poll_at <= ?", now).
+		Order("next_poll_at").Order("id").
+		Limit(limit).
+		Find(&tasks).Error
+	if err != nil {
+		return nil
+	}
+	return tasks
+}
+
+// ClaimTaskPoll 以 CAS 方式将任务的下次轮询时间从 expected 推进到 next，成功表示本节点取得本轮轮询权
+func ClaimTaskPoll(id int64, expected int64, next int64) (bool, error) {
+	result := DB.Model(&Task{}).
+		Where("id = ? AND next_poll_at = ?", id, expected).
+		Update("next_poll_at", next)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ClaimResubmit 以 status 与 resubmit_count 双重 CAS 认领一次重新提交并将 ResubmitCount 加一。
+// 状态为 SUBMITTED 时仅靠 status 无法区分认领前后，超时清理与轮询失败可能同时认领成功，
+// 因此额外比较 resubmit_count，保证同一次失败只有一个调用方获胜。
+func (t *Task) ClaimResubmit(fromStatus TaskStatus) (bool, error) {
+	fromCount := t.ResubmitCount
+	t.ResubmitCount = fromCount + 1
+	result := DB.Model(t).Where("status = ? AND resubmit_count = ?", fromStatus, fromCount).Select("*").Updates(t)
+	if result.Error != nil || result.RowsAffected == 0 {
+		t.ResubmitCount = fromCount
+		return false, result.Error
+	}
+	return true, nil
+}
+
+// CountUnfinishedTasksByPlatform 统计各平台未完成任务数
+func CountUnfinishedTasksByPlatform() (map[constant.TaskPlatform]int64, error) {
+	var rows []struct {
+		Platform constant.TaskPlatform
+		Count    int64
+	}
+	err := DB.Model(&Task{}).
+		Select("platform, count(*) as count").
+		Where("progress != ?", "100%").
+		Where("status != ?", TaskStatusFailure).
+		Where("status != ?", TaskStatusSuccess).
+		Where("queued_at = ?", 0).
+		Group("platform").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[constant.TaskPlatform]int64, len(rows))
+	for _, row := range rows {
+		result[row.Platform] = row.Count
+	}
+	return result, nil
+}
+
 func GetByOnlyTaskId(taskId string) (*Task, bool, error) {
 	if taskId == "" {
 		return nil, false, nil
@@ -524,3 +629,93 @@ func (t *Task) ToOpenAIVideo() *dto.OpenAIVideo {
 	openAIVideo.SetMetadata("url", t.GetResultURL())
 	return openAIVideo
 }
+
+// CountInFlightTasks 统计用户（tokenId 非 0 时为该令牌）已提交到上游且未结束的任务数，不含排队中的任务
+func CountInFlightTasks(userId int, tokenId int) (int64, error) {
+	return CountInFlightTasksTx(DB, userId, tokenId)
+}
+
+func CountInFlightTasksTx(tx *gorm.DB, userId int, tokenId int) (int64, error) {
+	var count int64
+	query := tx.Model(&Task{}).
+		Where("user_id = ?", userId).
+		Where("status NOT IN ?", []string{TaskStatusFailure, TaskStatusSuccess}).
+		Where("queued_at = ?", 0)
+	if tokenId != 0 {
+		query = query.Where("token_id = ?", tokenId)
+	}
+	err := query.Count(&count).Error
+	return count, err
+}
+
+// CountQueuedTasks 统计用户在网关排队中的任务数
+func CountQueuedTasks(userId int) (int64, error) {
+	var count int64
+	err := DB.Model(&Task{}).Where("user_id = ? AND queued_at > ?", userId, 0).Count(&count).Error
+	return count, err
+}
+
+// GetQueuedTasks 按排队先后获取排队中的任务
+func GetQueuedTasks(limit int) []*Task {
+	var tasks []*Task
+	err := DB.Where("queued_at > ?", 0).Order("id").Limit(limit).Find(&tasks).Error
+	if err != nil {
+		return nil
+	}
+	return tasks
+}
+
+// ClaimQueuedTask 以 CAS 方式将排队任务标记为提交中，成功表示本进程取得提交权；
+// 提交期间推迟轮询，避免在上游任务 ID 写入前被轮询
+func ClaimQueuedTask(id int64, queuedAt int64, nextPollAt int64) (bool, error) {
+	return ClaimQueuedTaskTx(DB, id, queuedAt, nextPollAt)
+}
+
+func ClaimQueuedTaskTx(tx *gorm.DB, id int64, queuedAt int64, nextPollAt int64) (bool, error) {
+	result := tx.Model(&Task{}).
+		Where("id = ? AND queued_at = ?", id, queuedAt).
+		Updates(map[string]any{
+			"queued_at":    0,
+			"status":       TaskStatusSubmitted,
+			"next_poll_at": nextPollAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// LockUserTasksTx 锁定用户行，使同一用户的并发数检查与任务写入在事务内串行执行（SQLite 本身串行写入，忽略行锁）
+func LockUserTasksTx(tx *gorm.DB, userId int) error {
+	var user User
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", userId).First(&user).Error
+}
+
+// DeleteReservedTask 删除提交失败的占位任务，仅在任务仍处于未开始状态时删除
+func DeleteReservedTask(id int64) error {
+	return DB.Where("id = ? AND status = ?", id, TaskStatusNotStart).Delete(&Task{}).Error
+}
+
+// DeleteStaleReservedTasks 删除创建早于 before、仍未开始且没有上游任务 ID 的占位任务，
+// 用于清理提交过程中进程退出而遗留、持续占用并发名额的占位任务，返回删除的数量
+func DeleteStaleReservedTasks(before int64, limit int) (int64, error) {
+	var tasks []*Task
+	err := DB.Select("id", "private_data").
+		Where("status = ? AND queued_at = ? AND created_at < ?", TaskStatusNotStart, 0, before).
+		Order("id").Limit(limit).Find(&tasks).Error
+	if err != nil {
+		return 0, err
+	}
+	// 上游任务 ID 存放在 private_data 中，不同数据库的 JSON 查询语法不一致，在内存中过滤
+	ids := make([]int64, 0, len(tasks))
+	for _, task := range tasks {
+		if task.PrivateData.UpstreamTaskID == "" {
+			ids = append(ids, task.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := DB.Where("id IN ? AND status = ?", ids, TaskStatusNotStart).Delete(&Task{})
+	return result.RowsAffected, result.Error
+}
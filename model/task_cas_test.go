@@ -26,6 +26,7 @@ func TestMain(m *testing.M) {
 	common.RedisEnabled = false
 	common.BatchUpdateEnabled = false
 	common.LogConsumeEnabled = true
+	initCol()
 
 	sqlDB, err := db.DB()
 	if err != nil {
@@ -33,7 +34,7 @@ func TestMain(m *testing.M) {
 	}
 	sqlDB.SetMaxOpenConns(1)
 
-	if err := db.AutoMigrate(&Task{}, &User{}, &Token{}, &Log{}, &Channel{}, &UserSubscription{}); err != nil {
+	if err := db.AutoMigrate(&Task{}, &User{}, &Token{}, &Log{}, &Channel{}, &UserSubscription{}, &ShadowComparison{}, &ChannelCanaryResult{}, &ChannelKeyStat{}, &TrafficSplitStat{}, &Model{}, &ClusterLease{}, &Midjourney{}); err != nil {
 		panic("failed to migrate: " + err.Error())
 	}
 
@@ -49,6 +50,13 @@ func truncateTables(t *testing.T) {
 		DB.Exec("DELETE FROM logs")
 		DB.Exec("DELETE FROM channels")
 		DB.Exec("DELETE FROM user_subscriptions")
+		DB.Exec("DELETE FROM shadow_comparisons")
+		DB.Exec("DELETE FROM channel_canary_results")
+		DB.Exec("DELETE FROM channel_key_stats")
+		DB.Exec("DELETE FROM traffic_split_stats")
+		DB.Exec("DELETE FROM models")
+		DB.Exec("DELETE FROM cluster_leases")
+		DB.Exec("DELETE FROM midjourneys")
 	})
 }
 
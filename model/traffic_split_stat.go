@@ -0,0 +1,160 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+// TrafficSplitStat 分流分支按小时汇总的请求数、错误数、耗时与额度/成本
+type TrafficSplitStat struct {
+	Id           int    `json:"id"`
+	RuleName     string `json:"rule_name" gorm:"type:varchar(64);index:idx_tss_rule_arm,priority:1"`
+	ArmName      string `json:"arm_name" gorm:"type:varchar(64);index:idx_tss_rule_arm,priority:2"`
+	CreatedAt    int64  `json:"created_at" gorm:"bigint;index"`
+	Count        int    `json:"count" gorm:"default:0"`
+	ErrorCount   int    `json:"error_count" gorm:"default:0"`
+	TotalLatency int64  `json:"total_latency" gorm:"bigint;default:0"` // 成功请求的总耗时，毫秒
+	Quota        int    `json:"quota" gorm:"default:0"`
+	Cost         int    `json:"cost" gorm:"default:0"`
+}
+
+// TrafficSplitReport 分支在时间范围内的汇总
+type TrafficSplitReport struct {
+	RuleName   string  `json:"rule_name"`
+	ArmName    string  `json:"arm_name"`
+	Count      int64   `json:"count"`
+	ErrorCount int64   `json:"error_count"`
+	ErrorRate  float64 `json:"error_rate"`
+	AvgLatency float64 `json:"avg_latency"` // 毫秒
+	Quota      int64   `json:"quota"`
+	Cost       int64   `json:"cost"`
+	Margin     int64   `json:"margin"`
+}
+
+var trafficSplitStatCache = make(map[string]*TrafficSplitStat)
+var trafficSplitStatCacheLock sync.Mutex
+
+func getTrafficSplitStatCache(ruleName string, armName string) *TrafficSplitStat {
+	createdAt := common.GetTimestamp()
+	// 只精确到小时
+	createdAt = createdAt - (createdAt % 3600)
+	key := fmt.Sprintf("%s-%s-%d", ruleName, armName, createdAt)
+	stat, ok := trafficSplitStatCache[key]
+	if !ok {
+		stat = &TrafficSplitStat{RuleName: ruleName, ArmName: armName, CreatedAt: createdAt}
+		trafficSplitStatCache[key] = stat
+	}
+	return stat
+}
+
+// RecordTrafficSplitSuccess 记录分支的一次成功请求
+func RecordTrafficSplitSuccess(ruleName string, armName string, latency int64, quota int, cost int) {
+	trafficSplitStatCacheLock.Lock()
+	defer trafficSplitStatCacheLock.Unlock()
+	stat := getTrafficSplitStatCache(ruleName, armName)
+	stat.Count++
+	stat.TotalLatency += latency
+	stat.Quota += quota
+	stat.Cost += cost
+}
+
+// RecordTrafficSplitError 记录分支的一次失败请求（所有重试均失败）
+func RecordTrafficSplitError(ruleName string, armName string) {
+	trafficSplitStatCacheLock.Lock()
+	defer trafficSplitStatCacheLock.Unlock()
+	stat := getTrafficSplitStatCache(ruleName, armName)
+	stat.Count++
+	stat.ErrorCount++
+}
+
+func saveTrafficSplitStat(delta *TrafficSplitStat) error {
+	existing := &TrafficSplitStat{}
+	err := DB.Where("rule_name = ? AND arm_name = ? AND created_at = ?", delta.RuleName, delta.ArmName, delta.CreatedAt).First(existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return DB.Create(delta).Error
+	}
+	if err != nil {
+		return err
+	}
+	return DB.Model(&TrafficSplitStat{}).Where("id = ?", existing.Id).Updates(map[string]interface{}{
+		"count":         gorm.Expr("count + ?", delta.Count),
+		"error_count":   gorm.Expr("error_count + ?", delta.ErrorCount),
+		"total_latency": gorm.Expr("total_latency + ?", delta.TotalLatency),
+		"quota":         gorm.Expr("quota + ?", delta.Quota),
+		"cost":          gorm.Expr("cost + ?", delta.Cost),
+	}).Error
+}
+
+func SaveTrafficSplitStatCache() {
+	trafficSplitStatCacheLock.Lock()
+	cache := trafficSplitStatCache
+	trafficSplitStatCache = make(map[string]*TrafficSplitStat)
+	trafficSplitStatCacheLock.Unlock()
+	for _, stat := range cache {
+		if err := saveTrafficSplitStat(stat); err != nil {
+			common.SysError(fmt.Sprintf("failed to save traffic split stat %s/%s: %s", stat.RuleName, stat.ArmName, err.Error()))
+		}
+	}
+}
+
+func UpdateTrafficSplitStats() {
+	for {
+		time.Sleep(time.Minute)
+		SaveTrafficSplitStatCache()
+	}
+}
+
+// GetTrafficSplitReport 按规则与分支汇总，ruleName 为空时返回所有规则
+func GetTrafficSplitReport(ruleName string, startTimestamp int64, endTimestamp int64) ([]*TrafficSplitReport, error) {
+	var reports []*TrafficSplitReport
+	tx := DB.Model(&TrafficSplitStat{}).Select(
+		"rule_name, arm_name, SUM(count) AS count, SUM(error_count) AS error_count, " +
+			"SUM(total_latency) AS total_latency, SUM(quota) AS quota, SUM(cost) AS cost",
+	)
+	if ruleName != "" {
+		tx = tx.Where("rule_name = ?", ruleName)
+	}
+	if startTimestamp > 0 {
+		tx = tx.Where("created_at >= ?", startTimestamp)
+	}
+	if endTimestamp > 0 {
+		tx = tx.Where("created_at <= ?", endTimestamp)
+	}
+	var rows []struct {
+		RuleName     string
+		ArmName      string
+		Count        int64
+		ErrorCount   int64
+		TotalLatency int64
+		Quota        int64
+		Cost         int64
+	}
+	if err := tx.Group("rule_name, arm_name").Order("rule_name, arm_name").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		report := &TrafficSplitReport{
+			RuleName:   row.RuleName,
+			ArmName:    row.ArmName,
+			Count:      row.Count,
+			ErrorCount: row.ErrorCount,
+			Quota:      row.Quota,
+			Cost:       row.Cost,
+			Margin:     row.Quota - row.Cost,
+		}
+		if row.Count > 0 {
+			report.ErrorRate = float64(row.ErrorCount) / float64(row.Count)
+		}
+		if succeeded := row.Count - row.ErrorCount; succeeded > 0 {
+			report.AvgLatency = float64(row.TotalLatency) / float64(succeeded)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTrafficSplitReport(t *testing.T) {
+	truncateTables(t)
+
+	RecordTrafficSplitSuccess("rollout", "control", 100, 50, 30)
+	RecordTrafficSplitSuccess("rollout", "control", 300, 50, 30)
+	RecordTrafficSplitError("rollout", "control")
+	SaveTrafficSplitStatCache()
+	RecordTrafficSplitSuccess("rollout", "control", 200, 50, 30)
+	RecordTrafficSplitSuccess("rollout", "candidate", 80, 40, 10)
+	RecordTrafficSplitSuccess("other", "a", 10, 1, 1)
+	SaveTrafficSplitStatCache()
+
+	reports, err := GetTrafficSplitReport("rollout", 0, 0)
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+
+	candidate, control := reports[0], reports[1]
+	require.Equal(t, "candidate", candidate.ArmName)
+	require.Equal(t, int64(1), candidate.Count)
+	require.Equal(t, int64(30), candidate.Margin)
+
+	require.Equal(t, "control", control.ArmName)
+	require.Equal(t, int64(4), control.Count)
+	require.Equal(t, int64(1), control.ErrorCount)
+	require.InDelta(t, 0.25, control.ErrorRate, 1e-9)
+	require.InDelta(t, 200, control.AvgLatency, 1e-9)
+	require.Equal(t, int64(150), control.Quota)
+	require.Equal(t, int64(90), control.Cost)
+}
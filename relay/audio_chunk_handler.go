@@ -0,0 +1,281 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// audioChunkRequest 长音频分片转写时各分片共享的请求信息
+type audioChunkRequest struct {
+	info           *relaycommon.RelayInfo
+	request        *dto.AudioRequest
+	form           *multipart.Form
+	fileName       string
+	upstreamFormat string
+	url            string
+	header         http.Header
+	keys           map[string]any
+}
+
+// prepareAudioChunks 音频文件超过上游大小限制且格式支持切分时返回切分结果，否则返回 nil 走原有的直接转发
+func prepareAudioChunks(c *gin.Context, info *relaycommon.RelayInfo) (*multipart.Form, string, []service.AudioChunk) {
+	setting := operation_setting.GetAudioChunkSetting()
+	if !setting.Enabled || setting.MaxFileSizeMB <= 0 {
+		return nil, "", nil
+	}
+	if info.RelayMode != relayconstant.RelayModeAudioTranscription && info.RelayMode != relayconstant.RelayModeAudioTranslation {
+		return nil, "", nil
+	}
+	maxBytes := setting.MaxFileSizeMB * 1024 * 1024
+	form, err := common.ParseMultipartFormReusable(c)
+	if err != nil || len(form.File["file"]) == 0 {
+		return nil, "", nil
+	}
+	fileHeader := form.File["file"][0]
+	if fileHeader.Size <= int64(maxBytes) {
+		return nil, "", nil
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, "", nil
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, "", nil
+	}
+
+	chunks, err := service.SplitAudioOnSilence(data, filepath.Ext(fileHeader.Filename), float64(setting.ChunkSeconds), maxBytes, float64(setting.SilenceSearchSeconds))
+	if err != nil {
+		if !errors.Is(err, service.ErrAudioChunkUnsupported) {
+			logger.LogWarn(c, fmt.Sprintf("split audio %s failed, forwarding as is: %v", fileHeader.Filename, err))
+		}
+		return nil, "", nil
+	}
+	if len(chunks) < 2 {
+		return nil, "", nil
+	}
+	return form, fileHeader.Filename, chunks
+}
+
+// AudioChunkedHelper 将切分后的音频分片并发转写（每个分片可落在不同渠道，失败时换渠道重试），
+// 合并为请求的 response_format 后返回，按总时长计费
+func AudioChunkedHelper(c *gin.Context, info *relaycommon.RelayInfo, request *dto.AudioRequest, form *multipart.Form, fileName string, chunks []service.AudioChunk) *types.NewAPIError {
+	setting := operation_setting.GetAudioChunkSetting()
+	logger.LogInfo(c, fmt.Sprintf("audio %s exceeds %dMB, transcribing in %d chunks", fileName, setting.MaxFileSizeMB, len(chunks)))
+
+	// 分片上下文复制原请求的用户、令牌等上下文，不含请求体缓存
+	keys := maps.Clone(c.Keys)
+	delete(keys, common.KeyBodyStorage)
+	delete(keys, common.KeyRequestBody)
+	delete(keys, "_original_multipart_ct")
+	shared := &audioChunkRequest{
+		info:           info,
+		request:        request,
+		form:           form,
+		fileName:       fileName,
+		upstreamFormat: service.TranscriptionUpstreamFormat(request.ResponseFormat),
+		url:            c.Request.URL.String(),
+		header:         c.Request.Header.Clone(),
+		keys:           keys,
+	}
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	results := make([]service.TranscriptionChunkResult, len(chunks))
+	var firstErr *types.NewAPIError
+	var errOnce sync.Once
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, max(1, setting.Concurrency))
+	for i, chunk := range chunks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			body, apiErr := transcribeAudioChunkWithRetry(ctx, c, shared, i, chunk)
+			if apiErr != nil {
+				errOnce.Do(func() {
+					firstErr = apiErr
+					cancel()
+				})
+				return
+			}
+			results[i] = service.TranscriptionChunkResult{Offset: chunk.Offset, Duration: chunk.Duration, Body: body}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		// 已在分片级别换渠道重试过，整体不再重试
+		types.ErrOptionWithSkipRetry()(firstErr)
+		return firstErr
+	}
+
+	task := "transcribe"
+	if info.RelayMode == relayconstant.RelayModeAudioTranslation {
+		task = "translate"
+	}
+	body, contentType, err := service.MergeTranscriptions(results, request.ResponseFormat, task)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeBadResponseBody, types.ErrOptionWithSkipRetry())
+	}
+	c.Data(http.StatusOK, contentType, body)
+
+	totalDuration := 0.0
+	for _, chunk := range chunks {
+		totalDuration += chunk.Duration
+	}
+	usage := &dto.Usage{}
+	usage.PromptTokens = service.GetAudioDurationTokens(totalDuration)
+	usage.TotalTokens = usage.PromptTokens
+	service.PostTextConsumeQuota(c, info, usage, nil)
+	return nil
+}
+
+// transcribeAudioChunkWithRetry 第一个分片沿用已选渠道，其余分片重新选择渠道以分散到多个渠道；失败后排除已失败渠道重试
+func transcribeAudioChunkWithRetry(ctx context.Context, c *gin.Context, shared *audioChunkRequest, index int, chunk service.AudioChunk) ([]byte, *types.NewAPIError) {
+	var failedChannels []int
+	var lastErr *types.NewAPIError
+	for retry := 0; retry <= common.RetryTimes; retry++ {
+		if ctx.Err() != nil {
+			break
+		}
+		var channel *model.Channel
+		if index > 0 || retry > 0 {
+			picked, err := model.GetRandomSatisfiedChannel(shared.info.UsingGroup, shared.info.OriginModelName, retry)
+			if err == nil && picked != nil && !slices.Contains(failedChannels, picked.Id) {
+				channel = picked
+			} else if retry > 0 {
+				continue
+			}
+		}
+		body, channelId, apiErr := transcribeAudioChunk(ctx, shared, channel, chunk)
+		if apiErr == nil {
+			return body, nil
+		}
+		lastErr = apiErr
+		failedChannels = append(failedChannels, channelId)
+		logger.LogWarn(c, fmt.Sprintf("audio chunk %d (channel #%d) failed: %s", index, channelId, apiErr.Error()))
+		if types.IsSkipRetryError(apiErr) {
+			break
+		}
+	}
+	if lastErr == nil {
+		lastErr = types.NewError(fmt.Errorf("audio chunk %d: no available channel", index), types.ErrorCodeGetChannelFailed)
+	}
+	return nil, lastErr
+}
+
+// transcribeAudioChunk 在独立的上下文中通过渠道适配器转写一个分片；channel 为 nil 时使用请求已选的渠道
+func transcribeAudioChunk(ctx context.Context, shared *audioChunkRequest, channel *model.Channel, chunk service.AudioChunk) ([]byte, int, *types.NewAPIError) {
+	formBody, contentType, err := buildAudioChunkForm(shared.form, shared.fileName, chunk.Data, shared.upstreamFormat)
+	if err != nil {
+		return nil, 0, types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+
+	w := httptest.NewRecorder()
+	cc, _ := gin.CreateTestContext(w)
+	cc.Request, err = http.NewRequestWithContext(ctx, http.MethodPost, shared.url, bytes.NewReader(formBody))
+	if err != nil {
+		return nil, 0, types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	cc.Request.Header = shared.header.Clone()
+	cc.Request.Header.Set("Content-Type", contentType)
+	cc.Keys = maps.Clone(shared.keys)
+	defer common.CleanupBodyStorage(cc)
+	if channel != nil {
+		if apiErr := middleware.SetupContextForSelectedChannel(cc, channel, shared.info.OriginModelName); apiErr != nil {
+			return nil, channel.Id, apiErr
+		}
+	}
+
+	request := *shared.request
+	request.ResponseFormat = shared.upstreamFormat
+	info := *shared.info
+	info.Request = &request
+	info.InitChannelMeta(cc)
+	if err = helper.ModelMappedHelper(cc, &info, &request); err != nil {
+		return nil, info.ChannelId, types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
+	}
+	adaptor := GetAdaptor(info.ApiType)
+	if adaptor == nil {
+		return nil, info.ChannelId, types.NewError(fmt.Errorf("invalid api type: %d", info.ApiType), types.ErrorCodeInvalidApiType)
+	}
+	adaptor.Init(&info)
+
+	ioReader, err := adaptor.ConvertAudioRequest(cc, &info, request)
+	if err != nil {
+		return nil, info.ChannelId, types.NewError(err, types.ErrorCodeConvertRequestFailed)
+	}
+	resp, err := adaptor.DoRequest(cc, &info, ioReader)
+	if err != nil {
+		return nil, info.ChannelId, types.NewError(err, types.ErrorCodeDoRequestFailed)
+	}
+	httpResp, _ := resp.(*http.Response)
+	if httpResp == nil {
+		return nil, info.ChannelId, types.NewError(errors.New("empty upstream response"), types.ErrorCodeBadResponse)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		apiErr := service.RelayErrorHandler(ctx, httpResp, false)
+		service.ResetStatusCode(apiErr, cc.GetString("status_code_mapping"))
+		return nil, info.ChannelId, apiErr
+	}
+	if _, apiErr := adaptor.DoResponse(cc, httpResp, &info); apiErr != nil {
+		return nil, info.ChannelId, apiErr
+	}
+	return w.Body.Bytes(), info.ChannelId, nil
+}
+
+// buildAudioChunkForm 以原请求的表单字段与分片音频重新构造 multipart 请求体
+func buildAudioChunkForm(form *multipart.Form, fileName string, data []byte, responseFormat string) ([]byte, string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for key, values := range form.Value {
+		if key == "response_format" {
+			continue
+		}
+		for _, value := range values {
+			if err := writer.WriteField(key, value); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	if err := writer.WriteField("response_format", responseFormat); err != nil {
+		return nil, "", err
+	}
+	part, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err = part.Write(data); err != nil {
+		return nil, "", err
+	}
+	if err = writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return body.Bytes(), writer.FormDataContentType(), nil
+}
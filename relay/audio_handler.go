@@ -28,6 +28,11 @@ func AudioHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *type
 		return types.NewError(fmt.Errorf("failed to copy request to AudioRequest: %w", err), types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
 	}
 
+	// 超过上游大小限制的长音频切分后分片转写
+	if form, fileName, chunks := prepareAudioChunks(c, info); len(chunks) > 0 {
+		return AudioChunkedHelper(c, info, request, form, fileName, chunks)
+	}
+
 	err = helper.ModelMappedHelper(c, info, request)
 	if err != nil {
 		return types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
@@ -81,3 +81,26 @@ type TaskAdaptor interface {
 type OpenAIVideoConverter interface {
 	ConvertToOpenAIVideo(originTask *model.Task) ([]byte, error)
 }
+
+// TaskCallbackReceiver 可选接口：支持上游完成回调的适配器实现此接口。
+// 提交时网关生成的 info.CallbackURL 由适配器写入请求体。回调只触发一次上游查询，
+// 回调内容经 NormalizeTaskCallback 转换为查询响应格式后仅用于核对上游任务 ID。
+type TaskCallbackReceiver interface {
+	NormalizeTaskCallback(body []byte) []byte
+}
+
+// TaskCanceler 可选接口：上游支持取消任务的适配器实现此接口。
+// 上游确认取消时返回 nil，任务已无法取消（如已完成）时返回错误。
+type TaskCanceler interface {
+	CancelTask(baseUrl, key string, upstreamTaskID string, proxy string) error
+}
+
+// ImageTaskAdaptor 可选接口：支持异步生图任务的适配器实现此接口。
+// /v1/images/generations 走任务流水线时 info.RelayMode 为 RelayModeImagesGenerations，
+// ValidateRequestAndSetAction 通过 relaycommon.ValidateImageTaskRequest 解析 dto.ImageRequest，
+// DoResponse 只解析上游任务 ID 而不写响应，由网关返回任务或等待结果。
+type ImageTaskAdaptor interface {
+	// WaitImageTaskOnSync 同步生图请求是否也提交任务并等待结果（上游仅提供异步接口时为 true）
+	WaitImageTaskOnSync() bool
+	ConvertToImageResponse(task *model.Task) (*dto.ImageResponse, error)
+}
@@ -31,6 +31,7 @@ type Adaptor struct {
 	AwsModelId string
 	AwsReq     any
 	IsNova     bool
+	IsImage    bool
 }
 
 func (a *Adaptor) ConvertGeminiRequest(*gin.Context, *relaycommon.RelayInfo, *dto.GeminiChatRequest) (any, error) {
@@ -81,8 +82,11 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInf
 }
 
 func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
-	//TODO implement me
-	return nil, errors.New("not implemented")
+	if !isImageModel(getAwsModelID(info.UpstreamModelName)) {
+		return nil, errors.New("not supported model for image generation, only titan, nova canvas and stability models are supported")
+	}
+	a.IsImage = true
+	return convertAwsImageRequest(c, info, request)
 }
 
 func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
@@ -96,6 +100,9 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 		if len(awsSecret) != 2 {
 			return "", errors.New("invalid aws api key, should be in format of <api-key>|<region>")
 		}
+		if a.IsImage {
+			return fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com/model/%s/invoke", awsSecret[1], awsModelId), nil
+		}
 		return fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com/model/%s/converse", awsModelId, awsSecret[1]), nil
 	} else {
 		a.ClientMode = ClientModeAKSK
@@ -104,7 +111,9 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 }
 
 func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Header, info *relaycommon.RelayInfo) error {
-	claude.CommonClaudeHeadersOperation(c, req, info)
+	if !a.IsImage {
+		claude.CommonClaudeHeadersOperation(c, req, info)
+	}
 	if a.ClientMode == ClientModeApiKey {
 		req.Set("Authorization", "Bearer "+info.ApiKey)
 	}
@@ -154,6 +163,14 @@ func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, request
 }
 
 func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *types.NewAPIError) {
+	if a.IsImage {
+		if a.ClientMode == ClientModeApiKey {
+			err, usage = awsImageHandler(c, info, resp)
+		} else {
+			err, usage = handleImageRequest(c, info, a)
+		}
+		return
+	}
 	if a.ClientMode == ClientModeApiKey {
 		claudeAdaptor := claude.Adaptor{}
 		usage, err = claudeAdaptor.DoResponse(c, resp, info)
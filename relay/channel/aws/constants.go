@@ -27,6 +27,12 @@ var awsModelIDMap = map[string]string{
 	"nova-reel-v1:0":    "amazon.nova-reel-v1:0",
 	"nova-reel-v1:1":    "amazon.nova-reel-v1:1",
 	"nova-sonic-v1:0":   "amazon.nova-sonic-v1:0",
+	// Image generation models
+	"titan-image-generator-v1": "amazon.titan-image-generator-v1",
+	"titan-image-generator-v2": "amazon.titan-image-generator-v2:0",
+	"sd3-5-large":              "stability.sd3-5-large-v1:0",
+	"stable-image-core":        "stability.stable-image-core-v1:1",
+	"stable-image-ultra":       "stability.stable-image-ultra-v1:1",
 }
 
 var awsModelCanCrossRegionMap = map[string]map[string]bool{
@@ -143,6 +149,18 @@ var awsRegionCrossModelPrefixMap = map[string]string{
 
 var ChannelName = "aws"
 
+// 判断是否为图片生成模型（Titan / Nova Canvas / Stability）
+func isImageModel(modelId string) bool {
+	return strings.Contains(modelId, "titan-image-generator") ||
+		strings.Contains(modelId, "nova-canvas") ||
+		strings.HasPrefix(modelId, "stability.")
+}
+
+// 判断是否为Stability模型
+func isStabilityModel(modelId string) bool {
+	return strings.HasPrefix(modelId, "stability.")
+}
+
 // 判断是否为Nova模型
 func isNovaModel(modelId string) bool {
 	return strings.Contains(modelId, "nova-")
@@ -0,0 +1,235 @@
+package aws
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+)
+
+// https://docs.aws.amazon.com/bedrock/latest/userguide/model-parameters-titan-image.html
+type awsTitanImageRequest struct {
+	TaskType              string                         `json:"taskType"`
+	TextToImageParams     *awsTitanTextToImageParams     `json:"textToImageParams,omitempty"`
+	ImageVariationParams  *awsTitanImageVariationParams  `json:"imageVariationParams,omitempty"`
+	ImageGenerationConfig *awsTitanImageGenerationConfig `json:"imageGenerationConfig,omitempty"`
+}
+
+type awsTitanTextToImageParams struct {
+	Text string `json:"text"`
+}
+
+type awsTitanImageVariationParams struct {
+	Text   string   `json:"text,omitempty"`
+	Images []string `json:"images"`
+}
+
+type awsTitanImageGenerationConfig struct {
+	NumberOfImages int    `json:"numberOfImages,omitempty"`
+	Width          int    `json:"width,omitempty"`
+	Height         int    `json:"height,omitempty"`
+	Quality        string `json:"quality,omitempty"`
+}
+
+// https://docs.aws.amazon.com/bedrock/latest/userguide/model-parameters-diffusion-3-text-image.html
+type awsStabilityImageRequest struct {
+	Prompt       string `json:"prompt"`
+	AspectRatio  string `json:"aspect_ratio,omitempty"`
+	OutputFormat string `json:"output_format,omitempty"`
+}
+
+// Titan / Nova Canvas 与 Stability 的响应都在 images 字段中返回 base64 图片
+type awsImageResponse struct {
+	Images        []string  `json:"images"`
+	Error         *string   `json:"error,omitempty"`
+	FinishReasons []*string `json:"finish_reasons,omitempty"`
+}
+
+var stabilityAspectRatios = map[string]bool{
+	"16:9": true, "1:1": true, "21:9": true, "2:3": true, "3:2": true,
+	"4:5": true, "5:4": true, "9:16": true, "9:21": true,
+}
+
+func parseImageSize(size string) (int, int) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(size)), "x")
+	if len(parts) != 2 {
+		return 1024, 1024
+	}
+	width, err1 := strconv.Atoi(parts[0])
+	height, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || width <= 0 || height <= 0 {
+		return 1024, 1024
+	}
+	return width, height
+}
+
+func sizeToStabilityAspectRatio(size string) string {
+	if strings.Contains(size, ":") {
+		if stabilityAspectRatios[size] {
+			return size
+		}
+		return "1:1"
+	}
+	width, height := parseImageSize(size)
+	a, b := width, height
+	for b != 0 {
+		a, b = b, a%b
+	}
+	ratio := fmt.Sprintf("%d:%d", width/a, height/a)
+	if stabilityAspectRatios[ratio] {
+		return ratio
+	}
+	return "1:1"
+}
+
+func convertAwsImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
+	awsModelId := getAwsModelID(info.UpstreamModelName)
+	if isStabilityModel(awsModelId) {
+		if info.RelayMode != relayconstant.RelayModeImagesGenerations {
+			return nil, errors.New("stability models on bedrock only support image generation")
+		}
+		return &awsStabilityImageRequest{
+			Prompt:       request.Prompt,
+			AspectRatio:  sizeToStabilityAspectRatio(request.Size),
+			OutputFormat: "png",
+		}, nil
+	}
+
+	width, height := parseImageSize(request.Size)
+	quality := "standard"
+	if request.Quality == "hd" || request.Quality == "high" {
+		quality = "premium"
+	}
+	titanRequest := &awsTitanImageRequest{
+		ImageGenerationConfig: &awsTitanImageGenerationConfig{
+			NumberOfImages: int(lo.FromPtrOr(request.N, uint(1))),
+			Width:          width,
+			Height:         height,
+			Quality:        quality,
+		},
+	}
+	if info.RelayMode == relayconstant.RelayModeImagesVariations {
+		images, err := readMultipartImagesAsBase64(c)
+		if err != nil {
+			return nil, err
+		}
+		titanRequest.TaskType = "IMAGE_VARIATION"
+		titanRequest.ImageVariationParams = &awsTitanImageVariationParams{
+			Text:   request.Prompt,
+			Images: images,
+		}
+	} else {
+		titanRequest.TaskType = "TEXT_IMAGE"
+		titanRequest.TextToImageParams = &awsTitanTextToImageParams{
+			Text: request.Prompt,
+		}
+	}
+	return titanRequest, nil
+}
+
+func readMultipartImagesAsBase64(c *gin.Context) ([]string, error) {
+	mf := c.Request.MultipartForm
+	if mf == nil {
+		if _, err := c.MultipartForm(); err != nil {
+			return nil, errors.New("failed to parse multipart form")
+		}
+		mf = c.Request.MultipartForm
+	}
+	if mf == nil || len(mf.File["image"]) == 0 {
+		return nil, errors.New("image is required")
+	}
+	images := make([]string, 0, len(mf.File["image"]))
+	for _, fileHeader := range mf.File["image"] {
+		file, err := fileHeader.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open image file: %w", err)
+		}
+		data, err := io.ReadAll(file)
+		_ = file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image file: %w", err)
+		}
+		images = append(images, base64.StdEncoding.EncodeToString(data))
+	}
+	return images, nil
+}
+
+func buildAwsImageInvokeInput(awsModelId string, requestBody io.Reader) (*bedrockruntime.InvokeModelInput, error) {
+	body, err := io.ReadAll(requestBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "read image request body")
+	}
+	return &bedrockruntime.InvokeModelInput{
+		ModelId:     aws.String(awsModelId),
+		Accept:      aws.String("application/json"),
+		ContentType: aws.String("application/json"),
+		Body:        body,
+	}, nil
+}
+
+// handleImageRequest 使用 AK/SK 客户端调用图片生成模型
+func handleImageRequest(c *gin.Context, info *relaycommon.RelayInfo, a *Adaptor) (*types.NewAPIError, *dto.Usage) {
+	ctx, cancel := newAwsInvokeContext()
+	defer cancel()
+
+	awsResp, err := a.AwsClient.InvokeModel(ctx, a.AwsReq.(*bedrockruntime.InvokeModelInput))
+	if err != nil {
+		statusCode := getAwsErrorStatusCode(err)
+		return types.NewOpenAIError(errors.Wrap(err, "InvokeModel"), types.ErrorCodeAwsInvokeError, statusCode), nil
+	}
+	return writeAwsImageResponse(c, info, awsResp.Body)
+}
+
+// awsImageHandler 处理 API Key 模式下 /invoke 接口返回的图片响应
+func awsImageHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*types.NewAPIError, *dto.Usage) {
+	defer service.CloseResponseBodyGracefully(resp)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError), nil
+	}
+	return writeAwsImageResponse(c, info, body)
+}
+
+func writeAwsImageResponse(c *gin.Context, info *relaycommon.RelayInfo, body []byte) (*types.NewAPIError, *dto.Usage) {
+	var awsResp awsImageResponse
+	if err := common.Unmarshal(body, &awsResp); err != nil {
+		return types.NewError(errors.Wrap(err, "unmarshal image response"), types.ErrorCodeBadResponseBody), nil
+	}
+	if awsResp.Error != nil && *awsResp.Error != "" {
+		return types.NewOpenAIError(errors.New(*awsResp.Error), types.ErrorCodeBadResponseBody, http.StatusBadRequest), nil
+	}
+	if len(awsResp.Images) == 0 {
+		return types.NewOpenAIError(errors.New("no images generated"), types.ErrorCodeBadResponseBody, http.StatusInternalServerError), nil
+	}
+
+	response := dto.ImageResponse{
+		Created: common.GetTimestamp(),
+		Data:    make([]dto.ImageData, 0, len(awsResp.Images)),
+	}
+	for i, image := range awsResp.Images {
+		// Stability 被内容过滤的图片会返回 finish_reason
+		if i < len(awsResp.FinishReasons) && awsResp.FinishReasons[i] != nil && *awsResp.FinishReasons[i] != "" {
+			continue
+		}
+		response.Data = append(response.Data, dto.ImageData{B64Json: image})
+	}
+	service.NormalizeImageResponse(c, info, &response)
+
+	c.JSON(http.StatusOK, response)
+	return nil, &dto.Usage{}
+}
@@ -0,0 +1,92 @@
+package aws
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertAwsImageRequest_TitanTextToImage(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/images/generations", nil)
+
+	n := uint(2)
+	info := &relaycommon.RelayInfo{
+		RelayMode:   relayconstant.RelayModeImagesGenerations,
+		ChannelMeta: &relaycommon.ChannelMeta{UpstreamModelName: "amazon.titan-image-generator-v2:0"},
+	}
+	converted, err := convertAwsImageRequest(ctx, info, dto.ImageRequest{
+		Prompt:  "a red fox",
+		Size:    "1024x512",
+		Quality: "hd",
+		N:       &n,
+	})
+	require.NoError(t, err)
+
+	titanReq, ok := converted.(*awsTitanImageRequest)
+	require.True(t, ok)
+	require.Equal(t, "TEXT_IMAGE", titanReq.TaskType)
+	require.Equal(t, "a red fox", titanReq.TextToImageParams.Text)
+	require.Equal(t, 2, titanReq.ImageGenerationConfig.NumberOfImages)
+	require.Equal(t, 1024, titanReq.ImageGenerationConfig.Width)
+	require.Equal(t, 512, titanReq.ImageGenerationConfig.Height)
+	require.Equal(t, "premium", titanReq.ImageGenerationConfig.Quality)
+}
+
+func TestConvertAwsImageRequest_StabilityAspectRatio(t *testing.T) {
+	t.Parallel()
+
+	info := &relaycommon.RelayInfo{
+		RelayMode:   relayconstant.RelayModeImagesGenerations,
+		ChannelMeta: &relaycommon.ChannelMeta{UpstreamModelName: "stability.sd3-5-large-v1:0"},
+	}
+	converted, err := convertAwsImageRequest(nil, info, dto.ImageRequest{Prompt: "a castle", Size: "1792x1024"})
+	require.NoError(t, err)
+
+	stabilityReq, ok := converted.(*awsStabilityImageRequest)
+	require.True(t, ok)
+	require.Equal(t, "a castle", stabilityReq.Prompt)
+	// 1792x1024 = 7:4，不在 Stability 支持列表中，回退为 1:1
+	require.Equal(t, "1:1", stabilityReq.AspectRatio)
+	require.Equal(t, "16:9", sizeToStabilityAspectRatio("1920x1080"))
+}
+
+func TestDoAwsClientRequest_ImageUsesInvokeModelInput(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/images/generations", nil)
+
+	info := &relaycommon.RelayInfo{
+		OriginModelName: "amazon.nova-canvas-v1:0",
+		ChannelMeta: &relaycommon.ChannelMeta{
+			ApiKey:            "access-key|secret-key|us-east-1",
+			UpstreamModelName: "amazon.nova-canvas-v1:0",
+		},
+	}
+	adaptor := &Adaptor{IsImage: true}
+	_, err := doAwsClientRequest(ctx, info, adaptor, bytes.NewBufferString(`{"taskType":"TEXT_IMAGE","textToImageParams":{"text":"hi"}}`))
+	require.NoError(t, err)
+
+	awsReq, ok := adaptor.AwsReq.(*bedrockruntime.InvokeModelInput)
+	require.True(t, ok)
+	require.True(t, strings.HasSuffix(*awsReq.ModelId, "amazon.nova-canvas-v1:0"))
+
+	var payload map[string]any
+	require.NoError(t, common.Unmarshal(awsReq.Body, &payload))
+	require.Equal(t, "TEXT_IMAGE", payload["taskType"])
+}
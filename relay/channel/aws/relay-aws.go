@@ -115,6 +115,15 @@ func doAwsClientRequest(c *gin.Context, info *relaycommon.RelayInfo, a *Adaptor,
 		requestHeader.Set(key, value)
 	}
 
+	if a.IsImage {
+		awsReq, err := buildAwsImageInvokeInput(awsModelId, requestBody)
+		if err != nil {
+			return nil, types.NewError(err, types.ErrorCodeBadRequestBody)
+		}
+		a.AwsReq = awsReq
+		return nil, nil
+	}
+
 	if isNovaModel(awsModelId) {
 		var novaReq *NovaRequest
 		err = common.DecodeJson(requestBody, &novaReq)
@@ -348,6 +348,31 @@ func RequestOpenAI2ClaudeMessage(c *gin.Context, textRequest dto.GeneralOpenAIRe
 					}
 					if mediaMessage.Type == "text" {
 						claudeMediaMessage.Text = common.GetPointer[string](mediaMessage.Text)
+					} else if mediaMessage.Type == dto.ContentTypeFile {
+						// OpenAI file（如 PDF）转换为 Claude document
+						file := mediaMessage.GetFile()
+						if file == nil || file.FileData == "" {
+							continue
+						}
+						var source *types.FileSource
+						if strings.HasPrefix(file.FileData, "http") {
+							source = types.NewURLFileSource(file.FileData)
+						} else {
+							source = types.NewBase64FileSource(file.FileData, "")
+						}
+						base64Data, mimeType, err := service.GetBase64Data(c, source, "formatting document for Claude")
+						if err != nil {
+							return nil, fmt.Errorf("get file data failed: %s", err.Error())
+						}
+						claudeMediaMessage.Type = "document"
+						claudeMediaMessage.Source = &dto.ClaudeMessageSource{
+							Type:      "base64",
+							MediaType: mimeType,
+							Data:      base64Data,
+						}
+					} else if mediaMessage.Type != dto.ContentTypeImageURL {
+						// Claude 不支持 input_audio / video_url 等内容
+						continue
 					} else {
 						imageUrl := mediaMessage.GetImageMedia()
 						claudeMediaMessage.Type = "image"
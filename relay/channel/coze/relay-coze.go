@@ -20,10 +20,21 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func convertCozeChatRequest(c *gin.Context, request dto.GeneralOpenAIRequest) *CozeChatRequest {
+func convertCozeChatRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.GeneralOpenAIRequest) *CozeChatRequest {
 	var messages []CozeEnterMessage
 	// 将 request的messages的role为user的content转换为CozeMessage
 	for _, message := range request.Messages {
+		// 工具模拟时需要保留协议提示词与历史工具调用：Coze 不支持 system 角色，按 user 消息发送
+		if info != nil && info.ToolEmulated {
+			switch message.Role {
+			case "system", "developer":
+				messages = append(messages, CozeEnterMessage{Role: "user", Content: message.StringContent(), ContentType: "text"})
+				continue
+			case "assistant":
+				messages = append(messages, CozeEnterMessage{Role: "assistant", Type: "answer", Content: message.StringContent(), ContentType: "text"})
+				continue
+			}
+		}
 		if message.Role == "user" {
 			messages = append(messages, CozeEnterMessage{
 				Role:    "user",
@@ -86,7 +97,25 @@ func cozeChatHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Res
 			FinishReason: "stop",
 		},
 	}
-	jsonResponse, err := json.Marshal(response)
+	var jsonResponse []byte
+	if info.ToolEmulated {
+		textResponse := dto.OpenAITextResponse{
+			Id:      response.Id,
+			Model:   response.Model,
+			Object:  "chat.completion",
+			Created: response.Created,
+			Choices: response.Choices,
+			Usage:   response.Usage,
+		}
+		var text string
+		if common.Unmarshal(responseContent, &text) == nil {
+			textResponse.Choices[0].Message.SetStringContent(text)
+		}
+		service.ApplyEmulatedToolCallsToResponse(&textResponse)
+		jsonResponse, err = common.Marshal(textResponse)
+	} else {
+		jsonResponse, err = json.Marshal(response)
+	}
 	if err != nil {
 		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
 	}
@@ -107,6 +136,10 @@ func cozeChatStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *ht
 	var currentEvent string
 	var currentData string
 	var usage = &dto.Usage{}
+	var toolEmulationParser *service.ToolEmulationStreamParser
+	if info.ToolEmulated {
+		toolEmulationParser = service.NewToolEmulationStreamParser()
+	}
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -114,7 +147,7 @@ func cozeChatStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *ht
 		if line == "" {
 			if currentEvent != "" && currentData != "" {
 				// handle last event
-				handleCozeEvent(c, currentEvent, currentData, &responseText, usage, id, info)
+				handleCozeEvent(c, currentEvent, currentData, &responseText, usage, id, info, toolEmulationParser)
 				currentEvent = ""
 				currentData = ""
 			}
@@ -134,7 +167,7 @@ func cozeChatStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *ht
 
 	// Last event
 	if currentEvent != "" && currentData != "" {
-		handleCozeEvent(c, currentEvent, currentData, &responseText, usage, id, info)
+		handleCozeEvent(c, currentEvent, currentData, &responseText, usage, id, info, toolEmulationParser)
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -149,7 +182,7 @@ func cozeChatStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *ht
 	return usage, nil
 }
 
-func handleCozeEvent(c *gin.Context, event string, data string, responseText *string, usage *dto.Usage, id string, info *relaycommon.RelayInfo) {
+func handleCozeEvent(c *gin.Context, event string, data string, responseText *string, usage *dto.Usage, id string, info *relaycommon.RelayInfo, toolEmulationParser *service.ToolEmulationStreamParser) {
 	switch event {
 	case "conversation.chat.completed":
 		// 将 data 解析为 CozeChatResponseData
@@ -166,6 +199,7 @@ func handleCozeEvent(c *gin.Context, event string, data string, responseText *st
 
 		finishReason := "stop"
 		stopResponse := helper.GenerateStopResponse(id, common.GetTimestamp(), info.UpstreamModelName, finishReason)
+		toolEmulationParser.ProcessChunk(stopResponse)
 		helper.ObjectData(c, stopResponse)
 
 	case "conversation.message.delta":
@@ -198,6 +232,7 @@ func handleCozeEvent(c *gin.Context, event string, data string, responseText *st
 		}
 		choice.Delta.SetContentString(content)
 		openaiResponse.Choices = append(openaiResponse.Choices, choice)
+		toolEmulationParser.ProcessChunk(&openaiResponse)
 
 		helper.ObjectData(c, openaiResponse)
 
@@ -0,0 +1,73 @@
+package coze
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func newToolEmulationInfo() *relaycommon.RelayInfo {
+	return &relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{
+		ChannelSetting:    dto.ChannelSettings{EmulateTools: true},
+		UpstreamModelName: "bot",
+	}}
+}
+
+func TestConvertOpenAIRequest_ToolEmulation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	var request dto.GeneralOpenAIRequest
+	require.NoError(t, common.UnmarshalJsonStr(`{
+		"model": "bot",
+		"user": "u1",
+		"messages": [{"role": "user", "content": "weather in Paris?"}],
+		"tools": [{"type": "function", "function": {"name": "get_weather", "description": "Get weather", "parameters": {"type": "object"}}}]
+	}`, &request))
+
+	info := newToolEmulationInfo()
+	converted, err := (&Adaptor{}).ConvertOpenAIRequest(c, info, &request)
+	require.NoError(t, err)
+	require.True(t, info.ToolEmulated)
+
+	cozeRequest := converted.(*CozeChatRequest)
+	require.Len(t, cozeRequest.AdditionalMessages, 2)
+	require.Equal(t, "user", cozeRequest.AdditionalMessages[0].Role)
+	require.Contains(t, cozeRequest.AdditionalMessages[0].Content, "get_weather: Get weather")
+	require.Equal(t, "weather in Paris?", cozeRequest.AdditionalMessages[1].Content)
+}
+
+func TestCozeChatHandler_ParsesEmulatedToolCalls(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+
+	answer := "<tool_call>\n{\"name\": \"get_weather\", \"arguments\": {\"city\": \"Paris\"}}\n</tool_call>"
+	content, err := common.Marshal(answer)
+	require.NoError(t, err)
+	body, err := common.Marshal(CozeChatDetailResponse{Data: []CozeChatV3MessageDetail{{Type: "answer", Content: content}}})
+	require.NoError(t, err)
+	resp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(body))}
+
+	info := newToolEmulationInfo()
+	info.ToolEmulated = true
+	_, apiErr := cozeChatHandler(c, info, resp)
+	require.Nil(t, apiErr)
+
+	var response dto.OpenAITextResponse
+	require.NoError(t, common.Unmarshal(recorder.Body.Bytes(), &response))
+	require.Len(t, response.Choices, 1)
+	require.Equal(t, "tool_calls", response.Choices[0].FinishReason)
+	toolCalls := response.Choices[0].Message.ParseToolCalls()
+	require.Len(t, toolCalls, 1)
+	require.Equal(t, "get_weather", toolCalls[0].Function.Name)
+	require.JSONEq(t, `{"city":"Paris"}`, toolCalls[0].Function.Arguments)
+}
@@ -1,17 +1,20 @@
 package gemini
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 
+	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/relay/channel"
 	"github.com/QuantumNous/new-api/relay/channel/openai"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/model_setting"
 	"github.com/QuantumNous/new-api/setting/reasoning"
 	"github.com/QuantumNous/new-api/types"
@@ -21,6 +24,8 @@ import (
 )
 
 type Adaptor struct {
+	// 语音合成时客户端要求流式返回，向上游请求 streamGenerateContent
+	speechStream bool
 }
 
 func (a *Adaptor) ConvertGeminiRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeminiChatRequest) (any, error) {
@@ -53,39 +58,75 @@ func (a *Adaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayIn
 }
 
 func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.AudioRequest) (io.Reader, error) {
-	//TODO implement me
-	return nil, errors.New("not implemented")
+	if info.RelayMode != constant.RelayModeAudioSpeech {
+		return nil, errors.New("unsupported audio relay mode")
+	}
+	// Gemini 只输出 pcm，仅支持 pcm 及由网关补文件头的 wav
+	if _, err := service.NegotiateSpeechFormat(request.ResponseFormat, "pcm"); err != nil {
+		return nil, err
+	}
+	geminiRequest, err := buildGeminiTTSRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	a.speechStream = request.StreamFormat != ""
+	jsonData, err := common.Marshal(geminiRequest)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling gemini TTS request: %w", err)
+	}
+	return bytes.NewReader(jsonData), nil
 }
 
-func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
-	if !strings.HasPrefix(info.UpstreamModelName, "imagen") {
-		return nil, errors.New("not supported model for image generation, only imagen models are supported")
-	}
+// IsImagenModel 判断是否为走 :predict 接口的 Imagen 系列模型（含 Vertex 的 imagegeneration@xxx）
+func IsImagenModel(modelName string) bool {
+	return strings.HasPrefix(modelName, "imagen") || strings.HasPrefix(modelName, "imagegeneration")
+}
 
-	// convert size to aspect ratio but allow user to specify aspect ratio
+// sizeToAspectRatio 将 OpenAI 的 size 转换为 Gemini 的宽高比，允许直接传入 "16:9" 这类宽高比
+func sizeToAspectRatio(size string) string {
 	aspectRatio := "1:1" // default aspect ratio
-	size := strings.TrimSpace(request.Size)
-	if size != "" {
-		if strings.Contains(size, ":") {
-			aspectRatio = size
-		} else {
-			switch size {
-			case "256x256", "512x512", "1024x1024":
-				aspectRatio = "1:1"
-			case "1536x1024":
-				aspectRatio = "3:2"
-			case "1024x1536":
-				aspectRatio = "2:3"
-			case "1024x1792":
-				aspectRatio = "9:16"
-			case "1792x1024":
-				aspectRatio = "16:9"
-			}
-		}
+	size = strings.TrimSpace(size)
+	if size == "" {
+		return aspectRatio
+	}
+	if strings.Contains(size, ":") {
+		return size
+	}
+	switch size {
+	case "256x256", "512x512", "1024x1024":
+		aspectRatio = "1:1"
+	case "1536x1024":
+		aspectRatio = "3:2"
+	case "1024x1536":
+		aspectRatio = "2:3"
+	case "1024x1792":
+		aspectRatio = "9:16"
+	case "1792x1024":
+		aspectRatio = "16:9"
+	}
+	return aspectRatio
+}
+
+func isImageRelayMode(relayMode int) bool {
+	return relayMode == constant.RelayModeImagesGenerations ||
+		relayMode == constant.RelayModeImagesEdits ||
+		relayMode == constant.RelayModeImagesVariations
+}
+
+func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
+	if model_setting.IsGeminiModelSupportImagine(info.UpstreamModelName) {
+		return convertImageRequestToGeminiChat(request), nil
+	}
+	if !IsImagenModel(info.UpstreamModelName) {
+		return nil, errors.New("not supported model for image generation, only imagen and gemini image models are supported")
 	}
+	return ConvertImageRequestToImagen(request), nil
+}
 
+// ConvertImageRequestToImagen 构建 Imagen :predict 请求
+func ConvertImageRequestToImagen(request dto.ImageRequest) *dto.GeminiImageRequest {
 	// build gemini imagen request
-	geminiRequest := dto.GeminiImageRequest{
+	geminiRequest := &dto.GeminiImageRequest{
 		Instances: []dto.GeminiImageInstance{
 			{
 				Prompt: request.Prompt,
@@ -93,7 +134,7 @@ func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInf
 		},
 		Parameters: dto.GeminiImageParameters{
 			SampleCount:      int(lo.FromPtrOr(request.N, uint(1))),
-			AspectRatio:      aspectRatio,
+			AspectRatio:      sizeToAspectRatio(request.Size),
 			PersonGeneration: "allow_adult", // default allow adult
 		},
 	}
@@ -120,7 +161,26 @@ func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInf
 		geminiRequest.Parameters.ImageSize = imageSize
 	}
 
-	return geminiRequest, nil
+	return geminiRequest
+}
+
+// convertImageRequestToGeminiChat 为 gemini-*-image 这类原生出图模型构建 generateContent 请求
+func convertImageRequestToGeminiChat(request dto.ImageRequest) *dto.GeminiChatRequest {
+	imageConfig, _ := common.Marshal(map[string]string{
+		"aspectRatio": sizeToAspectRatio(request.Size),
+	})
+	return &dto.GeminiChatRequest{
+		Contents: []dto.GeminiChatContent{
+			{
+				Role:  "user",
+				Parts: []dto.GeminiPart{{Text: request.Prompt}},
+			},
+		},
+		GenerationConfig: dto.GeminiChatGenerationConfig{
+			ResponseModalities: []string{"TEXT", "IMAGE"},
+			ImageConfig:        imageConfig,
+		},
+	}
 }
 
 func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
@@ -146,7 +206,7 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 
 	version := model_setting.GetGeminiVersionSetting(info.UpstreamModelName)
 
-	if strings.HasPrefix(info.UpstreamModelName, "imagen") {
+	if IsImagenModel(info.UpstreamModelName) {
 		return fmt.Sprintf("%s/%s/models/%s:predict", info.ChannelBaseUrl, version, info.UpstreamModelName), nil
 	}
 
@@ -161,7 +221,7 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 	}
 
 	action := "generateContent"
-	if info.IsStream {
+	if info.IsStream || a.speechStream {
 		action = "streamGenerateContent?alt=sse"
 		if info.RelayMode == constant.RelayModeGemini {
 			info.DisablePing = true
@@ -259,10 +319,18 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 		}
 	}
 
-	if strings.HasPrefix(info.UpstreamModelName, "imagen") {
+	if info.RelayMode == constant.RelayModeAudioSpeech {
+		return GeminiTTSHandler(c, info, resp, a.speechStream)
+	}
+
+	if IsImagenModel(info.UpstreamModelName) {
 		return GeminiImageHandler(c, info, resp)
 	}
 
+	if isImageRelayMode(info.RelayMode) {
+		return GeminiChatImageHandler(c, info, resp)
+	}
+
 	// check if the model is an embedding model
 	if strings.HasPrefix(info.UpstreamModelName, "text-embedding") ||
 		strings.HasPrefix(info.UpstreamModelName, "embedding") ||
@@ -237,6 +237,7 @@ func CovertOpenAI2Gemini(c *gin.Context, textRequest dto.GeneralOpenAIRequest, i
 	}
 
 	adaptorWithExtraBody := false
+	var extraSafetySettings []dto.GeminiChatSafetySettings
 
 	// patch extra_body
 	if len(textRequest.ExtraBody) > 0 {
@@ -315,6 +316,18 @@ func CovertOpenAI2Gemini(c *gin.Context, textRequest dto.GeneralOpenAIRequest, i
 				}
 			}
 
+			// eg. {"google":{"safety_settings":[{"category":"HARM_CATEGORY_HARASSMENT","threshold":"BLOCK_NONE"}],"cached_content":"cachedContents/xxx"}}
+			if rawSafetySettings, ok := googleBody["safety_settings"]; ok {
+				settings, err := common.Any2Type[[]dto.GeminiChatSafetySettings](rawSafetySettings)
+				if err != nil {
+					return nil, fmt.Errorf("invalid extra_body.google.safety_settings: %w", err)
+				}
+				extraSafetySettings = settings
+			}
+			if cachedContent, ok := googleBody["cached_content"].(string); ok {
+				geminiRequest.CachedContent = cachedContent
+			}
+
 			// check error param name like imageConfig, should be image_config
 			if _, hasErrorParam := googleBody["imageConfig"]; hasErrorParam {
 				return nil, errors.New("extra_body.google.imageConfig is not supported, use extra_body.google.image_config instead")
@@ -361,12 +374,13 @@ func CovertOpenAI2Gemini(c *gin.Context, textRequest dto.GeneralOpenAIRequest, i
 			Threshold: model_setting.GetGeminiSafetySetting(category),
 		})
 	}
-	geminiRequest.SafetySettings = safetySettings
+	geminiRequest.SafetySettings = mergeGeminiSafetySettings(safetySettings, extraSafetySettings)
 
 	// openaiContent.FuncToToolCalls()
-	if textRequest.Tools != nil {
+	if textRequest.Tools != nil || textRequest.WebSearchOptions != nil {
 		functions := make([]dto.FunctionRequest, 0, len(textRequest.Tools))
-		googleSearch := false
+		// web_search_options 等价于 Gemini 的 googleSearch 工具
+		googleSearch := textRequest.WebSearchOptions != nil
 		codeExecution := false
 		urlContext := false
 		for _, tool := range textRequest.Tools {
@@ -1589,6 +1603,7 @@ func GeminiImageHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.
 			B64Json: prediction.BytesBase64Encoded,
 		})
 	}
+	service.NormalizeImageResponse(c, info, &openAIResponse)
 
 	jsonResponse, jsonErr := json.Marshal(openAIResponse)
 	if jsonErr != nil {
@@ -1613,6 +1628,59 @@ func GeminiImageHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.
 	return usage, nil
 }
 
+// GeminiChatImageHandler 将 gemini-*-image 模型的 generateContent 响应转换为 OpenAI 图片响应
+func GeminiChatImageHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	responseBody, readErr := io.ReadAll(resp.Body)
+	if readErr != nil {
+		return nil, types.NewOpenAIError(readErr, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	service.CloseResponseBodyGracefully(resp)
+
+	var geminiResponse dto.GeminiChatResponse
+	if jsonErr := common.Unmarshal(responseBody, &geminiResponse); jsonErr != nil {
+		return nil, types.NewOpenAIError(jsonErr, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+
+	openAIResponse := dto.ImageResponse{
+		Created: common.GetTimestamp(),
+		Data:    make([]dto.ImageData, 0),
+	}
+	var revisedPrompt strings.Builder
+	for _, candidate := range geminiResponse.Candidates {
+		for _, part := range candidate.Content.Parts {
+			if part.InlineData != nil && strings.HasPrefix(part.InlineData.MimeType, "image/") {
+				openAIResponse.Data = append(openAIResponse.Data, dto.ImageData{
+					B64Json: part.InlineData.Data,
+				})
+			} else if part.Text != "" && !part.Thought {
+				revisedPrompt.WriteString(part.Text)
+			}
+		}
+	}
+	if len(openAIResponse.Data) == 0 {
+		return nil, types.NewOpenAIError(errors.New("no images generated"), types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	if revisedPrompt.Len() > 0 {
+		openAIResponse.Data[0].RevisedPrompt = revisedPrompt.String()
+	}
+	service.NormalizeImageResponse(c, info, &openAIResponse)
+
+	jsonResponse, jsonErr := common.Marshal(openAIResponse)
+	if jsonErr != nil {
+		return nil, types.NewError(jsonErr, types.ErrorCodeBadResponseBody)
+	}
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.WriteHeader(resp.StatusCode)
+	_, _ = c.Writer.Write(jsonResponse)
+
+	usage := &dto.Usage{
+		PromptTokens:     geminiResponse.UsageMetadata.PromptTokenCount,
+		CompletionTokens: geminiResponse.UsageMetadata.CandidatesTokenCount,
+		TotalTokens:      geminiResponse.UsageMetadata.TotalTokenCount,
+	}
+	return usage, nil
+}
+
 type GeminiModelsResponse struct {
 	Models        []dto.GeminiModel `json:"models"`
 	NextPageToken string            `json:"nextPageToken"`
@@ -1686,6 +1754,24 @@ func FetchGeminiModels(baseURL, apiKey, proxyURL string) ([]string, error) {
 	return allModels, nil
 }
 
+// mergeGeminiSafetySettings 使用请求中显式指定的安全设置覆盖同类别的全局默认值
+func mergeGeminiSafetySettings(defaults []dto.GeminiChatSafetySettings, overrides []dto.GeminiChatSafetySettings) []dto.GeminiChatSafetySettings {
+	if len(overrides) == 0 {
+		return defaults
+	}
+	merged := make([]dto.GeminiChatSafetySettings, 0, len(defaults)+len(overrides))
+	overridden := make(map[string]bool, len(overrides))
+	for _, setting := range overrides {
+		overridden[setting.Category] = true
+	}
+	for _, setting := range defaults {
+		if !overridden[setting.Category] {
+			merged = append(merged, setting)
+		}
+	}
+	return append(merged, overrides...)
+}
+
 // convertToolChoiceToGeminiConfig converts OpenAI tool_choice to Gemini toolConfig
 // OpenAI tool_choice values:
 //   - "auto": Let the model decide (default)
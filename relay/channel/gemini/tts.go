@@ -0,0 +1,153 @@
+package gemini
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultGeminiTTSVoice = "Kore"
+
+// OpenAI 音色映射到风格相近的 Gemini 预置音色，其余名称视为 Gemini 音色直接透传
+var openAIToGeminiVoiceMap = map[string]string{
+	"alloy":   "Kore",
+	"ash":     "Charon",
+	"ballad":  "Sulafat",
+	"coral":   "Aoede",
+	"echo":    "Puck",
+	"fable":   "Fenrir",
+	"nova":    "Leda",
+	"onyx":    "Orus",
+	"sage":    "Zephyr",
+	"shimmer": "Callirrhoe",
+	"verse":   "Enceladus",
+}
+
+type geminiSpeechConfig struct {
+	VoiceConfig geminiVoiceConfig `json:"voiceConfig"`
+}
+
+type geminiVoiceConfig struct {
+	PrebuiltVoiceConfig geminiPrebuiltVoiceConfig `json:"prebuiltVoiceConfig"`
+}
+
+type geminiPrebuiltVoiceConfig struct {
+	VoiceName string `json:"voiceName"`
+}
+
+func mapGeminiVoice(voice string) string {
+	if voice == "" {
+		return defaultGeminiTTSVoice
+	}
+	if mapped, ok := openAIToGeminiVoiceMap[voice]; ok {
+		return mapped
+	}
+	return voice
+}
+
+// buildGeminiTTSRequest 将 OpenAI speech 请求转换为 responseModalities=AUDIO 的 generateContent 请求，
+// Gemini TTS 通过自然语言控制语气，instructions 拼接在文本之前
+func buildGeminiTTSRequest(request dto.AudioRequest) (*dto.GeminiChatRequest, error) {
+	text := request.Input
+	if request.Instructions != "" {
+		text = request.Instructions + "\n\n" + request.Input
+	}
+	speechConfig, err := common.Marshal(geminiSpeechConfig{
+		VoiceConfig: geminiVoiceConfig{
+			PrebuiltVoiceConfig: geminiPrebuiltVoiceConfig{VoiceName: mapGeminiVoice(request.Voice)},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	geminiRequest := &dto.GeminiChatRequest{
+		Contents: []dto.GeminiChatContent{{
+			Role:  "user",
+			Parts: []dto.GeminiPart{{Text: text}},
+		}},
+		GenerationConfig: dto.GeminiChatGenerationConfig{
+			ResponseModalities: []string{"AUDIO"},
+			SpeechConfig:       speechConfig,
+		},
+	}
+	// 同步扩展字段的厂商自定义metadata，如多人对话的 multiSpeakerVoiceConfig
+	if len(request.Metadata) > 0 {
+		if err = json.Unmarshal(request.Metadata, geminiRequest); err != nil {
+			return nil, fmt.Errorf("error unmarshalling metadata to gemini request: %w", err)
+		}
+	}
+	return geminiRequest, nil
+}
+
+// GeminiTTSHandler Gemini TTS 输出 24kHz 16bit 单声道 PCM（audio/L16），按客户端格式补 WAV 文件头后返回，
+// 输入按字符、输出按音频时长计费
+func GeminiTTSHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response, stream bool) (*dto.Usage, *types.NewAPIError) {
+	defer service.CloseResponseBodyGracefully(resp)
+	writer := helper.NewSpeechWriter(c, info, "pcm", service.SpeechPCMSampleRate)
+
+	writeResponse := func(response *dto.GeminiChatResponse) error {
+		for _, candidate := range response.Candidates {
+			for _, part := range candidate.Content.Parts {
+				if part.InlineData == nil || !strings.HasPrefix(part.InlineData.MimeType, "audio/") {
+					continue
+				}
+				audio, err := base64.StdEncoding.DecodeString(part.InlineData.Data)
+				if err != nil {
+					return fmt.Errorf("failed to decode gemini audio data: %w", err)
+				}
+				if err = writer.WriteAudio(audio); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if stream {
+		err := helper.ScanSpeechEvents(resp.Body, func(data string) error {
+			var response dto.GeminiChatResponse
+			if err := common.UnmarshalJsonStr(data, &response); err != nil {
+				return fmt.Errorf("failed to unmarshal gemini TTS stream chunk: %w", err)
+			}
+			return writeResponse(&response)
+		})
+		if err != nil {
+			return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponse, http.StatusInternalServerError)
+		}
+	} else {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+		}
+		var response dto.GeminiChatResponse
+		if err = common.Unmarshal(body, &response); err != nil {
+			return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+		}
+		if err = writeResponse(&response); err != nil {
+			return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+		}
+	}
+
+	duration, err := writer.Duration()
+	if err != nil {
+		return nil, types.NewOpenAIError(errors.New("no audio data in gemini TTS response"), types.ErrorCodeBadResponse, http.StatusInternalServerError)
+	}
+	usage := service.BuildSpeechUsage(info.GetEstimatePromptTokens(), duration)
+	if err = writer.Finish(usage); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponse, http.StatusInternalServerError)
+	}
+	return usage, nil
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/QuantumNous/new-api/relay/channel/openai"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -21,6 +22,8 @@ import (
 )
 
 type Adaptor struct {
+	// 语音合成时上游实际输出的音频格式
+	audioFormat string
 }
 
 func (a *Adaptor) ConvertGeminiRequest(*gin.Context, *relaycommon.RelayInfo, *dto.GeminiChatRequest) (any, error) {
@@ -39,7 +42,15 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInf
 
 	voiceID := request.Voice
 	speed := lo.FromPtrOr(request.Speed, 0.0)
-	outputFormat := request.ResponseFormat
+	stream := request.StreamFormat != ""
+	// 流式合成不支持 wav，改为 pcm 由网关补文件头
+	outputFormat, err := service.NegotiateSpeechFormat(request.ResponseFormat, "mp3", "pcm", "flac", "wav")
+	if err != nil {
+		return nil, err
+	}
+	if stream && outputFormat == "wav" {
+		outputFormat = "pcm"
+	}
 
 	minimaxRequest := MiniMaxTTSRequest{
 		Model: info.OriginModelName,
@@ -51,7 +62,15 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInf
 		AudioSetting: &AudioSetting{
 			Format: outputFormat,
 		},
-		OutputFormat: outputFormat,
+		// 以 hex 返回音频由网关转换格式并计算时长，需要直链时可通过 metadata 指定 output_format=url
+		OutputFormat: "hex",
+	}
+	if outputFormat == "pcm" {
+		minimaxRequest.AudioSetting.SampleRate = service.SpeechPCMSampleRate
+	}
+	if stream {
+		minimaxRequest.Stream = true
+		minimaxRequest.StreamOptions = &StreamOptions{ExcludeAggregatedAudio: true}
 	}
 
 	// 同步扩展字段的厂商自定义metadata
@@ -60,19 +79,15 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInf
 			return nil, fmt.Errorf("error unmarshalling metadata to minimax request: %w", err)
 		}
 	}
+	a.audioFormat = outputFormat
+	if minimaxRequest.AudioSetting != nil && minimaxRequest.AudioSetting.Format != "" {
+		a.audioFormat = minimaxRequest.AudioSetting.Format
+	}
 
 	jsonData, err := json.Marshal(minimaxRequest)
 	if err != nil {
 		return nil, fmt.Errorf("error marshalling minimax request: %w", err)
 	}
-	if outputFormat != "hex" {
-		outputFormat = "url"
-	}
-
-	c.Set("response_format", outputFormat)
-
-	// Debug: log the request structure
-	// fmt.Printf("MiniMax TTS Request: %s\n", string(jsonData))
 
 	return bytes.NewReader(jsonData), nil
 }
@@ -119,7 +134,7 @@ func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, request
 
 func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *types.NewAPIError) {
 	if info.RelayMode == constant.RelayModeAudioSpeech {
-		return handleTTSResponse(c, resp, info)
+		return handleTTSResponse(c, resp, info, a.audioFormat)
 	}
 
 	switch info.RelayFormat {
@@ -9,8 +9,12 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/types"
 	"github.com/gin-gonic/gin"
 )
@@ -82,6 +86,7 @@ type MiniMaxTTSData struct {
 }
 
 type MiniMaxExtraInfo struct {
+	AudioLength     int64 `json:"audio_length"`
 	UsageCharacters int64 `json:"usage_characters"`
 }
 
@@ -90,62 +95,79 @@ type MiniMaxBaseResp struct {
 	StatusMsg  string `json:"status_msg"`
 }
 
-func getContentTypeByFormat(format string) string {
-	contentTypeMap := map[string]string{
-		"mp3":  "audio/mpeg",
-		"wav":  "audio/wav",
-		"flac": "audio/flac",
-		"aac":  "audio/aac",
-		"pcm":  "audio/pcm",
-	}
-	if ct, ok := contentTypeMap[format]; ok {
-		return ct
-	}
-	return "audio/mpeg" // default to mp3
-}
-
-func handleTTSResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *types.NewAPIError) {
-	body, readErr := io.ReadAll(resp.Body)
-	if readErr != nil {
-		return nil, types.NewErrorWithStatusCode(
-			fmt.Errorf("failed to read minimax response: %w", readErr),
-			types.ErrorCodeReadResponseBodyFailed,
-			http.StatusInternalServerError,
-		)
-	}
-	defer resp.Body.Close()
+func handleTTSResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo, audioFormat string) (usage any, err *types.NewAPIError) {
+	defer service.CloseResponseBodyGracefully(resp)
+	writer := helper.NewSpeechWriter(c, info, audioFormat, service.SpeechPCMSampleRate)
+
+	var extraInfo MiniMaxExtraInfo
+	if writer.IsStream() && strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
+		scanErr := helper.ScanSpeechEvents(resp.Body, func(data string) error {
+			var chunk MiniMaxTTSResponse
+			if unmarshalErr := common.UnmarshalJsonStr(data, &chunk); unmarshalErr != nil {
+				return fmt.Errorf("failed to unmarshal minimax TTS stream chunk: %w", unmarshalErr)
+			}
+			if chunk.BaseResp.StatusCode != 0 {
+				return fmt.Errorf("minimax TTS error: %d - %s", chunk.BaseResp.StatusCode, chunk.BaseResp.StatusMsg)
+			}
+			if chunk.ExtraInfo.UsageCharacters > 0 || chunk.ExtraInfo.AudioLength > 0 {
+				extraInfo = chunk.ExtraInfo
+			}
+			// status 为 2 的结束事件可能携带完整音频，流式时已关闭聚合音频，仅接收增量
+			if chunk.Data.Status == 2 || chunk.Data.Audio == "" {
+				return nil
+			}
+			audioData, decodeErr := hex.DecodeString(chunk.Data.Audio)
+			if decodeErr != nil {
+				return fmt.Errorf("failed to decode hex audio data: %w", decodeErr)
+			}
+			return writer.WriteAudio(audioData)
+		})
+		if scanErr != nil {
+			return nil, types.NewErrorWithStatusCode(scanErr, types.ErrorCodeBadResponse, http.StatusInternalServerError)
+		}
+	} else {
+		body, readErr := io.ReadAll(resp.Body)
+		if readErr != nil {
+			return nil, types.NewErrorWithStatusCode(
+				fmt.Errorf("failed to read minimax response: %w", readErr),
+				types.ErrorCodeReadResponseBodyFailed,
+				http.StatusInternalServerError,
+			)
+		}
 
-	// Parse response
-	var minimaxResp MiniMaxTTSResponse
-	if unmarshalErr := json.Unmarshal(body, &minimaxResp); unmarshalErr != nil {
-		return nil, types.NewErrorWithStatusCode(
-			fmt.Errorf("failed to unmarshal minimax TTS response: %w", unmarshalErr),
-			types.ErrorCodeBadResponseBody,
-			http.StatusInternalServerError,
-		)
-	}
+		// Parse response
+		var minimaxResp MiniMaxTTSResponse
+		if unmarshalErr := json.Unmarshal(body, &minimaxResp); unmarshalErr != nil {
+			return nil, types.NewErrorWithStatusCode(
+				fmt.Errorf("failed to unmarshal minimax TTS response: %w", unmarshalErr),
+				types.ErrorCodeBadResponseBody,
+				http.StatusInternalServerError,
+			)
+		}
 
-	// Check base_resp status code
-	if minimaxResp.BaseResp.StatusCode != 0 {
-		return nil, types.NewErrorWithStatusCode(
-			fmt.Errorf("minimax TTS error: %d - %s", minimaxResp.BaseResp.StatusCode, minimaxResp.BaseResp.StatusMsg),
-			types.ErrorCodeBadResponse,
-			http.StatusBadRequest,
-		)
-	}
+		// Check base_resp status code
+		if minimaxResp.BaseResp.StatusCode != 0 {
+			return nil, types.NewErrorWithStatusCode(
+				fmt.Errorf("minimax TTS error: %d - %s", minimaxResp.BaseResp.StatusCode, minimaxResp.BaseResp.StatusMsg),
+				types.ErrorCodeBadResponse,
+				http.StatusBadRequest,
+			)
+		}
 
-	// Check if we have audio data
-	if minimaxResp.Data.Audio == "" {
-		return nil, types.NewErrorWithStatusCode(
-			fmt.Errorf("no audio data in minimax TTS response"),
-			types.ErrorCodeBadResponse,
-			http.StatusBadRequest,
-		)
-	}
+		// Check if we have audio data
+		if minimaxResp.Data.Audio == "" {
+			return nil, types.NewErrorWithStatusCode(
+				fmt.Errorf("no audio data in minimax TTS response"),
+				types.ErrorCodeBadResponse,
+				http.StatusBadRequest,
+			)
+		}
+		extraInfo = minimaxResp.ExtraInfo
 
-	if strings.HasPrefix(minimaxResp.Data.Audio, "http") {
-		c.Redirect(http.StatusFound, minimaxResp.Data.Audio)
-	} else {
+		if strings.HasPrefix(minimaxResp.Data.Audio, "http") {
+			c.Redirect(http.StatusFound, minimaxResp.Data.Audio)
+			return buildTTSUsage(info, extraInfo, 0), nil
+		}
 		// Handle hex-encoded audio data
 		audioData, decodeErr := hex.DecodeString(minimaxResp.Data.Audio)
 		if decodeErr != nil {
@@ -155,20 +177,30 @@ func handleTTSResponse(c *gin.Context, resp *http.Response, info *relaycommon.Re
 				http.StatusInternalServerError,
 			)
 		}
-
-		// Determine content type - default to mp3
-		contentType := "audio/mpeg"
-
-		c.Data(http.StatusOK, contentType, audioData)
+		_ = writer.WriteAudio(audioData)
 	}
 
-	usage = &dto.Usage{
-		PromptTokens:     info.GetEstimatePromptTokens(),
-		CompletionTokens: 0,
-		TotalTokens:      int(minimaxResp.ExtraInfo.UsageCharacters),
+	duration, durationErr := writer.Duration()
+	if durationErr != nil && extraInfo.AudioLength == 0 {
+		logger.LogWarn(c, fmt.Sprintf("failed to get minimax TTS audio duration: %v", durationErr))
+	}
+	ttsUsage := buildTTSUsage(info, extraInfo, duration)
+	if finishErr := writer.Finish(ttsUsage); finishErr != nil {
+		logger.LogError(c, fmt.Sprintf("failed to write minimax TTS response: %v", finishErr))
 	}
+	return ttsUsage, nil
+}
 
-	return usage, nil
+// buildTTSUsage 输入按上游返回的计费字符数，输出优先按上游返回的音频时长
+func buildTTSUsage(info *relaycommon.RelayInfo, extraInfo MiniMaxExtraInfo, duration float64) *dto.Usage {
+	promptTokens := info.GetEstimatePromptTokens()
+	if extraInfo.UsageCharacters > 0 {
+		promptTokens = int(extraInfo.UsageCharacters)
+	}
+	if extraInfo.AudioLength > 0 {
+		duration = float64(extraInfo.AudioLength) / 1000
+	}
+	return service.BuildSpeechUsage(promptTokens, duration)
 }
 
 func handleChatCompletionResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *types.NewAPIError) {
@@ -0,0 +1,132 @@
+package openai
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+)
+
+const defaultAzureSpeechVoice = "en-US-AvaMultilingualNeural"
+
+// Azure AI Speech 提供与 OpenAI 同名的多语言音色，其余 OpenAI 音色映射到默认音色；
+// 含 "-" 的名称视为 Azure 音色（如 zh-CN-XiaoxiaoNeural）直接透传
+var openAIToAzureVoiceMap = map[string]string{
+	"alloy":   "en-US-AlloyMultilingualNeural",
+	"echo":    "en-US-EchoMultilingualNeural",
+	"fable":   "en-US-FableMultilingualNeural",
+	"onyx":    "en-US-OnyxMultilingualNeural",
+	"nova":    "en-US-NovaMultilingualNeural",
+	"shimmer": "en-US-ShimmerMultilingualNeural",
+}
+
+var azureSpeechOutputFormats = map[string]string{
+	"mp3":  "audio-24khz-48kbitrate-mono-mp3",
+	"opus": "ogg-24khz-16bit-mono-opus",
+	"wav":  "riff-24khz-16bit-mono-pcm",
+	"pcm":  "raw-24khz-16bit-mono-pcm",
+}
+
+// isAzureSpeech Azure 渠道地址为 https://{region}.tts.speech.microsoft.com 时，speech 请求走 Azure AI Speech 的 SSML 接口
+func isAzureSpeech(info *relaycommon.RelayInfo) bool {
+	return info.ChannelType == constant.ChannelTypeAzure &&
+		info.RelayMode == relayconstant.RelayModeAudioSpeech &&
+		strings.Contains(info.ChannelBaseUrl, "tts.speech.microsoft.com")
+}
+
+func mapAzureVoice(voice string) string {
+	if strings.Contains(voice, "-") {
+		return voice
+	}
+	if mapped, ok := openAIToAzureVoiceMap[voice]; ok {
+		return mapped
+	}
+	return defaultAzureSpeechVoice
+}
+
+// buildAzureSSML 语言取音色名称的前两段（如 zh-CN），speed 转换为 prosody 的相对语速
+func buildAzureSSML(request dto.AudioRequest) ([]byte, error) {
+	voice := mapAzureVoice(request.Voice)
+	lang := "en-US"
+	if parts := strings.SplitN(voice, "-", 3); len(parts) == 3 {
+		lang = parts[0] + "-" + parts[1]
+	}
+	var text bytes.Buffer
+	if err := xml.EscapeText(&text, []byte(request.Input)); err != nil {
+		return nil, err
+	}
+	content := text.String()
+	if speed := lo.FromPtrOr(request.Speed, 1.0); speed > 0 && speed != 1.0 {
+		content = fmt.Sprintf(`<prosody rate="%+.0f%%">%s</prosody>`, (speed-1)*100, content)
+	}
+	ssml := fmt.Sprintf(`<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" xml:lang="%s"><voice name="%s">%s</voice></speak>`,
+		lang, voice, content)
+	return []byte(ssml), nil
+}
+
+func (a *Adaptor) convertAzureSpeechRequest(request dto.AudioRequest) (io.Reader, error) {
+	speechFormat, err := service.NegotiateSpeechFormat(request.ResponseFormat, "mp3", "opus", "wav", "pcm")
+	if err != nil {
+		return nil, err
+	}
+	a.speechFormat = speechFormat
+	ssml, err := buildAzureSSML(request)
+	if err != nil {
+		return nil, fmt.Errorf("error building ssml: %w", err)
+	}
+	return bytes.NewReader(ssml), nil
+}
+
+func (a *Adaptor) setupAzureSpeechHeader(header *http.Header, info *relaycommon.RelayInfo) {
+	header.Set("Ocp-Apim-Subscription-Key", info.ApiKey)
+	header.Set("Content-Type", "application/ssml+xml")
+	header.Set("X-Microsoft-OutputFormat", azureSpeechOutputFormats[a.speechFormat])
+	header.Set("User-Agent", "new-api")
+}
+
+// AzureSpeechHandler Azure 边合成边返回音频，按读取到的数据块转发给客户端；输入按字符、输出按音频时长计费
+func AzureSpeechHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo, format string) (*dto.Usage, *types.NewAPIError) {
+	defer service.CloseResponseBodyGracefully(resp)
+	writer := helper.NewSpeechWriter(c, info, format, service.SpeechPCMSampleRate)
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if writeErr := writer.WriteAudio(bytes.Clone(buf[:n])); writeErr != nil {
+				return nil, types.NewOpenAIError(writeErr, types.ErrorCodeBadResponse, http.StatusInternalServerError)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+		}
+	}
+
+	duration, err := writer.Duration()
+	if err != nil {
+		logger.LogWarn(c, fmt.Sprintf("failed to get azure speech audio duration: %v", err))
+	}
+	usage := service.BuildSpeechUsage(info.GetEstimatePromptTokens(), duration)
+	if err = writer.Finish(usage); err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to write azure speech response: %v", err))
+	}
+	return usage, nil
+}
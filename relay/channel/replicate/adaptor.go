@@ -191,17 +191,7 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 		return nil, types.NewError(fmt.Errorf("replicate adaptor: failed to decode response: %w", err), types.ErrorCodeBadResponseBody)
 	}
 
-	if prediction.Error != nil {
-		errMsg := prediction.Error.Message
-		if errMsg == "" {
-			errMsg = prediction.Error.Detail
-		}
-		if errMsg == "" {
-			errMsg = prediction.Error.Code
-		}
-		if errMsg == "" {
-			errMsg = "replicate adaptor: prediction error"
-		}
+	if errMsg := prediction.ErrorMessage(); errMsg != "" {
 		return nil, types.NewError(errors.New(errMsg), types.ErrorCodeBadResponse)
 	}
 
@@ -209,33 +199,7 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 		return nil, types.NewError(fmt.Errorf("replicate adaptor: prediction status %q", prediction.Status), types.ErrorCodeBadResponse)
 	}
 
-	var urls []string
-
-	appendOutput := func(value string) {
-		value = strings.TrimSpace(value)
-		if value == "" {
-			return
-		}
-		urls = append(urls, value)
-	}
-
-	switch output := prediction.Output.(type) {
-	case string:
-		appendOutput(output)
-	case []any:
-		for _, item := range output {
-			if str, ok := item.(string); ok {
-				appendOutput(str)
-			}
-		}
-	case nil:
-		// no output
-	default:
-		if str, ok := output.(fmt.Stringer); ok {
-			appendOutput(str.String())
-		}
-	}
-
+	urls := prediction.OutputURLs()
 	if len(urls) == 0 {
 		return nil, types.NewError(errors.New("replicate adaptor: empty prediction output"), types.ErrorCodeBadResponseBody)
 	}
@@ -1,6 +1,12 @@
 package replicate
 
+import (
+	"fmt"
+	"strings"
+)
+
 type PredictionResponse struct {
+	ID     string           `json:"id"`
 	Status string           `json:"status"`
 	Output any              `json:"output"`
 	Error  *PredictionError `json:"error"`
@@ -12,6 +18,49 @@ type PredictionError struct {
 	Detail  string `json:"detail"`
 }
 
+// ErrorMessage 返回预测失败的原因，未失败时为空
+func (p *PredictionResponse) ErrorMessage() string {
+	if p.Error == nil {
+		return ""
+	}
+	for _, msg := range []string{p.Error.Message, p.Error.Detail, p.Error.Code} {
+		if msg != "" {
+			return msg
+		}
+	}
+	return "replicate adaptor: prediction error"
+}
+
+// OutputURLs 预测输出可能是单个地址或地址数组
+func (p *PredictionResponse) OutputURLs() []string {
+	var urls []string
+	appendOutput := func(value string) {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return
+		}
+		urls = append(urls, value)
+	}
+
+	switch output := p.Output.(type) {
+	case string:
+		appendOutput(output)
+	case []any:
+		for _, item := range output {
+			if str, ok := item.(string); ok {
+				appendOutput(str)
+			}
+		}
+	case nil:
+		// no output
+	default:
+		if str, ok := output.(fmt.Stringer); ok {
+			appendOutput(str.String())
+		}
+	}
+	return urls
+}
+
 type FileUploadResponse struct {
 	Urls struct {
 		Get string `json:"get"`
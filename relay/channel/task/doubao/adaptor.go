@@ -136,6 +136,9 @@ func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayIn
 	} else {
 		info.UpstreamModelName = body.Model
 	}
+	if info.CallbackURL != "" {
+		body.CallbackURL = info.CallbackURL
+	}
 	data, err := common.Marshal(body)
 	if err != nil {
 		return nil, err
@@ -204,6 +207,34 @@ func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any, proxy
 	return client.Do(req)
 }
 
+// CancelTask 取消排队中的上游任务（火山方舟仅支持取消 queued 状态的任务）
+func (a *TaskAdaptor) CancelTask(baseUrl, key string, upstreamTaskID string, proxy string) error {
+	uri := fmt.Sprintf("%s/api/v3/contents/generations/tasks/%s", baseUrl, upstreamTaskID)
+
+	req, err := http.NewRequest(http.MethodDelete, uri, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key)
+
+	client, err := service.GetHttpClientWithProxy(proxy)
+	if err != nil {
+		return fmt.Errorf("new proxy http client failed: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("cancel task failed: status %d, body: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
 func (a *TaskAdaptor) GetModelList() []string {
 	return ModelList
 }
@@ -284,6 +315,11 @@ func (a *TaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, e
 	return &taskResult, nil
 }
 
+// NormalizeTaskCallback 豆包回调内容与查询任务响应一致
+func (a *TaskAdaptor) NormalizeTaskCallback(body []byte) []byte {
+	return body
+}
+
 func (a *TaskAdaptor) ConvertToOpenAIVideo(originTask *model.Task) ([]byte, error) {
 	var dResp responseTask
 	if err := common.Unmarshal(originTask.Data, &dResp); err != nil {
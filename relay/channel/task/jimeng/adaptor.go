@@ -6,11 +6,13 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -26,6 +28,7 @@ import (
 	"github.com/QuantumNous/new-api/relay/channel"
 	taskcommon "github.com/QuantumNous/new-api/relay/channel/task/taskcommon"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/service"
 )
 
@@ -43,6 +46,14 @@ type requestPayload struct {
 	Frames           int      `json:"frames,omitempty"`
 }
 
+// imageRequestPayload 即梦图片生成（CV 异步接口），req_key 即模型名如 jimeng_t2i_v40
+type imageRequestPayload struct {
+	ReqKey string `json:"req_key"`
+	Prompt string `json:"prompt"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
 type responsePayload struct {
 	Code      int    `json:"code"`
 	Message   string `json:"message"`
@@ -98,6 +109,9 @@ func (a *TaskAdaptor) Init(info *relaycommon.RelayInfo) {
 
 // ValidateRequestAndSetAction parses body, validates fields and sets default action.
 func (a *TaskAdaptor) ValidateRequestAndSetAction(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.TaskError) {
+	if info.RelayMode == relayconstant.RelayModeImagesGenerations {
+		return relaycommon.ValidateImageTaskRequest(c, info)
+	}
 	return relaycommon.ValidateBasicTaskRequest(c, info, constant.TaskActionGenerate)
 }
 
@@ -122,6 +136,9 @@ func (a *TaskAdaptor) BuildRequestHeader(c *gin.Context, req *http.Request, info
 }
 
 func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayInfo) (io.Reader, error) {
+	if info.Action == constant.TaskActionImageGenerate {
+		return a.buildImageRequestBody(c, info)
+	}
 	v, exists := c.Get("task_request")
 	if !exists {
 		return nil, fmt.Errorf("request not found in context")
@@ -202,6 +219,11 @@ func (a *TaskAdaptor) DoResponse(c *gin.Context, resp *http.Response, info *rela
 		return
 	}
 
+	// 查询结果时需要与提交一致的 req_key，生图任务将其编码进上游任务 ID，响应由网关返回
+	if info.Action == constant.TaskActionImageGenerate {
+		return encodeImageTaskID(info.UpstreamModelName, jResp.Data.TaskID), responseBody, nil
+	}
+
 	ov := dto.NewOpenAIVideo()
 	ov.ID = info.PublicTaskID
 	ov.TaskID = info.PublicTaskID
@@ -226,6 +248,16 @@ func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any, proxy
 		"req_key": "jimeng_vgfm_t2v_l20", // This is fixed value from doc: https://www.volcengine.com/docs/85621/1544774
 		"task_id": taskID,
 	}
+	if action, _ := body["action"].(string); action == constant.TaskActionImageGenerate {
+		reqKey, upstreamID, ok := decodeImageTaskID(taskID)
+		if !ok {
+			return nil, fmt.Errorf("invalid image task_id: %s", taskID)
+		}
+		payload["req_key"] = reqKey
+		payload["task_id"] = upstreamID
+		// 返回图片链接而非 base64
+		payload["req_json"] = `{"return_url":true}`
+	}
 	payloadBytes, err := common.Marshal(payload)
 	if err != nil {
 		return nil, errors.Wrap(err, "marshal fetch task payload failed")
@@ -378,6 +410,59 @@ func hmacSHA256(key []byte, data []byte) []byte {
 	return h.Sum(nil)
 }
 
+func (a *TaskAdaptor) buildImageRequestBody(c *gin.Context, info *relaycommon.RelayInfo) (io.Reader, error) {
+	req, err := relaycommon.GetImageTaskRequest(c)
+	if err != nil {
+		return nil, err
+	}
+	payload := imageRequestPayload{
+		ReqKey: info.UpstreamModelName,
+		Prompt: req.Prompt,
+	}
+	if w, h, ok := strings.Cut(req.Size, "x"); ok {
+		payload.Width, _ = strconv.Atoi(strings.TrimSpace(w))
+		payload.Height, _ = strconv.Atoi(strings.TrimSpace(h))
+	}
+	data, err := common.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	// 即梦特有参数（image_urls、scale、seed 等）通过额外字段透传
+	if len(req.Extra) > 0 {
+		var body map[string]json.RawMessage
+		if err = common.Unmarshal(data, &body); err != nil {
+			return nil, err
+		}
+		for k, v := range req.Extra {
+			body[k] = v
+		}
+		if data, err = common.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	return bytes.NewReader(data), nil
+}
+
+func encodeImageTaskID(reqKey, taskID string) string {
+	return reqKey + ":" + taskID
+}
+
+func decodeImageTaskID(id string) (reqKey string, taskID string, ok bool) {
+	return strings.Cut(id, ":")
+}
+
+// imageUrls return_url 为 true 时 image_urls 为图片链接数组
+func (t *responseTask) imageUrls() []string {
+	items, _ := t.Data.ImageUrls.([]interface{})
+	urls := make([]string, 0, len(items))
+	for _, item := range items {
+		if url, ok := item.(string); ok {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
 func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq, info *relaycommon.RelayInfo) (*requestPayload, error) {
 	r := requestPayload{
 		ReqKey: info.UpstreamModelName,
@@ -448,6 +533,9 @@ func (a *TaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, e
 		taskResult.Progress = "100%"
 	}
 	taskResult.Url = resTask.Data.VideoUrl
+	if urls := resTask.imageUrls(); taskResult.Url == "" && len(urls) > 0 {
+		taskResult.Url = urls[0]
+	}
 	return &taskResult, nil
 }
 
@@ -475,6 +563,19 @@ func (a *TaskAdaptor) ConvertToOpenAIVideo(originTask *model.Task) ([]byte, erro
 	return common.Marshal(openAIVideo)
 }
 
+// WaitImageTaskOnSync 即梦同步生图已由 CVProcess 接口支持，仅 async=true 时提交任务
+func (a *TaskAdaptor) WaitImageTaskOnSync() bool {
+	return false
+}
+
+func (a *TaskAdaptor) ConvertToImageResponse(task *model.Task) (*dto.ImageResponse, error) {
+	var jimengResp responseTask
+	if err := common.Unmarshal(task.Data, &jimengResp); err != nil {
+		return nil, errors.Wrap(err, "unmarshal jimeng task data failed")
+	}
+	return taskcommon.BuildImageResponse(task, jimengResp.imageUrls()), nil
+}
+
 func isNewAPIRelay(apiKey string) bool {
 	return strings.HasPrefix(apiKey, "sk-")
 }
@@ -2,6 +2,7 @@ package kling
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math"
@@ -24,6 +25,7 @@ import (
 	"github.com/QuantumNous/new-api/relay/channel"
 	taskcommon "github.com/QuantumNous/new-api/relay/channel/task/taskcommon"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/service"
 )
 
@@ -73,6 +75,16 @@ type requestPayload struct {
 	ExternalTaskId string         `json:"external_task_id,omitempty"`
 }
 
+// imageRequestPayload 可灵图像生成 /v1/images/generations
+type imageRequestPayload struct {
+	ModelName      string `json:"model_name,omitempty"`
+	Prompt         string `json:"prompt"`
+	NegativePrompt string `json:"negative_prompt,omitempty"`
+	N              int    `json:"n,omitempty"`
+	AspectRatio    string `json:"aspect_ratio,omitempty"`
+	CallbackUrl    string `json:"callback_url,omitempty"`
+}
+
 type responsePayload struct {
 	Code      int    `json:"code"`
 	Message   string `json:"message"`
@@ -128,13 +140,28 @@ func (a *TaskAdaptor) Init(info *relaycommon.RelayInfo) {
 
 // ValidateRequestAndSetAction parses body, validates fields and sets default action.
 func (a *TaskAdaptor) ValidateRequestAndSetAction(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.TaskError) {
+	if info.RelayMode == relayconstant.RelayModeImagesGenerations {
+		return relaycommon.ValidateImageTaskRequest(c, info)
+	}
 	// Use the standard validation method for TaskSubmitReq
 	return relaycommon.ValidateBasicTaskRequest(c, info, constant.TaskActionGenerate)
 }
 
+// EstimateBilling 生图任务按张数计费
+func (a *TaskAdaptor) EstimateBilling(c *gin.Context, info *relaycommon.RelayInfo) map[string]float64 {
+	if info.Action != constant.TaskActionImageGenerate {
+		return nil
+	}
+	req, err := relaycommon.GetImageTaskRequest(c)
+	if err != nil {
+		return nil
+	}
+	return taskcommon.ImageCountRatio(req)
+}
+
 // BuildRequestURL constructs the upstream URL.
 func (a *TaskAdaptor) BuildRequestURL(info *relaycommon.RelayInfo) (string, error) {
-	path := lo.Ternary(info.Action == constant.TaskActionGenerate, "/v1/videos/image2video", "/v1/videos/text2video")
+	path := getTaskPath(info.Action)
 
 	if isNewAPIRelay(info.ApiKey) {
 		return fmt.Sprintf("%s/kling%s", a.baseURL, path), nil
@@ -159,6 +186,9 @@ func (a *TaskAdaptor) BuildRequestHeader(c *gin.Context, req *http.Request, info
 
 // BuildRequestBody converts request into Kling specific format.
 func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayInfo) (io.Reader, error) {
+	if info.Action == constant.TaskActionImageGenerate {
+		return a.buildImageRequestBody(c, info)
+	}
 	v, exists := c.Get("task_request")
 	if !exists {
 		return nil, fmt.Errorf("request not found in context")
@@ -205,6 +235,10 @@ func (a *TaskAdaptor) DoResponse(c *gin.Context, resp *http.Response, info *rela
 		taskErr = service.TaskErrorWrapperLocal(fmt.Errorf("%s", kResp.Message), "task_failed", http.StatusBadRequest)
 		return
 	}
+	// 生图任务由网关返回任务或等待结果
+	if info.Action == constant.TaskActionImageGenerate {
+		return kResp.Data.TaskId, responseBody, nil
+	}
 	ov := dto.NewOpenAIVideo()
 	ov.ID = info.PublicTaskID
 	ov.TaskID = info.PublicTaskID
@@ -224,7 +258,7 @@ func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any, proxy
 	if !ok {
 		return nil, fmt.Errorf("invalid action")
 	}
-	path := lo.Ternary(action == constant.TaskActionGenerate, "/v1/videos/image2video", "/v1/videos/text2video")
+	path := getTaskPath(action)
 	url := fmt.Sprintf("%s%s/%s", baseUrl, path, taskID)
 	if isNewAPIRelay(key) {
 		url = fmt.Sprintf("%s/kling%s/%s", baseUrl, path, taskID)
@@ -276,7 +310,7 @@ func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq, in
 		StaticMask:     "",
 		DynamicMasks:   []DynamicMask{},
 		CameraControl:  nil,
-		CallbackUrl:    "",
+		CallbackUrl:    info.CallbackURL,
 		ExternalTaskId: "",
 	}
 	if r.ModelName == "" {
@@ -286,9 +320,56 @@ func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq, in
 	if err := taskcommon.UnmarshalMetadata(req.Metadata, &r); err != nil {
 		return nil, errors.Wrap(err, "unmarshal metadata failed")
 	}
+	// 网关注册的回调地址优先，任务状态依赖它推进
+	if info.CallbackURL != "" {
+		r.CallbackUrl = info.CallbackURL
+	}
 	return &r, nil
 }
 
+func (a *TaskAdaptor) buildImageRequestBody(c *gin.Context, info *relaycommon.RelayInfo) (io.Reader, error) {
+	req, err := relaycommon.GetImageTaskRequest(c)
+	if err != nil {
+		return nil, err
+	}
+	payload := imageRequestPayload{
+		ModelName:   taskcommon.DefaultString(info.UpstreamModelName, "kling-v1"),
+		Prompt:      req.Prompt,
+		N:           int(lo.FromPtrOr(req.N, 1)),
+		AspectRatio: a.getAspectRatio(req.Size),
+		CallbackUrl: info.CallbackURL,
+	}
+	data, err := common.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	// 可灵特有参数（negative_prompt、image、image_reference 等）通过额外字段透传
+	if len(req.Extra) > 0 {
+		var body map[string]json.RawMessage
+		if err = common.Unmarshal(data, &body); err != nil {
+			return nil, err
+		}
+		for k, v := range req.Extra {
+			body[k] = v
+		}
+		if data, err = common.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	return bytes.NewReader(data), nil
+}
+
+func getTaskPath(action string) string {
+	switch action {
+	case constant.TaskActionImageGenerate:
+		return "/v1/images/generations"
+	case constant.TaskActionGenerate:
+		return "/v1/videos/image2video"
+	default:
+		return "/v1/videos/text2video"
+	}
+}
+
 func (a *TaskAdaptor) getAspectRatio(size string) string {
 	switch size {
 	case "1024x1024", "512x512":
@@ -356,6 +437,8 @@ func (a *TaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, e
 		if videos := resPayload.Data.TaskResult.Videos; len(videos) > 0 {
 			video := videos[0]
 			taskInfo.Url = video.Url
+		} else if images := resPayload.Data.TaskResult.Images; len(images) > 0 {
+			taskInfo.Url = images[0].Url
 		}
 		if tokens, err := strconv.ParseFloat(resPayload.Data.FinalUnitDeduction, 64); err == nil {
 			rounded := int(math.Ceil(tokens))
@@ -372,10 +455,47 @@ func (a *TaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, e
 	return taskInfo, nil
 }
 
+// NormalizeTaskCallback 可灵回调推送的是任务对象本身，包装为查询响应格式
+func (a *TaskAdaptor) NormalizeTaskCallback(body []byte) []byte {
+	var probe struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := common.Unmarshal(body, &probe); err == nil && len(probe.Data) > 0 {
+		return body
+	}
+	wrapped, err := common.Marshal(map[string]any{
+		"code":    0,
+		"message": "SUCCEED",
+		"data":    json.RawMessage(body),
+	})
+	if err != nil {
+		return body
+	}
+	return wrapped
+}
+
 func isNewAPIRelay(apiKey string) bool {
 	return strings.HasPrefix(apiKey, "sk-")
 }
 
+// WaitImageTaskOnSync 可灵生图仅提供异步接口，同步请求也提交任务并等待结果
+func (a *TaskAdaptor) WaitImageTaskOnSync() bool {
+	return true
+}
+
+func (a *TaskAdaptor) ConvertToImageResponse(task *model.Task) (*dto.ImageResponse, error) {
+	var klingResp responsePayload
+	if err := common.Unmarshal(task.Data, &klingResp); err != nil {
+		return nil, errors.Wrap(err, "unmarshal kling task data failed")
+	}
+	images := klingResp.Data.TaskResult.Images
+	urls := make([]string, 0, len(images))
+	for _, image := range images {
+		urls = append(urls, image.Url)
+	}
+	return taskcommon.BuildImageResponse(task, urls), nil
+}
+
 func (a *TaskAdaptor) ConvertToOpenAIVideo(originTask *model.Task) ([]byte, error) {
 	var klingResp responsePayload
 	if err := common.Unmarshal(originTask.Data, &klingResp); err != nil {
@@ -0,0 +1,382 @@
+package midjourney
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel"
+	taskcommon "github.com/QuantumNous/new-api/relay/channel/task/taskcommon"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 文档：https://github.com/novicezk/midjourney-proxy/blob/main/docs/api.md
+const (
+	submitCodeSuccess    = 1
+	submitCodeNoInstance = 3
+	submitCodeExisted    = 21
+	submitCodeQueued     = 22
+	submitCodeQueueFull  = 23
+	submitCodeBanned     = 24
+)
+
+// TaskAdaptor 将 Midjourney-Proxy 的提交与查询接入统一任务框架，任务记录保存在 tasks 表
+type TaskAdaptor struct {
+	taskcommon.BaseBilling
+	ChannelType int
+	baseURL     string
+	apiKey      string
+	requestPath string
+}
+
+func (a *TaskAdaptor) Init(info *relaycommon.RelayInfo) {
+	a.ChannelType = info.ChannelType
+	a.baseURL = info.ChannelBaseUrl
+	a.apiKey = info.ApiKey
+}
+
+// GetOriginTaskID 返回放大、变换、局部重绘等基于已有任务的请求所引用的任务 ID，
+// 在选择渠道前由 relay.ResolveOriginTask 调用，以便锁定到原任务所在渠道
+func GetOriginTaskID(c *gin.Context) string {
+	var req dto.MidjourneyRequest
+	if err := common.UnmarshalBodyReusable(c, &req); err != nil {
+		return ""
+	}
+	if relayconstant.Path2RelayModeMidjourney(c.Request.URL.Path) == relayconstant.RelayModeMidjourneySimpleChange {
+		if params := service.ConvertSimpleChangeParams(req.Content); params != nil {
+			return params.TaskId
+		}
+		return ""
+	}
+	return req.TaskId
+}
+
+func (a *TaskAdaptor) ValidateRequestAndSetAction(c *gin.Context, info *relaycommon.RelayInfo) *dto.TaskError {
+	var req dto.MidjourneyRequest
+	if err := common.UnmarshalBodyReusable(c, &req); err != nil {
+		return service.TaskErrorWrapperLocal(err, "invalid_request", http.StatusBadRequest)
+	}
+	// 原样转发请求中的其他字段（如 blend 的 dimensions），仅改写任务 ID 等少数字段
+	var body map[string]any
+	if err := common.UnmarshalBodyReusable(c, &body); err != nil {
+		return service.TaskErrorWrapperLocal(err, "invalid_request", http.StatusBadRequest)
+	}
+	relayMode := relayconstant.Path2RelayModeMidjourney(c.Request.URL.Path)
+	action, err := resolveAction(relayMode, &req)
+	if err != nil {
+		return service.TaskErrorWrapperLocal(err, "invalid_request", http.StatusBadRequest)
+	}
+
+	// 引用的是公开任务 ID，提交到上游前替换为上游任务 ID
+	if info.OriginTaskID != "" {
+		originTask, exist, err := model.GetByTaskId(info.UserId, info.OriginTaskID)
+		if err != nil {
+			return service.TaskErrorWrapper(err, "get_origin_task_failed", http.StatusInternalServerError)
+		}
+		if !exist {
+			return service.TaskErrorWrapperLocal(fmt.Errorf("task_not_found"), "task_not_exist", http.StatusBadRequest)
+		}
+		upstreamTaskID := originTask.GetUpstreamTaskID()
+		if relayMode == relayconstant.RelayModeMidjourneySimpleChange {
+			fields := strings.Fields(req.Content)
+			fields[0] = upstreamTaskID
+			body["content"] = strings.Join(fields, " ")
+		} else {
+			body["taskId"] = upstreamTaskID
+		}
+	}
+
+	if !setting.MjAccountFilterEnabled {
+		delete(body, "accountFilter")
+	}
+	if !setting.MjNotifyEnabled || req.NotifyHook == "" {
+		delete(body, "notifyHook")
+	}
+	if prompt, ok := body["prompt"].(string); ok && setting.MjModeClearEnabled {
+		for _, mode := range []string{"--fast", "--relax", "--turbo"} {
+			prompt = strings.ReplaceAll(prompt, mode, "")
+		}
+		body["prompt"] = prompt
+	}
+
+	info.Action = action
+	a.requestPath = upstreamRequestPath(c.Request.URL.Path)
+	c.Set("task_request", body)
+	return nil
+}
+
+// resolveAction 按请求路径确定 MJ 动作并校验必填参数，与旧版 RelayMidjourneySubmit 的规则一致
+func resolveAction(relayMode int, req *dto.MidjourneyRequest) (string, error) {
+	switch relayMode {
+	case relayconstant.RelayModeMidjourneyImagine:
+		if req.Prompt == "" {
+			return "", fmt.Errorf("prompt_is_required")
+		}
+		return constant.MjActionImagine, nil
+	case relayconstant.RelayModeMidjourneyDescribe:
+		return constant.MjActionDescribe, nil
+	case relayconstant.RelayModeMidjourneyBlend:
+		return constant.MjActionBlend, nil
+	case relayconstant.RelayModeMidjourneyShorten:
+		return constant.MjActionShorten, nil
+	case relayconstant.RelayModeMidjourneyEdits:
+		return constant.MjActionEdits, nil
+	case relayconstant.RelayModeMidjourneyVideo:
+		return constant.MjActionVideo, nil
+	case relayconstant.RelayModeMidjourneyAction:
+		if mjErr := service.CoverPlusActionToNormalAction(req); mjErr != nil {
+			return "", fmt.Errorf("%s", mjErr.Description)
+		}
+		if req.TaskId == "" {
+			return "", fmt.Errorf("task_id_is_required")
+		}
+		return req.Action, nil
+	case relayconstant.RelayModeMidjourneyChange:
+		if req.TaskId == "" {
+			return "", fmt.Errorf("task_id_is_required")
+		} else if req.Action == "" {
+			return "", fmt.Errorf("action_is_required")
+		} else if req.Index == 0 {
+			return "", fmt.Errorf("index_is_required")
+		}
+		return req.Action, nil
+	case relayconstant.RelayModeMidjourneySimpleChange:
+		params := service.ConvertSimpleChangeParams(req.Content)
+		if params == nil {
+			return "", fmt.Errorf("content_parse_failed")
+		}
+		return params.Action, nil
+	case relayconstant.RelayModeMidjourneyModal:
+		if req.TaskId == "" {
+			return "", fmt.Errorf("task_id_is_required")
+		}
+		return constant.MjActionModal, nil
+	}
+	return "", fmt.Errorf("unsupported midjourney action")
+}
+
+func upstreamRequestPath(path string) string {
+	if strings.Contains(path, "/mj-") {
+		if idx := strings.Index(path, "/mj/"); idx >= 0 {
+			return path[idx:]
+		}
+	}
+	return path
+}
+
+func (a *TaskAdaptor) BuildRequestURL(info *relaycommon.RelayInfo) (string, error) {
+	return fmt.Sprintf("%s%s", a.baseURL, a.requestPath), nil
+}
+
+func (a *TaskAdaptor) BuildRequestHeader(c *gin.Context, req *http.Request, info *relaycommon.RelayInfo) error {
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("mj-api-secret", strings.TrimPrefix(a.apiKey, "Bearer "))
+	return nil
+}
+
+func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayInfo) (io.Reader, error) {
+	body, ok := c.Get("task_request")
+	if !ok {
+		return nil, fmt.Errorf("request not found in context")
+	}
+	// 回调地址在校验请求之后才生成，这里写入；用户自带 notifyHook 时不覆盖
+	if requestBody, isMap := body.(map[string]any); isMap && info.CallbackURL != "" {
+		if _, exists := requestBody["notifyHook"]; !exists {
+			requestBody["notifyHook"] = info.CallbackURL
+		}
+	}
+	data, err := common.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+func (a *TaskAdaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, requestBody io.Reader) (*http.Response, error) {
+	return channel.DoTaskApiRequest(a, c, info, requestBody)
+}
+
+// DoResponse 返回旧版 /mj 接口的提交响应格式，result 为公开任务 ID
+func (a *TaskAdaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (taskID string, taskData []byte, taskErr *dto.TaskError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		taskErr = service.TaskErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError)
+		return
+	}
+	var mjResp dto.MidjourneyResponse
+	if err = common.Unmarshal(responseBody, &mjResp); err != nil {
+		taskErr = service.TaskErrorWrapper(fmt.Errorf("%s", responseBody), "unmarshal_response_body_failed", http.StatusInternalServerError)
+		return
+	}
+
+	switch mjResp.Code {
+	case submitCodeSuccess, submitCodeQueued:
+		mjResp.Code = submitCodeSuccess
+	case submitCodeExisted:
+		// 任务已存在（处理中或已有结果），局部重绘与自定义变焦需要保留 21 以便客户端弹出窗口
+		if info.Action != constant.MjActionInPaint && info.Action != constant.MjActionCustomZoom {
+			mjResp.Code = submitCodeSuccess
+		}
+	case submitCodeNoInstance:
+		// 无实例账号自动禁用渠道（No available account instance）
+		if ch, err := model.GetChannelById(info.ChannelId, true); err == nil && ch.GetAutoBan() && common.AutomaticDisableChannelEnabled {
+			model.UpdateChannelStatus(info.ChannelId, "", common.ChannelStatusAutoDisabled, "No available account instance")
+		}
+		taskErr = service.TaskErrorWrapper(fmt.Errorf("%s", mjResp.Description), fmt.Sprint(mjResp.Code), http.StatusServiceUnavailable)
+		return
+	case submitCodeQueueFull:
+		taskErr = service.TaskErrorWrapper(fmt.Errorf("%s", mjResp.Description), fmt.Sprint(mjResp.Code), http.StatusTooManyRequests)
+		return
+	case submitCodeBanned:
+		taskErr = service.TaskErrorWrapperLocal(fmt.Errorf("%s", mjResp.Description), fmt.Sprint(mjResp.Code), http.StatusBadRequest)
+		return
+	default:
+		taskErr = service.TaskErrorWrapper(fmt.Errorf("%s", mjResp.Description), fmt.Sprint(mjResp.Code), http.StatusBadRequest)
+		return
+	}
+	if mjResp.Result == "" {
+		taskErr = service.TaskErrorWrapper(fmt.Errorf("empty task id, response: %s", responseBody), "empty_task_id", http.StatusInternalServerError)
+		return
+	}
+
+	upstreamTaskID := mjResp.Result
+	mjResp.Result = info.PublicTaskID
+	c.JSON(http.StatusOK, mjResp)
+	return upstreamTaskID, responseBody, nil
+}
+
+func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any, proxy string) (*http.Response, error) {
+	taskID, ok := body["task_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid task_id")
+	}
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/mj/task/%s/fetch", baseUrl, taskID), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("mj-api-secret", strings.TrimPrefix(key, "Bearer "))
+
+	client, err := service.GetHttpClientWithProxy(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("new proxy http client failed: %w", err)
+	}
+	return client.Do(req)
+}
+
+// ParseTaskResult 解析 /mj/task/{id}/fetch 的响应。
+// MODAL 表示上游在等待用户提交弹窗内容，本任务已结束，按成功处理；原始状态保留在任务数据中供 /mj 接口返回
+func (a *TaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, error) {
+	var mjTask dto.MidjourneyDto
+	if err := common.Unmarshal(respBody, &mjTask); err != nil {
+		return nil, fmt.Errorf("unmarshal midjourney task failed: %w", err)
+	}
+	taskInfo := &relaycommon.TaskInfo{
+		TaskID:   mjTask.MjId,
+		Progress: mjTask.Progress,
+	}
+	switch mjTask.Status {
+	case "NOT_START", "SUBMITTED":
+		taskInfo.Status = model.TaskStatusSubmitted
+	case "IN_PROGRESS":
+		taskInfo.Status = model.TaskStatusInProgress
+	case "SUCCESS", "MODAL":
+		taskInfo.Status = model.TaskStatusSuccess
+		taskInfo.Progress = taskcommon.ProgressComplete
+		taskInfo.Url = resultURL(&mjTask)
+	case "FAILURE", "CANCEL":
+		taskInfo.Status = model.TaskStatusFailure
+		taskInfo.Reason = taskcommon.DefaultString(mjTask.FailReason, strings.ToLower(mjTask.Status))
+	default:
+		return nil, fmt.Errorf("unknown midjourney task status: %s", mjTask.Status)
+	}
+	return taskInfo, nil
+}
+
+func resultURL(mjTask *dto.MidjourneyDto) string {
+	if mjTask.Action == constant.MjActionVideo {
+		if mjTask.VideoUrl != "" {
+			return mjTask.VideoUrl
+		}
+		if len(mjTask.VideoUrls) > 0 {
+			return mjTask.VideoUrls[0].Url
+		}
+	}
+	return mjTask.ImageUrl
+}
+
+// NormalizeTaskCallback midjourney-proxy 的 notifyHook 推送完整任务对象，与 /mj/task/{id}/fetch 响应格式一致
+func (a *TaskAdaptor) NormalizeTaskCallback(body []byte) []byte {
+	return body
+}
+
+func (a *TaskAdaptor) GetModelList() []string {
+	models := make([]string, 0, len(constant.MidjourneyModel2Action))
+	for modelName, action := range constant.MidjourneyModel2Action {
+		if action == constant.MjActionUpload || action == constant.MjActionSwapFace {
+			continue
+		}
+		models = append(models, modelName)
+	}
+	sort.Strings(models)
+	return models
+}
+
+func (a *TaskAdaptor) GetChannelName() string {
+	return "midjourney"
+}
+
+// ConvertToMidjourneyDto 将统一任务转换为旧版 /mj 接口的任务格式，任务 ID 使用公开任务 ID。
+// 任务数据为上游最近一次返回的任务详情，本地推进的状态（超时、取消等）以任务记录为准
+func ConvertToMidjourneyDto(task *model.Task) dto.MidjourneyDto {
+	var mjTask dto.MidjourneyDto
+	_ = common.Unmarshal(task.Data, &mjTask)
+	mjTask.MjId = task.TaskID
+	if mjTask.Action == "" {
+		mjTask.Action = task.Action
+	}
+	if mjTask.Prompt == "" {
+		mjTask.Prompt = task.Properties.Input
+	}
+	switch task.Status {
+	case model.TaskStatusFailure:
+		mjTask.Status = "FAILURE"
+		mjTask.FailReason = task.FailReason
+	case model.TaskStatusSuccess:
+		if mjTask.Status != "MODAL" {
+			mjTask.Status = "SUCCESS"
+		}
+	case model.TaskStatusInProgress:
+		mjTask.Status = "IN_PROGRESS"
+	default:
+		if mjTask.Status == "" {
+			mjTask.Status = "SUBMITTED"
+		}
+	}
+	if mjTask.Progress == "" {
+		mjTask.Progress = task.Progress
+	}
+	if mjTask.SubmitTime == 0 {
+		mjTask.SubmitTime = task.SubmitTime * 1000
+	}
+	if mjTask.StartTime == 0 {
+		mjTask.StartTime = task.StartTime * 1000
+	}
+	if mjTask.FinishTime == 0 {
+		mjTask.FinishTime = task.FinishTime * 1000
+	}
+	return mjTask
+}
@@ -0,0 +1,52 @@
+package midjourney
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+var _ channel.TaskCallbackReceiver = (*TaskAdaptor)(nil)
+
+func TestBuildRequestBody_SetsGatewayNotifyHook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/mj/submit/imagine", strings.NewReader(`{"prompt":"a cat"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	adaptor := &TaskAdaptor{}
+	info := &relaycommon.RelayInfo{TaskRelayInfo: &relaycommon.TaskRelayInfo{}}
+	require.Nil(t, adaptor.ValidateRequestAndSetAction(c, info))
+
+	// 回调地址在校验之后由 RelayTaskSubmit 生成
+	info.CallbackURL = "https://gateway.example/api/task/callback/1/task_abc"
+	reader, err := adaptor.BuildRequestBody(c, info)
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	var body map[string]any
+	require.NoError(t, common.Unmarshal(data, &body))
+	require.Equal(t, info.CallbackURL, body["notifyHook"])
+}
+
+func TestNormalizeTaskCallback_ParsesNotifyPayload(t *testing.T) {
+	// midjourney-proxy notifyHook 推送的任务对象
+	payload := []byte(`{"id":"1712345678901","action":"IMAGINE","status":"SUCCESS","progress":"100%","imageUrl":"https://cdn.example/a.png","failReason":""}`)
+
+	adaptor := &TaskAdaptor{}
+	result, err := adaptor.ParseTaskResult(adaptor.NormalizeTaskCallback(payload))
+	require.NoError(t, err)
+	require.Equal(t, "1712345678901", result.TaskID)
+	require.Equal(t, model.TaskStatus(model.TaskStatusSuccess), model.TaskStatus(result.Status))
+	require.Equal(t, "https://cdn.example/a.png", result.Url)
+}
@@ -0,0 +1,248 @@
+package replicate
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel"
+	"github.com/QuantumNous/new-api/relay/channel/replicate"
+	taskcommon "github.com/QuantumNous/new-api/relay/channel/task/taskcommon"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// ============================
+// Adaptor implementation
+// ============================
+
+// TaskAdaptor Replicate 预测任务，提交后不等待（不带 Prefer: wait），通过查询或 webhook 获取结果
+type TaskAdaptor struct {
+	taskcommon.BaseBilling
+	ChannelType int
+	apiKey      string
+	baseURL     string
+}
+
+func (a *TaskAdaptor) Init(info *relaycommon.RelayInfo) {
+	a.ChannelType = info.ChannelType
+	a.baseURL = info.ChannelBaseUrl
+	if a.baseURL == "" {
+		a.baseURL = constant.ChannelBaseURLs[constant.ChannelTypeReplicate]
+	}
+	a.apiKey = info.ApiKey
+}
+
+// ValidateRequestAndSetAction 目前仅支持生图任务
+func (a *TaskAdaptor) ValidateRequestAndSetAction(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.TaskError) {
+	if info.RelayMode != relayconstant.RelayModeImagesGenerations {
+		return service.TaskErrorWrapperLocal(fmt.Errorf("replicate only supports image generation tasks"), "invalid_request", http.StatusBadRequest)
+	}
+	return relaycommon.ValidateImageTaskRequest(c, info)
+}
+
+// EstimateBilling 生图任务按张数计费
+func (a *TaskAdaptor) EstimateBilling(c *gin.Context, info *relaycommon.RelayInfo) map[string]float64 {
+	req, err := relaycommon.GetImageTaskRequest(c)
+	if err != nil {
+		return nil
+	}
+	return taskcommon.ImageCountRatio(req)
+}
+
+// BuildRequestURL constructs the upstream URL.
+func (a *TaskAdaptor) BuildRequestURL(info *relaycommon.RelayInfo) (string, error) {
+	modelName := strings.TrimSpace(info.UpstreamModelName)
+	if modelName == "" {
+		modelName = replicate.ModelFlux11Pro
+	}
+	return fmt.Sprintf("%s/v1/models/%s/predictions", a.baseURL, modelName), nil
+}
+
+// BuildRequestHeader sets required headers.
+func (a *TaskAdaptor) BuildRequestHeader(c *gin.Context, req *http.Request, info *relaycommon.RelayInfo) error {
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+a.apiKey)
+	return nil
+}
+
+// BuildRequestBody 复用同步渠道的输入参数映射，并注册完成回调
+func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayInfo) (io.Reader, error) {
+	req, err := relaycommon.GetImageTaskRequest(c)
+	if err != nil {
+		return nil, err
+	}
+	converted, err := (&replicate.Adaptor{}).ConvertImageRequest(c, info, *req)
+	if err != nil {
+		return nil, err
+	}
+	body, ok := converted.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("unexpected replicate request type %T", converted)
+	}
+	if info.CallbackURL != "" {
+		body["webhook"] = info.CallbackURL
+		body["webhook_events_filter"] = []string{"completed"}
+	}
+	data, err := common.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// DoRequest delegates to common helper.
+func (a *TaskAdaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, requestBody io.Reader) (*http.Response, error) {
+	return channel.DoTaskApiRequest(a, c, info, requestBody)
+}
+
+// DoResponse 解析预测 ID，响应由网关返回
+func (a *TaskAdaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (taskID string, taskData []byte, taskErr *dto.TaskError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		taskErr = service.TaskErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError)
+		return
+	}
+	_ = resp.Body.Close()
+
+	var prediction replicate.PredictionResponse
+	if err := common.Unmarshal(responseBody, &prediction); err != nil {
+		taskErr = service.TaskErrorWrapper(errors.Wrapf(err, "body: %s", responseBody), "unmarshal_response_body_failed", http.StatusInternalServerError)
+		return
+	}
+	if errMsg := prediction.ErrorMessage(); errMsg != "" {
+		taskErr = service.TaskErrorWrapper(errors.New(errMsg), "task_failed", http.StatusBadRequest)
+		return
+	}
+	if prediction.ID == "" {
+		taskErr = service.TaskErrorWrapper(fmt.Errorf("prediction id is empty"), "invalid_response", http.StatusInternalServerError)
+		return
+	}
+	return prediction.ID, responseBody, nil
+}
+
+// FetchTask fetch task status
+func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any, proxy string) (*http.Response, error) {
+	taskID, ok := body["task_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid task_id")
+	}
+	if baseUrl == "" {
+		baseUrl = constant.ChannelBaseURLs[constant.ChannelTypeReplicate]
+	}
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/v1/predictions/%s", baseUrl, taskID), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key)
+
+	client, err := service.GetHttpClientWithProxy(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("new proxy http client failed: %w", err)
+	}
+	return client.Do(req)
+}
+
+// CancelTask 取消尚未完成的预测
+func (a *TaskAdaptor) CancelTask(baseUrl, key string, upstreamTaskID string, proxy string) error {
+	if baseUrl == "" {
+		baseUrl = constant.ChannelBaseURLs[constant.ChannelTypeReplicate]
+	}
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/v1/predictions/%s/cancel", baseUrl, upstreamTaskID), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key)
+
+	client, err := service.GetHttpClientWithProxy(proxy)
+	if err != nil {
+		return fmt.Errorf("new proxy http client failed: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("cancel task failed: status %d, body: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (a *TaskAdaptor) GetModelList() []string {
+	return replicate.ModelList
+}
+
+func (a *TaskAdaptor) GetChannelName() string {
+	return replicate.ChannelName
+}
+
+func (a *TaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, error) {
+	var prediction replicate.PredictionResponse
+	if err := common.Unmarshal(respBody, &prediction); err != nil {
+		return nil, errors.Wrap(err, "unmarshal task result failed")
+	}
+
+	taskResult := relaycommon.TaskInfo{
+		Code:   0,
+		TaskID: prediction.ID,
+	}
+	// https://replicate.com/docs/topics/predictions/lifecycle
+	switch prediction.Status {
+	case "starting":
+		taskResult.Status = model.TaskStatusQueued
+		taskResult.Progress = taskcommon.ProgressQueued
+	case "processing":
+		taskResult.Status = model.TaskStatusInProgress
+		taskResult.Progress = taskcommon.ProgressInProgress
+	case "succeeded":
+		taskResult.Status = model.TaskStatusSuccess
+		taskResult.Progress = taskcommon.ProgressComplete
+		if urls := prediction.OutputURLs(); len(urls) > 0 {
+			taskResult.Url = urls[0]
+		}
+	case "failed", "canceled", "aborted":
+		taskResult.Status = model.TaskStatusFailure
+		taskResult.Progress = taskcommon.ProgressComplete
+		taskResult.Reason = prediction.ErrorMessage()
+		if taskResult.Reason == "" {
+			taskResult.Reason = "prediction " + prediction.Status
+		}
+	default:
+		return nil, fmt.Errorf("unknown prediction status: %s", prediction.Status)
+	}
+	return &taskResult, nil
+}
+
+// NormalizeTaskCallback webhook 推送的是完整的预测对象，与查询响应一致
+func (a *TaskAdaptor) NormalizeTaskCallback(body []byte) []byte {
+	return body
+}
+
+// WaitImageTaskOnSync Replicate 同步等待（Prefer: wait）最长 60 秒，同步请求改为提交任务并等待结果
+func (a *TaskAdaptor) WaitImageTaskOnSync() bool {
+	return true
+}
+
+func (a *TaskAdaptor) ConvertToImageResponse(task *model.Task) (*dto.ImageResponse, error) {
+	var prediction replicate.PredictionResponse
+	if err := common.Unmarshal(task.Data, &prediction); err != nil {
+		return nil, errors.Wrap(err, "unmarshal replicate task data failed")
+	}
+	return taskcommon.BuildImageResponse(task, prediction.OutputURLs()), nil
+}
@@ -0,0 +1,32 @@
+package taskcommon
+
+import (
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+)
+
+// ImageCountRatio 生图任务按张数计费，n>1 时作为倍率参与预扣
+func ImageCountRatio(req *dto.ImageRequest) map[string]float64 {
+	if req == nil || req.N == nil || *req.N <= 1 {
+		return nil
+	}
+	return map[string]float64{"n": float64(*req.N)}
+}
+
+// BuildImageResponse 由任务结果中的图片地址构造 OpenAI 生图响应
+func BuildImageResponse(task *model.Task, urls []string) *dto.ImageResponse {
+	created := task.FinishTime
+	if created == 0 {
+		created = task.CreatedAt
+	}
+	resp := &dto.ImageResponse{
+		Data:    make([]dto.ImageData, 0, len(urls)),
+		Created: created,
+	}
+	for _, url := range urls {
+		if url != "" {
+			resp.Data = append(resp.Data, dto.ImageData{Url: url})
+		}
+	}
+	return resp
+}
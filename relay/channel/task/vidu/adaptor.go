@@ -237,6 +237,9 @@ func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq, in
 	if err := taskcommon.UnmarshalMetadata(req.Metadata, &r); err != nil {
 		return nil, errors.Wrap(err, "unmarshal metadata failed")
 	}
+	if info.CallbackURL != "" {
+		r.CallbackUrl = info.CallbackURL
+	}
 	return &r, nil
 }
 
@@ -272,6 +275,11 @@ func (a *TaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, e
 	return taskInfo, nil
 }
 
+// NormalizeTaskCallback Vidu 回调内容与查询任务响应一致
+func (a *TaskAdaptor) NormalizeTaskCallback(body []byte) []byte {
+	return body
+}
+
 func (a *TaskAdaptor) ConvertToOpenAIVideo(originTask *model.Task) ([]byte, error) {
 	var viduResp taskResultResponse
 	if err := common.Unmarshal(originTask.Data, &viduResp); err != nil {
@@ -107,8 +107,25 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInf
 }
 
 func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
-	geminiAdaptor := gemini.Adaptor{}
-	return geminiAdaptor.ConvertImageRequest(c, info, request)
+	if !gemini.IsImagenModel(info.UpstreamModelName) {
+		geminiAdaptor := gemini.Adaptor{}
+		return geminiAdaptor.ConvertImageRequest(c, info, request)
+	}
+	// Vertex Imagen 额外支持水印开关与输出格式
+	// https://cloud.google.com/vertex-ai/generative-ai/docs/model-reference/imagen-api
+	imagenRequest := gemini.ConvertImageRequestToImagen(request)
+	if request.Watermark != nil {
+		imagenRequest.Parameters.AddWatermark = request.Watermark
+	}
+	if len(request.OutputFormat) > 0 {
+		var outputFormat string
+		if err := common.Unmarshal(request.OutputFormat, &outputFormat); err == nil && outputFormat != "" {
+			imagenRequest.Parameters.OutputOptions = &dto.GeminiImageOutputOptions{
+				MimeType: "image/" + strings.TrimPrefix(outputFormat, "image/"),
+			}
+		}
+	}
+	return imagenRequest, nil
 }
 
 func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
@@ -228,7 +245,7 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 			suffix = "generateContent"
 		}
 
-		if strings.HasPrefix(info.UpstreamModelName, "imagen") {
+		if gemini.IsImagenModel(info.UpstreamModelName) {
 			suffix = "predict"
 		}
 		return a.getRequestUrl(info, info.UpstreamModelName, suffix)
@@ -271,7 +288,7 @@ func (a *Adaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayIn
 	if request == nil {
 		return nil, errors.New("request is nil")
 	}
-	if a.RequestMode == RequestModeGemini && strings.HasPrefix(info.UpstreamModelName, "imagen") {
+	if a.RequestMode == RequestModeGemini && gemini.IsImagenModel(info.UpstreamModelName) {
 		prompt := ""
 		for _, m := range request.Messages {
 			if m.Role == "user" {
@@ -388,9 +405,12 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 			if info.RelayMode == constant.RelayModeGemini {
 				return gemini.GeminiTextGenerationHandler(c, info, resp)
 			} else {
-				if strings.HasPrefix(info.UpstreamModelName, "imagen") {
+				if gemini.IsImagenModel(info.UpstreamModelName) {
 					return gemini.GeminiImageHandler(c, info, resp)
 				}
+				if info.RelayMode == constant.RelayModeImagesGenerations {
+					return gemini.GeminiChatImageHandler(c, info, resp)
+				}
 				return gemini.GeminiChatHandler(c, info, resp)
 			}
 		case RequestModeOpenSource:
@@ -17,6 +17,7 @@ import (
 	"github.com/QuantumNous/new-api/relay/channel/openai"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/model_setting"
 	"github.com/QuantumNous/new-api/types"
 
@@ -58,9 +59,15 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInf
 
 	voiceType := mapVoiceType(request.Voice)
 	speedRatio := lo.FromPtrOr(request.Speed, 0.0)
-	encoding := mapEncoding(request.ResponseFormat)
-
-	c.Set(contextKeyResponseFormat, encoding)
+	responseFormat, err := service.NegotiateSpeechFormat(request.ResponseFormat, "mp3", "opus", "wav", "pcm")
+	if err != nil {
+		return nil, err
+	}
+	// 流式输出 wav 时向上游请求 pcm，由网关补文件头
+	if request.StreamFormat != "" && responseFormat == "wav" {
+		responseFormat = "pcm"
+	}
+	encoding := mapEncoding(responseFormat)
 
 	volcRequest := VolcengineTTSRequest{
 		App: VolcengineTTSApp{
@@ -92,6 +99,7 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInf
 	}
 
 	c.Set(contextKeyTTSRequest, volcRequest)
+	c.Set(contextKeyResponseFormat, volcRequest.Audio.Encoding)
 
 	if volcRequest.Request.Operation == "submit" {
 		info.IsStream = true
@@ -354,26 +362,25 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 	}
 
 	if info.RelayMode == constant.RelayModeAudioSpeech {
-		encoding := mapEncoding(c.GetString(contextKeyResponseFormat))
-		if info.IsStream {
-			volcRequestInterface, exists := c.Get(contextKeyTTSRequest)
-			if !exists {
-				return nil, types.NewErrorWithStatusCode(
-					errors.New("volcengine TTS request not found in context"),
-					types.ErrorCodeBadRequestBody,
-					http.StatusInternalServerError,
-				)
-			}
+		volcRequestInterface, exists := c.Get(contextKeyTTSRequest)
+		if !exists {
+			return nil, types.NewErrorWithStatusCode(
+				errors.New("volcengine TTS request not found in context"),
+				types.ErrorCodeBadRequestBody,
+				http.StatusInternalServerError,
+			)
+		}
 
-			volcRequest, ok := volcRequestInterface.(VolcengineTTSRequest)
-			if !ok {
-				return nil, types.NewErrorWithStatusCode(
-					errors.New("invalid volcengine TTS request type"),
-					types.ErrorCodeBadRequestBody,
-					http.StatusInternalServerError,
-				)
-			}
+		volcRequest, ok := volcRequestInterface.(VolcengineTTSRequest)
+		if !ok {
+			return nil, types.NewErrorWithStatusCode(
+				errors.New("invalid volcengine TTS request type"),
+				types.ErrorCodeBadRequestBody,
+				http.StatusInternalServerError,
+			)
+		}
 
+		if info.IsStream {
 			// Get the WebSocket URL
 			requestURL, urlErr := a.GetRequestURL(info)
 			if urlErr != nil {
@@ -383,9 +390,9 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 					http.StatusInternalServerError,
 				)
 			}
-			return handleTTSWebSocketResponse(c, requestURL, volcRequest, info, encoding)
+			return handleTTSWebSocketResponse(c, requestURL, volcRequest, info)
 		}
-		return handleTTSResponse(c, resp, info, encoding)
+		return handleTTSResponse(c, resp, info, volcRequest.Audio)
 	}
 
 	adaptor := openai.Adaptor{}
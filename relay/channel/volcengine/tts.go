@@ -11,7 +11,10 @@ import (
 	"strings"
 
 	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/types"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -101,8 +104,6 @@ var openAIToVolcengineVoiceMap = map[string]string{
 var responseFormatToEncodingMap = map[string]string{
 	"mp3":  "mp3",
 	"opus": "ogg_opus",
-	"aac":  "mp3",
-	"flac": "mp3",
 	"wav":  "wav",
 	"pcm":  "pcm",
 }
@@ -129,20 +130,40 @@ func mapEncoding(responseFormat string) string {
 	return "mp3"
 }
 
-func getContentTypeByEncoding(encoding string) string {
-	contentTypeMap := map[string]string{
-		"mp3":      "audio/mpeg",
-		"ogg_opus": "audio/ogg",
-		"wav":      "audio/wav",
-		"pcm":      "audio/pcm",
+// getFormatByEncoding 将火山引擎的 encoding 还原为 OpenAI 的 response_format
+func getFormatByEncoding(encoding string) string {
+	switch encoding {
+	case "ogg_opus":
+		return "opus"
+	case "wav", "pcm":
+		return encoding
+	default:
+		return "mp3"
 	}
-	if ct, ok := contentTypeMap[encoding]; ok {
-		return ct
+}
+
+func newSpeechWriter(c *gin.Context, info *relaycommon.RelayInfo, audio VolcengineTTSAudio) *helper.SpeechWriter {
+	sampleRate := audio.Rate
+	if sampleRate <= 0 {
+		sampleRate = service.SpeechPCMSampleRate
 	}
-	return "application/octet-stream"
+	return helper.NewSpeechWriter(c, info, getFormatByEncoding(audio.Encoding), sampleRate)
 }
 
-func handleTTSResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo, encoding string) (usage any, err *types.NewAPIError) {
+// finishSpeech 输入按字符计费，输出按合成音频时长计费
+func finishSpeech(c *gin.Context, info *relaycommon.RelayInfo, writer *helper.SpeechWriter) *dto.Usage {
+	duration, durationErr := writer.Duration()
+	if durationErr != nil {
+		logger.LogWarn(c, fmt.Sprintf("failed to get volcengine TTS audio duration: %v", durationErr))
+	}
+	usage := service.BuildSpeechUsage(info.GetEstimatePromptTokens(), duration)
+	if finishErr := writer.Finish(usage); finishErr != nil {
+		logger.LogError(c, fmt.Sprintf("failed to write volcengine TTS response: %v", finishErr))
+	}
+	return usage
+}
+
+func handleTTSResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo, audio VolcengineTTSAudio) (usage any, err *types.NewAPIError) {
 	body, readErr := io.ReadAll(resp.Body)
 	if readErr != nil {
 		return nil, types.NewErrorWithStatusCode(
@@ -179,24 +200,16 @@ func handleTTSResponse(c *gin.Context, resp *http.Response, info *relaycommon.Re
 		)
 	}
 
-	contentType := getContentTypeByEncoding(encoding)
-	c.Header("Content-Type", contentType)
-	c.Data(http.StatusOK, contentType, audioData)
-
-	usage = &dto.Usage{
-		PromptTokens:     info.GetEstimatePromptTokens(),
-		CompletionTokens: 0,
-		TotalTokens:      info.GetEstimatePromptTokens(),
-	}
-
-	return usage, nil
+	writer := newSpeechWriter(c, info, audio)
+	_ = writer.WriteAudio(audioData)
+	return finishSpeech(c, info, writer), nil
 }
 
 func generateRequestID() string {
 	return uuid.New().String()
 }
 
-func handleTTSWebSocketResponse(c *gin.Context, requestURL string, volcRequest VolcengineTTSRequest, info *relaycommon.RelayInfo, encoding string) (usage any, err *types.NewAPIError) {
+func handleTTSWebSocketResponse(c *gin.Context, requestURL string, volcRequest VolcengineTTSRequest, info *relaycommon.RelayInfo) (usage any, err *types.NewAPIError) {
 	_, token, parseErr := parseVolcengineAuth(info.ApiKey)
 	if parseErr != nil {
 		return nil, types.NewErrorWithStatusCode(
@@ -243,9 +256,7 @@ func handleTTSWebSocketResponse(c *gin.Context, requestURL string, volcRequest V
 		)
 	}
 
-	contentType := getContentTypeByEncoding(encoding)
-	c.Header("Content-Type", contentType)
-	c.Header("Transfer-Encoding", "chunked")
+	writer := newSpeechWriter(c, info, volcRequest.Audio)
 
 	for {
 		msg, recvErr := ReceiveMessage(conn)
@@ -270,36 +281,21 @@ func handleTTSWebSocketResponse(c *gin.Context, requestURL string, volcRequest V
 		case MsgTypeFrontEndResultServer:
 			continue
 		case MsgTypeAudioOnlyServer:
-			if len(msg.Payload) > 0 {
-				if _, writeErr := c.Writer.Write(msg.Payload); writeErr != nil {
-					return nil, types.NewErrorWithStatusCode(
-						fmt.Errorf("failed to write audio data: %w", writeErr),
-						types.ErrorCodeBadResponse,
-						http.StatusInternalServerError,
-					)
-				}
-				c.Writer.Flush()
+			if writeErr := writer.WriteAudio(msg.Payload); writeErr != nil {
+				return nil, types.NewErrorWithStatusCode(
+					fmt.Errorf("failed to write audio data: %w", writeErr),
+					types.ErrorCodeBadResponse,
+					http.StatusInternalServerError,
+				)
 			}
 
 			if msg.Sequence < 0 {
-				c.Status(http.StatusOK)
-				usage = &dto.Usage{
-					PromptTokens:     info.GetEstimatePromptTokens(),
-					CompletionTokens: 0,
-					TotalTokens:      info.GetEstimatePromptTokens(),
-				}
-				return usage, nil
+				return finishSpeech(c, info, writer), nil
 			}
 		default:
 			continue
 		}
 	}
 
-	c.Status(http.StatusOK)
-	usage = &dto.Usage{
-		PromptTokens:     info.GetEstimatePromptTokens(),
-		CompletionTokens: 0,
-		TotalTokens:      info.GetEstimatePromptTokens(),
-	}
-	return usage, nil
+	return finishSpeech(c, info, writer), nil
 }
@@ -42,6 +42,46 @@ type ClaudeConvertInfo struct {
 	ToolCallMaxIndexOffset int
 }
 
+// GeminiConvertInfo 记录 OpenAI 流式响应转换为 Gemini 格式时的状态
+type GeminiConvertInfo struct {
+	// 按工具调用 index 累积的增量，Gemini 的 functionCall 需要完整参数
+	pendingToolCalls []*dto.ToolCallResponse
+	toolCallIndexes  map[int]int
+}
+
+// AppendToolCallDelta 累积一个工具调用增量，position 为增量在本次 delta 中的位置，用于缺失 index 时定位
+func (g *GeminiConvertInfo) AppendToolCallDelta(delta dto.ToolCallResponse, position int) {
+	if g.toolCallIndexes == nil {
+		g.toolCallIndexes = make(map[int]int)
+	}
+	index := position
+	if delta.Index != nil {
+		index = *delta.Index
+	}
+	slot, ok := g.toolCallIndexes[index]
+	if !ok {
+		g.pendingToolCalls = append(g.pendingToolCalls, &dto.ToolCallResponse{})
+		slot = len(g.pendingToolCalls) - 1
+		g.toolCallIndexes[index] = slot
+	}
+	toolCall := g.pendingToolCalls[slot]
+	if delta.ID != "" {
+		toolCall.ID = delta.ID
+	}
+	if delta.Function.Name != "" {
+		toolCall.Function.Name = delta.Function.Name
+	}
+	toolCall.Function.Arguments += delta.Function.Arguments
+}
+
+// FlushToolCalls 返回已累积的完整工具调用并清空状态
+func (g *GeminiConvertInfo) FlushToolCalls() []*dto.ToolCallResponse {
+	toolCalls := g.pendingToolCalls
+	g.pendingToolCalls = nil
+	g.toolCallIndexes = nil
+	return toolCalls
+}
+
 type RerankerInfo struct {
 	Documents       []any
 	ReturnDocuments bool
@@ -75,6 +115,8 @@ type ChannelMeta struct {
 	UpstreamModelName    string
 	IsModelMapped        bool
 	SupportStreamOptions bool // 是否支持流式选项
+	ToolEmulated         bool // tools 已改写为提示词协议，响应需要从文本中解析工具调用
+	CompletionsEmulated  bool // completions 请求已转换为对话请求，响应需要转换回 text_completion
 }
 
 type TokenCountMeta struct {
@@ -145,6 +187,7 @@ type RelayInfo struct {
 	SubscriptionAmountUsedAfterPreConsume int64
 	IsClaudeBetaQuery                     bool // /v1/messages?beta=true
 	IsChannelTest                         bool // channel test request
+	IsShadow                              bool // 影子流量请求，响应不返回给客户端，不计费、不记录日志
 	RetryIndex                            int
 	LastError                             *types.NewAPIError
 	RuntimeHeadersOverride                map[string]interface{}
@@ -153,6 +196,9 @@ type RelayInfo struct {
 
 	PriceData types.PriceData
 
+	// FinalUsage 结算时的最终用量
+	FinalUsage *dto.Usage
+
 	Request dto.Request
 
 	// RequestConversionChain records request format conversions in order, e.g.
@@ -165,6 +211,7 @@ type RelayInfo struct {
 	ThinkingContentInfo
 	TokenCountMeta
 	*ClaudeConvertInfo
+	*GeminiConvertInfo
 	*RerankerInfo
 	*ResponsesUsageInfo
 	*ChannelMeta
@@ -397,6 +444,7 @@ func GenRelayInfoGemini(c *gin.Context, request dto.Request) *RelayInfo {
 	info := genBaseRelayInfo(c, request)
 	info.RelayFormat = types.RelayFormatGemini
 	info.ShouldIncludeUsage = false
+	info.GeminiConvertInfo = &GeminiConvertInfo{}
 
 	return info
 }
@@ -654,6 +702,11 @@ type TaskRelayInfo struct {
 	// PublicTaskID 是提交时预生成的 task_xxxx 格式公开 ID，
 	// 供 DoResponse 在返回给客户端时使用（避免暴露上游真实 ID）。
 	PublicTaskID string
+	// CallbackURL 网关为本次提交生成的上游完成回调地址，为空表示不注册回调，
+	// 支持回调的适配器在构建请求体时写入对应字段
+	CallbackURL string
+	// Resubmit 失败任务自动重新提交，沿用原任务的预扣费，不再重复预扣
+	Resubmit bool
 
 	ConsumeQuota bool
 
@@ -71,6 +71,32 @@ func GetTaskRequest(c *gin.Context) (TaskSubmitReq, error) {
 	return req, nil
 }
 
+// ValidateImageTaskRequest 解析 OpenAI 生图请求作为任务请求，供实现 ImageTaskAdaptor 的任务适配器使用
+func ValidateImageTaskRequest(c *gin.Context, info *RelayInfo) *dto.TaskError {
+	var req dto.ImageRequest
+	if err := common.UnmarshalBodyReusable(c, &req); err != nil {
+		return createTaskError(err, "invalid_request", http.StatusBadRequest, true)
+	}
+	if taskErr := validatePrompt(req.Prompt); taskErr != nil {
+		return taskErr
+	}
+	info.Action = constant.TaskActionImageGenerate
+	c.Set("image_task_request", &req)
+	return nil
+}
+
+func GetImageTaskRequest(c *gin.Context) (*dto.ImageRequest, error) {
+	v, exists := c.Get("image_task_request")
+	if !exists {
+		return nil, fmt.Errorf("image request not found in context")
+	}
+	req, ok := v.(*dto.ImageRequest)
+	if !ok {
+		return nil, fmt.Errorf("invalid image request type")
+	}
+	return req, nil
+}
+
 func validatePrompt(prompt string) *dto.TaskError {
 	if strings.TrimSpace(prompt) == "" {
 		return createTaskError(fmt.Errorf("prompt is required"), "invalid_request", http.StatusBadRequest, true)
@@ -77,7 +77,10 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 		!info.ChannelSetting.PassThroughBodyEnabled &&
 		service.ShouldChatCompletionsUseResponsesGlobal(info.ChannelId, info.ChannelType, info.OriginModelName) {
 		applySystemPromptIfNeeded(c, info, request)
+		outputValidator := service.NewOutputValidator(c, info, request)
+		outputValidator.Attach()
 		usage, newApiErr := chatCompletionsViaResponses(c, info, adaptor, request)
+		newApiErr = outputValidator.Finish(newApiErr)
 		if newApiErr != nil {
 			return newApiErr
 		}
@@ -93,6 +96,17 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 		return nil
 	}
 
+	var completionsEmulation *service.CompletionsEmulation
+	if !passThroughGlobal && !info.ChannelSetting.PassThroughBodyEnabled {
+		// 上游不支持 legacy completions 时改写为对话请求
+		completionsEmulation, err = service.NewCompletionsEmulation(c, info, request)
+		if err != nil {
+			return types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+		}
+		// 转换请求失败等提前返回的路径不会调用 Finish，确保重试时 RelayMode 与路径已还原
+		defer completionsEmulation.Restore()
+	}
+
 	var requestBody io.Reader
 
 	if passThroughGlobal || info.ChannelSetting.PassThroughBodyEnabled {
@@ -182,6 +196,7 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 	var httpResp *http.Response
 	resp, err := adaptor.DoRequest(c, info, requestBody)
 	if err != nil {
+		completionsEmulation.Finish(nil)
 		return types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
 	}
 
@@ -192,13 +207,21 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 		info.IsStream = info.IsStream || strings.HasPrefix(httpResp.Header.Get("Content-Type"), "text/event-stream")
 		if httpResp.StatusCode != http.StatusOK {
 			newApiErr := service.RelayErrorHandler(c.Request.Context(), httpResp, false)
+			completionsEmulation.Finish(nil)
 			// reset status code 重置状态码
 			service.ResetStatusCode(newApiErr, statusCodeMappingStr)
 			return newApiErr
 		}
 	}
 
+	// 校验工具调用参数与结构化输出，非流式校验失败时不会向客户端写出响应
+	// 模拟 completions 时在最外层将对话响应转换回 text_completion
+	completionsEmulation.Attach()
+	outputValidator := service.NewOutputValidator(c, info, request)
+	outputValidator.Attach()
 	usage, newApiErr := adaptor.DoResponse(c, httpResp, info)
+	newApiErr = outputValidator.Finish(newApiErr)
+	newApiErr = completionsEmulation.Finish(newApiErr)
 	if newApiErr != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(newApiErr, statusCodeMappingStr)
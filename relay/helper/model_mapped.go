@@ -22,6 +22,14 @@ func ModelMappedHelper(c *gin.Context, info *common.RelayInfo, request dto.Reque
 		}
 	}
 
+	// 分流规则选择的上游模型，再按渠道的模型重定向映射
+	startModel := info.OriginModelName
+	if splitModel := c.GetString(string(constant.ContextKeyTrafficSplitModel)); splitModel != "" && splitModel != info.OriginModelName {
+		startModel = splitModel
+		info.UpstreamModelName = splitModel
+		info.IsModelMapped = true
+	}
+
 	// map model name
 	modelMapping := c.GetString("model_mapping")
 	if modelMapping != "" && modelMapping != "{}" {
@@ -32,7 +40,7 @@ func ModelMappedHelper(c *gin.Context, info *common.RelayInfo, request dto.Reque
 		}
 
 		// 支持链式模型重定向，最终使用链尾的模型
-		currentModel := info.OriginModelName
+		currentModel := startModel
 		visitedModels := map[string]bool{
 			currentModel: true,
 		}
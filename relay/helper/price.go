@@ -2,6 +2,7 @@ package helper
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/logger"
@@ -47,6 +48,10 @@ func HandleGroupRatio(ctx *gin.Context, relayInfo *relaycommon.RelayInfo) types.
 	dynamicRatio := setting.GetDynamicRatio()
 	groupRatioInfo.GroupRatio *= dynamicRatio
 
+	// Apply time-of-day model price multiplier (e.g. off-peak discount)
+	groupRatioInfo.TimeRatio = ratio_setting.GetModelTimeRatio(relayInfo.OriginModelName, time.Now())
+	groupRatioInfo.GroupRatio *= groupRatioInfo.TimeRatio
+
 	// Track request for RPM counting
 	setting.IncrementRequestCount()
 
@@ -98,7 +103,10 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		ratio := modelRatio * groupRatioInfo.GroupRatio
 		preConsumedQuota = int(float64(preConsumedTokens) * ratio)
 	} else {
-		if meta.ImagePriceRatio != 0 {
+		if sizeRatio, ok := ratio_setting.GetImageSizeRatio(info.OriginModelName, meta.ImageSize, meta.ImageQuality); ok {
+			// 管理员配置的尺寸/质量倍率优先于内置的 dall-e 倍率
+			modelPrice = modelPrice * sizeRatio * float64(common.Max(meta.ImageCount, 1))
+		} else if meta.ImagePriceRatio != 0 {
 			modelPrice = modelPrice * meta.ImagePriceRatio
 		}
 		preConsumedQuota = int(modelPrice * common.QuotaPerUnit * groupRatioInfo.GroupRatio)
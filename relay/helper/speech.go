@@ -0,0 +1,159 @@
+package helper
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	SpeechStreamFormatAudio = "audio"
+	SpeechStreamFormatSSE   = "sse"
+)
+
+type speechAudioDeltaEvent struct {
+	Type  string `json:"type"`
+	Audio string `json:"audio"`
+}
+
+type speechAudioDoneEvent struct {
+	Type  string           `json:"type"`
+	Usage speechAudioUsage `json:"usage"`
+}
+
+type speechAudioUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// SpeechWriter 将非 OpenAI 上游合成的音频按客户端的 stream_format 写回：
+// 为空时完整返回，audio 时按块透传原始音频，sse 时输出 speech.audio.delta / speech.audio.done 事件。
+// 上游输出 pcm 而客户端请求 wav 或未指定格式时补 WAV 文件头，其余格式按上游实际格式返回，
+// 上游无法输出的格式已在转换请求时由 service.NegotiateSpeechFormat 拒绝
+type SpeechWriter struct {
+	c            *gin.Context
+	streamFormat string
+	format       string
+	sourceFormat string
+	sampleRate   int
+	audio        []byte
+	started      bool
+}
+
+// NewSpeechWriter sourceFormat 为上游实际输出的格式，sampleRate 仅在上游输出 pcm 时使用
+func NewSpeechWriter(c *gin.Context, info *relaycommon.RelayInfo, sourceFormat string, sampleRate int) *SpeechWriter {
+	w := &SpeechWriter{c: c, format: sourceFormat, sourceFormat: sourceFormat, sampleRate: sampleRate}
+	if request, ok := info.Request.(*dto.AudioRequest); ok {
+		w.streamFormat = request.StreamFormat
+		if sourceFormat == "pcm" && request.ResponseFormat != "pcm" {
+			w.format = "wav"
+		}
+	}
+	return w
+}
+
+// IsStream 客户端是否要求边合成边返回
+func (w *SpeechWriter) IsStream() bool {
+	return w.streamFormat == SpeechStreamFormatAudio || w.streamFormat == SpeechStreamFormatSSE
+}
+
+// WriteAudio 写入一段上游音频，流式时立即发送给客户端
+func (w *SpeechWriter) WriteAudio(chunk []byte) error {
+	if len(chunk) == 0 {
+		return nil
+	}
+	w.audio = append(w.audio, chunk...)
+	if !w.IsStream() {
+		return nil
+	}
+	if !w.started && w.format != w.sourceFormat {
+		chunk = append(service.StreamingWAVHeader(w.sampleRate), chunk...)
+	}
+	w.start()
+	if w.streamFormat == SpeechStreamFormatSSE {
+		return ObjectData(w.c, speechAudioDeltaEvent{
+			Type:  "speech.audio.delta",
+			Audio: base64.StdEncoding.EncodeToString(chunk),
+		})
+	}
+	if _, err := w.c.Writer.Write(chunk); err != nil {
+		return err
+	}
+	return FlushWriter(w.c)
+}
+
+func (w *SpeechWriter) start() {
+	if w.started {
+		return
+	}
+	w.started = true
+	if w.streamFormat == SpeechStreamFormatSSE {
+		SetEventStreamHeaders(w.c)
+		return
+	}
+	w.c.Writer.Header().Set("Content-Type", service.SpeechContentType(w.format))
+	w.c.Writer.WriteHeader(http.StatusOK)
+}
+
+// Duration 已写入音频的时长（秒）
+func (w *SpeechWriter) Duration() (float64, error) {
+	if len(w.audio) == 0 {
+		return 0, errors.New("no audio data")
+	}
+	return service.GetSpeechDuration(w.c.Request.Context(), w.audio, w.sourceFormat, w.sampleRate)
+}
+
+// Finish 非流式时一次性返回完整音频，sse 时发送带用量的 speech.audio.done 事件
+func (w *SpeechWriter) Finish(usage *dto.Usage) error {
+	if !w.IsStream() {
+		audio := w.audio
+		if w.format != w.sourceFormat {
+			audio = service.PCMToWAV(audio, w.sampleRate)
+		}
+		w.c.Data(http.StatusOK, service.SpeechContentType(w.format), audio)
+		return nil
+	}
+	w.start()
+	if w.streamFormat != SpeechStreamFormatSSE {
+		return nil
+	}
+	return ObjectData(w.c, speechAudioDoneEvent{
+		Type: "speech.audio.done",
+		Usage: speechAudioUsage{
+			InputTokens:  usage.PromptTokens,
+			OutputTokens: usage.CompletionTokens,
+			TotalTokens:  usage.TotalTokens,
+		},
+	})
+}
+
+// ScanSpeechEvents 逐条读取上游 TTS 的 SSE data 行；与 StreamScannerHandler 不同，不向客户端写入任何内容，
+// 避免心跳等数据混入原始音频流
+func ScanSpeechEvents(body io.Reader, handler func(data string) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, InitialScannerBufferSize), getScannerBufferSize())
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" || data == "[DONE]" {
+			continue
+		}
+		if err := handler(data); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
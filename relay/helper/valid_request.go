@@ -143,6 +143,26 @@ func GetAndValidOpenAIImageRequest(c *gin.Context, relayMode int) (*dto.ImageReq
 	imageRequest := &dto.ImageRequest{}
 
 	switch relayMode {
+	case relayconstant.RelayModeImagesVariations:
+		// variations 仅支持 multipart 表单，且没有 prompt 字段
+		_, err := c.MultipartForm()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse image variation form request: %w", err)
+		}
+		formData := c.Request.PostForm
+		imageRequest.Model = formData.Get("model")
+		imageRequest.Size = formData.Get("size")
+		imageRequest.ResponseFormat = formData.Get("response_format")
+		imageRequest.N = common.GetPointer(uint(common.String2Int(formData.Get("n"))))
+		if imageRequest.Model == "" {
+			imageRequest.Model = "dall-e-2"
+		}
+		if imageRequest.N == nil || *imageRequest.N == 0 {
+			imageRequest.N = common.GetPointer(uint(1))
+		}
+		if c.Request.MultipartForm == nil || len(c.Request.MultipartForm.File["image"]) == 0 {
+			return nil, errors.New("image is required")
+		}
 	case relayconstant.RelayModeImagesEdits:
 		if strings.Contains(c.Request.Header.Get("Content-Type"), "multipart/form-data") {
 			_, err := c.MultipartForm()
@@ -254,6 +274,11 @@ func GetAndValidateTextRequest(c *gin.Context, relayMode int) (*dto.GeneralOpenA
 		return nil, err
 	}
 
+	// logprobs 为 null 时与未传一致，不转发给上游
+	if string(textRequest.LogProbs) == "null" {
+		textRequest.LogProbs = nil
+	}
+
 	if relayMode == relayconstant.RelayModeModerations && textRequest.Model == "" {
 		textRequest.Model = "text-moderation-latest"
 	}
@@ -292,6 +317,10 @@ func GetAndValidateTextRequest(c *gin.Context, relayMode int) (*dto.GeneralOpenA
 		if len(textRequest.Messages) == 0 && textRequest.Prefix == nil && textRequest.Suffix == nil {
 			return nil, errors.New("field messages is required")
 		}
+		// chat 接口的 logprobs 为 bool，整数形式仅用于 legacy completions
+		if len(textRequest.LogProbs) > 0 && common.GetJsonType(textRequest.LogProbs) != "boolean" {
+			return nil, errors.New("logprobs must be a boolean")
+		}
 	case relayconstant.RelayModeEmbeddings:
 	case relayconstant.RelayModeModerations:
 		if textRequest.Input == nil || textRequest.Input == "" {
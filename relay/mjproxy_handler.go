@@ -15,6 +15,7 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/model"
+	taskmidjourney "github.com/QuantumNous/new-api/relay/channel/task/midjourney"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/relay/helper"
@@ -27,15 +28,22 @@ import (
 
 func RelayMidjourneyImage(c *gin.Context) {
 	taskId := c.Param("id")
-	midjourneyTask := model.GetByOnlyMJId(taskId)
-	if midjourneyTask == nil {
+	var channelId int
+	var imageUrl string
+	if task, exist, _ := model.GetByOnlyTaskId(taskId); exist && task.Platform == constant.TaskPlatformMidjourney {
+		channelId = task.ChannelId
+		imageUrl = taskmidjourney.ConvertToMidjourneyDto(task).ImageUrl
+	} else if midjourneyTask := model.GetByOnlyMJId(taskId); midjourneyTask != nil {
+		channelId = midjourneyTask.ChannelId
+		imageUrl = midjourneyTask.ImageUrl
+	} else {
 		c.JSON(400, gin.H{
 			"error": "midjourney_task_not_found",
 		})
 		return
 	}
 	var httpClient *http.Client
-	if channel, err := model.CacheGetChannel(midjourneyTask.ChannelId); err == nil {
+	if channel, err := model.CacheGetChannel(channelId); err == nil {
 		proxy := channel.GetSetting().Proxy
 		if proxy != "" {
 			if httpClient, err = service.NewProxyHttpClient(proxy); err != nil {
@@ -49,7 +57,7 @@ func RelayMidjourneyImage(c *gin.Context) {
 	if httpClient == nil {
 		httpClient = service.GetHttpClient()
 	}
-	resp, err := httpClient.Get(midjourneyTask.ImageUrl)
+	resp, err := httpClient.Get(imageUrl)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "http_get_image_failed",
@@ -172,6 +180,18 @@ func coverMidjourneyTaskDto(c *gin.Context, originTask *model.Midjourney) (midjo
 	return
 }
 
+// coverTaskMidjourneyDto 将统一任务表中的 MJ 任务转换为 /mj 接口格式，图片地址按配置走网关转发
+func coverTaskMidjourneyDto(task *model.Task) dto.MidjourneyDto {
+	midjourneyTask := taskmidjourney.ConvertToMidjourneyDto(task)
+	if midjourneyTask.ImageUrl != "" && setting.MjForwardUrlEnabled {
+		midjourneyTask.ImageUrl = system_setting.ServerAddress + "/mj/image/" + task.TaskID
+		if task.Status != model.TaskStatusSuccess {
+			midjourneyTask.ImageUrl += "?rand=" + strconv.FormatInt(time.Now().UnixNano(), 10)
+		}
+	}
+	return midjourneyTask
+}
+
 func RelaySwapFace(c *gin.Context, info *relaycommon.RelayInfo) *dto.MidjourneyResponse {
 	var swapFaceRequest dto.SwapFaceRequest
 	err := common.UnmarshalBodyReusable(c, &swapFaceRequest)
@@ -230,6 +250,7 @@ func RelaySwapFace(c *gin.Context, info *relaycommon.RelayInfo) *dto.MidjourneyR
 				ModelName: modelName,
 				TokenName: tokenName,
 				Quota:     priceData.Quota,
+				Cost:      service.CalculateUpstreamCost(info, service.UpstreamCostUsage{}),
 				Content:   logContent,
 				TokenId:   info.TokenId,
 				Group:     info.UsingGroup,
@@ -278,21 +299,26 @@ func RelaySwapFace(c *gin.Context, info *relaycommon.RelayInfo) *dto.MidjourneyR
 func RelayMidjourneyTaskImageSeed(c *gin.Context) *dto.MidjourneyResponse {
 	taskId := c.Param("id")
 	userId := c.GetInt("id")
-	originTask := model.GetByMJId(userId, taskId)
-	if originTask == nil {
+	var channelId int
+	requestURL := getMjRequestPath(c.Request.URL.String())
+	if task, exist, _ := model.GetByTaskId(userId, taskId); exist && task.Platform == constant.TaskPlatformMidjourney {
+		channelId = task.ChannelId
+		requestURL = fmt.Sprintf("/mj/task/%s/image-seed", task.GetUpstreamTaskID())
+	} else if originTask := model.GetByMJId(userId, taskId); originTask != nil {
+		channelId = originTask.ChannelId
+	} else {
 		return service.MidjourneyErrorWrapper(constant.MjRequestError, "task_no_found")
 	}
-	channel, err := model.GetChannelById(originTask.ChannelId, true)
+	channel, err := model.GetChannelById(channelId, true)
 	if err != nil {
 		return service.MidjourneyErrorWrapper(constant.MjRequestError, "get_channel_info_failed")
 	}
 	if channel.Status != common.ChannelStatusEnabled {
 		return service.MidjourneyErrorWrapper(constant.MjRequestError, "该任务所属渠道已被禁用")
 	}
-	c.Set("channel_id", originTask.ChannelId)
+	c.Set("channel_id", channelId)
 	c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", channel.Key))
 
-	requestURL := getMjRequestPath(c.Request.URL.String())
 	fullRequestURL := fmt.Sprintf("%s%s", channel.GetBaseURL(), requestURL)
 	midjResponseWithStatus, _, err := service.DoMidjourneyHttpRequest(c, time.Second*30, fullRequestURL)
 	if err != nil {
@@ -315,14 +341,17 @@ func RelayMidjourneyTask(c *gin.Context, relayMode int) *dto.MidjourneyResponse
 	switch relayMode {
 	case relayconstant.RelayModeMidjourneyTaskFetch:
 		taskId := c.Param("id")
-		originTask := model.GetByMJId(userId, taskId)
-		if originTask == nil {
+		var midjourneyTask dto.MidjourneyDto
+		if task, exist, _ := model.GetByTaskId(userId, taskId); exist && task.Platform == constant.TaskPlatformMidjourney {
+			midjourneyTask = coverTaskMidjourneyDto(task)
+		} else if originTask := model.GetByMJId(userId, taskId); originTask != nil {
+			midjourneyTask = coverMidjourneyTaskDto(c, originTask)
+		} else {
 			return &dto.MidjourneyResponse{
 				Code:        4,
 				Description: "task_no_found",
 			}
 		}
-		midjourneyTask := coverMidjourneyTaskDto(c, originTask)
 		respBody, err = json.Marshal(midjourneyTask)
 		if err != nil {
 			return &dto.MidjourneyResponse{
@@ -343,10 +372,32 @@ func RelayMidjourneyTask(c *gin.Context, relayMode int) *dto.MidjourneyResponse
 		}
 		var tasks []dto.MidjourneyDto
 		if len(condition.IDs) != 0 {
-			originTasks := model.GetByMJIds(userId, condition.IDs)
-			for _, originTask := range originTasks {
-				midjourneyTask := coverMidjourneyTaskDto(c, originTask)
-				tasks = append(tasks, midjourneyTask)
+			// 先查统一任务表，未找到的再回退到旧版 midjourneys 表
+			found := make(map[string]bool, len(condition.IDs))
+			ids := make([]any, 0, len(condition.IDs))
+			for _, id := range condition.IDs {
+				ids = append(ids, id)
+			}
+			unifiedTasks, _ := model.GetByTaskIds(userId, ids)
+			for _, task := range unifiedTasks {
+				if task.Platform != constant.TaskPlatformMidjourney {
+					continue
+				}
+				found[task.TaskID] = true
+				tasks = append(tasks, coverTaskMidjourneyDto(task))
+			}
+			legacyIds := make([]string, 0, len(condition.IDs))
+			for _, id := range condition.IDs {
+				if !found[id] {
+					legacyIds = append(legacyIds, id)
+				}
+			}
+			if len(legacyIds) > 0 {
+				originTasks := model.GetByMJIds(userId, legacyIds)
+				for _, originTask := range originTasks {
+					midjourneyTask := coverMidjourneyTaskDto(c, originTask)
+					tasks = append(tasks, midjourneyTask)
+				}
 			}
 		}
 		if tasks == nil {
@@ -536,6 +587,7 @@ func RelayMidjourneySubmit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dt
 				ModelName: modelName,
 				TokenName: tokenName,
 				Quota:     priceData.Quota,
+				Cost:      service.CalculateUpstreamCost(relayInfo, service.UpstreamCostUsage{}),
 				Content:   logContent,
 				TokenId:   relayInfo.TokenId,
 				Group:     relayInfo.UsingGroup,
@@ -36,6 +36,8 @@ import (
 	"github.com/QuantumNous/new-api/relay/channel/task/hailuo"
 	taskjimeng "github.com/QuantumNous/new-api/relay/channel/task/jimeng"
 	"github.com/QuantumNous/new-api/relay/channel/task/kling"
+	taskmidjourney "github.com/QuantumNous/new-api/relay/channel/task/midjourney"
+	taskreplicate "github.com/QuantumNous/new-api/relay/channel/task/replicate"
 	tasksora "github.com/QuantumNous/new-api/relay/channel/task/sora"
 	"github.com/QuantumNous/new-api/relay/channel/task/suno"
 	taskvertex "github.com/QuantumNous/new-api/relay/channel/task/vertex"
@@ -138,6 +140,8 @@ func GetTaskAdaptor(platform constant.TaskPlatform) channel.TaskAdaptor {
 	//	return &aiproxy.Adaptor{}
 	case constant.TaskPlatformSuno:
 		return &suno.TaskAdaptor{}
+	case constant.TaskPlatformMidjourney:
+		return &taskmidjourney.TaskAdaptor{}
 	}
 	if channelType, err := strconv.ParseInt(string(platform), 10, 64); err == nil {
 		switch channelType {
@@ -159,7 +163,15 @@ func GetTaskAdaptor(platform constant.TaskPlatform) channel.TaskAdaptor {
 			return &taskGemini.TaskAdaptor{}
 		case constant.ChannelTypeMiniMax:
 			return &hailuo.TaskAdaptor{}
+		case constant.ChannelTypeReplicate:
+			return &taskreplicate.TaskAdaptor{}
 		}
 	}
 	return nil
 }
+
+// GetImageTaskAdaptor 返回支持异步生图任务的任务适配器，不支持时返回 nil
+func GetImageTaskAdaptor(platform constant.TaskPlatform) channel.ImageTaskAdaptor {
+	imageAdaptor, _ := GetTaskAdaptor(platform).(channel.ImageTaskAdaptor)
+	return imageAdaptor
+}
@@ -14,11 +14,13 @@ import (
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/relay/channel"
+	taskmidjourney "github.com/QuantumNous/new-api/relay/channel/task/midjourney"
 	"github.com/QuantumNous/new-api/relay/channel/task/taskcommon"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/relay/helper"
 	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting"
 	"github.com/gin-gonic/gin"
 )
 
@@ -51,6 +53,12 @@ func ResolveOriginTask(c *gin.Context, info *relaycommon.RelayInfo) *dto.TaskErr
 		info.OriginTaskID = videoID
 	}
 
+	// Midjourney 放大、变换等操作引用已有任务，必须提交到原任务所在渠道
+	isMidjourney := strings.Contains(path, "/mj/")
+	if isMidjourney {
+		info.OriginTaskID = taskmidjourney.GetOriginTaskID(c)
+	}
+
 	if info.OriginTaskID == "" {
 		return nil
 	}
@@ -63,6 +71,15 @@ func ResolveOriginTask(c *gin.Context, info *relaycommon.RelayInfo) *dto.TaskErr
 	if !exist {
 		return service.TaskErrorWrapperLocal(errors.New("task_origin_not_exist"), "task_not_exist", http.StatusBadRequest)
 	}
+	if isMidjourney {
+		if originTask.Platform != constant.TaskPlatformMidjourney {
+			return service.TaskErrorWrapperLocal(errors.New("task_origin_not_exist"), "task_not_exist", http.StatusBadRequest)
+		}
+		// 原任务成功后才能放大、变换；弹窗提交针对的是等待输入的任务
+		if setting.MjActionCheckSuccessEnabled && originTask.Status != model.TaskStatusSuccess && !strings.HasSuffix(path, "/mj/submit/modal") {
+			return service.TaskErrorWrapperLocal(errors.New("task_status_not_success"), "task_status_not_success", http.StatusBadRequest)
+		}
+	}
 
 	// 从原始任务推导模型名称
 	if info.OriginModelName == "" {
@@ -153,6 +170,12 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (*TaskSubmitRe
 	if adaptor == nil {
 		return nil, service.TaskErrorWrapperLocal(fmt.Errorf("invalid api platform: %s", platform), "invalid_api_platform", http.StatusBadRequest)
 	}
+	// 生图任务可能在重试时切换到不支持的渠道
+	if info.RelayMode == relayconstant.RelayModeImagesGenerations {
+		if _, ok := adaptor.(channel.ImageTaskAdaptor); !ok {
+			return nil, service.TaskErrorWrapperLocal(fmt.Errorf("channel type %s does not support image generation tasks", platform), "invalid_api_platform", http.StatusBadRequest)
+		}
+	}
 	adaptor.Init(info)
 	if taskErr := adaptor.ValidateRequestAndSetAction(c, info); taskErr != nil {
 		return nil, taskErr
@@ -175,6 +198,11 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (*TaskSubmitRe
 	if info.PublicTaskID == "" {
 		info.PublicTaskID = model.GenerateTaskID()
 	}
+	// 回调地址绑定渠道，重试切换渠道时需重新生成
+	info.CallbackURL = ""
+	if _, ok := adaptor.(channel.TaskCallbackReceiver); ok {
+		info.CallbackURL = service.BuildTaskCallbackURL(info.ChannelId, info.PublicTaskID)
+	}
 
 	// 4. 价格计算：基础模型价格
 	info.OriginModelName = modelName
@@ -203,7 +231,7 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (*TaskSubmitRe
 	}
 
 	// 7. 预扣费（仅首次 — 重试时 info.Billing 已存在，跳过）
-	if info.Billing == nil && !info.PriceData.FreeModel {
+	if info.Billing == nil && !info.PriceData.FreeModel && !info.Resubmit {
 		info.ForcePreConsume = true
 		if apiErr := service.PreConsumeBilling(c, info.PriceData.Quota, info); apiErr != nil {
 			return nil, service.TaskErrorFromAPIError(apiErr)
@@ -221,7 +249,8 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (*TaskSubmitRe
 	if err != nil {
 		return nil, service.TaskErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
 	}
-	if resp != nil && resp.StatusCode != http.StatusOK {
+	// Replicate 等上游创建任务时返回 201
+	if resp != nil && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
 		responseBody, _ := io.ReadAll(resp.Body)
 		return nil, service.TaskErrorWrapper(fmt.Errorf("%s", string(responseBody)), "fail_to_fetch_task", resp.StatusCode)
 	}
@@ -397,7 +426,7 @@ func videoFetchByIDRespBodyBuilder(c *gin.Context) (respBody []byte, taskResp *d
 				taskResp = service.TaskErrorWrapper(err, "convert_to_openai_video_failed", http.StatusInternalServerError)
 				return
 			}
-			respBody = openAIVideoData
+			respBody = service.RewriteTaskMediaURLs(originTask, openAIVideoData)
 			return
 		}
 		taskResp = service.TaskErrorWrapperLocal(fmt.Errorf("not_implemented:%s", originTask.Platform), "not_implemented", http.StatusNotImplemented)
@@ -474,7 +503,10 @@ func tryRealtimeFetch(task *model.Task, isOpenAIVideoAPI bool) []byte {
 	}
 
 	if !snap.Equal(task.Snapshot()) {
-		_, _ = task.UpdateWithStatus(snap.Status)
+		won, _ := task.UpdateWithStatus(snap.Status)
+		if won && snap.Status != model.TaskStatusSuccess && task.Status == model.TaskStatusSuccess {
+			service.ArchiveTaskMediaAsync(task)
+		}
 	}
 
 	// OpenAI Video API 由调用者的 ConvertToOpenAIVideo 分支处理
@@ -490,7 +522,7 @@ func tryRealtimeFetch(task *model.Task, isOpenAIVideoAPI bool) []byte {
 		"metadata": nil,
 		"status":   mapTaskStatusToSimple(task.Status),
 		"task_id":  task.TaskID,
-		"url":      task.GetResultURL(),
+		"url":      service.GetTaskPublicResultURL(task),
 	}
 	respBody, _ := common.Marshal(dto.TaskResponse[any]{
 		Code: "success",
@@ -539,6 +571,13 @@ func mapTaskStatusToSimple(status model.TaskStatus) string {
 }
 
 func TaskModel2Dto(task *model.Task) *dto.TaskDto {
+	resultURL := task.GetResultURL()
+	data := task.Data
+	// 已转存的结果替换为网关签名链接
+	if replacer := service.NewTaskMediaReplacer(task); replacer != nil {
+		resultURL = replacer.Replace(resultURL)
+		data = []byte(replacer.Replace(string(data)))
+	}
 	return &dto.TaskDto{
 		ID:         task.ID,
 		CreatedAt:  task.CreatedAt,
@@ -552,13 +591,13 @@ func TaskModel2Dto(task *model.Task) *dto.TaskDto {
 		Action:     task.Action,
 		Status:     string(task.Status),
 		FailReason: task.FailReason,
-		ResultURL:  task.GetResultURL(),
+		ResultURL:  resultURL,
 		SubmitTime: task.SubmitTime,
 		StartTime:  task.StartTime,
 		FinishTime: task.FinishTime,
 		Progress:   task.Progress,
 		Properties: task.Properties,
 		Username:   task.Username,
-		Data:       task.Data,
+		Data:       data,
 	}
 }
@@ -0,0 +1,128 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel"
+	"github.com/QuantumNous/new-api/relay/channel/task/taskcommon"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const taskCancelReason = "任务已被用户取消"
+
+// RelayTaskCancel 取消排队或执行中的任务：先请求上游取消，再以 CAS 方式将任务置为失败并全额退款
+func RelayTaskCancel(c *gin.Context) (taskResp *dto.TaskError) {
+	taskId := c.Param("task_id")
+	if taskId == "" {
+		taskId = c.Param("id")
+	}
+	userId := c.GetInt("id")
+
+	task, exist, err := model.GetByTaskId(userId, taskId)
+	if err != nil {
+		return service.TaskErrorWrapper(err, "get_task_failed", http.StatusInternalServerError)
+	}
+	if !exist {
+		return service.TaskErrorWrapperLocal(errors.New("task_not_exist"), "task_not_exist", http.StatusNotFound)
+	}
+	if task.Status == model.TaskStatusSuccess || task.Status == model.TaskStatusFailure {
+		return service.TaskErrorWrapperLocal(fmt.Errorf("task already finished with status %s", task.Status), "task_already_finished", http.StatusBadRequest)
+	}
+
+	if task.QueuedAt > 0 {
+		return cancelQueuedTask(c, task)
+	}
+
+	adaptor := GetTaskAdaptor(task.Platform)
+	canceler, ok := adaptor.(channel.TaskCanceler)
+	if !ok {
+		return service.TaskErrorWrapperLocal(fmt.Errorf("task cancellation is not supported by platform %s", task.Platform), "cancel_not_supported", http.StatusBadRequest)
+	}
+
+	ch, err := model.CacheGetChannel(task.ChannelId)
+	if err != nil {
+		return service.TaskErrorWrapper(err, "get_channel_failed", http.StatusInternalServerError)
+	}
+	baseURL := constant.ChannelBaseURLs[ch.Type]
+	if ch.GetBaseURL() != "" {
+		baseURL = ch.GetBaseURL()
+	}
+	key := ch.Key
+	if task.PrivateData.Key != "" {
+		key = task.PrivateData.Key
+	}
+	if err = canceler.CancelTask(baseURL, key, task.GetUpstreamTaskID(), ch.GetSetting().Proxy); err != nil {
+		logger.LogWarn(c, fmt.Sprintf("cancel upstream task %s failed: %s", task.TaskID, err.Error()))
+		return service.TaskErrorWrapper(err, "cancel_task_failed", http.StatusBadGateway)
+	}
+
+	oldStatus := task.Status
+	task.Status = model.TaskStatusFailure
+	task.Progress = taskcommon.ProgressComplete
+	task.FinishTime = time.Now().Unix()
+	task.FailReason = taskCancelReason
+	won, err := task.UpdateWithStatus(oldStatus)
+	if err != nil {
+		return service.TaskErrorWrapper(err, "update_task_failed", http.StatusInternalServerError)
+	}
+	if !won {
+		// 轮询已先一步推进了任务状态，由轮询负责结算，这里不再退款
+		return service.TaskErrorWrapperLocal(errors.New("task status changed during cancellation"), "task_status_changed", http.StatusConflict)
+	}
+	service.RefundTaskQuota(c, task, taskCancelReason)
+	logger.LogInfo(c, fmt.Sprintf("task %s cancelled by user %d", task.TaskID, userId))
+	return respondTaskCancelled(c, task)
+}
+
+// cancelQueuedTask 取消仍在网关排队的任务：任务尚未提交到上游也未扣费，认领后直接置为失败
+func cancelQueuedTask(c *gin.Context, task *model.Task) *dto.TaskError {
+	won, err := model.ClaimQueuedTask(task.ID, task.QueuedAt, 0)
+	if err != nil {
+		return service.TaskErrorWrapper(err, "update_task_failed", http.StatusInternalServerError)
+	}
+	if !won {
+		// 任务已被出队提交，需按执行中的任务重新发起取消
+		return service.TaskErrorWrapperLocal(errors.New("task status changed during cancellation"), "task_status_changed", http.StatusConflict)
+	}
+	task.QueuedAt = 0
+	task.Status = model.TaskStatusFailure
+	task.Progress = taskcommon.ProgressComplete
+	task.FinishTime = time.Now().Unix()
+	task.FailReason = taskCancelReason
+	if _, err = task.UpdateWithStatus(model.TaskStatusSubmitted); err != nil {
+		return service.TaskErrorWrapper(err, "update_task_failed", http.StatusInternalServerError)
+	}
+	logger.LogInfo(c, fmt.Sprintf("queued task %s cancelled by user %d", task.TaskID, task.UserId))
+	return respondTaskCancelled(c, task)
+}
+
+func respondTaskCancelled(c *gin.Context, task *model.Task) *dto.TaskError {
+	var respBody []byte
+	var err error
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/videos/") {
+		video := task.ToOpenAIVideo()
+		video.Error = &dto.OpenAIVideoError{Message: taskCancelReason, Code: "cancelled"}
+		respBody, err = common.Marshal(video)
+	} else {
+		respBody, err = common.Marshal(dto.TaskResponse[any]{
+			Code: "success",
+			Data: TaskModel2Dto(task),
+		})
+	}
+	if err != nil {
+		return service.TaskErrorWrapper(err, "marshal_response_failed", http.StatusInternalServerError)
+	}
+	c.Data(http.StatusOK, "application/json", respBody)
+	return nil
+}
@@ -0,0 +1,190 @@
+package relay
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		panic("failed to open test db: " + err.Error())
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		panic("failed to get sql.DB: " + err.Error())
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	model.DB = db
+	model.LOG_DB = db
+
+	common.UsingSQLite = true
+	common.RedisEnabled = false
+	common.BatchUpdateEnabled = false
+	common.LogConsumeEnabled = true
+
+	if err := db.AutoMigrate(&model.Task{}, &model.User{}, &model.Token{}, &model.Log{}, &model.Channel{}); err != nil {
+		panic("failed to migrate: " + err.Error())
+	}
+	gin.SetMode(gin.TestMode)
+	service.InitHttpClient()
+
+	code := m.Run()
+	_ = sqlDB.Close()
+	os.Exit(code)
+}
+
+// seedCancelTask 创建用户、令牌、Sora 渠道与已预扣 quota 的进行中任务
+func seedCancelTask(t *testing.T, baseURL string, status model.TaskStatus) *model.Task {
+	t.Helper()
+	t.Cleanup(func() {
+		model.DB.Exec("DELETE FROM tasks")
+		model.DB.Exec("DELETE FROM users")
+		model.DB.Exec("DELETE FROM tokens")
+		model.DB.Exec("DELETE FROM logs")
+		model.DB.Exec("DELETE FROM channels")
+	})
+	require.NoError(t, model.DB.Create(&model.User{Id: 1, Username: "cancel_user", Quota: 1000, Status: common.UserStatusEnabled}).Error)
+	require.NoError(t, model.DB.Create(&model.Token{Id: 1, UserId: 1, Key: "cancel-token", Name: "t", Status: common.TokenStatusEnabled, RemainQuota: 1000}).Error)
+	require.NoError(t, model.DB.Create(&model.Channel{Id: 1, Name: "sora", Type: constant.ChannelTypeSora, Key: "sk-test", Status: common.ChannelStatusEnabled, BaseURL: common.GetPointer(baseURL)}).Error)
+
+	task := &model.Task{
+		TaskID:     "task_cancel",
+		Platform:   constant.TaskPlatform(strconv.Itoa(constant.ChannelTypeSora)),
+		UserId:     1,
+		ChannelId:  1,
+		Quota:      300,
+		Status:     status,
+		Group:      "default",
+		Data:       json.RawMessage(`{}`),
+		CreatedAt:  time.Now().Unix(),
+		Properties: model.Properties{OriginModelName: "sora-2"},
+		PrivateData: model.TaskPrivateData{
+			UpstreamTaskID: "video_upstream",
+			BillingSource:  service.BillingSourceWallet,
+			TokenId:        1,
+		},
+	}
+	require.NoError(t, model.DB.Create(task).Error)
+	return task
+}
+
+func newCancelContext(taskId string) (*gin.Context, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodDelete, "/v1/videos/"+taskId, nil)
+	c.Params = gin.Params{{Key: "task_id", Value: taskId}}
+	c.Set("id", 1)
+	return c, recorder
+}
+
+func getTaskAndQuota(t *testing.T, taskId string) (*model.Task, int) {
+	t.Helper()
+	task, exist, err := model.GetByOnlyTaskId(taskId)
+	require.NoError(t, err)
+	require.True(t, exist)
+	var user model.User
+	require.NoError(t, model.DB.Select("quota").Where("id = ?", 1).First(&user).Error)
+	return task, user.Quota
+}
+
+func TestRelayTaskCancel_RefundsOnSuccess(t *testing.T) {
+	var cancelled atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodDelete, r.Method)
+		require.Equal(t, "/v1/videos/video_upstream", r.URL.Path)
+		cancelled.Store(true)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+	task := seedCancelTask(t, upstream.URL, model.TaskStatusInProgress)
+
+	c, recorder := newCancelContext(task.TaskID)
+	require.Nil(t, RelayTaskCancel(c))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.True(t, cancelled.Load())
+
+	task, quota := getTaskAndQuota(t, task.TaskID)
+	require.Equal(t, model.TaskStatus(model.TaskStatusFailure), task.Status)
+	require.Equal(t, taskCancelReason, task.FailReason)
+	require.Equal(t, 1300, quota)
+}
+
+func TestRelayTaskCancel_UpstreamRefusal(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte(`{"error":{"message":"video already completed"}}`))
+	}))
+	defer upstream.Close()
+	task := seedCancelTask(t, upstream.URL, model.TaskStatusInProgress)
+
+	c, _ := newCancelContext(task.TaskID)
+	taskErr := RelayTaskCancel(c)
+	require.NotNil(t, taskErr)
+	require.Equal(t, "cancel_task_failed", taskErr.Code)
+	require.Equal(t, http.StatusBadGateway, taskErr.StatusCode)
+
+	// 上游拒绝时任务保持原状态，不退款
+	task, quota := getTaskAndQuota(t, task.TaskID)
+	require.Equal(t, model.TaskStatus(model.TaskStatusInProgress), task.Status)
+	require.Equal(t, 1000, quota)
+}
+
+func TestRelayTaskCancel_AlreadyFinished(t *testing.T) {
+	var called atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called.Store(true)
+	}))
+	defer upstream.Close()
+	task := seedCancelTask(t, upstream.URL, model.TaskStatusSuccess)
+
+	c, _ := newCancelContext(task.TaskID)
+	taskErr := RelayTaskCancel(c)
+	require.NotNil(t, taskErr)
+	require.Equal(t, "task_already_finished", taskErr.Code)
+	require.Equal(t, http.StatusBadRequest, taskErr.StatusCode)
+	require.False(t, called.Load())
+
+	task, quota := getTaskAndQuota(t, task.TaskID)
+	require.Equal(t, model.TaskStatus(model.TaskStatusSuccess), task.Status)
+	require.Equal(t, 1000, quota)
+}
+
+func TestRelayTaskCancel_QueuedTask(t *testing.T) {
+	var called atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called.Store(true)
+	}))
+	defer upstream.Close()
+	task := seedCancelTask(t, upstream.URL, model.TaskStatusQueued)
+	// 排队任务尚未选择渠道、未扣费
+	require.NoError(t, model.DB.Model(task).Updates(map[string]any{"channel_id": 0, "quota": 0, "queued_at": time.Now().Unix()}).Error)
+
+	c, recorder := newCancelContext(task.TaskID)
+	require.Nil(t, RelayTaskCancel(c))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.False(t, called.Load())
+
+	task, quota := getTaskAndQuota(t, task.TaskID)
+	require.Equal(t, model.TaskStatus(model.TaskStatusFailure), task.Status)
+	require.Equal(t, taskCancelReason, task.FailReason)
+	require.Zero(t, task.QueuedAt)
+	require.Equal(t, 1000, quota)
+}
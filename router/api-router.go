@@ -142,6 +142,20 @@ func SetApiRouter(router *gin.Engine) {
 			optionRoute.POST("/rest_model_ratio", controller.ResetModelRatio)
 			optionRoute.POST("/migrate_console_setting", controller.MigrateConsoleSetting) // 用于迁移检测的旧键，下个版本会删除
 		}
+		shadowRoute := apiRouter.Group("/shadow")
+		shadowRoute.Use(middleware.AdminAuth())
+		{
+			shadowRoute.GET("/report", controller.GetShadowComparisonReport)
+			shadowRoute.GET("/records", controller.GetShadowComparisons)
+			shadowRoute.DELETE("/records", controller.DeleteShadowComparisons)
+		}
+		configRoute := apiRouter.Group("/config")
+		configRoute.Use(middleware.RootAuth())
+		{
+			configRoute.GET("/export", middleware.DisableCache(), controller.ExportConfigBundle)
+			configRoute.POST("/diff", controller.DiffConfigBundle)
+			configRoute.POST("/apply", middleware.CriticalRateLimit(), controller.ApplyConfigBundle)
+		}
 		ratioSyncRoute := apiRouter.Group("/ratio_sync")
 		ratioSyncRoute.Use(middleware.RootAuth())
 		{
@@ -159,6 +173,10 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.POST("/:id/key", middleware.RootAuth(), middleware.CriticalRateLimit(), middleware.DisableCache(), middleware.SecureVerificationRequired(), controller.GetChannelKey)
 			channelRoute.GET("/test", controller.TestAllChannels)
 			channelRoute.GET("/test/:id", controller.TestChannel)
+			channelRoute.POST("/test/:id/keys", controller.TestChannelKeys)
+			channelRoute.GET("/canary/stats", controller.GetChannelCanaryPassRates)
+			channelRoute.GET("/canary/records", controller.GetChannelCanaryResults)
+			channelRoute.POST("/canary/:id", controller.RunChannelCanary)
 			channelRoute.GET("/update_balance", controller.UpdateAllChannelsBalance)
 			channelRoute.GET("/update_balance/:id", controller.UpdateChannelBalance)
 			channelRoute.POST("/", controller.AddChannel)
@@ -176,6 +194,8 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.GET("/tag/models", controller.GetTagModels)
 			channelRoute.POST("/copy/:id", controller.CopyChannel)
 			channelRoute.POST("/multi_key/manage", controller.ManageMultiKeys)
+			channelRoute.GET("/key_encryption", middleware.RootAuth(), controller.GetChannelKeyEncryptionStatus)
+			channelRoute.POST("/key_encryption/rotate", middleware.RootAuth(), middleware.CriticalRateLimit(), controller.RotateChannelKeyEncryption)
 		}
 		tokenRoute := apiRouter.Group("/token")
 		tokenRoute.Use(middleware.UserAuth())
@@ -253,6 +273,8 @@ func SetApiRouter(router *gin.Engine) {
 
 		dataRoute := apiRouter.Group("/data")
 		dataRoute.GET("/", middleware.AdminAuth(), controller.GetAllQuotaDates)
+		dataRoute.GET("/margin", middleware.AdminAuth(), controller.GetMarginReport)
+		dataRoute.GET("/traffic_split", middleware.AdminAuth(), controller.GetTrafficSplitReport)
 		dataRoute.GET("/self", middleware.UserAuth(), controller.GetUserQuotaDates)
 
 		logRoute.Use(middleware.CORS())
@@ -282,6 +304,9 @@ func SetApiRouter(router *gin.Engine) {
 		{
 			taskRoute.GET("/self", middleware.UserAuth(), controller.GetUserTask)
 			taskRoute.GET("/", middleware.AdminAuth(), controller.GetAllTask)
+			taskRoute.GET("/polling/stats", middleware.AdminAuth(), controller.GetTaskPollingStats)
+			// 上游任务完成回调，通过地址中的签名鉴权
+			taskRoute.POST("/callback/:channel_id/:task_id", controller.TaskCallback)
 		}
 
 		vendorRoute := apiRouter.Group("/vendors")
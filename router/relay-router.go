@@ -59,6 +59,11 @@ func SetRelayRouter(router *gin.Engine) {
 		})
 	}
 
+	// 本地存储的生成图片，通过带过期时间的签名链接访问
+	router.GET("/v1/images/generated/:name", controller.GetImage)
+	// 转存的生成结果，通过带过期时间的签名链接访问
+	router.GET("/v1/media/:id", controller.GetMediaContent)
+
 	playgroundRouter := router.Group("/pg")
 	playgroundRouter.Use(middleware.RouteTag("relay"))
 	playgroundRouter.Use(middleware.SystemPerformanceCheck())
@@ -80,6 +85,10 @@ func SetRelayRouter(router *gin.Engine) {
 			controller.Relay(c, types.RelayFormatOpenAIRealtime)
 		})
 	}
+	{
+		// 异步生图任务查询不需要选择渠道，任务从记录中获取
+		relayV1Router.GET("/images/generations/:task_id", controller.RelayImageTaskFetch)
+	}
 	{
 		//http router
 		httpRouter := relayV1Router.Group("")
@@ -111,11 +120,19 @@ func SetRelayRouter(router *gin.Engine) {
 			controller.Relay(c, types.RelayFormatOpenAIImage)
 		})
 		httpRouter.POST("/images/generations", func(c *gin.Context) {
+			// 异步生图任务：async=true 或上游仅提供异步接口时提交任务
+			if controller.ShouldRelayImageTask(c) {
+				controller.RelayImageTask(c)
+				return
+			}
 			controller.Relay(c, types.RelayFormatOpenAIImage)
 		})
 		httpRouter.POST("/images/edits", func(c *gin.Context) {
 			controller.Relay(c, types.RelayFormatOpenAIImage)
 		})
+		httpRouter.POST("/images/variations", func(c *gin.Context) {
+			controller.Relay(c, types.RelayFormatOpenAIImage)
+		})
 
 		// embedding related routes
 		httpRouter.POST("/embeddings", func(c *gin.Context) {
@@ -152,7 +169,6 @@ func SetRelayRouter(router *gin.Engine) {
 		})
 
 		// not implemented
-		httpRouter.POST("/images/variations", controller.RelayNotImplemented)
 		httpRouter.GET("/files", controller.RelayNotImplemented)
 		httpRouter.POST("/files", controller.RelayNotImplemented)
 		httpRouter.DELETE("/files/:id", controller.RelayNotImplemented)
@@ -14,6 +14,11 @@ func SetVideoRouter(router *gin.Engine) {
 	videoProxyRouter.Use(middleware.TokenOrUserAuth())
 	{
 		videoProxyRouter.GET("/videos/:task_id/content", controller.VideoProxy)
+		// 取消任务不需要选择渠道，任务所属渠道从任务记录中获取
+		videoProxyRouter.DELETE("/videos/:task_id", controller.RelayTaskCancel)
+		videoProxyRouter.POST("/video/generations/:task_id/cancel", controller.RelayTaskCancel)
+		// 统一任务查询：按公开任务 ID 返回任意平台（视频、Suno、Midjourney）的任务
+		videoProxyRouter.GET("/tasks/:task_id", controller.RelayTaskQuery)
 	}
 
 	videoV1Router := router.Group("/v1")
@@ -3,6 +3,7 @@ package service
 import (
 	"encoding/base64"
 	"fmt"
+	"math"
 	"strings"
 )
 
@@ -46,3 +47,8 @@ func DecodeBase64AudioData(audioBase64 string) (string, error) {
 
 	return audioBase64, nil
 }
+
+// GetAudioDurationTokens 按音频时长折算 token，一分钟 1000 token，与 $price / minute 对齐
+func GetAudioDurationTokens(duration float64) int {
+	return int(math.Round(math.Ceil(duration) / 60.0 * 1000))
+}
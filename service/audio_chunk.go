@@ -0,0 +1,365 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/tcolgate/mp3"
+)
+
+// ErrAudioChunkUnsupported 该音频格式无法在网关切分
+var ErrAudioChunkUnsupported = errors.New("audio format does not support chunking")
+
+// wavAnalysisWindowSeconds WAV 静音检测的分析窗口长度
+const wavAnalysisWindowSeconds = 0.02
+
+// silenceSmoothUnits 计算静音程度时向两侧各取的相邻单元数，避免切在单个极短的静音帧上
+const silenceSmoothUnits = 2
+
+// AudioChunk 切分后的一段音频，Offset 为该段在原音频中的起始时间（秒）
+type AudioChunk struct {
+	Data     []byte
+	Offset   float64
+	Duration float64
+}
+
+// audioUnit 可独立切分的最小单元：WAV 的分析窗口或 MP3 的一帧，Energy 越小越接近静音
+type audioUnit struct {
+	start    int
+	end      int
+	duration float64
+	energy   float64
+}
+
+// SplitAudioOnSilence 将音频切分为不超过 maxSeconds 秒、不超过 maxBytes 字节的分片，
+// 切分点取每个分片末尾 searchSeconds 秒内最安静的位置，maxSeconds 不大于 0 时只按大小切分。目前支持 WAV（PCM）与 MP3
+func SplitAudioOnSilence(data []byte, ext string, maxSeconds float64, maxBytes int, searchSeconds float64) ([]AudioChunk, error) {
+	if maxSeconds <= 0 {
+		maxSeconds = math.Inf(1)
+	}
+	switch strings.ToLower(ext) {
+	case ".wav":
+		return splitWAV(data, maxSeconds, maxBytes, searchSeconds)
+	case ".mp3", ".mpga", ".mpeg":
+		return splitMP3(data, maxSeconds, maxBytes, searchSeconds)
+	default:
+		return nil, ErrAudioChunkUnsupported
+	}
+}
+
+// planAudioCuts 返回每个分片的单元区间 [from, to)
+func planAudioCuts(units []audioUnit, maxSeconds float64, maxBytes int, searchSeconds float64) [][2]int {
+	var cuts [][2]int
+	from := 0
+	for from < len(units) {
+		// 找出从 from 开始时长与大小都不超限的最远单元
+		limit := from
+		elapsed := 0.0
+		for limit < len(units) {
+			if limit > from && (elapsed+units[limit].duration > maxSeconds ||
+				(maxBytes > 0 && units[limit].end-units[from].start > maxBytes)) {
+				break
+			}
+			elapsed += units[limit].duration
+			limit++
+		}
+		if limit >= len(units) {
+			cuts = append(cuts, [2]int{from, len(units)})
+			break
+		}
+
+		// 在分片末尾 searchSeconds 内选择最安静的单元之后切分
+		to := limit
+		best := math.MaxFloat64
+		window := 0.0
+		for i := limit - 1; i > from && window < searchSeconds; i-- {
+			window += units[i].duration
+			if energy := smoothedEnergy(units, i); energy < best {
+				best = energy
+				to = i + 1
+			}
+		}
+		cuts = append(cuts, [2]int{from, to})
+		from = to
+	}
+	return cuts
+}
+
+func smoothedEnergy(units []audioUnit, i int) float64 {
+	lo := max(0, i-silenceSmoothUnits)
+	hi := min(len(units)-1, i+silenceSmoothUnits)
+	sum := 0.0
+	for j := lo; j <= hi; j++ {
+		sum += units[j].energy
+	}
+	return sum / float64(hi-lo+1)
+}
+
+type wavFormat struct {
+	audioFormat   uint16
+	channels      uint16
+	sampleRate    uint32
+	blockAlign    uint16
+	bitsPerSample uint16
+}
+
+func splitWAV(data []byte, maxSeconds float64, maxBytes int, searchSeconds float64) ([]AudioChunk, error) {
+	format, pcm, err := parseWAV(data)
+	if err != nil {
+		return nil, err
+	}
+	if format.audioFormat != 1 && format.audioFormat != 3 {
+		return nil, ErrAudioChunkUnsupported
+	}
+	if format.sampleRate == 0 || format.blockAlign == 0 || format.channels == 0 {
+		return nil, errors.New("invalid wav header metadata")
+	}
+
+	frameBytes := int(format.blockAlign)
+	windowFrames := max(1, int(float64(format.sampleRate)*wavAnalysisWindowSeconds))
+	windowBytes := windowFrames * frameBytes
+	units := make([]audioUnit, 0, len(pcm)/windowBytes+1)
+	for start := 0; start+frameBytes <= len(pcm); start += windowBytes {
+		end := min(start+windowBytes, len(pcm)-len(pcm)%frameBytes)
+		units = append(units, audioUnit{
+			start:    start,
+			end:      end,
+			duration: float64((end-start)/frameBytes) / float64(format.sampleRate),
+			energy:   wavWindowEnergy(pcm[start:end], format),
+		})
+	}
+	if len(units) == 0 {
+		return nil, errors.New("empty wav data")
+	}
+
+	// 每个分片需要额外写入 44 字节文件头
+	chunkMaxBytes := maxBytes
+	if chunkMaxBytes > 0 {
+		chunkMaxBytes -= 44
+	}
+	var chunks []AudioChunk
+	offset := 0.0
+	for _, cut := range planAudioCuts(units, maxSeconds, chunkMaxBytes, searchSeconds) {
+		segment := pcm[units[cut[0]].start:units[cut[1]-1].end]
+		duration := float64(len(segment)/frameBytes) / float64(format.sampleRate)
+		chunks = append(chunks, AudioChunk{
+			Data:     buildWAV(format, segment),
+			Offset:   offset,
+			Duration: duration,
+		})
+		offset += duration
+	}
+	return chunks, nil
+}
+
+// wavWindowEnergy 计算窗口内样本的平均绝对幅度（归一化到 0~1）
+func wavWindowEnergy(window []byte, format *wavFormat) float64 {
+	bytesPerSample := int(format.bitsPerSample+7) / 8
+	if bytesPerSample == 0 {
+		return 0
+	}
+	sum := 0.0
+	count := 0
+	for i := 0; i+bytesPerSample <= len(window); i += bytesPerSample {
+		sample := window[i : i+bytesPerSample]
+		var v float64
+		switch {
+		case format.audioFormat == 3 && bytesPerSample == 4:
+			v = float64(math.Float32frombits(binary.LittleEndian.Uint32(sample)))
+		case format.audioFormat == 3 && bytesPerSample == 8:
+			v = math.Float64frombits(binary.LittleEndian.Uint64(sample))
+		case bytesPerSample == 1:
+			// 8 位 PCM 为无符号数
+			v = (float64(sample[0]) - 128) / 128
+		case bytesPerSample == 2:
+			v = float64(int16(binary.LittleEndian.Uint16(sample))) / math.MaxInt16
+		case bytesPerSample == 3:
+			raw := int32(uint32(sample[0]) | uint32(sample[1])<<8 | uint32(sample[2])<<16)
+			v = float64(raw<<8>>8) / (1 << 23)
+		case bytesPerSample == 4:
+			v = float64(int32(binary.LittleEndian.Uint32(sample))) / math.MaxInt32
+		}
+		sum += math.Abs(v)
+		count++
+	}
+	if count == 0 {
+		return 0
+	}
+	return sum / float64(count)
+}
+
+// parseWAV 解析 fmt 与 data 块；流式生成的 WAV 文件头中长度可能为 0 或占位值，按实际数据长度截取
+func parseWAV(data []byte) (*wavFormat, []byte, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, nil, errors.New("invalid wav file")
+	}
+	var format *wavFormat
+	var pcm []byte
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		body := data[pos+8:]
+		if size > len(body) || id == "data" && size == 0 {
+			size = len(body)
+		}
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, nil, errors.New("invalid wav fmt chunk")
+			}
+			format = &wavFormat{
+				audioFormat:   binary.LittleEndian.Uint16(body[0:2]),
+				channels:      binary.LittleEndian.Uint16(body[2:4]),
+				sampleRate:    binary.LittleEndian.Uint32(body[4:8]),
+				blockAlign:    binary.LittleEndian.Uint16(body[12:14]),
+				bitsPerSample: binary.LittleEndian.Uint16(body[14:16]),
+			}
+			// WAVE_FORMAT_EXTENSIBLE 的实际格式在 SubFormat GUID 的前两个字节
+			if format.audioFormat == 0xFFFE && size >= 26 {
+				format.audioFormat = binary.LittleEndian.Uint16(body[24:26])
+			}
+		case "data":
+			pcm = body[:size]
+		}
+		pos += 8 + size + size%2
+	}
+	if format == nil || pcm == nil {
+		return nil, nil, errors.New("wav fmt or data chunk not found")
+	}
+	return format, pcm, nil
+}
+
+func buildWAV(format *wavFormat, pcm []byte) []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, 44+len(pcm)))
+	byteRate := format.sampleRate * uint32(format.blockAlign)
+	buf.WriteString("RIFF")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(36+len(pcm)))
+	buf.WriteString("WAVEfmt ")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(16))
+	_ = binary.Write(buf, binary.LittleEndian, format.audioFormat)
+	_ = binary.Write(buf, binary.LittleEndian, format.channels)
+	_ = binary.Write(buf, binary.LittleEndian, format.sampleRate)
+	_ = binary.Write(buf, binary.LittleEndian, byteRate)
+	_ = binary.Write(buf, binary.LittleEndian, format.blockAlign)
+	_ = binary.Write(buf, binary.LittleEndian, format.bitsPerSample)
+	buf.WriteString("data")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+	return buf.Bytes()
+}
+
+func splitMP3(data []byte, maxSeconds float64, maxBytes int, searchSeconds float64) ([]AudioChunk, error) {
+	pos := skipID3v2(data)
+	decoder := mp3.NewDecoder(bytes.NewReader(data[pos:]))
+	var frame mp3.Frame
+	skipped := 0
+	var units []audioUnit
+	for {
+		if err := decoder.Decode(&frame, &skipped); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return nil, fmt.Errorf("failed to decode mp3 frame: %w", err)
+		}
+		pos += skipped
+		raw, _ := io.ReadAll(frame.Reader())
+		units = append(units, audioUnit{
+			start:    pos,
+			end:      pos + len(raw),
+			duration: frame.Duration().Seconds(),
+			energy:   mp3FrameEnergy(raw, &frame),
+		})
+		pos += len(raw)
+	}
+	if len(units) == 0 {
+		return nil, errors.New("no mp3 frames found")
+	}
+
+	var chunks []AudioChunk
+	offset := 0.0
+	for _, cut := range planAudioCuts(units, maxSeconds, maxBytes, searchSeconds) {
+		duration := 0.0
+		for _, unit := range units[cut[0]:cut[1]] {
+			duration += unit.duration
+		}
+		chunks = append(chunks, AudioChunk{
+			Data:     data[units[cut[0]].start:units[cut[1]-1].end],
+			Offset:   offset,
+			Duration: duration,
+		})
+		offset += duration
+	}
+	return chunks, nil
+}
+
+// skipID3v2 返回 ID3v2 标签之后的偏移，避免在标签数据中误识别帧同步字
+func skipID3v2(data []byte) int {
+	if len(data) < 10 || string(data[0:3]) != "ID3" {
+		return 0
+	}
+	size := int(data[6]&0x7F)<<21 | int(data[7]&0x7F)<<14 | int(data[8]&0x7F)<<7 | int(data[9]&0x7F)
+	end := 10 + size
+	if data[5]&0x10 != 0 {
+		end += 10
+	}
+	return min(end, len(data))
+}
+
+// mp3FrameEnergy 以 Layer III 各声道各颗粒的 part2_3_length（主数据比特数）之和衡量一帧的响度：
+// 编码器借助比特池为静音帧分配的比特远少于有声帧，CBR 文件同样适用。非 Layer III 时退化为帧大小
+func mp3FrameEnergy(raw []byte, frame *mp3.Frame) float64 {
+	header := frame.Header()
+	if header.Layer() != mp3.Layer3 {
+		return float64(len(raw))
+	}
+	channels := 2
+	if header.ChannelMode() == mp3.SingleChannel {
+		channels = 1
+	}
+	pos := 32
+	if header.Protection() {
+		pos += 16
+	}
+	granules := 1
+	granuleBits := 63
+	if header.Version() == mp3.MPEG1 {
+		granules = 2
+		granuleBits = 59
+		// main_data_begin(9) + private_bits(5/3) + scfsi(4 每声道)
+		pos += 9 + 4*channels
+		if channels == 1 {
+			pos += 5
+		} else {
+			pos += 3
+		}
+	} else {
+		// main_data_begin(8) + private_bits(1/2)
+		pos += 8 + channels
+	}
+	total := 0
+	for g := 0; g < granules; g++ {
+		for ch := 0; ch < channels; ch++ {
+			total += readBits(raw, pos, 12)
+			pos += granuleBits
+		}
+	}
+	return float64(total)
+}
+
+func readBits(data []byte, pos int, n int) int {
+	v := 0
+	for i := 0; i < n; i++ {
+		idx := (pos + i) / 8
+		if idx >= len(data) {
+			return v
+		}
+		bit := (data[idx] >> (7 - uint((pos+i)%8))) & 1
+		v = v<<1 | int(bit)
+	}
+	return v
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/require"
+)
+
+// buildTestWAV 生成 16 位单声道 WAV，segments 为交替的 有声/静音 时长（秒）
+func buildTestWAV(sampleRate int, segments ...float64) []byte {
+	var pcm bytes.Buffer
+	for i, seconds := range segments {
+		for n := 0; n < int(seconds*float64(sampleRate)); n++ {
+			var v int16
+			if i%2 == 0 {
+				v = int16(8000 * math.Sin(2*math.Pi*440*float64(n)/float64(sampleRate)))
+			}
+			_ = binary.Write(&pcm, binary.LittleEndian, v)
+		}
+	}
+	format := &wavFormat{audioFormat: 1, channels: 1, sampleRate: uint32(sampleRate), blockAlign: 2, bitsPerSample: 16}
+	return buildWAV(format, pcm.Bytes())
+}
+
+func TestSplitAudioOnSilence_WAVCutsInSilence(t *testing.T) {
+	// 3s 有声 + 0.5s 静音 + 3s 有声 + 0.5s 静音 + 3s 有声
+	data := buildTestWAV(8000, 3, 0.5, 3, 0.5, 3)
+
+	chunks, err := SplitAudioOnSilence(data, ".wav", 5, 0, 3)
+	require.NoError(t, err)
+	require.Len(t, chunks, 3)
+
+	total := 0.0
+	for i, chunk := range chunks {
+		require.InDelta(t, total, chunk.Offset, 1e-9)
+		duration, err := common.GetAudioDuration(context.Background(), bytes.NewReader(chunk.Data), ".wav")
+		require.NoError(t, err)
+		require.InDelta(t, chunk.Duration, duration, 1e-6)
+		if i < len(chunks)-1 {
+			end := chunk.Offset + chunk.Duration
+			require.True(t, (end >= 3 && end <= 3.5) || (end >= 6.5 && end <= 7), "cut at %.2fs is not in silence", end)
+		}
+		total += chunk.Duration
+	}
+	require.InDelta(t, 10.0, total, 1e-6)
+}
+
+func TestSplitAudioOnSilence_Unsupported(t *testing.T) {
+	_, err := SplitAudioOnSilence([]byte("data"), ".m4a", 600, 0, 30)
+	require.ErrorIs(t, err, ErrAudioChunkUnsupported)
+}
+
+func TestMergeTranscriptions_ShiftsTimestamps(t *testing.T) {
+	results := []TranscriptionChunkResult{
+		{Offset: 0, Duration: 10, Body: []byte(`{"text":"Hello there.","language":"english","segments":[{"id":0,"start":0.5,"end":2,"text":" Hello there."}],"words":[{"word":"Hello","start":0.5,"end":1}]}`)},
+		{Offset: 10, Duration: 5, Body: []byte(`{"text":"Bye.","language":"english","segments":[{"id":0,"start":1,"end":2.25,"text":" Bye."}],"words":[{"word":"Bye","start":1,"end":2}]}`)},
+	}
+
+	body, contentType, err := MergeTranscriptions(results, "verbose_json", "transcribe")
+	require.NoError(t, err)
+	require.Equal(t, "application/json", contentType)
+	var verbose struct {
+		Text     string  `json:"text"`
+		Language string  `json:"language"`
+		Duration float64 `json:"duration"`
+		Segments []struct {
+			Id    int     `json:"id"`
+			Start float64 `json:"start"`
+			End   float64 `json:"end"`
+		} `json:"segments"`
+		Words []struct {
+			Start float64 `json:"start"`
+		} `json:"words"`
+	}
+	require.NoError(t, common.Unmarshal(body, &verbose))
+	require.Equal(t, "Hello there. Bye.", verbose.Text)
+	require.Equal(t, "english", verbose.Language)
+	require.Equal(t, 15.0, verbose.Duration)
+	require.Len(t, verbose.Segments, 2)
+	require.Equal(t, 1, verbose.Segments[1].Id)
+	require.Equal(t, 11.0, verbose.Segments[1].Start)
+	require.Equal(t, 12.25, verbose.Segments[1].End)
+	require.Equal(t, 11.0, verbose.Words[1].Start)
+
+	srt, _, err := MergeTranscriptions(results, "srt", "transcribe")
+	require.NoError(t, err)
+	require.True(t, strings.Contains(string(srt), "2\n00:00:11,000 --> 00:00:12,250\nBye."))
+}
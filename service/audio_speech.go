@@ -0,0 +1,102 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/types"
+)
+
+// SpeechPCMSampleRate 与 OpenAI TTS 一致，原始 PCM 输出为 24kHz 16bit 单声道
+const SpeechPCMSampleRate = 24000
+
+// SpeechContentType 返回语音合成输出格式对应的 Content-Type
+func SpeechContentType(format string) string {
+	switch format {
+	case "opus":
+		return "audio/ogg"
+	case "aac":
+		return "audio/aac"
+	case "flac":
+		return "audio/flac"
+	case "wav":
+		return "audio/wav"
+	case "pcm":
+		return "audio/pcm"
+	default:
+		return "audio/mpeg"
+	}
+}
+
+// NegotiateSpeechFormat 选择向上游请求的音频格式：上游支持请求格式时直接使用；
+// 请求 wav 而上游只能输出 pcm 时由网关补文件头；未指定格式时使用上游支持的第一个格式。
+// 其余格式网关无法转码，返回 400 并列出该渠道支持的格式，不静默改用其他格式
+func NegotiateSpeechFormat(requested string, supported ...string) (string, error) {
+	if requested == "" {
+		return supported[0], nil
+	}
+	if slices.Contains(supported, requested) {
+		return requested, nil
+	}
+	if requested == "wav" && slices.Contains(supported, "pcm") {
+		return "pcm", nil
+	}
+	available := slices.Clone(supported)
+	if slices.Contains(available, "pcm") && !slices.Contains(available, "wav") {
+		available = append(available, "wav")
+	}
+	return "", types.NewErrorWithStatusCode(
+		fmt.Errorf("response_format %s is not supported by this channel, supported formats: %s", requested, strings.Join(available, ", ")),
+		types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+}
+
+// PCMToWAV 为 16 位单声道 PCM 数据加上 WAV 文件头
+func PCMToWAV(pcm []byte, sampleRate int) []byte {
+	return buildWAV(pcmWAVFormat(sampleRate), pcm)
+}
+
+// StreamingWAVHeader 流式输出时总长度未知，RIFF 与 data 块长度写为 0xFFFFFFFF
+func StreamingWAVHeader(sampleRate int) []byte {
+	header := buildWAV(pcmWAVFormat(sampleRate), nil)
+	binary.LittleEndian.PutUint32(header[4:8], 0xFFFFFFFF)
+	binary.LittleEndian.PutUint32(header[40:44], 0xFFFFFFFF)
+	return header
+}
+
+func pcmWAVFormat(sampleRate int) *wavFormat {
+	return &wavFormat{audioFormat: 1, channels: 1, sampleRate: uint32(sampleRate), blockAlign: 2, bitsPerSample: 16}
+}
+
+// GetSpeechDuration 计算合成音频的时长（秒），pcm 按 16 位单声道 sampleRate 计算，
+// wav 优先按文件头计算以兼容流式生成的文件头
+func GetSpeechDuration(ctx context.Context, data []byte, format string, sampleRate int) (float64, error) {
+	switch format {
+	case "pcm":
+		return float64(len(data)) / float64(sampleRate*2), nil
+	case "wav":
+		if wav, pcm, err := parseWAV(data); err == nil && wav.sampleRate > 0 && wav.blockAlign > 0 {
+			return float64(len(pcm)/int(wav.blockAlign)) / float64(wav.sampleRate), nil
+		}
+	}
+	return common.GetAudioDuration(ctx, bytes.NewReader(data), "."+format)
+}
+
+// BuildSpeechUsage 语音合成统一计费：输入文本按字符（gpt 系列按 tokenizer）计入 prompt，
+// 输出音频按时长计入 audio tokens（每分钟 1000）
+func BuildSpeechUsage(promptTokens int, duration float64) *dto.Usage {
+	usage := &dto.Usage{PromptTokens: promptTokens}
+	usage.PromptTokensDetails.TextTokens = promptTokens
+	if duration > 0 {
+		usage.CompletionTokens = GetAudioDurationTokens(duration)
+		usage.CompletionTokenDetails.AudioTokens = usage.CompletionTokens
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateSpeechFormat(t *testing.T) {
+	format, err := NegotiateSpeechFormat("", "mp3", "pcm")
+	require.NoError(t, err)
+	require.Equal(t, "mp3", format)
+
+	format, err = NegotiateSpeechFormat("wav", "mp3", "pcm")
+	require.NoError(t, err)
+	require.Equal(t, "pcm", format)
+
+	format, err = NegotiateSpeechFormat("opus", "mp3", "opus")
+	require.NoError(t, err)
+	require.Equal(t, "opus", format)
+
+	// 无法转码的格式直接拒绝，不静默改用其他格式
+	_, err = NegotiateSpeechFormat("aac", "mp3", "pcm")
+	var apiErr *types.NewAPIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	require.Contains(t, err.Error(), "mp3, pcm, wav")
+}
+
+func TestGetSpeechDuration_PCMAndWAV(t *testing.T) {
+	// 1.5 秒 24kHz 16bit 单声道
+	pcm := make([]byte, SpeechPCMSampleRate*2*3/2)
+
+	duration, err := GetSpeechDuration(context.Background(), pcm, "pcm", SpeechPCMSampleRate)
+	require.NoError(t, err)
+	require.InDelta(t, 1.5, duration, 1e-9)
+
+	duration, err = GetSpeechDuration(context.Background(), PCMToWAV(pcm, SpeechPCMSampleRate), "wav", SpeechPCMSampleRate)
+	require.NoError(t, err)
+	require.InDelta(t, 1.5, duration, 1e-9)
+
+	// 流式文件头的长度为占位值，按实际数据计算
+	streamed := append(StreamingWAVHeader(SpeechPCMSampleRate), pcm...)
+	duration, err = GetSpeechDuration(context.Background(), streamed, "wav", SpeechPCMSampleRate)
+	require.NoError(t, err)
+	require.InDelta(t, 1.5, duration, 1e-9)
+}
+
+func TestBuildSpeechUsage(t *testing.T) {
+	usage := BuildSpeechUsage(120, 90)
+	require.Equal(t, 120, usage.PromptTokens)
+	require.Equal(t, 120, usage.PromptTokensDetails.TextTokens)
+	require.Equal(t, 1500, usage.CompletionTokens)
+	require.Equal(t, 1500, usage.CompletionTokenDetails.AudioTokens)
+	require.Equal(t, 1620, usage.TotalTokens)
+
+	usage = BuildSpeechUsage(10, 0)
+	require.Equal(t, 0, usage.CompletionTokenDetails.AudioTokens)
+	require.Equal(t, 10, usage.TotalTokens)
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// TranscriptionChunkResult 单个音频分片的上游转写结果，Offset/Duration 为分片在原音频中的时间（秒）
+type TranscriptionChunkResult struct {
+	Offset   float64
+	Duration float64
+	Body     []byte
+}
+
+type mergedTranscription struct {
+	text     string
+	language string
+	duration float64
+	segments []map[string]any
+	words    []map[string]any
+}
+
+// TranscriptionUpstreamFormat 分片转写时向上游请求的格式：需要时间戳的格式统一请求 verbose_json，由网关合并后再转换
+func TranscriptionUpstreamFormat(responseFormat string) string {
+	switch responseFormat {
+	case "verbose_json", "srt", "vtt":
+		return "verbose_json"
+	default:
+		return "json"
+	}
+}
+
+// MergeTranscriptions 按时间顺序合并各分片的转写文本、分段与词级时间戳，并以 responseFormat 输出，返回响应体与 Content-Type
+func MergeTranscriptions(results []TranscriptionChunkResult, responseFormat string, task string) ([]byte, string, error) {
+	merged := &mergedTranscription{}
+	var texts []string
+	for _, result := range results {
+		merged.duration = math.Max(merged.duration, result.Offset+result.Duration)
+
+		var body map[string]any
+		if err := common.Unmarshal(result.Body, &body); err != nil {
+			// text 等非 JSON 格式直接视为纯文本
+			text := strings.TrimSpace(string(result.Body))
+			texts = append(texts, text)
+			merged.segments = append(merged.segments, map[string]any{
+				"start": result.Offset,
+				"end":   result.Offset + result.Duration,
+				"text":  text,
+			})
+			continue
+		}
+		text, _ := body["text"].(string)
+		texts = append(texts, strings.TrimSpace(text))
+		if language, ok := body["language"].(string); ok && merged.language == "" {
+			merged.language = language
+		}
+
+		segments := shiftTimestamps(body["segments"], result.Offset)
+		if len(segments) == 0 && text != "" {
+			segments = []map[string]any{{
+				"start": result.Offset,
+				"end":   result.Offset + result.Duration,
+				"text":  text,
+			}}
+		}
+		for _, segment := range segments {
+			// whisper 的 seek 以 10ms 为单位
+			if seek, ok := segment["seek"].(float64); ok {
+				segment["seek"] = seek + math.Round(result.Offset*100)
+			}
+		}
+		merged.segments = append(merged.segments, segments...)
+		merged.words = append(merged.words, shiftTimestamps(body["words"], result.Offset)...)
+	}
+	for i, segment := range merged.segments {
+		segment["id"] = i
+	}
+	merged.text = joinTranscriptionTexts(texts)
+
+	switch responseFormat {
+	case "text":
+		return []byte(merged.text), "text/plain; charset=utf-8", nil
+	case "srt":
+		return []byte(merged.subtitles(false)), "text/plain; charset=utf-8", nil
+	case "vtt":
+		return []byte(merged.subtitles(true)), "text/vtt; charset=utf-8", nil
+	case "verbose_json":
+		response := map[string]any{
+			"task":     task,
+			"language": merged.language,
+			"duration": merged.duration,
+			"text":     merged.text,
+			"segments": merged.segments,
+		}
+		if len(merged.words) > 0 {
+			response["words"] = merged.words
+		}
+		body, err := common.Marshal(response)
+		return body, "application/json", err
+	default:
+		body, err := common.Marshal(map[string]any{"text": merged.text})
+		return body, "application/json", err
+	}
+}
+
+// shiftTimestamps 将分片内的 start/end 平移到原音频的时间轴
+func shiftTimestamps(raw any, offset float64) []map[string]any {
+	items, ok := raw.([]any)
+	if !ok {
+		return nil
+	}
+	shifted := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		for _, key := range []string{"start", "end"} {
+			if v, ok := m[key].(float64); ok {
+				m[key] = v + offset
+			}
+		}
+		shifted = append(shifted, m)
+	}
+	return shifted
+}
+
+// joinTranscriptionTexts 拼接各分片文本，中日文等不以空格分词的文字之间不插入空格
+func joinTranscriptionTexts(texts []string) string {
+	var b strings.Builder
+	for _, text := range texts {
+		if text == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			last, _ := utf8.DecodeLastRuneInString(b.String())
+			first, _ := utf8.DecodeRuneInString(text)
+			if !isUnspacedScript(last) && !isUnspacedScript(first) {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+func isUnspacedScript(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) || unicode.Is(unicode.P, r) && r > unicode.MaxLatin1
+}
+
+func (m *mergedTranscription) subtitles(vtt bool) string {
+	var b strings.Builder
+	if vtt {
+		b.WriteString("WEBVTT\n\n")
+	}
+	index := 0
+	for _, segment := range m.segments {
+		text, _ := segment["text"].(string)
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		start, _ := segment["start"].(float64)
+		end, _ := segment["end"].(float64)
+		index++
+		if !vtt {
+			fmt.Fprintf(&b, "%d\n", index)
+		}
+		fmt.Fprintf(&b, "%s --> %s\n%s\n\n", formatSubtitleTime(start, vtt), formatSubtitleTime(end, vtt), text)
+	}
+	return b.String()
+}
+
+func formatSubtitleTime(seconds float64, vtt bool) string {
+	ms := int64(math.Round(seconds * 1000))
+	separator := ","
+	if vtt {
+		separator = "."
+	}
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, separator, ms%1000)
+}
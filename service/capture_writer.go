@@ -0,0 +1,112 @@
+package service
+
+import (
+	"bytes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CaptureWriter 捕获 gin 的响应输出。
+// passthrough 为 false 时缓存全部输出与状态码，不发送给客户端，由调用方处理后自行写回；
+// 为 true 时照常写出并旁路复制一份，复制超过 limit 字节（limit>0）时放弃复制并标记 Overflow。
+type CaptureWriter struct {
+	gin.ResponseWriter
+	passthrough bool
+	limit       int
+	buf         bytes.Buffer
+	status      int
+	written     bool
+	overflow    bool
+}
+
+func NewCaptureWriter(w gin.ResponseWriter, passthrough bool, limit int) *CaptureWriter {
+	return &CaptureWriter{ResponseWriter: w, passthrough: passthrough, limit: limit, status: 200}
+}
+
+// Bytes 已捕获的输出
+func (w *CaptureWriter) Bytes() []byte {
+	return w.buf.Bytes()
+}
+
+// CapturedStatus 缓存模式下上游处理器写入的状态码
+func (w *CaptureWriter) CapturedStatus() int {
+	return w.status
+}
+
+// Overflow 透传模式下输出超过上限，捕获内容已丢弃
+func (w *CaptureWriter) Overflow() bool {
+	return w.overflow
+}
+
+func (w *CaptureWriter) capture(data []byte) {
+	if w.passthrough && w.limit > 0 {
+		if w.overflow {
+			return
+		}
+		if w.buf.Len()+len(data) > w.limit {
+			w.overflow = true
+			w.buf.Reset()
+			return
+		}
+	}
+	w.buf.Write(data)
+}
+
+func (w *CaptureWriter) Write(data []byte) (int, error) {
+	w.written = true
+	w.capture(data)
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+	return len(data), nil
+}
+
+func (w *CaptureWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *CaptureWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *CaptureWriter) WriteHeaderNow() {
+	w.written = true
+	if w.passthrough {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *CaptureWriter) Status() int {
+	if w.passthrough {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *CaptureWriter) Size() int {
+	if w.passthrough {
+		return w.ResponseWriter.Size()
+	}
+	if !w.written {
+		return -1
+	}
+	return w.buf.Len()
+}
+
+func (w *CaptureWriter) Written() bool {
+	if w.passthrough {
+		return w.ResponseWriter.Written()
+	}
+	return w.written
+}
+
+func (w *CaptureWriter) Flush() {
+	if w.passthrough {
+		w.ResponseWriter.Flush()
+	}
+}
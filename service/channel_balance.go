@@ -0,0 +1,76 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+)
+
+func formatLowBalanceNotifyType(channelId int) string {
+	return fmt.Sprintf("%s_%d_low_balance", dto.NotifyTypeChannelUpdate, channelId)
+}
+
+// CheckChannelLowBalance 根据查询到的余额处理渠道：余额耗尽时禁用；低于阈值时降低优先级或禁用，
+// 并通知超级管理员；余额恢复后还原优先级。返回渠道优先级或状态是否发生变化
+func CheckChannelLowBalance(channel *model.Channel, balance float64) bool {
+	monitorSetting := operation_setting.GetMonitorSetting()
+	otherSettings := channel.GetOtherSettings()
+	threshold := monitorSetting.LowBalanceThreshold
+	if otherSettings.LowBalanceThreshold > 0 {
+		threshold = otherSettings.LowBalanceThreshold
+	}
+	channelError := *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, "", channel.GetAutoBan())
+
+	// err is nil & balance <= 0 means quota is used up
+	if balance <= 0 {
+		DisableChannel(channelError, "余额不足")
+		return true
+	}
+	if balance >= threshold {
+		if otherSettings.LowBalanceOriginalPriority == nil {
+			return false
+		}
+		priority := *otherSettings.LowBalanceOriginalPriority
+		otherSettings.LowBalanceOriginalPriority = nil
+		if !updateChannelLowBalancePriority(channel, priority, otherSettings) {
+			return false
+		}
+		subject := fmt.Sprintf("通道「%s」（#%d）余额已恢复", channel.Name, channel.Id)
+		content := fmt.Sprintf("通道「%s」（#%d）余额已恢复至 $%.2f，优先级已还原为 %d", channel.Name, channel.Id, balance, priority)
+		NotifyRootUser(formatLowBalanceNotifyType(channel.Id), subject, content)
+		return true
+	}
+
+	if monitorSetting.LowBalanceAction == operation_setting.LowBalanceActionDisable {
+		DisableChannel(channelError, fmt.Sprintf("余额 $%.2f 低于阈值 $%.2f", balance, threshold))
+		return true
+	}
+	if otherSettings.LowBalanceOriginalPriority != nil || channel.GetPriority() <= monitorSetting.LowBalancePriority {
+		return false
+	}
+	originalPriority := channel.GetPriority()
+	otherSettings.LowBalanceOriginalPriority = &originalPriority
+	if !updateChannelLowBalancePriority(channel, monitorSetting.LowBalancePriority, otherSettings) {
+		return false
+	}
+	subject := fmt.Sprintf("通道「%s」（#%d）余额不足", channel.Name, channel.Id)
+	content := fmt.Sprintf("通道「%s」（#%d）余额 $%.2f 低于阈值 $%.2f，优先级已由 %d 降低为 %d，请及时充值",
+		channel.Name, channel.Id, balance, threshold, originalPriority, monitorSetting.LowBalancePriority)
+	NotifyRootUser(formatLowBalanceNotifyType(channel.Id), subject, content)
+	return true
+}
+
+func updateChannelLowBalancePriority(channel *model.Channel, priority int64, otherSettings dto.ChannelOtherSettings) bool {
+	channel.SetOtherSettings(otherSettings)
+	if err := model.UpdateChannelPriorityAndOtherSettings(channel.Id, priority, channel.OtherSettings); err != nil {
+		common.SysError(fmt.Sprintf("failed to update priority of channel #%d: %s", channel.Id, err.Error()))
+		return false
+	}
+	channel.Priority = &priority
+	common.SysLog(fmt.Sprintf("通道「%s」（#%d）优先级已更新为 %d", channel.Name, channel.Id, priority))
+	return true
+}
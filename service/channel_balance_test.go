@@ -0,0 +1,48 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckChannelLowBalance(t *testing.T) {
+	truncate(t)
+	require.NoError(t, model.DB.Create(&model.User{Id: 1, Username: "root", Role: common.RoleRootUser, Status: common.UserStatusEnabled}).Error)
+
+	monitorSetting := operation_setting.GetMonitorSetting()
+	original := *monitorSetting
+	t.Cleanup(func() { *monitorSetting = original })
+	monitorSetting.LowBalanceThreshold = 10
+	monitorSetting.LowBalanceAction = operation_setting.LowBalanceActionPriority
+	monitorSetting.LowBalancePriority = -100
+
+	priority := int64(5)
+	channel := &model.Channel{Id: 7, Name: "low", Key: "sk-test", Status: common.ChannelStatusEnabled, Priority: &priority, Group: "default", Models: "gpt-4o"}
+	require.NoError(t, model.DB.Create(channel).Error)
+	require.NoError(t, channel.UpdateAbilities(nil))
+
+	// 高于阈值且未被降级时不做处理
+	require.False(t, CheckChannelLowBalance(channel, 20))
+
+	require.True(t, CheckChannelLowBalance(channel, 3))
+	stored, err := model.GetChannelById(7, true)
+	require.NoError(t, err)
+	require.Equal(t, int64(-100), stored.GetPriority())
+	require.NotNil(t, stored.GetOtherSettings().LowBalanceOriginalPriority)
+	var ability model.Ability
+	require.NoError(t, model.DB.Where("channel_id = ?", 7).First(&ability).Error)
+	require.Equal(t, int64(-100), *ability.Priority)
+
+	// 已降级时不重复处理
+	require.False(t, CheckChannelLowBalance(stored, 2))
+
+	require.True(t, CheckChannelLowBalance(stored, 50))
+	stored, err = model.GetChannelById(7, true)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), stored.GetPriority())
+	require.Nil(t, stored.GetOtherSettings().LowBalanceOriginalPriority)
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/samber/lo"
+	"github.com/tidwall/gjson"
+)
+
+const canaryDefaultMaxTokens = 256
+
+// BuildCanaryRequest 按用例构造 Chat Completions 请求
+func BuildCanaryRequest(model string, canary operation_setting.CanaryCase) (*dto.GeneralOpenAIRequest, error) {
+	maxTokens := canary.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = canaryDefaultMaxTokens
+	}
+	request := &dto.GeneralOpenAIRequest{
+		Model:  model,
+		Stream: lo.ToPtr(canary.Stream),
+		Messages: []dto.Message{
+			{
+				Role:    "user",
+				Content: canary.Prompt,
+			},
+		},
+		MaxTokens: lo.ToPtr(maxTokens),
+	}
+	if canary.Stream {
+		request.StreamOptions = &dto.StreamOptions{IncludeUsage: true}
+	}
+	if canary.ExpectJSONSchema != "" {
+		var schema any
+		if err := common.UnmarshalJsonStr(canary.ExpectJSONSchema, &schema); err != nil {
+			return nil, fmt.Errorf("invalid expect_json_schema: %w", err)
+		}
+		if err := common.CheckJSONSchemaSupported(schema); err != nil {
+			return nil, fmt.Errorf("invalid expect_json_schema: %w", err)
+		}
+		jsonSchema, err := common.Marshal(dto.FormatJsonSchema{Name: "canary", Schema: schema})
+		if err != nil {
+			return nil, err
+		}
+		request.ResponseFormat = &dto.ResponseFormat{Type: "json_schema", JsonSchema: jsonSchema}
+	}
+	if canary.ExpectToolCall != "" {
+		parameters := any(map[string]any{"type": "object", "properties": map[string]any{}})
+		if canary.ToolParameters != "" {
+			if err := common.UnmarshalJsonStr(canary.ToolParameters, &parameters); err != nil {
+				return nil, fmt.Errorf("invalid tool_parameters: %w", err)
+			}
+			if err := common.CheckJSONSchemaSupported(parameters); err != nil {
+				return nil, fmt.Errorf("invalid tool_parameters: %w", err)
+			}
+		}
+		request.Tools = []dto.ToolCallRequest{{
+			Type:     "function",
+			Function: dto.FunctionRequest{Name: canary.ExpectToolCall, Parameters: parameters},
+		}}
+		request.ToolChoice = map[string]any{"type": "function", "function": map[string]any{"name": canary.ExpectToolCall}}
+	}
+	return request, nil
+}
+
+// EvaluateCanaryResponse 校验 OpenAI 格式响应（流式为 SSE）是否完整、是否来自预期模型以及输出是否符合用例要求，
+// expectedModels 为空时不校验模型身份，否则响应模型需与其中任意一个一致（上游模型名或映射前的模型名）
+func EvaluateCanaryResponse(canary operation_setting.CanaryCase, body []byte, expectedModels []string) error {
+	var choices []validatedChoice
+	var responseModel string
+	if canary.Stream {
+		if !bytes.Contains(body, []byte("[DONE]")) {
+			return errors.New("stream ended without [DONE]")
+		}
+		choices = parseStreamChoices(body)
+		for _, line := range bytes.Split(body, []byte{'\n'}) {
+			payload := bytes.TrimSpace(bytes.TrimPrefix(bytes.TrimSpace(line), []byte("data:")))
+			if model := gjson.GetBytes(payload, "model").String(); model != "" {
+				responseModel = model
+				break
+			}
+		}
+	} else {
+		var err error
+		if choices, err = parseResponseChoices(body); err != nil {
+			return err
+		}
+		responseModel = gjson.GetBytes(body, "model").String()
+	}
+	if len(choices) == 0 {
+		return errors.New("response has no choices")
+	}
+	choice := choices[0]
+	switch choice.finishReason {
+	case "":
+		return errors.New("response has no finish_reason, output may be truncated")
+	case "length":
+		return errors.New("output truncated by max_tokens")
+	}
+
+	if len(expectedModels) > 0 && !lo.ContainsBy(expectedModels, func(expected string) bool {
+		return IsSameModelIdentity(expected, responseModel)
+	}) {
+		return fmt.Errorf("model identity mismatch: expected %s, got %s", expectedModels[0], responseModel)
+	}
+
+	if canary.ExpectToolCall != "" {
+		var call *validatedToolCall
+		for i := range choice.toolCalls {
+			if choice.toolCalls[i].name == canary.ExpectToolCall {
+				call = &choice.toolCalls[i]
+				break
+			}
+		}
+		if call == nil {
+			return fmt.Errorf("expected tool call %s not found", canary.ExpectToolCall)
+		}
+		rawArguments := strings.TrimSpace(call.arguments)
+		if rawArguments == "" {
+			rawArguments = "{}"
+		}
+		var arguments any
+		if err := common.UnmarshalJsonStr(rawArguments, &arguments); err != nil {
+			return fmt.Errorf("tool %s arguments are not valid JSON: %w", call.name, err)
+		}
+		if canary.ToolParameters != "" {
+			var schema any
+			if err := common.UnmarshalJsonStr(canary.ToolParameters, &schema); err == nil {
+				if err := common.ValidateJSONSchema(schema, arguments); err != nil {
+					return fmt.Errorf("tool %s arguments: %w", call.name, err)
+				}
+			}
+		}
+	}
+
+	content := strings.TrimSpace(choice.content)
+	if canary.ExpectJSONSchema != "" {
+		var schema, parsed any
+		if err := common.UnmarshalJsonStr(canary.ExpectJSONSchema, &schema); err != nil {
+			return fmt.Errorf("invalid expect_json_schema: %w", err)
+		}
+		if err := common.UnmarshalJsonStr(content, &parsed); err != nil {
+			return fmt.Errorf("content is not valid JSON: %w", err)
+		}
+		if err := common.ValidateJSONSchema(schema, parsed); err != nil {
+			return fmt.Errorf("content: %w", err)
+		}
+	}
+	if canary.ExpectRegex != "" {
+		pattern, err := regexp.Compile(canary.ExpectRegex)
+		if err != nil {
+			return fmt.Errorf("invalid expect_regex: %w", err)
+		}
+		if !pattern.MatchString(content) {
+			if runes := []rune(content); len(runes) > 200 {
+				content = string(runes[:200]) + "..."
+			}
+			return fmt.Errorf("content %q does not match %s", content, canary.ExpectRegex)
+		}
+	}
+	return nil
+}
+
+// IsSameModelIdentity 判断响应中的模型名是否与请求的模型一致，允许大小写差异、厂商前缀与日期/版本后缀，
+// 例如 gpt-4o 与 gpt-4o-2024-08-06、claude-3-5-sonnet 与 anthropic/claude-3-5-sonnet
+func IsSameModelIdentity(expected string, actual string) bool {
+	expected = strings.ToLower(strings.TrimSpace(expected))
+	actual = strings.ToLower(strings.TrimSpace(actual))
+	if expected == "" || actual == "" {
+		// 部分上游不返回 model 字段，无法判断时视为一致
+		return true
+	}
+	if i := strings.LastIndex(expected, "/"); i >= 0 {
+		expected = expected[i+1:]
+	}
+	if i := strings.LastIndex(actual, "/"); i >= 0 {
+		actual = actual[i+1:]
+	}
+	if expected == actual {
+		return true
+	}
+	if rest, ok := strings.CutPrefix(actual, expected+"-"); ok {
+		return isModelVersionSuffix(rest)
+	}
+	if rest, ok := strings.CutPrefix(expected, actual+"-"); ok {
+		return isModelVersionSuffix(rest)
+	}
+	return false
+}
+
+// isModelVersionSuffix 日期或版本号后缀（如 2024-08-06、0613、latest），gpt-4o-mini 不应被视为 gpt-4o
+func isModelVersionSuffix(suffix string) bool {
+	if suffix == "latest" || suffix == "preview" {
+		return true
+	}
+	return suffix != "" && suffix[0] >= '0' && suffix[0] <= '9'
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluateCanaryResponse(t *testing.T) {
+	t.Parallel()
+
+	regexCase := operation_setting.CanaryCase{Name: "math", Prompt: "1+1=?", ExpectRegex: `\b2\b`}
+	ok := `{"model":"gpt-4o-2024-08-06","choices":[{"index":0,"message":{"role":"assistant","content":"The answer is 2."},"finish_reason":"stop"}]}`
+	require.NoError(t, EvaluateCanaryResponse(regexCase, []byte(ok), []string{"gpt-4o"}))
+
+	wrong := `{"model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"The answer is 3."},"finish_reason":"stop"}]}`
+	require.ErrorContains(t, EvaluateCanaryResponse(regexCase, []byte(wrong), nil), "does not match")
+
+	otherModel := `{"model":"gpt-3.5-turbo","choices":[{"index":0,"message":{"role":"assistant","content":"2"},"finish_reason":"stop"}]}`
+	require.ErrorContains(t, EvaluateCanaryResponse(regexCase, []byte(otherModel), []string{"gpt-4o"}), "model identity mismatch")
+
+	truncated := `{"model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"The ans"},"finish_reason":"length"}]}`
+	require.ErrorContains(t, EvaluateCanaryResponse(regexCase, []byte(truncated), nil), "truncated")
+
+	streamCase := operation_setting.CanaryCase{Name: "stream", Stream: true, ExpectRegex: "hello world"}
+	stream := "data: {\"model\":\"gpt-4o\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hello \"}}]}\n\n" +
+		"data: {\"model\":\"gpt-4o\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"world\"},\"finish_reason\":\"stop\"}]}\n\n"
+	require.ErrorContains(t, EvaluateCanaryResponse(streamCase, []byte(stream), nil), "[DONE]")
+	require.NoError(t, EvaluateCanaryResponse(streamCase, []byte(stream+"data: [DONE]\n\n"), []string{"gpt-4o"}))
+
+	toolCase := operation_setting.CanaryCase{
+		Name:           "tool",
+		ExpectToolCall: "get_weather",
+		ToolParameters: `{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}`,
+	}
+	toolOK := `{"model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","tool_calls":[{"id":"1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]},"finish_reason":"tool_calls"}]}`
+	require.NoError(t, EvaluateCanaryResponse(toolCase, []byte(toolOK), nil))
+	toolBad := `{"model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","tool_calls":[{"id":"1","type":"function","function":{"name":"get_weather","arguments":"{}"}}]},"finish_reason":"tool_calls"}]}`
+	require.Error(t, EvaluateCanaryResponse(toolCase, []byte(toolBad), nil))
+
+	schemaCase := operation_setting.CanaryCase{Name: "schema", ExpectJSONSchema: `{"type":"object","properties":{"n":{"type":"integer"}},"required":["n"]}`}
+	schemaOK := `{"choices":[{"index":0,"message":{"role":"assistant","content":"{\"n\": 2}"},"finish_reason":"stop"}]}`
+	require.NoError(t, EvaluateCanaryResponse(schemaCase, []byte(schemaOK), nil))
+	schemaBad := `{"choices":[{"index":0,"message":{"role":"assistant","content":"{\"n\": \"two\"}"},"finish_reason":"stop"}]}`
+	require.Error(t, EvaluateCanaryResponse(schemaCase, []byte(schemaBad), nil))
+}
+
+func TestIsSameModelIdentity(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, IsSameModelIdentity("gpt-4o", "gpt-4o-2024-08-06"))
+	require.True(t, IsSameModelIdentity("claude-3-5-sonnet", "anthropic/Claude-3-5-Sonnet"))
+	require.True(t, IsSameModelIdentity("gpt-4o", ""))
+	require.False(t, IsSameModelIdentity("gpt-4o", "gpt-4o-mini-2024-07-18"))
+	require.False(t, IsSameModelIdentity("gpt-4o", "gpt-3.5-turbo"))
+}
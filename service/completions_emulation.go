@@ -0,0 +1,416 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	completionsEmulationSystemPrompt = "You are a raw text completion engine. Continue the text given by the user exactly from where it stops. " +
+		"Output only the continuation itself: do not repeat the given text, do not add explanations, quotes or markdown fences."
+	completionsEmulationInsertPrompt = "You are a raw text insertion engine. The user gives a <prefix> and a <suffix>. " +
+		"Output only the text that belongs between them so that prefix + output + suffix reads naturally: " +
+		"do not repeat the prefix or suffix, do not add explanations, quotes or markdown fences."
+	// chat 接口 top_logprobs 的上限
+	maxEmulatedTopLogprobs = 20
+)
+
+// chatOnlyApiTypes 上游仅提供对话接口、没有 legacy completions 的 API 类型，默认启用模拟
+var chatOnlyApiTypes = map[int]bool{
+	constant.APITypeAnthropic: true,
+	constant.APITypeGemini:    true,
+	constant.APITypeVertexAi:  true,
+	constant.APITypeAws:       true,
+	constant.APITypeCohere:    true,
+}
+
+// ShouldEmulateCompletions 判断 /v1/completions 请求是否需要转换为对话请求
+func ShouldEmulateCompletions(info *relaycommon.RelayInfo) bool {
+	if info == nil || info.ChannelMeta == nil {
+		return false
+	}
+	if info.RelayMode != relayconstant.RelayModeCompletions || info.RelayFormat != types.RelayFormatOpenAI {
+		return false
+	}
+	return info.ChannelSetting.EmulateCompletions || chatOnlyApiTypes[info.ApiType]
+}
+
+// CompletionsEmulation 将 legacy completions 请求改写为对话请求，并把上游的对话响应转换回 text_completion。
+// 用法与 OutputValidator 一致：Attach 在 DoResponse 之前调用，Finish 在之后调用。
+type CompletionsEmulation struct {
+	c        *gin.Context
+	info     *relaycommon.RelayInfo
+	prompt   string
+	echo     bool
+	logprobs bool
+
+	originRelayMode int
+	originURLPath   string
+	original        gin.ResponseWriter
+	writer          *completionsEmulationWriter
+}
+
+// NewCompletionsEmulation 在需要模拟时将 request 原地改写为对话请求并返回模拟器，否则返回 nil。
+// 改写期间 info.RelayMode 被切换为对话模式，Finish 或 Restore 时恢复。
+func NewCompletionsEmulation(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (*CompletionsEmulation, error) {
+	if !ShouldEmulateCompletions(info) || request == nil {
+		return nil, nil
+	}
+	prompt, err := parseCompletionsPrompt(request.Prompt)
+	if err != nil {
+		return nil, err
+	}
+	suffix, _ := request.Suffix.(string)
+	// best_of 需要服务端生成多个候选后择优，对话接口无法等价实现；best_of 不超过 n 时每个候选都会返回，可直接忽略
+	if len(request.BestOf) > 0 && string(request.BestOf) != "null" {
+		var bestOf int
+		if err := common.Unmarshal(request.BestOf, &bestOf); err != nil {
+			return nil, errors.New("best_of must be an integer")
+		}
+		n := 1
+		if request.N != nil && *request.N > 0 {
+			n = *request.N
+		}
+		if bestOf > n {
+			return nil, fmt.Errorf("best_of (%d) greater than n (%d) is not supported when completions are emulated via chat", bestOf, n)
+		}
+	}
+
+	e := &CompletionsEmulation{
+		c:               c,
+		info:            info,
+		prompt:          prompt,
+		originRelayMode: info.RelayMode,
+		originURLPath:   info.RequestURLPath,
+	}
+	if len(request.Echo) > 0 {
+		_ = common.Unmarshal(request.Echo, &e.echo)
+	}
+
+	systemPrompt := completionsEmulationSystemPrompt
+	userContent := prompt
+	if suffix != "" {
+		systemPrompt = completionsEmulationInsertPrompt
+		userContent = fmt.Sprintf("<prefix>%s</prefix>\n<suffix>%s</suffix>", prompt, suffix)
+	}
+	request.Messages = []dto.Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: userContent},
+	}
+
+	// logprobs 在 completions 中为 0-5 的整数，对应 chat 的 logprobs=true + top_logprobs
+	if len(request.LogProbs) > 0 {
+		var topLogprobs int
+		if err := common.Unmarshal(request.LogProbs, &topLogprobs); err == nil {
+			e.logprobs = true
+			request.LogProbs = []byte("true")
+			if topLogprobs > 0 {
+				request.TopLogProbs = common.GetPointer(min(topLogprobs, maxEmulatedTopLogprobs))
+			}
+		} else {
+			request.LogProbs = nil
+		}
+	}
+	request.BestOf = nil
+	request.Echo = nil
+	request.Prompt = nil
+	request.Suffix = nil
+
+	info.CompletionsEmulated = true
+	info.RelayMode = relayconstant.RelayModeChatCompletions
+	info.RequestURLPath = strings.Replace(info.RequestURLPath, "/v1/completions", "/v1/chat/completions", 1)
+	return e, nil
+}
+
+func parseCompletionsPrompt(prompt any) (string, error) {
+	switch p := prompt.(type) {
+	case string:
+		return p, nil
+	case []any:
+		if len(p) == 1 {
+			if s, ok := p[0].(string); ok {
+				return s, nil
+			}
+		}
+		if len(p) > 1 {
+			if _, ok := p[0].(string); ok {
+				return "", errors.New("multiple prompts are not supported when completions are emulated via chat")
+			}
+		}
+	}
+	return "", errors.New("only string prompts are supported when completions are emulated via chat")
+}
+
+// Attach 接管 c.Writer
+func (e *CompletionsEmulation) Attach() {
+	if e == nil {
+		return
+	}
+	e.original = e.c.Writer
+	e.writer = &completionsEmulationWriter{
+		CaptureWriter: NewCaptureWriter(e.original, e.info.IsStream, 0),
+		emulation:     e,
+		stream:        e.info.IsStream,
+		echoed:        make(map[int]bool),
+		textOffsets:   make(map[int]int),
+	}
+	e.c.Writer = e.writer
+}
+
+// Restore 恢复被改写的 RelayMode 与请求路径，可重复调用
+func (e *CompletionsEmulation) Restore() {
+	if e == nil {
+		return
+	}
+	e.info.RelayMode = e.originRelayMode
+	e.info.RequestURLPath = e.originURLPath
+}
+
+// Finish 恢复 c.Writer 与 RelayMode；非流式响应在此转换后写回客户端
+func (e *CompletionsEmulation) Finish(relayErr *types.NewAPIError) *types.NewAPIError {
+	if e == nil {
+		return relayErr
+	}
+	e.Restore()
+	if e.writer == nil {
+		return relayErr
+	}
+	e.c.Writer = e.original
+	if e.writer.stream {
+		e.writer.flushPending()
+		return relayErr
+	}
+	if relayErr != nil {
+		return relayErr
+	}
+	body := e.writer.Bytes()
+	if converted, ok := e.convertResponse(body); ok {
+		body = converted
+		e.original.Header().Del("Content-Length")
+	}
+	e.original.WriteHeader(e.writer.CapturedStatus())
+	_, _ = e.original.Write(body)
+	return nil
+}
+
+type emulatedChatChoice struct {
+	Index        int                         `json:"index"`
+	Message      dto.Message                 `json:"message"`
+	Logprobs     *dto.ChatCompletionLogprobs `json:"logprobs"`
+	FinishReason string                      `json:"finish_reason"`
+}
+
+type emulatedChatResponse struct {
+	Id                string               `json:"id"`
+	Object            string               `json:"object"`
+	Created           any                  `json:"created"`
+	Model             string               `json:"model"`
+	SystemFingerprint *string              `json:"system_fingerprint"`
+	Choices           []emulatedChatChoice `json:"choices"`
+	Usage             *dto.Usage           `json:"usage"`
+}
+
+// convertResponse 将非流式的 chat.completion 响应转换为 text_completion，非对话响应（如错误）原样返回
+func (e *CompletionsEmulation) convertResponse(body []byte) ([]byte, bool) {
+	var chatResponse emulatedChatResponse
+	if err := common.Unmarshal(body, &chatResponse); err != nil || chatResponse.Object != "chat.completion" {
+		return nil, false
+	}
+	response := dto.TextCompletionResponse{
+		Id:                completionsId(chatResponse.Id),
+		Object:            "text_completion",
+		Created:           completionsCreated(chatResponse.Created),
+		Model:             chatResponse.Model,
+		SystemFingerprint: chatResponse.SystemFingerprint,
+		Choices:           make([]dto.TextCompletionChoice, 0, len(chatResponse.Choices)),
+		Usage:             chatResponse.Usage,
+	}
+	for _, choice := range chatResponse.Choices {
+		text := choice.Message.StringContent()
+		choiceResult := dto.TextCompletionChoice{
+			Index:        choice.Index,
+			FinishReason: common.GetPointer(choice.FinishReason),
+		}
+		if e.logprobs {
+			choiceResult.Logprobs = chatLogprobsToCompletions(choice.Logprobs, len([]rune(e.prompt)))
+		}
+		if e.echo {
+			text = e.prompt + text
+		}
+		choiceResult.Text = text
+		response.Choices = append(response.Choices, choiceResult)
+	}
+	data, err := common.Marshal(response)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// convertStreamChunk 将一个 chat.completion.chunk 转换为 text_completion 分块，返回 nil 表示跳过该分块
+func (e *CompletionsEmulation) convertStreamChunk(chunk *dto.ChatCompletionsStreamResponse, w *completionsEmulationWriter) *dto.TextCompletionResponse {
+	response := &dto.TextCompletionResponse{
+		Id:                completionsId(chunk.Id),
+		Object:            "text_completion",
+		Created:           chunk.Created,
+		Model:             chunk.Model,
+		SystemFingerprint: chunk.SystemFingerprint,
+		Choices:           make([]dto.TextCompletionChoice, 0, len(chunk.Choices)),
+		Usage:             chunk.Usage,
+	}
+	for _, choice := range chunk.Choices {
+		text := choice.Delta.GetContentString()
+		// 跳过仅包含 role 或推理内容的分块
+		if text == "" && choice.FinishReason == nil {
+			continue
+		}
+		choiceResult := dto.TextCompletionChoice{
+			Index:        choice.Index,
+			FinishReason: choice.FinishReason,
+		}
+		if e.logprobs && choice.Logprobs != nil {
+			logprobs, _ := common.Any2Type[dto.ChatCompletionLogprobs](*choice.Logprobs)
+			offset, ok := w.textOffsets[choice.Index]
+			if !ok {
+				offset = len([]rune(e.prompt))
+			}
+			choiceResult.Logprobs = chatLogprobsToCompletions(&logprobs, offset)
+			w.textOffsets[choice.Index] = offset + len([]rune(text))
+		}
+		if e.echo && !w.echoed[choice.Index] {
+			text = e.prompt + text
+			w.echoed[choice.Index] = true
+		}
+		choiceResult.Text = text
+		response.Choices = append(response.Choices, choiceResult)
+	}
+	if len(response.Choices) == 0 && response.Usage == nil {
+		return nil
+	}
+	return response
+}
+
+// chatLogprobsToCompletions 将 chat 的逐 token logprobs 转换为 completions 的并列数组格式，offset 为首个 token 的字符偏移
+func chatLogprobsToCompletions(logprobs *dto.ChatCompletionLogprobs, offset int) *dto.TextCompletionLogprobs {
+	result := &dto.TextCompletionLogprobs{
+		Tokens:        make([]string, 0),
+		TokenLogprobs: make([]float64, 0),
+		TopLogprobs:   make([]map[string]float64, 0),
+		TextOffset:    make([]int, 0),
+	}
+	if logprobs == nil {
+		return result
+	}
+	for _, token := range logprobs.Content {
+		result.Tokens = append(result.Tokens, token.Token)
+		result.TokenLogprobs = append(result.TokenLogprobs, token.Logprob)
+		top := make(map[string]float64, len(token.TopLogprobs))
+		for _, candidate := range token.TopLogprobs {
+			top[candidate.Token] = candidate.Logprob
+		}
+		result.TopLogprobs = append(result.TopLogprobs, top)
+		result.TextOffset = append(result.TextOffset, offset)
+		offset += len([]rune(token.Token))
+	}
+	return result
+}
+
+func completionsId(chatId string) string {
+	if strings.HasPrefix(chatId, "chatcmpl-") {
+		return "cmpl-" + strings.TrimPrefix(chatId, "chatcmpl-")
+	}
+	return chatId
+}
+
+func completionsCreated(created any) int64 {
+	switch v := created.(type) {
+	case float64:
+		return int64(v)
+	case int64:
+		return v
+	default:
+		return time.Now().Unix()
+	}
+}
+
+// completionsEmulationWriter 非流式时缓存全部输出；流式时按 SSE 行改写 data 分块后写出
+type completionsEmulationWriter struct {
+	*CaptureWriter
+	emulation *CompletionsEmulation
+	stream    bool
+
+	// 流式状态
+	pending     bytes.Buffer
+	echoed      map[int]bool
+	textOffsets map[int]int
+}
+
+func (w *completionsEmulationWriter) Write(data []byte) (int, error) {
+	if !w.stream {
+		return w.CaptureWriter.Write(data)
+	}
+	w.pending.Write(data)
+	for {
+		line, err := w.pending.ReadBytes('\n')
+		if err != nil {
+			// 不完整的行放回缓冲区，等待后续数据
+			rest := append([]byte(nil), line...)
+			w.pending.Reset()
+			w.pending.Write(rest)
+			break
+		}
+		if _, writeErr := w.ResponseWriter.Write(w.convertLine(line)); writeErr != nil {
+			return 0, writeErr
+		}
+	}
+	return len(data), nil
+}
+
+func (w *completionsEmulationWriter) convertLine(line []byte) []byte {
+	trimmed := bytes.TrimSpace(line)
+	if !bytes.HasPrefix(trimmed, []byte("data:")) {
+		return line
+	}
+	payload := bytes.TrimSpace(bytes.TrimPrefix(trimmed, []byte("data:")))
+	if len(payload) == 0 || string(payload) == "[DONE]" {
+		return line
+	}
+	var chunk dto.ChatCompletionsStreamResponse
+	if err := common.Unmarshal(payload, &chunk); err != nil || chunk.Object != "chat.completion.chunk" {
+		return line
+	}
+	converted := w.emulation.convertStreamChunk(&chunk, w)
+	if converted == nil {
+		return nil
+	}
+	data, err := common.Marshal(converted)
+	if err != nil {
+		return line
+	}
+	return append(append([]byte("data: "), data...), '\n')
+}
+
+// flushPending 写出流结束时残留的不完整行
+func (w *completionsEmulationWriter) flushPending() {
+	if w.pending.Len() == 0 {
+		return
+	}
+	_, _ = w.ResponseWriter.Write(w.convertLine(w.pending.Bytes()))
+	w.pending.Reset()
+}
+
+func (w *completionsEmulationWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func newCompletionsEmulationInfo(stream bool) *relaycommon.RelayInfo {
+	return &relaycommon.RelayInfo{
+		RelayMode:      relayconstant.RelayModeCompletions,
+		RelayFormat:    types.RelayFormatOpenAI,
+		RequestURLPath: "/v1/completions",
+		IsStream:       stream,
+		ChannelMeta:    &relaycommon.ChannelMeta{ApiType: constant.APITypeAnthropic},
+	}
+}
+
+func TestNewCompletionsEmulation_RewritesRequest(t *testing.T) {
+	t.Parallel()
+
+	var request dto.GeneralOpenAIRequest
+	require.NoError(t, common.UnmarshalJsonStr(`{
+		"model": "claude-3-haiku",
+		"prompt": ["def add(a, b):"],
+		"suffix": "\nprint(add(1, 2))",
+		"echo": true,
+		"logprobs": 3,
+		"n": 2,
+		"best_of": 2
+	}`, &request))
+
+	info := newCompletionsEmulationInfo(false)
+	emulation, err := NewCompletionsEmulation(nil, info, &request)
+	require.NoError(t, err)
+	require.NotNil(t, emulation)
+	require.True(t, info.CompletionsEmulated)
+	require.Equal(t, relayconstant.RelayModeChatCompletions, info.RelayMode)
+	require.Equal(t, "/v1/chat/completions", info.RequestURLPath)
+
+	require.Nil(t, request.Prompt)
+	require.Nil(t, request.Suffix)
+	require.Nil(t, request.Echo)
+	require.Nil(t, request.BestOf)
+	require.Equal(t, "true", string(request.LogProbs))
+	require.Equal(t, 3, *request.TopLogProbs)
+	require.Len(t, request.Messages, 2)
+	require.Equal(t, completionsEmulationInsertPrompt, request.Messages[0].StringContent())
+	require.Equal(t, "<prefix>def add(a, b):</prefix>\n<suffix>\nprint(add(1, 2))</suffix>", request.Messages[1].StringContent())
+
+	emulation.Finish(nil)
+	require.Equal(t, relayconstant.RelayModeCompletions, info.RelayMode)
+	require.Equal(t, "/v1/completions", info.RequestURLPath)
+}
+
+func TestNewCompletionsEmulation_RejectsMultiplePrompts(t *testing.T) {
+	t.Parallel()
+
+	request := dto.GeneralOpenAIRequest{Prompt: []any{"a", "b"}}
+	_, err := NewCompletionsEmulation(nil, newCompletionsEmulationInfo(false), &request)
+	require.Error(t, err)
+
+	// 未启用模拟的渠道不做任何改写
+	info := newCompletionsEmulationInfo(false)
+	info.ApiType = constant.APITypeOpenAI
+	emulation, err := NewCompletionsEmulation(nil, info, &request)
+	require.NoError(t, err)
+	require.Nil(t, emulation)
+}
+
+func TestCompletionsEmulation_ConvertsResponse(t *testing.T) {
+	t.Parallel()
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	request := dto.GeneralOpenAIRequest{Prompt: "Once upon", Echo: []byte("true"), LogProbs: []byte("1")}
+	emulation, err := NewCompletionsEmulation(c, newCompletionsEmulationInfo(false), &request)
+	require.NoError(t, err)
+
+	emulation.Attach()
+	c.Writer.WriteHeader(http.StatusOK)
+	_, _ = c.Writer.Write([]byte(`{"id":"chatcmpl-1","object":"chat.completion","created":1700000000,"model":"claude-3-haiku",
+		"choices":[{"index":0,"message":{"role":"assistant","content":" a time"},"finish_reason":"stop",
+		"logprobs":{"content":[{"token":" a","logprob":-0.1,"top_logprobs":[{"token":" a","logprob":-0.1}]},{"token":" time","logprob":-0.2}]}}],
+		"usage":{"prompt_tokens":2,"completion_tokens":2,"total_tokens":4}}`))
+	require.Nil(t, emulation.Finish(nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var response dto.TextCompletionResponse
+	require.NoError(t, common.Unmarshal(recorder.Body.Bytes(), &response))
+	require.Equal(t, "cmpl-1", response.Id)
+	require.Equal(t, "text_completion", response.Object)
+	require.Equal(t, int64(1700000000), response.Created)
+	require.Equal(t, 4, response.Usage.TotalTokens)
+	choices, err := common.Marshal(response.Choices)
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"text":"Once upon a time","index":0,"finish_reason":"stop","logprobs":{
+		"tokens":[" a"," time"],"token_logprobs":[-0.1,-0.2],
+		"top_logprobs":[{" a":-0.1},{}],"text_offset":[9,11]}}]`, string(choices))
+}
+
+func TestCompletionsEmulation_ConvertsStream(t *testing.T) {
+	t.Parallel()
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	request := dto.GeneralOpenAIRequest{Prompt: "Hello", Echo: []byte("true")}
+	emulation, err := NewCompletionsEmulation(c, newCompletionsEmulationInfo(true), &request)
+	require.NoError(t, err)
+
+	emulation.Attach()
+	stream := `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"role":"assistant","content":""}}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"content":" wor"}}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"content":"ld"},"finish_reason":"stop"}]}
+
+data: [DONE]
+
+`
+	// 按任意位置切分写入，验证跨写入的行拼接
+	_, _ = c.Writer.Write([]byte(stream[:100]))
+	_, _ = c.Writer.Write([]byte(stream[100:]))
+	require.Nil(t, emulation.Finish(nil))
+
+	var texts []string
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		payload, ok := strings.CutPrefix(line, "data: ")
+		if !ok || payload == "[DONE]" {
+			continue
+		}
+		var chunk dto.TextCompletionResponse
+		require.NoError(t, common.UnmarshalJsonStr(payload, &chunk))
+		require.Equal(t, "text_completion", chunk.Object)
+		require.Equal(t, "cmpl-1", chunk.Id)
+		texts = append(texts, chunk.Choices[0].Text)
+	}
+	require.Equal(t, []string{"Hello wor", "ld"}, texts)
+	require.Contains(t, recorder.Body.String(), "data: [DONE]")
+}
+
+func TestNewCompletionsEmulation_RejectsBestOfGreaterThanN(t *testing.T) {
+	t.Parallel()
+
+	var request dto.GeneralOpenAIRequest
+	require.NoError(t, common.UnmarshalJsonStr(`{"model": "claude-3-haiku", "prompt": "hi", "best_of": 3}`, &request))
+
+	info := newCompletionsEmulationInfo(false)
+	emulation, err := NewCompletionsEmulation(nil, info, &request)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "best_of (3) greater than n (1)")
+	require.Nil(t, emulation)
+	require.Equal(t, relayconstant.RelayModeCompletions, info.RelayMode)
+}
+
+func TestCompletionsEmulation_RestoreWithoutFinish(t *testing.T) {
+	t.Parallel()
+
+	request := dto.GeneralOpenAIRequest{Prompt: "Once upon"}
+	info := newCompletionsEmulationInfo(false)
+	emulation, err := NewCompletionsEmulation(nil, info, &request)
+	require.NoError(t, err)
+	require.Equal(t, relayconstant.RelayModeChatCompletions, info.RelayMode)
+
+	emulation.Restore()
+	require.Equal(t, relayconstant.RelayModeCompletions, info.RelayMode)
+	require.Equal(t, "/v1/completions", info.RequestURLPath)
+}
@@ -0,0 +1,876 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/console_setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// 声明式配置包：渠道、倍率、分组与系统选项的导出、比对与幂等导入。
+// 渠道以名称作为稳定标识；密钥类字段（渠道 key、敏感选项，以及渠道 JSON 字段中的敏感子键）以 ${ENV_NAME} 引用环境变量，
+// 导出时不包含明文。
+
+const ConfigBundleVersion = 1
+
+const configSecretMask = "******"
+
+// ratioOptionKeys 导出到 ratios 段的选项，值为 JSON 对象
+var ratioOptionKeys = []string{
+	"ModelRatio",
+	"ModelPrice",
+	"CompletionRatio",
+	"CacheRatio",
+	"CreateCacheRatio",
+	"ImageRatio",
+	"ImageSizeRatio",
+	"ModelTimeRatio",
+	"AudioRatio",
+	"AudioCompletionRatio",
+}
+
+// groupOptionKeys 导出到 groups 段的选项，值为 JSON 对象或数组
+var groupOptionKeys = []string{
+	"GroupRatio",
+	"GroupGroupRatio",
+	"UserUsableGroups",
+	"AutoGroups",
+	"TopupGroupRatio",
+}
+
+var configEnvRefPattern = regexp.MustCompile(`^\$\{([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
+var configEnvNameReplacer = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+type ConfigBundle struct {
+	Version int `json:"version" yaml:"version"`
+	// PruneChannels 为 true 时删除配置包中不存在的渠道
+	PruneChannels bool              `json:"prune_channels,omitempty" yaml:"prune_channels,omitempty"`
+	Options       map[string]string `json:"options,omitempty" yaml:"options,omitempty"`
+	Ratios        map[string]any    `json:"ratios,omitempty" yaml:"ratios,omitempty"`
+	Groups        map[string]any    `json:"groups,omitempty" yaml:"groups,omitempty"`
+	Channels      []ChannelConfig   `json:"channels,omitempty" yaml:"channels,omitempty"`
+}
+
+type ChannelConfig struct {
+	Name string `json:"name" yaml:"name"`
+	Type int    `json:"type" yaml:"type"`
+	// Key 多个 key 以换行分隔，建议使用 ${ENV_NAME} 引用环境变量
+	Key                string   `json:"key,omitempty" yaml:"key,omitempty"`
+	MultiKeyMode       string   `json:"multi_key_mode,omitempty" yaml:"multi_key_mode,omitempty"`
+	Status             int      `json:"status,omitempty" yaml:"status,omitempty"`
+	BaseURL            string   `json:"base_url,omitempty" yaml:"base_url,omitempty"`
+	Models             []string `json:"models,omitempty" yaml:"models,omitempty"`
+	Groups             []string `json:"groups,omitempty" yaml:"groups,omitempty"`
+	Tag                string   `json:"tag,omitempty" yaml:"tag,omitempty"`
+	Priority           int64    `json:"priority,omitempty" yaml:"priority,omitempty"`
+	Weight             uint     `json:"weight,omitempty" yaml:"weight,omitempty"`
+	AutoBan            *bool    `json:"auto_ban,omitempty" yaml:"auto_ban,omitempty"`
+	TestModel          string   `json:"test_model,omitempty" yaml:"test_model,omitempty"`
+	OpenAIOrganization string   `json:"openai_organization,omitempty" yaml:"openai_organization,omitempty"`
+	Other              string   `json:"other,omitempty" yaml:"other,omitempty"`
+	Remark             string   `json:"remark,omitempty" yaml:"remark,omitempty"`
+	ModelMapping       any      `json:"model_mapping,omitempty" yaml:"model_mapping,omitempty"`
+	StatusCodeMapping  any      `json:"status_code_mapping,omitempty" yaml:"status_code_mapping,omitempty"`
+	Setting            any      `json:"setting,omitempty" yaml:"setting,omitempty"`
+	Settings           any      `json:"settings,omitempty" yaml:"settings,omitempty"`
+	ParamOverride      any      `json:"param_override,omitempty" yaml:"param_override,omitempty"`
+	HeaderOverride     any      `json:"header_override,omitempty" yaml:"header_override,omitempty"`
+}
+
+const (
+	ConfigActionCreate = "create"
+	ConfigActionUpdate = "update"
+	ConfigActionDelete = "delete"
+)
+
+type ConfigFieldChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+type ConfigChange struct {
+	Kind   string              `json:"kind"` // channel / option
+	Name   string              `json:"name"`
+	Action string              `json:"action"`
+	Fields []ConfigFieldChange `json:"fields,omitempty"`
+
+	channelId  int
+	channel    *ChannelConfig
+	optionVal  string
+	keyManaged bool
+}
+
+type ConfigPlan struct {
+	DryRun   bool           `json:"dry_run"`
+	Applied  bool           `json:"applied"`
+	Changes  []ConfigChange `json:"changes"`
+	Warnings []string       `json:"warnings,omitempty"`
+}
+
+// ParseConfigBundle 解析 JSON 或 YAML 格式的配置包
+func ParseConfigBundle(data []byte) (*ConfigBundle, error) {
+	var bundle ConfigBundle
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := common.Unmarshal(trimmed, &bundle); err != nil {
+			return nil, fmt.Errorf("invalid config bundle: %w", err)
+		}
+	} else if err := yaml.Unmarshal(trimmed, &bundle); err != nil {
+		return nil, fmt.Errorf("invalid config bundle: %w", err)
+	}
+	if bundle.Version != ConfigBundleVersion {
+		return nil, fmt.Errorf("unsupported config bundle version %d, expected %d", bundle.Version, ConfigBundleVersion)
+	}
+	names := make(map[string]bool, len(bundle.Channels))
+	for _, channel := range bundle.Channels {
+		if strings.TrimSpace(channel.Name) == "" {
+			return nil, errors.New("every channel in config bundle must have a name")
+		}
+		if names[channel.Name] {
+			return nil, fmt.Errorf("duplicate channel name %q in config bundle", channel.Name)
+		}
+		names[channel.Name] = true
+	}
+	for key := range bundle.Ratios {
+		if !lookupOptionKey(ratioOptionKeys, key) {
+			return nil, fmt.Errorf("unknown ratio option %q", key)
+		}
+	}
+	for key := range bundle.Groups {
+		if !lookupOptionKey(groupOptionKeys, key) {
+			return nil, fmt.Errorf("unknown group option %q", key)
+		}
+	}
+	return &bundle, nil
+}
+
+// MarshalConfigBundle 按 format（yaml / json）序列化配置包
+func MarshalConfigBundle(bundle *ConfigBundle, format string) ([]byte, error) {
+	if format == "json" {
+		return common.Marshal(bundle)
+	}
+	return yaml.Marshal(bundle)
+}
+
+func lookupOptionKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// isSecretOptionKey 与选项接口隐藏的敏感选项规则保持一致
+func isSecretOptionKey(key string) bool {
+	return strings.HasSuffix(key, "Token") ||
+		strings.HasSuffix(key, "Secret") ||
+		strings.HasSuffix(key, "Key") ||
+		strings.HasSuffix(key, "secret") ||
+		strings.HasSuffix(key, "api_key")
+}
+
+func configEnvName(prefix string, name string) string {
+	return prefix + strings.Trim(strings.ToUpper(configEnvNameReplacer.ReplaceAllString(name, "_")), "_")
+}
+
+// resolveConfigSecret 解析 ${ENV_NAME} 引用，返回值与是否可用；未设置的环境变量表示不管理该字段
+func resolveConfigSecret(value string) (string, bool) {
+	match := configEnvRefPattern.FindStringSubmatch(value)
+	if match == nil {
+		return value, true
+	}
+	resolved, ok := os.LookupEnv(match[1])
+	return resolved, ok
+}
+
+// configSecretFieldSuffixes 渠道 JSON 字段（请求头覆盖、参数覆盖、渠道设置等）中按子键名识别敏感值，
+// 子键名忽略大小写与 -、_ 后以这些后缀结尾或等于 key 时视为密钥
+var configSecretFieldSuffixes = []string{"authorization", "token", "secret", "password", "credential", "credentials", "apikey", "accesskey", "privatekey", "cookie"}
+
+func isSecretConfigField(name string) bool {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(name))
+	if normalized == "key" {
+		return true
+	}
+	for _, suffix := range configSecretFieldSuffixes {
+		if strings.HasSuffix(normalized, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactConfigSecrets 递归替换敏感子键的字符串值：envPrefix 非空时替换为 ${envPrefix_<子键路径>} 引用，
+// 为空时替换为掩码；返回替换后的副本以及是否有值被替换
+func redactConfigSecrets(value any, envPrefix string) (any, bool) {
+	switch v := value.(type) {
+	case map[string]any:
+		redacted := make(map[string]any, len(v))
+		changed := false
+		for key, item := range v {
+			path := ""
+			if envPrefix != "" {
+				path = envPrefix + "_" + key
+			}
+			if str, ok := item.(string); ok && isSecretConfigField(key) {
+				if str == "" || configEnvRefPattern.MatchString(str) {
+					redacted[key] = str
+					continue
+				}
+				if envPrefix != "" {
+					redacted[key] = "${" + configEnvName("", path) + "}"
+				} else {
+					redacted[key] = configSecretMask
+				}
+				changed = true
+				continue
+			}
+			var itemChanged bool
+			redacted[key], itemChanged = redactConfigSecrets(item, path)
+			changed = changed || itemChanged
+		}
+		return redacted, changed
+	case []any:
+		redacted := make([]any, len(v))
+		changed := false
+		for i, item := range v {
+			path := ""
+			if envPrefix != "" {
+				path = fmt.Sprintf("%s_%d", envPrefix, i)
+			}
+			var itemChanged bool
+			redacted[i], itemChanged = redactConfigSecrets(item, path)
+			changed = changed || itemChanged
+		}
+		return redacted, changed
+	}
+	return value, false
+}
+
+// resolveConfigSecrets 递归解析 ${ENV_NAME} 引用；环境变量未设置时沿用 live 中同一位置的值（不存在则去掉该子键），
+// 并将未设置的环境变量名追加到 missing
+func resolveConfigSecrets(value any, live any, missing *[]string) any {
+	switch v := value.(type) {
+	case string:
+		match := configEnvRefPattern.FindStringSubmatch(v)
+		if match == nil {
+			return v
+		}
+		if resolved, ok := os.LookupEnv(match[1]); ok {
+			return resolved
+		}
+		*missing = append(*missing, match[1])
+		return live
+	case map[string]any:
+		liveMap, _ := live.(map[string]any)
+		resolved := make(map[string]any, len(v))
+		for key, item := range v {
+			value := resolveConfigSecrets(item, liveMap[key], missing)
+			if value == nil && item != nil {
+				continue
+			}
+			resolved[key] = value
+		}
+		return resolved
+	case []any:
+		liveList, _ := live.([]any)
+		resolved := make([]any, len(v))
+		for i, item := range v {
+			var liveItem any
+			if i < len(liveList) {
+				liveItem = liveList[i]
+			}
+			resolved[i] = resolveConfigSecrets(item, liveItem, missing)
+		}
+		return resolved
+	}
+	return value
+}
+
+// channelConfigJSONFields 渠道配置中可能包含密钥子键的 JSON 字段
+func channelConfigJSONFields(config *ChannelConfig) map[string]*any {
+	return map[string]*any{
+		"setting":         &config.Setting,
+		"settings":        &config.Settings,
+		"param_override":  &config.ParamOverride,
+		"header_override": &config.HeaderOverride,
+	}
+}
+
+// redactChannelConfigSecrets 将渠道 JSON 字段（含 JSON 格式的 other）中的密钥替换为 NEWAPI_CHANNEL_<渠道名>_<字段>_<子键> 环境变量引用
+func redactChannelConfigSecrets(config *ChannelConfig) {
+	prefix := configEnvName("NEWAPI_CHANNEL_", config.Name)
+	for name, field := range channelConfigJSONFields(config) {
+		*field, _ = redactConfigSecrets(*field, prefix+"_"+strings.ToUpper(name))
+	}
+	if other, ok := parseConfigJSON(config.Other).(map[string]any); ok {
+		if redacted, changed := redactConfigSecrets(other, prefix+"_OTHER"); changed {
+			config.Other, _ = configJSONString(redacted)
+		}
+	}
+}
+
+// resolveChannelConfigSecrets 解析渠道 JSON 字段中的环境变量引用，live 为数据库中的当前配置（新建渠道时为 nil），
+// 返回未设置的环境变量名
+func resolveChannelConfigSecrets(config *ChannelConfig, live *ChannelConfig) []string {
+	var missing []string
+	liveFields := map[string]*any{}
+	if live != nil {
+		liveFields = channelConfigJSONFields(live)
+	}
+	for name, field := range channelConfigJSONFields(config) {
+		var liveValue any
+		if liveField, ok := liveFields[name]; ok {
+			liveValue = *liveField
+		}
+		*field = resolveConfigSecrets(*field, liveValue, &missing)
+	}
+	if strings.Contains(config.Other, "${") {
+		if other, ok := parseConfigJSON(config.Other).(map[string]any); ok {
+			var liveOther any
+			if live != nil {
+				liveOther = parseConfigJSON(live.Other)
+			}
+			config.Other, _ = configJSONString(resolveConfigSecrets(other, liveOther, &missing))
+		}
+	}
+	return missing
+}
+
+// ExportConfigBundle 导出当前实例的配置，密钥类字段替换为环境变量引用
+func ExportConfigBundle() (*ConfigBundle, error) {
+	bundle := &ConfigBundle{
+		Version: ConfigBundleVersion,
+		Options: make(map[string]string),
+		Ratios:  make(map[string]any),
+		Groups:  make(map[string]any),
+	}
+	options, err := model.AllOption()
+	if err != nil {
+		return nil, err
+	}
+	for _, option := range options {
+		switch {
+		case lookupOptionKey(ratioOptionKeys, option.Key):
+			bundle.Ratios[option.Key] = parseConfigJSON(option.Value)
+		case lookupOptionKey(groupOptionKeys, option.Key):
+			bundle.Groups[option.Key] = parseConfigJSON(option.Value)
+		case isSecretOptionKey(option.Key):
+			if option.Value != "" {
+				bundle.Options[option.Key] = "${" + configEnvName("NEWAPI_OPTION_", option.Key) + "}"
+			}
+		default:
+			bundle.Options[option.Key] = option.Value
+		}
+	}
+
+	var channels []*model.Channel
+	if err := model.DB.Order("id").Find(&channels).Error; err != nil {
+		return nil, err
+	}
+	for _, channel := range channels {
+		config := channelToConfig(channel)
+		config.Key = "${" + configEnvName("NEWAPI_CHANNEL_KEY_", channel.Name) + "}"
+		redactChannelConfigSecrets(&config)
+		bundle.Channels = append(bundle.Channels, config)
+	}
+	return bundle, nil
+}
+
+// parseConfigJSON 将 JSON 字符串解析为结构化值，便于在 YAML 中阅读；无法解析时保留原字符串
+func parseConfigJSON(raw string) any {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	var value any
+	if err := common.UnmarshalJsonStr(raw, &value); err != nil {
+		return raw
+	}
+	return value
+}
+
+// configJSONString 将结构化值序列化为存储用的 JSON 字符串
+func configJSONString(value any) (string, error) {
+	if value == nil {
+		return "", nil
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	data, err := common.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// normalizeConfigValue 统一 YAML 与 JSON 解码出的数值类型，用于比较
+func normalizeConfigValue(value any) any {
+	raw, err := configJSONString(value)
+	if err != nil {
+		return value
+	}
+	return parseConfigJSON(raw)
+}
+
+func splitConfigList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func stringOrEmpty(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func channelToConfig(channel *model.Channel) ChannelConfig {
+	config := ChannelConfig{
+		Name:               channel.Name,
+		Type:               channel.Type,
+		Key:                channel.Key,
+		Status:             channel.Status,
+		BaseURL:            stringOrEmpty(channel.BaseURL),
+		Models:             splitConfigList(channel.Models),
+		Groups:             splitConfigList(channel.Group),
+		Tag:                channel.GetTag(),
+		Priority:           channel.GetPriority(),
+		Weight:             uint(channel.GetWeight()),
+		TestModel:          stringOrEmpty(channel.TestModel),
+		OpenAIOrganization: stringOrEmpty(channel.OpenAIOrganization),
+		Other:              channel.Other,
+		Remark:             stringOrEmpty(channel.Remark),
+		ModelMapping:       parseConfigJSON(channel.GetModelMapping()),
+		StatusCodeMapping:  parseConfigJSON(channel.GetStatusCodeMapping()),
+		Setting:            parseConfigJSON(stringOrEmpty(channel.Setting)),
+		Settings:           parseConfigJSON(channel.OtherSettings),
+		ParamOverride:      parseConfigJSON(stringOrEmpty(channel.ParamOverride)),
+		HeaderOverride:     parseConfigJSON(stringOrEmpty(channel.HeaderOverride)),
+	}
+	// 余额查询凭证加密存储，比较与导出时使用明文（导出时再替换为环境变量引用）
+	if settings, ok := config.Settings.(map[string]any); ok {
+		if credential, err := channel.GetBalanceCredential(); err == nil && credential != "" {
+			settings["balance_credential"] = credential
+		}
+	}
+	if channel.ChannelInfo.IsMultiKey {
+		config.MultiKeyMode = string(channel.ChannelInfo.MultiKeyMode)
+		if config.MultiKeyMode == "" {
+			config.MultiKeyMode = string(constant.MultiKeyModeRandom)
+		}
+	}
+	if !channel.GetAutoBan() {
+		config.AutoBan = common.GetPointer(false)
+	}
+	// 空对象与未设置等价
+	for _, field := range []*any{&config.ModelMapping, &config.StatusCodeMapping, &config.Setting, &config.Settings, &config.ParamOverride, &config.HeaderOverride} {
+		if m, ok := (*field).(map[string]any); ok && len(m) == 0 {
+			*field = nil
+		}
+	}
+	return config
+}
+
+// normalizeChannelConfig 补全默认值，使配置包中的简写与数据库中的实际值可比较
+func normalizeChannelConfig(config ChannelConfig) ChannelConfig {
+	if config.Status == 0 {
+		config.Status = common.ChannelStatusEnabled
+	}
+	if len(config.Groups) == 0 {
+		config.Groups = []string{"default"}
+	}
+	if config.AutoBan != nil && *config.AutoBan {
+		config.AutoBan = nil
+	}
+	for _, field := range []*any{&config.ModelMapping, &config.StatusCodeMapping, &config.Setting, &config.Settings, &config.ParamOverride, &config.HeaderOverride} {
+		*field = normalizeConfigValue(*field)
+		if m, ok := (*field).(map[string]any); ok && len(m) == 0 {
+			*field = nil
+		}
+	}
+	return config
+}
+
+// diffChannelConfig 比较数据库中的渠道与期望配置，keyManaged 为 false 时不比较 key
+func diffChannelConfig(live *model.Channel, desired ChannelConfig, keyManaged bool) []ConfigFieldChange {
+	current := channelToConfig(live)
+	desired = normalizeChannelConfig(desired)
+	// 被自动禁用的渠道由运行时状态管理，期望启用时不视为差异
+	if current.Status == common.ChannelStatusAutoDisabled && desired.Status == common.ChannelStatusEnabled {
+		desired.Status = current.Status
+	}
+	if !keyManaged {
+		desired.Key = current.Key
+	}
+	var currentFields, desiredFields map[string]any
+	currentRaw, _ := common.Marshal(current)
+	desiredRaw, _ := common.Marshal(desired)
+	_ = common.Unmarshal(currentRaw, &currentFields)
+	_ = common.Unmarshal(desiredRaw, &desiredFields)
+
+	names := make(map[string]bool)
+	for name := range currentFields {
+		names[name] = true
+	}
+	for name := range desiredFields {
+		names[name] = true
+	}
+	sortedNames := make([]string, 0, len(names))
+	for name := range names {
+		sortedNames = append(sortedNames, name)
+	}
+	sort.Strings(sortedNames)
+
+	var changes []ConfigFieldChange
+	for _, name := range sortedNames {
+		oldValue, newValue := currentFields[name], desiredFields[name]
+		if name == "other" {
+			oldValue, newValue = parseConfigJSON(current.Other), parseConfigJSON(desired.Other)
+		}
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		if name == "key" {
+			oldValue, newValue = configSecretMask, configSecretMask
+		} else {
+			oldValue, _ = redactConfigSecrets(oldValue, "")
+			newValue, _ = redactConfigSecrets(newValue, "")
+		}
+		changes = append(changes, ConfigFieldChange{Field: name, Old: oldValue, New: newValue})
+	}
+	return changes
+}
+
+// PlanConfigBundle 计算将配置包应用到当前实例所需的变更
+func PlanConfigBundle(bundle *ConfigBundle) (*ConfigPlan, error) {
+	plan := &ConfigPlan{Changes: make([]ConfigChange, 0)}
+
+	if err := planConfigOptions(bundle, plan); err != nil {
+		return nil, err
+	}
+
+	var liveChannels []*model.Channel
+	if err := model.DB.Order("id").Find(&liveChannels).Error; err != nil {
+		return nil, err
+	}
+	liveByName := make(map[string][]*model.Channel)
+	for _, channel := range liveChannels {
+		liveByName[channel.Name] = append(liveByName[channel.Name], channel)
+	}
+
+	desiredNames := make(map[string]bool, len(bundle.Channels))
+	for i := range bundle.Channels {
+		desired := &bundle.Channels[i]
+		desiredNames[desired.Name] = true
+		key, keyManaged := resolveConfigSecret(desired.Key)
+		if desired.Key == "" {
+			keyManaged = false
+		}
+		resolved := *desired
+		resolved.Key = key
+
+		matches := liveByName[desired.Name]
+		if len(matches) > 1 {
+			return nil, fmt.Errorf("channel name %q is not unique in the current instance", desired.Name)
+		}
+		var live *ChannelConfig
+		if len(matches) == 1 {
+			current := channelToConfig(matches[0])
+			live = &current
+		}
+		for _, env := range resolveChannelConfigSecrets(&resolved, live) {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("channel %q: environment variable %s is not set, the value is left unchanged", desired.Name, env))
+		}
+		if len(matches) == 0 {
+			if !keyManaged {
+				plan.Warnings = append(plan.Warnings, fmt.Sprintf("channel %q skipped: key is missing or its environment variable is not set", desired.Name))
+				continue
+			}
+			plan.Changes = append(plan.Changes, ConfigChange{
+				Kind: "channel", Name: desired.Name, Action: ConfigActionCreate,
+				channel: &resolved, keyManaged: true,
+			})
+			continue
+		}
+		if desired.Key != "" && !keyManaged {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("channel %q: environment variable for key is not set, key is left unchanged", desired.Name))
+		}
+		fields := diffChannelConfig(matches[0], resolved, keyManaged)
+		if len(fields) == 0 {
+			continue
+		}
+		plan.Changes = append(plan.Changes, ConfigChange{
+			Kind: "channel", Name: desired.Name, Action: ConfigActionUpdate, Fields: fields,
+			channelId: matches[0].Id, channel: &resolved, keyManaged: keyManaged,
+		})
+	}
+
+	if bundle.PruneChannels {
+		for _, channel := range liveChannels {
+			if desiredNames[channel.Name] {
+				continue
+			}
+			plan.Changes = append(plan.Changes, ConfigChange{
+				Kind: "channel", Name: channel.Name, Action: ConfigActionDelete, channelId: channel.Id,
+			})
+		}
+	}
+	return plan, nil
+}
+
+func planConfigOptions(bundle *ConfigBundle, plan *ConfigPlan) error {
+	desired := make(map[string]string)
+	for key, value := range bundle.Options {
+		resolved, ok := resolveConfigSecret(value)
+		if !ok {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("option %q skipped: environment variable is not set", key))
+			continue
+		}
+		desired[key] = resolved
+	}
+	for _, section := range []map[string]any{bundle.Ratios, bundle.Groups} {
+		for key, value := range section {
+			raw, err := configJSONString(value)
+			if err != nil {
+				return fmt.Errorf("invalid value of option %q: %w", key, err)
+			}
+			desired[key] = raw
+		}
+	}
+
+	keys := make([]string, 0, len(desired))
+	for key := range desired {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	common.OptionMapRWMutex.RLock()
+	defer common.OptionMapRWMutex.RUnlock()
+	for _, key := range keys {
+		value := desired[key]
+		current, exists := common.OptionMap[key]
+		if exists && (current == value || (lookupOptionKey(ratioOptionKeys, key) || lookupOptionKey(groupOptionKeys, key)) &&
+			reflect.DeepEqual(parseConfigJSON(current), parseConfigJSON(value))) {
+			continue
+		}
+		action := ConfigActionUpdate
+		if !exists {
+			action = ConfigActionCreate
+		}
+		if err := validateConfigOption(key, value); err != nil {
+			return fmt.Errorf("invalid value of option %q: %w", key, err)
+		}
+		oldValue, newValue := any(current), any(value)
+		if isSecretOptionKey(key) {
+			oldValue, newValue = configSecretMask, configSecretMask
+		} else if lookupOptionKey(ratioOptionKeys, key) || lookupOptionKey(groupOptionKeys, key) {
+			oldValue, newValue = parseConfigJSON(current), parseConfigJSON(value)
+		}
+		plan.Changes = append(plan.Changes, ConfigChange{
+			Kind: "option", Name: key, Action: action,
+			Fields:    []ConfigFieldChange{{Field: "value", Old: oldValue, New: newValue}},
+			optionVal: value,
+		})
+	}
+	return nil
+}
+
+// validateConfigOption 与选项接口一致的格式校验，在写入事务开始前执行，避免无效值提交后才在刷新内存配置时失败；
+// 倍率与分组选项还按各自的结构解析
+func validateConfigOption(key string, value string) error {
+	switch key {
+	case "GroupRatio":
+		return ratio_setting.CheckGroupRatio(value)
+	case "ModelTimeRatio":
+		return ratio_setting.CheckModelTimeRatio(value)
+	case "ModelRatio", "ModelPrice", "CompletionRatio", "CacheRatio", "CreateCacheRatio", "ImageRatio",
+		"AudioRatio", "AudioCompletionRatio", "TopupGroupRatio":
+		var ratios map[string]float64
+		return common.UnmarshalJsonStr(value, &ratios)
+	case "ImageSizeRatio", "GroupGroupRatio":
+		var ratios map[string]map[string]float64
+		return common.UnmarshalJsonStr(value, &ratios)
+	case "UserUsableGroups":
+		var groups map[string]string
+		return common.UnmarshalJsonStr(value, &groups)
+	case "AutoGroups":
+		var groups []string
+		return common.UnmarshalJsonStr(value, &groups)
+	case "ModelRequestRateLimitGroup":
+		return setting.CheckModelRequestRateLimitGroup(value)
+	case "RateLimitExemptWhitelist":
+		return setting.CheckRateLimitExemptWhitelist(value)
+	case "RateLimitExemptIPWhitelist":
+		return setting.CheckRateLimitExemptIPWhitelist(value)
+	case "AutomaticDisableStatusCodes", "AutomaticRetryStatusCodes":
+		_, err := operation_setting.ParseHTTPStatusCodeRanges(value)
+		return err
+	case "console_setting.api_info":
+		return console_setting.ValidateConsoleSettings(value, "ApiInfo")
+	case "console_setting.announcements":
+		return console_setting.ValidateConsoleSettings(value, "Announcements")
+	case "console_setting.faq":
+		return console_setting.ValidateConsoleSettings(value, "FAQ")
+	case "console_setting.uptime_kuma_groups":
+		return console_setting.ValidateConsoleSettings(value, "UptimeKumaGroups")
+	case "console_setting.model_monitor":
+		return console_setting.ValidateModelMonitorJSON(value)
+	}
+	return nil
+}
+
+// ApplyConfigBundle 计算并应用变更；dryRun 时只返回变更计划
+func ApplyConfigBundle(bundle *ConfigBundle, dryRun bool) (*ConfigPlan, error) {
+	plan, err := PlanConfigBundle(bundle)
+	if err != nil {
+		return nil, err
+	}
+	plan.DryRun = dryRun
+	if dryRun || len(plan.Changes) == 0 {
+		return plan, nil
+	}
+	// 全部变更在同一事务中写入，任一失败整体回滚；内存中的配置与渠道缓存仅在提交后刷新
+	err = model.DB.Transaction(func(tx *gorm.DB) error {
+		for _, change := range plan.Changes {
+			if err := applyConfigChange(tx, change); err != nil {
+				return fmt.Errorf("failed to %s %s %q: %w", change.Action, change.Kind, change.Name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return plan, err
+	}
+	plan.Applied = true
+	for _, change := range plan.Changes {
+		if change.Kind != "option" {
+			continue
+		}
+		if err := model.RefreshOptionMap(change.Name, change.optionVal); err != nil {
+			common.SysError(fmt.Sprintf("failed to refresh option %s: %s", change.Name, err.Error()))
+		}
+	}
+	model.InitChannelCache()
+	ResetProxyClientCache()
+	return plan, nil
+}
+
+func applyConfigChange(tx *gorm.DB, change ConfigChange) error {
+	if change.Kind == "option" {
+		return model.SaveOptionTx(tx, change.Name, change.optionVal)
+	}
+	switch change.Action {
+	case ConfigActionCreate:
+		channel := &model.Channel{CreatedTime: common.GetTimestamp()}
+		if err := configToChannel(channel, *change.channel, true); err != nil {
+			return err
+		}
+		return model.InsertChannelTx(tx, channel)
+	case ConfigActionUpdate:
+		channel, err := model.GetChannelByIdTx(tx, change.channelId)
+		if err != nil {
+			return err
+		}
+		if err := configToChannel(channel, *change.channel, change.keyManaged); err != nil {
+			return err
+		}
+		columns := []string{"type", "status", "base_url", "models", "group", "tag", "priority", "weight", "auto_ban",
+			"test_model", "openai_organization", "other", "remark", "model_mapping", "status_code_mapping",
+			"setting", "settings", "param_override", "header_override", "channel_info"}
+		if change.keyManaged {
+			columns = append(columns, "key", "key_hash")
+		}
+		return channel.UpdateColumnsTx(tx, columns...)
+	case ConfigActionDelete:
+		channel := &model.Channel{Id: change.channelId}
+		return channel.DeleteTx(tx)
+	}
+	return fmt.Errorf("unknown config action %s", change.Action)
+}
+
+// configToChannel 将期望配置写入渠道，保留多 key 状态等运行时信息
+func configToChannel(channel *model.Channel, config ChannelConfig, keyManaged bool) error {
+	config = normalizeChannelConfig(config)
+	channel.Name = config.Name
+	channel.Type = config.Type
+	if keyManaged {
+		channel.Key = config.Key
+	}
+	// 保留自动禁用状态，避免每次导入都重新启用
+	if !(channel.Status == common.ChannelStatusAutoDisabled && config.Status == common.ChannelStatusEnabled) {
+		channel.Status = config.Status
+	}
+	channel.BaseURL = common.GetPointer(config.BaseURL)
+	channel.Models = strings.Join(config.Models, ",")
+	channel.Group = strings.Join(config.Groups, ",")
+	channel.SetTag(config.Tag)
+	channel.Priority = common.GetPointer(config.Priority)
+	channel.Weight = common.GetPointer(config.Weight)
+	autoBan := 1
+	if config.AutoBan != nil && !*config.AutoBan {
+		autoBan = 0
+	}
+	channel.AutoBan = &autoBan
+	channel.TestModel = common.GetPointer(config.TestModel)
+	channel.OpenAIOrganization = common.GetPointer(config.OpenAIOrganization)
+	channel.Other = config.Other
+	channel.Remark = common.GetPointer(config.Remark)
+
+	jsonFields := []struct {
+		value  any
+		target **string
+	}{
+		{config.ModelMapping, &channel.ModelMapping},
+		{config.StatusCodeMapping, &channel.StatusCodeMapping},
+		{config.Setting, &channel.Setting},
+		{config.ParamOverride, &channel.ParamOverride},
+		{config.HeaderOverride, &channel.HeaderOverride},
+	}
+	for _, field := range jsonFields {
+		raw, err := configJSONString(field.value)
+		if err != nil {
+			return err
+		}
+		*field.target = common.GetPointer(raw)
+	}
+	otherSettings, err := configJSONString(config.Settings)
+	if err != nil {
+		return err
+	}
+	channel.OtherSettings = otherSettings
+
+	if config.MultiKeyMode != "" {
+		channel.ChannelInfo.IsMultiKey = true
+		channel.ChannelInfo.MultiKeyMode = constant.MultiKeyMode(config.MultiKeyMode)
+		if keyManaged {
+			channel.ChannelInfo.MultiKeySize = len(channel.GetKeys())
+		}
+	} else {
+		channel.ChannelInfo.IsMultiKey = false
+	}
+	return channel.ValidateSettings()
+}
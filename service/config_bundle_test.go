@@ -0,0 +1,196 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfigBundle_YAML(t *testing.T) {
+	t.Parallel()
+
+	bundle, err := ParseConfigBundle([]byte(`
+version: 1
+prune_channels: true
+ratios:
+  ModelRatio:
+    gpt-4o: 1.25
+channels:
+  - name: openai-main
+    type: 1
+    key: ${OPENAI_MAIN_KEY}
+    models: [gpt-4o, gpt-4o-mini]
+    model_mapping:
+      gpt-4: gpt-4o
+`))
+	require.NoError(t, err)
+	require.True(t, bundle.PruneChannels)
+	require.Len(t, bundle.Channels, 1)
+	require.Equal(t, []string{"gpt-4o", "gpt-4o-mini"}, bundle.Channels[0].Models)
+
+	_, err = ParseConfigBundle([]byte(`{"version": 2}`))
+	require.Error(t, err)
+	_, err = ParseConfigBundle([]byte("version: 1\nchannels:\n  - name: a\n  - name: a\n"))
+	require.ErrorContains(t, err, "duplicate channel name")
+	_, err = ParseConfigBundle([]byte("version: 1\nratios:\n  Unknown: {}\n"))
+	require.ErrorContains(t, err, "unknown ratio option")
+}
+
+func TestResolveConfigSecret(t *testing.T) {
+	t.Setenv("CONFIG_BUNDLE_TEST_KEY", "sk-from-env")
+
+	value, ok := resolveConfigSecret("${CONFIG_BUNDLE_TEST_KEY}")
+	require.True(t, ok)
+	require.Equal(t, "sk-from-env", value)
+
+	_, ok = resolveConfigSecret("${CONFIG_BUNDLE_TEST_MISSING}")
+	require.False(t, ok)
+
+	value, ok = resolveConfigSecret("sk-inline")
+	require.True(t, ok)
+	require.Equal(t, "sk-inline", value)
+}
+
+func TestDiffChannelConfig(t *testing.T) {
+	t.Parallel()
+
+	modelMapping := `{"gpt-4":"gpt-4o"}`
+	live := &model.Channel{
+		Name:         "openai-main",
+		Type:         1,
+		Key:          "sk-live",
+		Status:       common.ChannelStatusAutoDisabled,
+		Models:       "gpt-4o,gpt-4o-mini",
+		Group:        "default",
+		ModelMapping: &modelMapping,
+		Priority:     common.GetPointer[int64](10),
+		AutoBan:      common.GetPointer(1),
+	}
+	desired := ChannelConfig{
+		Name:         "openai-main",
+		Type:         1,
+		Key:          "sk-live",
+		Models:       []string{"gpt-4o", "gpt-4o-mini"},
+		ModelMapping: map[string]any{"gpt-4": "gpt-4o"},
+		Priority:     10,
+	}
+	// 自动禁用状态与默认值不产生差异
+	require.Empty(t, diffChannelConfig(live, desired, true))
+
+	desired.Key = "sk-rotated"
+	desired.Priority = 5
+	desired.Models = []string{"gpt-4o"}
+	changes := diffChannelConfig(live, desired, true)
+	require.Len(t, changes, 3)
+	require.Equal(t, "key", changes[0].Field)
+	require.Equal(t, configSecretMask, changes[0].New)
+	require.Equal(t, "models", changes[1].Field)
+	require.Equal(t, "priority", changes[2].Field)
+
+	// 未管理的 key 不参与比较
+	require.Len(t, diffChannelConfig(live, desired, false), 2)
+}
+
+func TestApplyConfigBundle_RollsBackOnFailure(t *testing.T) {
+	truncate(t)
+	require.NoError(t, common.SetSecretMasterKeys("bundle-master-key"))
+	t.Cleanup(func() { _ = common.SetSecretMasterKeys("") })
+
+	bundle := &ConfigBundle{
+		Version: ConfigBundleVersion,
+		Channels: []ChannelConfig{
+			{Name: "bundle-ok", Type: 1, Key: "sk-ok", Models: []string{"gpt-4o"}, Groups: []string{"default"}},
+			// 无法校验的密文会被拒绝写入，使第二个变更失败
+			{Name: "bundle-bad", Type: 1, Key: "enc:v1:deadbeef:AAAA:AAAA", Models: []string{"gpt-4o"}, Groups: []string{"default"}},
+		},
+	}
+	plan, err := ApplyConfigBundle(bundle, false)
+	require.ErrorContains(t, err, `"bundle-bad"`)
+	require.False(t, plan.Applied)
+
+	var channels int64
+	require.NoError(t, model.DB.Model(&model.Channel{}).Count(&channels).Error)
+	require.Zero(t, channels)
+	var abilities int64
+	require.NoError(t, model.DB.Model(&model.Ability{}).Count(&abilities).Error)
+	require.Zero(t, abilities)
+}
+
+func TestExportConfigBundle_ReferencesNestedSecrets(t *testing.T) {
+	truncate(t)
+	require.NoError(t, common.SetSecretMasterKeys("bundle-master-key"))
+	t.Cleanup(func() { _ = common.SetSecretMasterKeys("") })
+
+	channel := &model.Channel{
+		Name:           "volc-main",
+		Type:           1,
+		Key:            "sk-channel-secret",
+		Status:         common.ChannelStatusEnabled,
+		Models:         "gpt-4o",
+		Group:          "default",
+		Other:          `{"token":"sk-other-secret","region":"cn-beijing"}`,
+		OtherSettings:  `{"balance_credential":"AK-secret|SK-secret"}`,
+		ParamOverride:  common.GetPointer(`{"max_tokens":100,"api_key":"sk-param-secret"}`),
+		HeaderOverride: common.GetPointer(`{"Authorization":"Bearer sk-header-secret","X-Trace":"on"}`),
+	}
+	require.NoError(t, model.DB.Create(channel).Error)
+
+	bundle, err := ExportConfigBundle()
+	require.NoError(t, err)
+	for _, format := range []string{"yaml", "json"} {
+		data, err := MarshalConfigBundle(bundle, format)
+		require.NoError(t, err)
+		for _, secret := range []string{"sk-channel-secret", "sk-other-secret", "AK-secret", "SK-secret", "enc:v1:", "sk-param-secret", "sk-header-secret"} {
+			require.NotContains(t, string(data), secret)
+		}
+	}
+	exported := bundle.Channels[0]
+	require.Equal(t, "${NEWAPI_CHANNEL_VOLC_MAIN_HEADER_OVERRIDE_AUTHORIZATION}", exported.HeaderOverride.(map[string]any)["Authorization"])
+	require.Equal(t, "on", exported.HeaderOverride.(map[string]any)["X-Trace"])
+	require.EqualValues(t, 100, exported.ParamOverride.(map[string]any)["max_tokens"])
+	require.True(t, strings.Contains(exported.Other, "cn-beijing"))
+
+	// 环境变量未设置时沿用当前值，不产生变更
+	plan, err := PlanConfigBundle(bundle)
+	require.NoError(t, err)
+	require.Empty(t, plan.Changes)
+	require.NotEmpty(t, plan.Warnings)
+
+	// 设置环境变量后按引用解析，变更中不显示明文
+	t.Setenv("NEWAPI_CHANNEL_VOLC_MAIN_HEADER_OVERRIDE_AUTHORIZATION", "Bearer sk-header-rotated")
+	plan, err = PlanConfigBundle(bundle)
+	require.NoError(t, err)
+	require.Len(t, plan.Changes, 1)
+	planData, err := common.Marshal(plan.Changes)
+	require.NoError(t, err)
+	require.NotContains(t, string(planData), "sk-header")
+}
+
+func TestApplyConfigBundle_RejectsInvalidOptionBeforeWriting(t *testing.T) {
+	truncate(t)
+
+	bundle := &ConfigBundle{
+		Version: ConfigBundleVersion,
+		Groups:  map[string]any{"GroupRatio": map[string]any{"default": -1}},
+		Ratios:  map[string]any{"ModelRatio": map[string]any{"gpt-4o": 1.25}},
+		Channels: []ChannelConfig{
+			{Name: "bundle-ok", Type: 1, Key: "sk-ok", Models: []string{"gpt-4o"}, Groups: []string{"default"}},
+		},
+	}
+	_, err := ApplyConfigBundle(bundle, false)
+	require.ErrorContains(t, err, `"GroupRatio"`)
+
+	var options, channels int64
+	require.NoError(t, model.DB.Model(&model.Option{}).Count(&options).Error)
+	require.Zero(t, options)
+	require.NoError(t, model.DB.Model(&model.Channel{}).Count(&channels).Error)
+	require.Zero(t, channels)
+
+	bundle.Groups = nil
+	bundle.Ratios = map[string]any{"ModelTimeRatio": map[string]any{"gpt-4o": []any{map[string]any{"schedule": "not a schedule", "ratio": 0.5}}}}
+	_, err = ApplyConfigBundle(bundle, true)
+	require.ErrorContains(t, err, `"ModelTimeRatio"`)
+}
@@ -73,9 +73,22 @@ func ClaudeToOpenAIRequest(claudeRequest dto.ClaudeRequest, info *relaycommon.Re
 	}
 
 	// Convert tools
-	tools, _ := common.Any2Type[[]dto.Tool](claudeRequest.Tools)
+	// 服务端工具（web_search_*、bash_*、code_execution_* 等）没有 OpenAI function 等价物：
+	// web_search 映射为 web_search_options，其余服务端工具忽略，避免被当作空 schema 的 function 发送
+	rawTools, _ := common.Any2Type[[]map[string]any](claudeRequest.Tools)
 	openAITools := make([]dto.ToolCallRequest, 0)
-	for _, claudeTool := range tools {
+	for _, rawTool := range rawTools {
+		toolType, _ := rawTool["type"].(string)
+		if toolType != "" && toolType != "custom" {
+			if strings.HasPrefix(toolType, "web_search") {
+				openAIRequest.WebSearchOptions = claudeWebSearchToolToOpenAI(rawTool)
+			}
+			continue
+		}
+		claudeTool, err := common.Any2Type[dto.Tool](rawTool)
+		if err != nil {
+			continue
+		}
 		openAITool := dto.ToolCallRequest{
 			Type: "function",
 			Function: dto.FunctionRequest{
@@ -87,6 +100,26 @@ func ClaudeToOpenAIRequest(claudeRequest dto.ClaudeRequest, info *relaycommon.Re
 		openAITools = append(openAITools, openAITool)
 	}
 	openAIRequest.Tools = openAITools
+	if claudeRequest.ToolChoice != nil {
+		toolChoice, disableParallel := claudeToolChoiceToOpenAI(claudeRequest.ToolChoice)
+		if toolChoice != nil && len(openAITools) > 0 {
+			openAIRequest.ToolChoice = toolChoice
+		}
+		if disableParallel && len(openAITools) > 0 {
+			openAIRequest.ParallelTooCalls = lo.ToPtr(false)
+		}
+	}
+
+	// metadata.user_id -> user
+	if len(claudeRequest.Metadata) > 0 {
+		var metadata struct {
+			UserId string `json:"user_id"`
+		}
+		if err := common.Unmarshal(claudeRequest.Metadata, &metadata); err == nil && metadata.UserId != "" {
+			userBytes, _ := common.Marshal(metadata.UserId)
+			openAIRequest.User = userBytes
+		}
+	}
 
 	// Convert messages
 	openAIMessages := make([]dto.Message, 0)
@@ -145,6 +178,7 @@ func ClaudeToOpenAIRequest(claudeRequest dto.ClaudeRequest, info *relaycommon.Re
 			}
 			contents := content
 			var toolCalls []dto.ToolCallRequest
+			var reasoningContent strings.Builder
 			mediaMessages := make([]dto.MediaContent, 0, len(contents))
 
 			for _, mediaMsg := range contents {
@@ -157,15 +191,23 @@ func ClaudeToOpenAIRequest(claudeRequest dto.ClaudeRequest, info *relaycommon.Re
 					}
 					mediaMessages = append(mediaMessages, message)
 				case "image":
-					// Handle image conversion (base64 to URL or keep as is)
-					imageData := fmt.Sprintf("data:%s;base64,%s", mediaMsg.Source.MediaType, mediaMsg.Source.Data)
-					//textContent += fmt.Sprintf("[Image: %s]", imageData)
+					if mediaMsg.Source == nil {
+						continue
+					}
 					mediaMessage := dto.MediaContent{
 						Type:     "image_url",
-						ImageUrl: &dto.MessageImageUrl{Url: imageData},
+						ImageUrl: &dto.MessageImageUrl{Url: claudeSourceToDataUrl(mediaMsg.Source)},
 					}
 					mediaMessages = append(mediaMessages, mediaMessage)
-				case "tool_use":
+				case "document":
+					if mediaMessage, ok := claudeDocumentToOpenAI(mediaMsg); ok {
+						mediaMessages = append(mediaMessages, mediaMessage)
+					}
+				case "thinking":
+					if mediaMsg.Thinking != nil {
+						reasoningContent.WriteString(*mediaMsg.Thinking)
+					}
+				case "tool_use", "server_tool_use":
 					toolCall := dto.ToolCallRequest{
 						ID:   mediaMsg.Id,
 						Type: "function",
@@ -186,13 +228,10 @@ func ClaudeToOpenAIRequest(claudeRequest dto.ClaudeRequest, info *relaycommon.Re
 						Name:       &toolName,
 						ToolCallId: mediaMsg.ToolUseId,
 					}
-					//oaiToolMessage.SetStringContent(*mediaMsg.GetMediaContent().Text)
 					if mediaMsg.IsStringContent() {
 						oaiToolMessage.SetStringContent(mediaMsg.GetStringContent())
 					} else {
-						mediaContents := mediaMsg.ParseMediaContent()
-						encodeJson, _ := common.Marshal(mediaContents)
-						oaiToolMessage.SetStringContent(string(encodeJson))
+						oaiToolMessage.SetStringContent(claudeToolResultToString(mediaMsg.ParseMediaContent()))
 					}
 					openAIMessages = append(openAIMessages, oaiToolMessage)
 				}
@@ -201,9 +240,17 @@ func ClaudeToOpenAIRequest(claudeRequest dto.ClaudeRequest, info *relaycommon.Re
 			if len(toolCalls) > 0 {
 				openAIMessage.SetToolCalls(toolCalls)
 			}
+			if reasoningContent.Len() > 0 && claudeMessage.Role == "assistant" {
+				openAIMessage.ReasoningContent = reasoningContent.String()
+			}
 
-			if len(mediaMessages) > 0 && len(toolCalls) == 0 {
-				openAIMessage.SetMediaContent(mediaMessages)
+			if len(mediaMessages) > 0 {
+				if len(toolCalls) > 0 && isTextOnlyMediaContents(mediaMessages) {
+					// assistant 的 tool_calls 消息只接受文本 content，合并为字符串以兼容更多上游
+					openAIMessage.SetStringContent(joinTextMediaContents(mediaMessages))
+				} else {
+					openAIMessage.SetMediaContent(mediaMessages)
+				}
 			}
 		}
 		if len(openAIMessage.ParseContent()) > 0 || len(openAIMessage.ToolCalls) > 0 {
@@ -527,7 +574,15 @@ func StreamResponseOpenAI2Claude(openAIResponse *dto.ChatCompletionsStreamRespon
 						Type:     "thinking_delta",
 						Thinking: &reasoning,
 					}
-				} else {
+				}
+				if textContent != "" {
+					// 同一个 delta 同时包含推理与正文时，先输出推理增量再切换到 text 块
+					if claudeResponse.Delta != nil {
+						thinkingResponse := claudeResponse
+						thinkingResponse.Index = common.GetPointer[int](info.ClaudeConvertInfo.Index)
+						claudeResponses = append(claudeResponses, &thinkingResponse)
+						claudeResponse.Delta = nil
+					}
 					if info.ClaudeConvertInfo.LastMessagesType != relaycommon.LastMessageTypeText {
 						stopOpenBlocksAndAdvance()
 						idx := info.ClaudeConvertInfo.Index
@@ -593,33 +648,41 @@ func ResponseOpenAI2Claude(openAIResponse *dto.OpenAITextResponse, info *relayco
 	}
 	for _, choice := range openAIResponse.Choices {
 		stopReason = stopReasonOpenAI2Claude(choice.FinishReason)
-		if choice.FinishReason == "tool_calls" {
-			for _, toolUse := range choice.Message.ParseToolCalls() {
-				claudeContent := dto.ClaudeMediaMessage{}
-				claudeContent.Type = "tool_use"
-				claudeContent.Id = toolUse.ID
-				claudeContent.Name = toolUse.Function.Name
-				var mapParams map[string]interface{}
-				if err := common.Unmarshal([]byte(toolUse.Function.Arguments), &mapParams); err == nil {
-					claudeContent.Input = mapParams
-				} else {
-					claudeContent.Input = toolUse.Function.Arguments
-				}
-				contents = append(contents, claudeContent)
-			}
-		} else {
+		// 按 Claude 的块顺序输出：thinking -> text -> tool_use
+		if reasoning := choice.Message.GetReasoningContent(); reasoning != "" {
+			contents = append(contents, dto.ClaudeMediaMessage{
+				Type:     "thinking",
+				Thinking: common.GetPointer[string](reasoning),
+			})
+		}
+		toolCalls := choice.Message.ParseToolCalls()
+		if text := choice.Message.StringContent(); text != "" || len(toolCalls) == 0 {
 			claudeContent := dto.ClaudeMediaMessage{}
 			claudeContent.Type = "text"
-			claudeContent.SetText(choice.Message.StringContent())
+			claudeContent.SetText(text)
 			contents = append(contents, claudeContent)
 		}
+		for _, toolUse := range toolCalls {
+			claudeContent := dto.ClaudeMediaMessage{}
+			claudeContent.Type = "tool_use"
+			claudeContent.Id = toolUse.ID
+			claudeContent.Name = toolUse.Function.Name
+			var mapParams map[string]interface{}
+			if err := common.Unmarshal([]byte(toolUse.Function.Arguments), &mapParams); err == nil {
+				claudeContent.Input = mapParams
+			} else {
+				claudeContent.Input = toolUse.Function.Arguments
+			}
+			contents = append(contents, claudeContent)
+		}
+		if len(toolCalls) > 0 && choice.FinishReason == "stop" {
+			// 部分上游在返回工具调用时仍给出 stop
+			stopReason = stopReasonOpenAI2Claude("tool_calls")
+		}
 	}
 	claudeResponse.Content = contents
 	claudeResponse.StopReason = stopReason
-	claudeResponse.Usage = &dto.ClaudeUsage{
-		InputTokens:  openAIResponse.PromptTokens,
-		OutputTokens: openAIResponse.CompletionTokens,
-	}
+	claudeResponse.Usage = buildClaudeUsageFromOpenAIUsage(&openAIResponse.Usage)
 
 	return claudeResponse
 }
@@ -636,12 +699,153 @@ func toJSONString(v interface{}) string {
 	return string(b)
 }
 
+// claudeSourceToDataUrl 将 Claude 的 image/document source 转为 OpenAI 可用的 URL，url 类型直接透传
+func claudeSourceToDataUrl(source *dto.ClaudeMessageSource) string {
+	if source.Type == "url" {
+		return source.Url
+	}
+	return fmt.Sprintf("data:%s;base64,%s", source.MediaType, common.Interface2String(source.Data))
+}
+
+// claudeDocumentToOpenAI 将 Claude document 块转换为 OpenAI content：
+// base64 / url 文档转为 file，纯文本文档转为 text，content 类型的文档展开其中的文本
+func claudeDocumentToOpenAI(document dto.ClaudeMediaMessage) (dto.MediaContent, bool) {
+	if document.Source == nil {
+		return dto.MediaContent{}, false
+	}
+	switch document.Source.Type {
+	case "text":
+		return dto.MediaContent{
+			Type:         dto.ContentTypeText,
+			Text:         common.Interface2String(document.Source.Data),
+			CacheControl: document.CacheControl,
+		}, true
+	case "content":
+		blocks, _ := common.Any2Type[[]dto.ClaudeMediaMessage](document.Source.Content)
+		texts := make([]string, 0, len(blocks))
+		for _, block := range blocks {
+			if block.Type == "text" {
+				texts = append(texts, block.GetText())
+			}
+		}
+		return dto.MediaContent{
+			Type:         dto.ContentTypeText,
+			Text:         strings.Join(texts, "\n"),
+			CacheControl: document.CacheControl,
+		}, len(texts) > 0
+	case "base64", "url":
+		return dto.MediaContent{
+			Type: dto.ContentTypeFile,
+			File: &dto.MessageFile{
+				FileName: "document" + documentExtension(document.Source.MediaType),
+				FileData: claudeSourceToDataUrl(document.Source),
+			},
+			CacheControl: document.CacheControl,
+		}, true
+	default:
+		return dto.MediaContent{}, false
+	}
+}
+
+func documentExtension(mediaType string) string {
+	switch mediaType {
+	case "application/pdf":
+		return ".pdf"
+	case "text/plain":
+		return ".txt"
+	default:
+		return ""
+	}
+}
+
+// claudeToolResultToString 将 tool_result 的数组内容转为字符串：纯文本直接拼接，包含图片等媒体时保留 JSON 结构
+func claudeToolResultToString(contents []dto.ClaudeMediaMessage) string {
+	texts := make([]string, 0, len(contents))
+	for _, content := range contents {
+		if content.Type != "text" {
+			encodeJson, _ := common.Marshal(contents)
+			return string(encodeJson)
+		}
+		texts = append(texts, content.GetText())
+	}
+	return strings.Join(texts, "\n")
+}
+
+func isTextOnlyMediaContents(contents []dto.MediaContent) bool {
+	for _, content := range contents {
+		if content.Type != dto.ContentTypeText {
+			return false
+		}
+	}
+	return true
+}
+
+func joinTextMediaContents(contents []dto.MediaContent) string {
+	texts := make([]string, 0, len(contents))
+	for _, content := range contents {
+		texts = append(texts, content.Text)
+	}
+	return strings.Join(texts, "\n")
+}
+
+// claudeToolChoiceToOpenAI 转换 Claude tool_choice，第二个返回值表示是否禁用并行工具调用
+func claudeToolChoiceToOpenAI(toolChoice any) (any, bool) {
+	choice, err := common.Any2Type[dto.ClaudeToolChoice](toolChoice)
+	if err != nil {
+		return nil, false
+	}
+	switch choice.Type {
+	case "auto":
+		return "auto", choice.DisableParallelToolUse
+	case "any":
+		return "required", choice.DisableParallelToolUse
+	case "none":
+		return "none", false
+	case "tool":
+		return map[string]any{
+			"type": "function",
+			"function": map[string]any{
+				"name": choice.Name,
+			},
+		}, choice.DisableParallelToolUse
+	default:
+		return nil, false
+	}
+}
+
+// claudeWebSearchToolToOpenAI 将 Claude web_search 服务端工具转换为 OpenAI web_search_options
+func claudeWebSearchToolToOpenAI(tool map[string]any) *dto.WebSearchOptions {
+	options := &dto.WebSearchOptions{}
+	location, ok := tool["user_location"].(map[string]any)
+	if !ok {
+		return options
+	}
+	approximate := make(map[string]any)
+	for _, key := range []string{"city", "region", "country", "timezone"} {
+		if value, ok := location[key].(string); ok && value != "" {
+			approximate[key] = value
+		}
+	}
+	if len(approximate) > 0 {
+		options.UserLocation, _ = common.Marshal(map[string]any{
+			"type":        "approximate",
+			"approximate": approximate,
+		})
+	}
+	return options
+}
+
 func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycommon.RelayInfo) (*dto.GeneralOpenAIRequest, error) {
 	openaiRequest := &dto.GeneralOpenAIRequest{
 		Model:  info.UpstreamModelName,
 		Stream: lo.ToPtr(info.IsStream),
 	}
 
+	// Gemini 的 functionCall / functionResponse 没有 ID，
+	// 按函数名维护待响应队列，保证跨消息的调用与响应能正确配对
+	callCounter := 0
+	pendingCallIds := make(map[string][]string)
+
 	// 转换 messages
 	var messages []dto.Message
 	for _, content := range geminiRequest.Contents {
@@ -652,7 +856,16 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 		// 处理 parts
 		var mediaContents []dto.MediaContent
 		var toolCalls []dto.ToolCallRequest
+		var toolMessages []dto.Message
+		var reasoningContent strings.Builder
 		for _, part := range content.Parts {
+			if part.Thought {
+				// 思考内容仅在 model 消息中作为 reasoning_content 保留
+				if part.Text != "" && message.Role == "assistant" {
+					reasoningContent.WriteString(part.Text)
+				}
+				continue
+			}
 			if part.Text != "" {
 				mediaContent := dto.MediaContent{
 					Type: "text",
@@ -660,15 +873,7 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 				}
 				mediaContents = append(mediaContents, mediaContent)
 			} else if part.InlineData != nil {
-				mediaContent := dto.MediaContent{
-					Type: "image_url",
-					ImageUrl: &dto.MessageImageUrl{
-						Url:      fmt.Sprintf("data:%s;base64,%s", part.InlineData.MimeType, part.InlineData.Data),
-						Detail:   "auto",
-						MimeType: part.InlineData.MimeType,
-					},
-				}
-				mediaContents = append(mediaContents, mediaContent)
+				mediaContents = append(mediaContents, geminiInlineDataToOpenAI(part.InlineData))
 			} else if part.FileData != nil {
 				mediaContent := dto.MediaContent{
 					Type: "image_url",
@@ -681,8 +886,11 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 				mediaContents = append(mediaContents, mediaContent)
 			} else if part.FunctionCall != nil {
 				// 处理 Gemini 的工具调用
+				callCounter++
+				callId := fmt.Sprintf("call_%d", callCounter)
+				pendingCallIds[part.FunctionCall.FunctionName] = append(pendingCallIds[part.FunctionCall.FunctionName], callId)
 				toolCall := dto.ToolCallRequest{
-					ID:   fmt.Sprintf("call_%d", len(toolCalls)+1), // 生成唯一ID
+					ID:   callId,
 					Type: "function",
 					Function: dto.FunctionRequest{
 						Name:      part.FunctionCall.FunctionName,
@@ -691,28 +899,55 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 				}
 				toolCalls = append(toolCalls, toolCall)
 			} else if part.FunctionResponse != nil {
-				// 处理 Gemini 的工具响应，创建单独的 tool 消息
+				// 处理 Gemini 的工具响应，创建单独的 tool 消息，按函数名匹配最早未响应的调用
+				name := part.FunctionResponse.Name
+				callId := ""
+				if queue := pendingCallIds[name]; len(queue) > 0 {
+					callId = queue[0]
+					pendingCallIds[name] = queue[1:]
+				} else {
+					callCounter++
+					callId = fmt.Sprintf("call_%d", callCounter)
+				}
 				toolMessage := dto.Message{
 					Role:       "tool",
-					ToolCallId: fmt.Sprintf("call_%d", len(toolCalls)), // 使用对应的调用ID
+					Name:       lo.ToPtr(name),
+					ToolCallId: callId,
 				}
 				toolMessage.SetStringContent(toJSONString(part.FunctionResponse.Response))
-				messages = append(messages, toolMessage)
+				toolMessages = append(toolMessages, toolMessage)
+			} else if part.ExecutableCode != nil {
+				// 代码执行内容没有 OpenAI 等价结构，以 markdown 代码块形式保留
+				mediaContents = append(mediaContents, dto.MediaContent{
+					Type: "text",
+					Text: fmt.Sprintf("```%s\n%s\n```", strings.ToLower(part.ExecutableCode.Language), part.ExecutableCode.Code),
+				})
+			} else if part.CodeExecutionResult != nil {
+				mediaContents = append(mediaContents, dto.MediaContent{
+					Type: "text",
+					Text: fmt.Sprintf("Code execution result (%s):\n```\n%s\n```", part.CodeExecutionResult.Outcome, part.CodeExecutionResult.Output),
+				})
 			}
 		}
 
 		// 设置消息内容
 		if len(toolCalls) > 0 {
-			// 如果有工具调用，设置工具调用
+			// 如果有工具调用，设置工具调用，同时保留文本内容
 			message.SetToolCalls(toolCalls)
-		} else if len(mediaContents) == 1 && mediaContents[0].Type == "text" {
-			// 如果只有一个文本内容，直接设置字符串
-			message.Content = mediaContents[0].Text
+		}
+		if len(mediaContents) > 0 && isTextOnlyMediaContents(mediaContents) {
+			// 纯文本内容直接设置字符串
+			message.SetStringContent(joinTextMediaContents(mediaContents))
 		} else if len(mediaContents) > 0 {
 			// 如果有多个内容或包含媒体，设置为数组
 			message.SetMediaContent(mediaContents)
 		}
+		if reasoningContent.Len() > 0 {
+			message.ReasoningContent = reasoningContent.String()
+		}
 
+		// tool 消息需紧跟在对应的 assistant tool_calls 之后，且先于同一 content 中的其他用户内容
+		messages = append(messages, toolMessages...)
 		// 只有当消息有内容或工具调用时才添加
 		if len(message.ParseContent()) > 0 || len(message.ToolCalls) > 0 {
 			messages = append(messages, message)
@@ -721,30 +956,58 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 
 	openaiRequest.Messages = messages
 
-	if geminiRequest.GenerationConfig.Temperature != nil {
-		openaiRequest.Temperature = geminiRequest.GenerationConfig.Temperature
+	generationConfig := geminiRequest.GenerationConfig
+	if generationConfig.Temperature != nil {
+		openaiRequest.Temperature = generationConfig.Temperature
 	}
-	if geminiRequest.GenerationConfig.TopP != nil && *geminiRequest.GenerationConfig.TopP > 0 {
-		openaiRequest.TopP = lo.ToPtr(*geminiRequest.GenerationConfig.TopP)
+	if generationConfig.TopP != nil && *generationConfig.TopP > 0 {
+		openaiRequest.TopP = lo.ToPtr(*generationConfig.TopP)
 	}
-	if geminiRequest.GenerationConfig.TopK != nil && *geminiRequest.GenerationConfig.TopK > 0 {
-		openaiRequest.TopK = lo.ToPtr(int(*geminiRequest.GenerationConfig.TopK))
+	if generationConfig.TopK != nil && *generationConfig.TopK > 0 {
+		openaiRequest.TopK = lo.ToPtr(int(*generationConfig.TopK))
 	}
-	if geminiRequest.GenerationConfig.MaxOutputTokens != nil && *geminiRequest.GenerationConfig.MaxOutputTokens > 0 {
-		openaiRequest.MaxTokens = lo.ToPtr(*geminiRequest.GenerationConfig.MaxOutputTokens)
+	if generationConfig.MaxOutputTokens != nil && *generationConfig.MaxOutputTokens > 0 {
+		openaiRequest.MaxTokens = lo.ToPtr(*generationConfig.MaxOutputTokens)
 	}
 	// gemini stop sequences 最多 5 个，openai stop 最多 4 个
-	if len(geminiRequest.GenerationConfig.StopSequences) > 0 {
-		openaiRequest.Stop = geminiRequest.GenerationConfig.StopSequences[:4]
+	if len(generationConfig.StopSequences) > 0 {
+		openaiRequest.Stop = generationConfig.StopSequences[:min(len(generationConfig.StopSequences), 4)]
+	}
+	if generationConfig.CandidateCount != nil && *generationConfig.CandidateCount > 0 {
+		openaiRequest.N = lo.ToPtr(*generationConfig.CandidateCount)
 	}
-	if geminiRequest.GenerationConfig.CandidateCount != nil && *geminiRequest.GenerationConfig.CandidateCount > 0 {
-		openaiRequest.N = lo.ToPtr(*geminiRequest.GenerationConfig.CandidateCount)
+	if generationConfig.PresencePenalty != nil {
+		openaiRequest.PresencePenalty = lo.ToPtr(float64(*generationConfig.PresencePenalty))
 	}
+	if generationConfig.FrequencyPenalty != nil {
+		openaiRequest.FrequencyPenalty = lo.ToPtr(float64(*generationConfig.FrequencyPenalty))
+	}
+	if generationConfig.Seed != nil {
+		openaiRequest.Seed = lo.ToPtr(float64(*generationConfig.Seed))
+	}
+	if generationConfig.ResponseLogprobs != nil && *generationConfig.ResponseLogprobs {
+		openaiRequest.LogProbs = json.RawMessage("true")
+		if generationConfig.Logprobs != nil {
+			openaiRequest.TopLogProbs = lo.ToPtr(int(*generationConfig.Logprobs))
+		}
+	}
+	openaiRequest.ResponseFormat = geminiResponseFormatToOpenAI(&generationConfig)
 
 	// 转换工具调用
+	isGeminiUpstream := isGeminiModel(info.UpstreamModelName)
 	if len(geminiRequest.GetTools()) > 0 {
 		var tools []dto.ToolCallRequest
 		for _, tool := range geminiRequest.GetTools() {
+			if tool.GoogleSearch != nil || tool.GoogleSearchRetrieval != nil {
+				openaiRequest.WebSearchOptions = &dto.WebSearchOptions{}
+			}
+			// codeExecution / urlContext 仅 Gemini 系上游可用，沿用 gemini 渠道识别的同名 function 约定
+			if isGeminiUpstream && tool.CodeExecution != nil {
+				tools = append(tools, dto.ToolCallRequest{Type: "function", Function: dto.FunctionRequest{Name: "codeExecution"}})
+			}
+			if isGeminiUpstream && tool.URLContext != nil {
+				tools = append(tools, dto.ToolCallRequest{Type: "function", Function: dto.FunctionRequest{Name: "urlContext"}})
+			}
 			if tool.FunctionDeclarations != nil {
 				functionDeclarations, err := common.Any2Type[[]dto.FunctionRequest](tool.FunctionDeclarations)
 				if err != nil {
@@ -766,7 +1029,27 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 		}
 		if len(tools) > 0 {
 			openaiRequest.Tools = tools
+			if geminiRequest.ToolConfig != nil {
+				openaiRequest.ToolChoice = geminiToolConfigToOpenAI(geminiRequest.ToolConfig)
+			}
+		}
+	}
+
+	// safetySettings / cachedContent 没有 OpenAI 等价字段，
+	// 仅在上游为 Gemini 系模型时通过 extra_body.google 透传（gemini 渠道与 Gemini OpenAI 兼容端点均可识别）
+	if isGeminiUpstream && (len(geminiRequest.SafetySettings) > 0 || geminiRequest.CachedContent != "") {
+		googleBody := make(map[string]any)
+		if len(geminiRequest.SafetySettings) > 0 {
+			googleBody["safety_settings"] = geminiRequest.SafetySettings
+		}
+		if geminiRequest.CachedContent != "" {
+			googleBody["cached_content"] = geminiRequest.CachedContent
+		}
+		extraBody, err := common.Marshal(map[string]any{"google": googleBody})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal extra_body: %w", err)
 		}
+		openaiRequest.ExtraBody = extraBody
 	}
 
 	// gemini system instructions
@@ -782,6 +1065,91 @@ func GeminiToOpenAIRequest(geminiRequest *dto.GeminiChatRequest, info *relaycomm
 	return openaiRequest, nil
 }
 
+// geminiInlineDataToOpenAI 按 mimeType 将 inlineData 转换为 image_url / input_audio / file
+func geminiInlineDataToOpenAI(inlineData *dto.GeminiInlineData) dto.MediaContent {
+	dataUrl := fmt.Sprintf("data:%s;base64,%s", inlineData.MimeType, inlineData.Data)
+	switch {
+	case strings.HasPrefix(inlineData.MimeType, "audio/"):
+		return dto.MediaContent{
+			Type: dto.ContentTypeInputAudio,
+			InputAudio: &dto.MessageInputAudio{
+				Data:   inlineData.Data,
+				Format: strings.TrimPrefix(inlineData.MimeType, "audio/"),
+			},
+		}
+	case strings.HasPrefix(inlineData.MimeType, "image/"), inlineData.MimeType == "":
+		return dto.MediaContent{
+			Type: dto.ContentTypeImageURL,
+			ImageUrl: &dto.MessageImageUrl{
+				Url:      dataUrl,
+				Detail:   "auto",
+				MimeType: inlineData.MimeType,
+			},
+		}
+	default:
+		return dto.MediaContent{
+			Type: dto.ContentTypeFile,
+			File: &dto.MessageFile{
+				FileName: "document" + documentExtension(inlineData.MimeType),
+				FileData: dataUrl,
+			},
+		}
+	}
+}
+
+// geminiResponseFormatToOpenAI 将 responseMimeType/responseSchema 转换为 response_format
+func geminiResponseFormatToOpenAI(config *dto.GeminiChatGenerationConfig) *dto.ResponseFormat {
+	if config.ResponseMimeType != "application/json" {
+		return nil
+	}
+	var schema any
+	if len(config.ResponseJsonSchema) > 0 {
+		_ = common.Unmarshal(config.ResponseJsonSchema, &schema)
+	} else if config.ResponseSchema != nil {
+		schema = config.ResponseSchema
+	}
+	if schema == nil {
+		return &dto.ResponseFormat{Type: "json_object"}
+	}
+	jsonSchema, err := common.Marshal(dto.FormatJsonSchema{
+		Name:   "response",
+		Schema: schema,
+	})
+	if err != nil {
+		return &dto.ResponseFormat{Type: "json_object"}
+	}
+	return &dto.ResponseFormat{Type: "json_schema", JsonSchema: jsonSchema}
+}
+
+// geminiToolConfigToOpenAI 将 functionCallingConfig.mode 转换为 tool_choice
+func geminiToolConfigToOpenAI(toolConfig *dto.ToolConfig) any {
+	if toolConfig.FunctionCallingConfig == nil {
+		return nil
+	}
+	switch strings.ToUpper(string(toolConfig.FunctionCallingConfig.Mode)) {
+	case "AUTO":
+		return "auto"
+	case "NONE":
+		return "none"
+	case "ANY", "VALIDATED":
+		if names := toolConfig.FunctionCallingConfig.AllowedFunctionNames; len(names) == 1 {
+			return map[string]any{
+				"type": "function",
+				"function": map[string]any{
+					"name": names[0],
+				},
+			}
+		}
+		return "required"
+	default:
+		return nil
+	}
+}
+
+func isGeminiModel(modelName string) bool {
+	return strings.Contains(strings.ToLower(modelName), "gemini")
+}
+
 func convertGeminiRoleToOpenAI(geminiRole string) string {
 	switch geminiRole {
 	case "user":
@@ -808,12 +1176,8 @@ func extractTextFromGeminiParts(parts []dto.GeminiPart) string {
 // ResponseOpenAI2Gemini 将 OpenAI 响应转换为 Gemini 格式
 func ResponseOpenAI2Gemini(openAIResponse *dto.OpenAITextResponse, info *relaycommon.RelayInfo) *dto.GeminiChatResponse {
 	geminiResponse := &dto.GeminiChatResponse{
-		Candidates: make([]dto.GeminiChatCandidate, 0, len(openAIResponse.Choices)),
-		UsageMetadata: dto.GeminiUsageMetadata{
-			PromptTokenCount:     openAIResponse.PromptTokens,
-			CandidatesTokenCount: openAIResponse.CompletionTokens,
-			TotalTokenCount:      openAIResponse.PromptTokens + openAIResponse.CompletionTokens,
-		},
+		Candidates:    make([]dto.GeminiChatCandidate, 0, len(openAIResponse.Choices)),
+		UsageMetadata: buildGeminiUsageFromOpenAIUsage(&openAIResponse.Usage),
 	}
 
 	for _, choice := range openAIResponse.Choices {
@@ -823,58 +1187,32 @@ func ResponseOpenAI2Gemini(openAIResponse *dto.OpenAITextResponse, info *relayco
 		}
 
 		// 设置结束原因
-		var finishReason string
-		switch choice.FinishReason {
-		case "stop":
-			finishReason = "STOP"
-		case "length":
-			finishReason = "MAX_TOKENS"
-		case "content_filter":
-			finishReason = "SAFETY"
-		case "tool_calls":
-			finishReason = "STOP"
-		default:
-			finishReason = "STOP"
-		}
+		finishReason := finishReasonOpenAI2Gemini(choice.FinishReason)
 		candidate.FinishReason = &finishReason
 
-		// 转换消息内容
+		// 转换消息内容，按 Gemini 的顺序输出：thought -> text -> functionCall
 		content := dto.GeminiChatContent{
 			Role:  "model",
 			Parts: make([]dto.GeminiPart, 0),
 		}
-
-		// 处理工具调用
-		toolCalls := choice.Message.ParseToolCalls()
-		if len(toolCalls) > 0 {
-			for _, toolCall := range toolCalls {
-				// 解析参数
-				var args map[string]interface{}
-				if toolCall.Function.Arguments != "" {
-					if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
-						args = map[string]interface{}{"arguments": toolCall.Function.Arguments}
-					}
-				} else {
-					args = make(map[string]interface{})
-				}
-
-				part := dto.GeminiPart{
-					FunctionCall: &dto.FunctionCall{
-						FunctionName: toolCall.Function.Name,
-						Arguments:    args,
-					},
-				}
-				content.Parts = append(content.Parts, part)
-			}
-		} else {
-			// 处理文本内容
-			textContent := choice.Message.StringContent()
-			if textContent != "" {
-				part := dto.GeminiPart{
-					Text: textContent,
-				}
-				content.Parts = append(content.Parts, part)
-			}
+		if reasoning := choice.Message.GetReasoningContent(); reasoning != "" {
+			content.Parts = append(content.Parts, dto.GeminiPart{
+				Text:    reasoning,
+				Thought: true,
+			})
+		}
+		if textContent := choice.Message.StringContent(); textContent != "" {
+			content.Parts = append(content.Parts, dto.GeminiPart{
+				Text: textContent,
+			})
+		}
+		for _, toolCall := range choice.Message.ParseToolCalls() {
+			content.Parts = append(content.Parts, dto.GeminiPart{
+				FunctionCall: &dto.FunctionCall{
+					FunctionName: toolCall.Function.Name,
+					Arguments:    parseToolCallArguments(toolCall.Function.Arguments),
+				},
+			})
 		}
 
 		candidate.Content = content
@@ -885,20 +1223,30 @@ func ResponseOpenAI2Gemini(openAIResponse *dto.OpenAITextResponse, info *relayco
 }
 
 // StreamResponseOpenAI2Gemini 将 OpenAI 流式响应转换为 Gemini 格式
+// Gemini 的 functionCall 必须携带完整参数，因此工具调用的增量会缓存在 info.GeminiConvertInfo 中，
+// 直到收到 finish_reason 时一次性输出
 func StreamResponseOpenAI2Gemini(openAIResponse *dto.ChatCompletionsStreamResponse, info *relaycommon.RelayInfo) *dto.GeminiChatResponse {
+	if info.GeminiConvertInfo == nil {
+		info.GeminiConvertInfo = &relaycommon.GeminiConvertInfo{}
+	}
+	convertInfo := info.GeminiConvertInfo
+
 	// 检查是否有实际内容或结束标志
 	hasContent := false
 	hasFinishReason := false
 	for _, choice := range openAIResponse.Choices {
-		if len(choice.Delta.GetContentString()) > 0 || (choice.Delta.ToolCalls != nil && len(choice.Delta.ToolCalls) > 0) {
+		if len(choice.Delta.GetContentString()) > 0 || len(choice.Delta.GetReasoningContent()) > 0 {
 			hasContent = true
 		}
+		for i, toolCall := range choice.Delta.ToolCalls {
+			convertInfo.AppendToolCallDelta(toolCall, i)
+		}
 		if choice.FinishReason != nil {
 			hasFinishReason = true
 		}
 	}
 
-	// 如果没有实际内容且没有结束标志，跳过。主要针对 openai 流响应开头的空数据
+	// 如果没有实际内容且没有结束标志，跳过。主要针对 openai 流响应开头的空数据以及工具调用的中间增量
 	if !hasContent && !hasFinishReason {
 		return nil
 	}
@@ -913,9 +1261,7 @@ func StreamResponseOpenAI2Gemini(openAIResponse *dto.ChatCompletionsStreamRespon
 	}
 
 	if openAIResponse.Usage != nil {
-		geminiResponse.UsageMetadata.PromptTokenCount = openAIResponse.Usage.PromptTokens
-		geminiResponse.UsageMetadata.CandidatesTokenCount = openAIResponse.Usage.CompletionTokens
-		geminiResponse.UsageMetadata.TotalTokenCount = openAIResponse.Usage.TotalTokens
+		geminiResponse.UsageMetadata = buildGeminiUsageFromOpenAIUsage(openAIResponse.Usage)
 	}
 
 	for _, choice := range openAIResponse.Choices {
@@ -924,59 +1270,34 @@ func StreamResponseOpenAI2Gemini(openAIResponse *dto.ChatCompletionsStreamRespon
 			SafetyRatings: []dto.GeminiChatSafetyRating{},
 		}
 
-		// 设置结束原因
-		if choice.FinishReason != nil {
-			var finishReason string
-			switch *choice.FinishReason {
-			case "stop":
-				finishReason = "STOP"
-			case "length":
-				finishReason = "MAX_TOKENS"
-			case "content_filter":
-				finishReason = "SAFETY"
-			case "tool_calls":
-				finishReason = "STOP"
-			default:
-				finishReason = "STOP"
-			}
-			candidate.FinishReason = &finishReason
-		}
-
 		// 转换消息内容
 		content := dto.GeminiChatContent{
 			Role:  "model",
 			Parts: make([]dto.GeminiPart, 0),
 		}
+		if reasoning := choice.Delta.GetReasoningContent(); reasoning != "" {
+			content.Parts = append(content.Parts, dto.GeminiPart{
+				Text:    reasoning,
+				Thought: true,
+			})
+		}
+		if textContent := choice.Delta.GetContentString(); textContent != "" {
+			content.Parts = append(content.Parts, dto.GeminiPart{
+				Text: textContent,
+			})
+		}
 
-		// 处理工具调用
-		if choice.Delta.ToolCalls != nil {
-			for _, toolCall := range choice.Delta.ToolCalls {
-				// 解析参数
-				var args map[string]interface{}
-				if toolCall.Function.Arguments != "" {
-					if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
-						args = map[string]interface{}{"arguments": toolCall.Function.Arguments}
-					}
-				} else {
-					args = make(map[string]interface{})
-				}
-
-				part := dto.GeminiPart{
+		// 设置结束原因，并输出缓存的完整工具调用
+		if choice.FinishReason != nil {
+			finishReason := finishReasonOpenAI2Gemini(*choice.FinishReason)
+			candidate.FinishReason = &finishReason
+			for _, toolCall := range convertInfo.FlushToolCalls() {
+				content.Parts = append(content.Parts, dto.GeminiPart{
 					FunctionCall: &dto.FunctionCall{
 						FunctionName: toolCall.Function.Name,
-						Arguments:    args,
+						Arguments:    parseToolCallArguments(toolCall.Function.Arguments),
 					},
-				}
-				content.Parts = append(content.Parts, part)
-			}
-		} else {
-			// 处理文本内容
-			textContent := choice.Delta.GetContentString()
-			if textContent != "" {
-				part := dto.GeminiPart{
-					Text: textContent,
-				}
-				content.Parts = append(content.Parts, part)
+				})
 			}
 		}
 
@@ -986,3 +1307,43 @@ func StreamResponseOpenAI2Gemini(openAIResponse *dto.ChatCompletionsStreamRespon
 
 	return geminiResponse
 }
+
+func finishReasonOpenAI2Gemini(reason string) string {
+	switch reason {
+	case "length":
+		return "MAX_TOKENS"
+	case "content_filter":
+		return "SAFETY"
+	default:
+		// stop / tool_calls / function_call 在 Gemini 中均为 STOP
+		return "STOP"
+	}
+}
+
+// parseToolCallArguments 解析工具调用参数，非 JSON 对象时包装为 {"arguments": "..."}
+func parseToolCallArguments(arguments string) map[string]interface{} {
+	args := make(map[string]interface{})
+	if arguments == "" {
+		return args
+	}
+	if err := common.Unmarshal([]byte(arguments), &args); err != nil {
+		return map[string]interface{}{"arguments": arguments}
+	}
+	return args
+}
+
+// buildGeminiUsageFromOpenAIUsage 转换 usage，OpenAI 的 completion_tokens 包含推理 token，Gemini 单独计为 thoughtsTokenCount
+func buildGeminiUsageFromOpenAIUsage(usage *dto.Usage) dto.GeminiUsageMetadata {
+	reasoningTokens := usage.CompletionTokenDetails.ReasoningTokens
+	totalTokens := usage.TotalTokens
+	if totalTokens == 0 {
+		totalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	return dto.GeminiUsageMetadata{
+		PromptTokenCount:        usage.PromptTokens,
+		CandidatesTokenCount:    usage.CompletionTokens - reasoningTokens,
+		ThoughtsTokenCount:      reasoningTokens,
+		CachedContentTokenCount: usage.PromptTokensDetails.CachedTokens,
+		TotalTokenCount:         totalTokens,
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/stretchr/testify/require"
+)
+
+// 使用 go test ./service -run TestConvertGolden -update 重新生成 golden 文件
+var updateConvertGolden = flag.Bool("update", false, "update convert golden files")
+
+// convertGoldenCase 是 testdata/convert/<kind>/<name>.json 的结构，
+// 期望输出保存在同目录的 <name>.golden.json
+type convertGoldenCase struct {
+	// 上游模型名，影响 Gemini 专有字段是否透传
+	Model string          `json:"model"`
+	Input json.RawMessage `json:"input"`
+}
+
+var convertGoldenRunners = map[string]func(t *testing.T, info *relaycommon.RelayInfo, input json.RawMessage) any{
+	"claude_to_openai": func(t *testing.T, info *relaycommon.RelayInfo, input json.RawMessage) any {
+		var request dto.ClaudeRequest
+		require.NoError(t, common.Unmarshal(input, &request))
+		info.OriginModelName = request.Model
+		converted, err := ClaudeToOpenAIRequest(request, info)
+		require.NoError(t, err)
+		return converted
+	},
+	"gemini_to_openai": func(t *testing.T, info *relaycommon.RelayInfo, input json.RawMessage) any {
+		var request dto.GeminiChatRequest
+		require.NoError(t, common.Unmarshal(input, &request))
+		converted, err := GeminiToOpenAIRequest(&request, info)
+		require.NoError(t, err)
+		return converted
+	},
+	"openai_to_claude": func(t *testing.T, info *relaycommon.RelayInfo, input json.RawMessage) any {
+		var response dto.OpenAITextResponse
+		require.NoError(t, common.Unmarshal(input, &response))
+		return ResponseOpenAI2Claude(&response, info)
+	},
+	"openai_to_gemini": func(t *testing.T, info *relaycommon.RelayInfo, input json.RawMessage) any {
+		var response dto.OpenAITextResponse
+		require.NoError(t, common.Unmarshal(input, &response))
+		return ResponseOpenAI2Gemini(&response, info)
+	},
+	"openai_stream_to_claude": func(t *testing.T, info *relaycommon.RelayInfo, input json.RawMessage) any {
+		info.ClaudeConvertInfo = &relaycommon.ClaudeConvertInfo{LastMessagesType: relaycommon.LastMessageTypeNone}
+		events := make([]*dto.ClaudeResponse, 0)
+		for _, chunk := range parseStreamChunks(t, input) {
+			info.SendResponseCount++
+			if chunk.Usage != nil {
+				info.ClaudeConvertInfo.Usage = chunk.Usage
+			}
+			events = append(events, StreamResponseOpenAI2Claude(chunk, info)...)
+		}
+		return events
+	},
+	"openai_stream_to_gemini": func(t *testing.T, info *relaycommon.RelayInfo, input json.RawMessage) any {
+		events := make([]*dto.GeminiChatResponse, 0)
+		for _, chunk := range parseStreamChunks(t, input) {
+			if event := StreamResponseOpenAI2Gemini(chunk, info); event != nil {
+				events = append(events, event)
+			}
+		}
+		return events
+	},
+}
+
+func parseStreamChunks(t *testing.T, input json.RawMessage) []*dto.ChatCompletionsStreamResponse {
+	var chunks []*dto.ChatCompletionsStreamResponse
+	require.NoError(t, common.Unmarshal(input, &chunks))
+	return chunks
+}
+
+func TestConvertGolden(t *testing.T) {
+	for kind, run := range convertGoldenRunners {
+		files, err := filepath.Glob(filepath.Join("testdata", "convert", kind, "*.json"))
+		require.NoError(t, err)
+		for _, file := range files {
+			if strings.HasSuffix(file, ".golden.json") {
+				continue
+			}
+			name := kind + "/" + strings.TrimSuffix(filepath.Base(file), ".json")
+			t.Run(name, func(t *testing.T) {
+				raw, err := os.ReadFile(file)
+				require.NoError(t, err)
+				var testCase convertGoldenCase
+				require.NoError(t, common.Unmarshal(raw, &testCase))
+
+				info := &relaycommon.RelayInfo{
+					ChannelMeta: &relaycommon.ChannelMeta{UpstreamModelName: testCase.Model},
+				}
+				actual, err := json.MarshalIndent(run(t, info, testCase.Input), "", "  ")
+				require.NoError(t, err)
+				actual = append(actual, '\n')
+
+				goldenFile := strings.TrimSuffix(file, ".json") + ".golden.json"
+				if *updateConvertGolden {
+					require.NoError(t, os.WriteFile(goldenFile, actual, 0o644))
+					return
+				}
+				expected, err := os.ReadFile(goldenFile)
+				require.NoError(t, err, "missing golden file, run with -update to create it")
+				require.Equal(t, string(bytes.TrimSpace(expected)), string(bytes.TrimSpace(actual)))
+			})
+		}
+	}
+}
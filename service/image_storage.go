@@ -0,0 +1,259 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	imageStorageDir         = "new-api-images"
+	imageStorageRoutePrefix = "/v1/images/generated/"
+)
+
+var (
+	generatedImageNamePattern = regexp.MustCompile(`^[a-f0-9]{32}\.(png|jpg|jpeg|webp|gif)$`)
+	imageStorageCleanupMu     sync.Mutex
+	imageStorageLastCleanup   time.Time
+)
+
+// GetImageStorageDir 获取生成图片的本地存储目录
+func GetImageStorageDir() string {
+	if constant.ImageStoragePath != "" {
+		return constant.ImageStoragePath
+	}
+	cachePath := common.GetDiskCachePath()
+	if cachePath == "" {
+		cachePath = os.TempDir()
+	}
+	return filepath.Join(cachePath, imageStorageDir)
+}
+
+func imageExtFromMimeType(mimeType string) string {
+	switch strings.ToLower(mimeType) {
+	case "image/jpeg", "image/jpg":
+		return "jpg"
+	case "image/webp":
+		return "webp"
+	case "image/gif":
+		return "gif"
+	default:
+		return "png"
+	}
+}
+
+// SaveGeneratedImage 将图片字节保存到本地存储，返回可公开访问的 URL
+func SaveGeneratedImage(c *gin.Context, data []byte, mimeType string) (string, error) {
+	dir := GetImageStorageDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create image storage directory: %w", err)
+	}
+	name := strings.ReplaceAll(uuid.New().String(), "-", "") + "." + imageExtFromMimeType(mimeType)
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write image file: %w", err)
+	}
+	cleanupGeneratedImagesIfNeeded()
+	return generatedImageURL(c, name), nil
+}
+
+func generatedImageURL(c *gin.Context, name string) string {
+	serverAddress := strings.TrimSuffix(system_setting.ServerAddress, "/")
+	if serverAddress == "" && c != nil && c.Request != nil {
+		scheme := "http"
+		if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		serverAddress = fmt.Sprintf("%s://%s", scheme, c.Request.Host)
+	}
+	expires := signedURLExpires()
+	signature := common.GenerateHMAC(generatedImageSignaturePayload(name, expires))
+	return fmt.Sprintf("%s%s%s?expires=%d&signature=%s", serverAddress, imageStorageRoutePrefix, name, expires, signature)
+}
+
+func generatedImageSignaturePayload(name string, expires int64) string {
+	return fmt.Sprintf("image:%s:%d", name, expires)
+}
+
+// VerifyGeneratedImageSignature 校验本地生成图片链接的签名与有效期，与媒体下载链接使用相同的签名方案
+func VerifyGeneratedImageSignature(name string, expiresStr string, signature string) bool {
+	return verifySignedPayload(func(expires int64) string {
+		return generatedImageSignaturePayload(name, expires)
+	}, expiresStr, signature)
+}
+
+// GetGeneratedImagePath 校验文件名并返回本地存储图片的路径
+func GetGeneratedImagePath(name string) (string, error) {
+	if !generatedImageNamePattern.MatchString(name) {
+		return "", errors.New("invalid image name")
+	}
+	path := filepath.Join(GetImageStorageDir(), name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if constant.ImageStorageRetentionHours > 0 &&
+		time.Since(info.ModTime()) > time.Duration(constant.ImageStorageRetentionHours)*time.Hour {
+		return "", os.ErrNotExist
+	}
+	return path, nil
+}
+
+// cleanupGeneratedImagesIfNeeded 每小时最多清理一次过期图片
+func cleanupGeneratedImagesIfNeeded() {
+	if constant.ImageStorageRetentionHours <= 0 {
+		return
+	}
+	imageStorageCleanupMu.Lock()
+	if time.Since(imageStorageLastCleanup) < time.Hour {
+		imageStorageCleanupMu.Unlock()
+		return
+	}
+	imageStorageLastCleanup = time.Now()
+	imageStorageCleanupMu.Unlock()
+
+	go func() {
+		dir := GetImageStorageDir()
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return
+		}
+		maxAge := time.Duration(constant.ImageStorageRetentionHours) * time.Hour
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			if time.Since(info.ModTime()) > maxAge {
+				_ = os.Remove(filepath.Join(dir, entry.Name()))
+			}
+		}
+	}()
+}
+
+// NormalizeImageResponse 按请求的 response_format 统一图片输出：
+// 要求 url 但上游只返回字节时落盘并返回本地 URL；要求 b64_json 但上游只返回 URL 时下载并编码。
+func NormalizeImageResponse(c *gin.Context, info *relaycommon.RelayInfo, response *dto.ImageResponse) {
+	if response == nil || info == nil {
+		return
+	}
+	if format := imageResponseFormat(info); format != "" {
+		for i := range response.Data {
+			data := &response.Data[i]
+			data.Url, data.B64Json = normalizeImageData(c, info, format, data.Url, data.B64Json)
+		}
+	}
+	StoreImageResponseMedia(c, info, response)
+}
+
+// NormalizeImageResponseBody 对透传的 OpenAI 兼容图片响应体做同样的处理，保留上游返回的其他字段
+func NormalizeImageResponseBody(c *gin.Context, info *relaycommon.RelayInfo, body []byte) []byte {
+	if info == nil {
+		return body
+	}
+	if format := imageResponseFormat(info); format != "" {
+		var response map[string]any
+		if err := common.Unmarshal(body, &response); err == nil {
+			items, _ := response["data"].([]any)
+			changed := false
+			for _, item := range items {
+				data, ok := item.(map[string]any)
+				if !ok {
+					continue
+				}
+				url, _ := data["url"].(string)
+				b64, _ := data["b64_json"].(string)
+				newURL, newB64 := normalizeImageData(c, info, format, url, b64)
+				if newURL == url && newB64 == b64 {
+					continue
+				}
+				changed = true
+				setOrDelete(data, "url", newURL)
+				setOrDelete(data, "b64_json", newB64)
+			}
+			if changed {
+				if newBody, err := common.Marshal(response); err == nil {
+					body = newBody
+				}
+			}
+		}
+	}
+	return StoreImageResponseBody(c, info, body)
+}
+
+func setOrDelete(m map[string]any, key string, value string) {
+	if value == "" {
+		delete(m, key)
+		return
+	}
+	m[key] = value
+}
+
+func imageResponseFormat(info *relaycommon.RelayInfo) string {
+	imageRequest, ok := info.Request.(*dto.ImageRequest)
+	if !ok || imageRequest == nil {
+		return ""
+	}
+	return imageRequest.ResponseFormat
+}
+
+// normalizeImageData 按 format 转换单张图片，失败时保持原样
+func normalizeImageData(c *gin.Context, info *relaycommon.RelayInfo, format string, url string, b64 string) (string, string) {
+	switch format {
+	case "url":
+		if url != "" || b64 == "" {
+			return url, b64
+		}
+		// 开启媒体转存时存入媒体存储，返回签名链接
+		if shouldStoreImageResponses() {
+			if stored, ok := storeImageB64(c, info.UserId, b64); ok {
+				return stored, ""
+			}
+		}
+		mimeType, rawBase64, err := DecodeBase64FileData(b64)
+		if err != nil {
+			logger.LogError(c, fmt.Sprintf("decode generated image failed: %s", err.Error()))
+			return url, b64
+		}
+		imageBytes, err := base64.StdEncoding.DecodeString(rawBase64)
+		if err != nil {
+			logger.LogError(c, fmt.Sprintf("decode generated image failed: %s", err.Error()))
+			return url, b64
+		}
+		saved, err := SaveGeneratedImage(c, imageBytes, mimeType)
+		if err != nil {
+			logger.LogError(c, fmt.Sprintf("save generated image failed: %s", err.Error()))
+			return url, b64
+		}
+		return saved, ""
+	case "b64_json":
+		if b64 != "" || url == "" {
+			return url, b64
+		}
+		_, data, err := GetImageFromUrl(url)
+		if err != nil {
+			logger.LogError(c, fmt.Sprintf("download generated image failed: %s", err.Error()))
+			return url, b64
+		}
+		return "", data
+	}
+	return url, b64
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeImageResponseBody_SavesSignedURL(t *testing.T) {
+	origin := constant.ImageStoragePath
+	constant.ImageStoragePath = t.TempDir()
+	t.Cleanup(func() { constant.ImageStoragePath = origin })
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "http://gateway.local/v1/images/generations", nil)
+	info := &relaycommon.RelayInfo{Request: &dto.ImageRequest{ResponseFormat: "url"}}
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1, 1))))
+	b64 := base64.StdEncoding.EncodeToString(buf.Bytes())
+	body := NormalizeImageResponseBody(c, info, []byte(`{"created":1,"data":[{"b64_json":"`+b64+`"}],"usage":{"total_tokens":3}}`))
+
+	var response map[string]any
+	require.NoError(t, common.Unmarshal(body, &response))
+	require.NotNil(t, response["usage"])
+	data := response["data"].([]any)[0].(map[string]any)
+	require.NotContains(t, data, "b64_json")
+
+	// 返回的链接带签名，篡改文件名或缺少签名都无法访问
+	parsed, err := url.Parse(data["url"].(string))
+	require.NoError(t, err)
+	name := strings.TrimPrefix(parsed.Path, imageStorageRoutePrefix)
+	query := parsed.Query()
+	require.True(t, VerifyGeneratedImageSignature(name, query.Get("expires"), query.Get("signature")))
+	require.False(t, VerifyGeneratedImageSignature("0"+name[1:], query.Get("expires"), query.Get("signature")))
+	require.False(t, VerifyGeneratedImageSignature(name, query.Get("expires"), ""))
+
+	_, err = GetGeneratedImagePath(name)
+	require.NoError(t, err)
+}
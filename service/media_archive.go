@@ -0,0 +1,321 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel/task/taskcommon"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	mediaRetentionTickInterval = time.Hour
+	mediaRetentionBatchSize    = 200
+)
+
+var (
+	mediaRetentionOnce    sync.Once
+	mediaRetentionRunning atomic.Bool
+)
+
+// OpenTaskMediaFunc 由 main 包注入，用于获取需要渠道鉴权的任务视频内容（如 Sora、Gemini、Vertex 的代理链接或 data URL）
+var OpenTaskMediaFunc func(ctx context.Context, task *model.Task) (io.ReadCloser, string, error)
+
+// ArchiveTaskMediaAsync 在后台转存成功任务的结果文件
+func ArchiveTaskMediaAsync(task *model.Task) {
+	if !system_setting.GetMediaStorageSettings().Enabled || task == nil {
+		return
+	}
+	gopool.Go(func() {
+		ArchiveTaskMedia(context.Background(), task)
+	})
+}
+
+// ArchiveTaskMedia 下载任务结果（视频或 Suno 音频）并转存到网关存储，已转存过的任务直接跳过
+func ArchiveTaskMedia(ctx context.Context, task *model.Task) {
+	if !system_setting.GetMediaStorageSettings().Enabled || task.Status != model.TaskStatusSuccess {
+		return
+	}
+	existing, err := model.GetMediaObjectsByTaskId(task.TaskID)
+	if err != nil || len(existing) > 0 {
+		return
+	}
+	switch task.Platform {
+	case constant.TaskPlatformMidjourney:
+		return
+	case constant.TaskPlatformSuno:
+		var songs []dto.SunoSong
+		if err := common.Unmarshal(task.Data, &songs); err != nil {
+			return
+		}
+		for _, song := range songs {
+			archiveTaskURL(ctx, task, model.MediaKindAudio, song.AudioURL)
+			archiveTaskURL(ctx, task, model.MediaKindVideo, song.VideoURL)
+			archiveTaskURL(ctx, task, model.MediaKindImage, song.ImageURL)
+		}
+	default:
+		resultURL := strings.TrimSpace(task.GetResultURL())
+		proxyURL := taskcommon.BuildProxyURL(task.TaskID)
+		if resultURL != "" && resultURL != proxyURL && !strings.HasPrefix(resultURL, "data:") {
+			archiveTaskURL(ctx, task, model.MediaKindVideo, resultURL)
+			return
+		}
+		// 结果需要渠道鉴权才能获取，走视频代理的取数逻辑
+		if OpenTaskMediaFunc == nil {
+			return
+		}
+		body, contentType, err := OpenTaskMediaFunc(ctx, task)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("archive media for task %s failed: %s", task.TaskID, err.Error()))
+			return
+		}
+		defer body.Close()
+		storeTaskMedia(ctx, task, model.MediaKindVideo, proxyURL, contentType, body)
+	}
+}
+
+func archiveTaskURL(ctx context.Context, task *model.Task, kind string, sourceURL string) {
+	sourceURL = strings.TrimSpace(sourceURL)
+	if sourceURL == "" {
+		return
+	}
+	resp, err := DoDownloadRequest(sourceURL, "archive task media")
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("archive media for task %s failed: %s", task.TaskID, err.Error()))
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		logger.LogWarn(ctx, fmt.Sprintf("archive media for task %s failed: upstream returned status %d", task.TaskID, resp.StatusCode))
+		return
+	}
+	storeTaskMedia(ctx, task, kind, sourceURL, resp.Header.Get("Content-Type"), resp.Body)
+}
+
+func storeTaskMedia(ctx context.Context, task *model.Task, kind string, sourceURL string, contentType string, body io.Reader) {
+	obj := &model.MediaObject{
+		UserId:      task.UserId,
+		TaskId:      task.TaskID,
+		Kind:        kind,
+		SourceURL:   sourceURL,
+		ContentType: contentType,
+	}
+	if err := StoreMedia(ctx, obj, body); err != nil {
+		if errors.Is(err, ErrMediaQuotaExceeded) {
+			logger.LogInfo(ctx, fmt.Sprintf("media storage quota exceeded for user %d, keep upstream url of task %s", task.UserId, task.TaskID))
+			return
+		}
+		logger.LogWarn(ctx, fmt.Sprintf("archive media for task %s failed: %s", task.TaskID, err.Error()))
+		return
+	}
+	logger.LogInfo(ctx, fmt.Sprintf("archived %s of task %s as media #%d (%d bytes)", kind, task.TaskID, obj.Id, obj.Size))
+}
+
+// NewTaskMediaReplacer 构造将上游链接替换为签名链接的替换器，任务没有转存文件时返回 nil
+func NewTaskMediaReplacer(task *model.Task) *strings.Replacer {
+	if task == nil || task.Status != model.TaskStatusSuccess || !system_setting.GetMediaStorageSettings().Enabled {
+		return nil
+	}
+	objects, err := model.GetMediaObjectsByTaskId(task.TaskID)
+	if err != nil || len(objects) == 0 {
+		return nil
+	}
+	pairs := make([]string, 0, len(objects)*4)
+	for _, obj := range objects {
+		if obj.SourceURL == "" {
+			continue
+		}
+		signed := SignMediaURL(obj.Id)
+		pairs = append(pairs, obj.SourceURL, signed)
+		// JSON 中的链接可能被转义（如 & 转义为 \u0026），一并替换
+		escapedSource, _ := common.Marshal(obj.SourceURL)
+		escapedSigned, _ := common.Marshal(signed)
+		if s := strings.Trim(string(escapedSource), `"`); s != obj.SourceURL {
+			pairs = append(pairs, s, strings.Trim(string(escapedSigned), `"`))
+		}
+	}
+	if len(pairs) == 0 {
+		return nil
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// RewriteTaskMediaURLs 将响应体中已转存的上游链接替换为网关签名链接
+func RewriteTaskMediaURLs(task *model.Task, body []byte) []byte {
+	replacer := NewTaskMediaReplacer(task)
+	if replacer == nil || len(body) == 0 {
+		return body
+	}
+	return []byte(replacer.Replace(string(body)))
+}
+
+// GetTaskPublicResultURL 返回对用户展示的结果链接，已转存时为签名链接
+func GetTaskPublicResultURL(task *model.Task) string {
+	resultURL := task.GetResultURL()
+	if replacer := NewTaskMediaReplacer(task); replacer != nil {
+		return replacer.Replace(resultURL)
+	}
+	return resultURL
+}
+
+// GetTaskArchivedVideo 返回任务已转存的视频文件，没有时返回 nil
+func GetTaskArchivedVideo(task *model.Task) *model.MediaObject {
+	objects, err := model.GetMediaObjectsByTaskId(task.TaskID)
+	if err != nil {
+		return nil
+	}
+	for _, obj := range objects {
+		if obj.Kind == model.MediaKindVideo {
+			return obj
+		}
+	}
+	return nil
+}
+
+// StoreImageResponseMedia 将图片接口返回的 b64_json 转存并替换为签名链接（需开启 store_image_responses）
+func StoreImageResponseMedia(c *gin.Context, info *relaycommon.RelayInfo, response *dto.ImageResponse) {
+	if !shouldStoreImageResponses() || response == nil || info == nil {
+		return
+	}
+	for i := range response.Data {
+		data := &response.Data[i]
+		if data.B64Json == "" {
+			continue
+		}
+		if url, ok := storeImageB64(c, info.UserId, data.B64Json); ok {
+			data.Url = url
+			data.B64Json = ""
+		}
+	}
+}
+
+// StoreImageResponseBody 对透传的图片响应体做同样的转存，保留上游返回的其他字段
+func StoreImageResponseBody(c *gin.Context, info *relaycommon.RelayInfo, body []byte) []byte {
+	if !shouldStoreImageResponses() || info == nil || !bytes.Contains(body, []byte(`"b64_json"`)) {
+		return body
+	}
+	var response map[string]any
+	if err := common.Unmarshal(body, &response); err != nil {
+		return body
+	}
+	items, _ := response["data"].([]any)
+	changed := false
+	for _, item := range items {
+		data, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		b64, _ := data["b64_json"].(string)
+		if b64 == "" {
+			continue
+		}
+		if url, ok := storeImageB64(c, info.UserId, b64); ok {
+			data["url"] = url
+			delete(data, "b64_json")
+			changed = true
+		}
+	}
+	if !changed {
+		return body
+	}
+	newBody, err := common.Marshal(response)
+	if err != nil {
+		return body
+	}
+	return newBody
+}
+
+func shouldStoreImageResponses() bool {
+	settings := system_setting.GetMediaStorageSettings()
+	return settings.Enabled && settings.StoreImageResponses
+}
+
+func storeImageB64(c *gin.Context, userId int, b64 string) (string, bool) {
+	mimeType, rawBase64, err := DecodeBase64FileData(b64)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("decode generated image failed: %s", err.Error()))
+		return "", false
+	}
+	obj := &model.MediaObject{
+		UserId:      userId,
+		Kind:        model.MediaKindImage,
+		ContentType: mimeType,
+	}
+	decoder := base64.NewDecoder(base64.StdEncoding, strings.NewReader(rawBase64))
+	if err = StoreMedia(c, obj, decoder); err != nil {
+		if !errors.Is(err, ErrMediaQuotaExceeded) {
+			logger.LogError(c, fmt.Sprintf("store generated image failed: %s", err.Error()))
+		}
+		return "", false
+	}
+	return SignMediaURL(obj.Id), true
+}
+
+// StartMediaRetentionTask 定时清理超过保留期的媒体文件
+func StartMediaRetentionTask() {
+	mediaRetentionOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			ticker := time.NewTicker(mediaRetentionTickInterval)
+			defer ticker.Stop()
+
+			runMediaRetentionOnce()
+			for range ticker.C {
+				runMediaRetentionOnce()
+			}
+		})
+	})
+}
+
+func runMediaRetentionOnce() {
+	if !mediaRetentionRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer mediaRetentionRunning.Store(false)
+
+	ctx := context.Background()
+	deleted := 0
+	for {
+		objects, err := model.GetExpiredMediaObjects(time.Now().Unix(), mediaRetentionBatchSize)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("media retention task failed: %v", err))
+			return
+		}
+		failed := 0
+		for _, obj := range objects {
+			if err := DeleteMedia(ctx, obj); err != nil {
+				failed++
+				logger.LogWarn(ctx, fmt.Sprintf("delete expired media #%d failed: %v", obj.Id, err))
+				continue
+			}
+			deleted++
+		}
+		// 整批都删除失败时停止，避免死循环
+		if len(objects) < mediaRetentionBatchSize || failed == len(objects) {
+			break
+		}
+	}
+	if deleted > 0 {
+		logger.LogInfo(ctx, fmt.Sprintf("media retention task deleted %d expired files", deleted))
+	}
+}
@@ -0,0 +1,367 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/google/uuid"
+)
+
+const (
+	mediaStorageDir         = "new-api-media"
+	mediaStorageRoutePrefix = "/v1/media/"
+	s3UnsignedPayload       = "UNSIGNED-PAYLOAD"
+)
+
+var ErrMediaQuotaExceeded = errors.New("media storage quota exceeded")
+
+// MediaStorageBackend 媒体文件存储后端
+type MediaStorageBackend interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// GetMediaStorageBackend 按名称返回存储后端，配置取自当前的媒体存储设置
+func GetMediaStorageBackend(name string) (MediaStorageBackend, error) {
+	settings := system_setting.GetMediaStorageSettings()
+	switch name {
+	case "", system_setting.MediaStorageBackendLocal:
+		dir := settings.LocalPath
+		if dir == "" {
+			cachePath := common.GetDiskCachePath()
+			if cachePath == "" {
+				cachePath = os.TempDir()
+			}
+			dir = filepath.Join(cachePath, mediaStorageDir)
+		}
+		return &localMediaStorage{dir: dir}, nil
+	case system_setting.MediaStorageBackendS3:
+		if settings.S3Endpoint == "" || settings.S3Bucket == "" {
+			return nil, errors.New("s3 endpoint and bucket are required")
+		}
+		return &s3MediaStorage{
+			endpoint:  strings.TrimSuffix(settings.S3Endpoint, "/"),
+			region:    settings.S3Region,
+			bucket:    settings.S3Bucket,
+			pathStyle: settings.S3PathStyle,
+			credentials: aws.Credentials{
+				AccessKeyID:     settings.S3AccessKeyId,
+				SecretAccessKey: settings.S3Secret,
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown media storage backend: %s", name)
+	}
+}
+
+type localMediaStorage struct {
+	dir string
+}
+
+// path 校验 key 不会逃逸出存储目录
+func (s *localMediaStorage) path(key string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(key))
+	if cleaned == "." || filepath.IsAbs(cleaned) || strings.HasPrefix(cleaned, "..") {
+		return "", fmt.Errorf("invalid media key: %s", key)
+	}
+	return filepath.Join(s.dir, cleaned), nil
+}
+
+func (s *localMediaStorage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create media storage directory: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, body); err != nil {
+		f.Close()
+		_ = os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+func (s *localMediaStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+func (s *localMediaStorage) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// s3MediaStorage 通过 SigV4 签名的 HTTP 请求访问兼容 S3 协议的对象存储
+type s3MediaStorage struct {
+	endpoint    string
+	region      string
+	bucket      string
+	pathStyle   bool
+	credentials aws.Credentials
+}
+
+func (s *s3MediaStorage) objectURL(key string) (string, error) {
+	u, err := url.Parse(s.endpoint)
+	if err != nil {
+		return "", err
+	}
+	escapedKey := (&url.URL{Path: key}).EscapedPath()
+	if s.pathStyle {
+		return fmt.Sprintf("%s://%s/%s/%s", u.Scheme, u.Host, s.bucket, escapedKey), nil
+	}
+	return fmt.Sprintf("%s://%s.%s/%s", u.Scheme, s.bucket, u.Host, escapedKey), nil
+}
+
+func (s *s3MediaStorage) do(ctx context.Context, method string, key string, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	objectURL, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, objectURL, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("X-Amz-Content-Sha256", s3UnsignedPayload)
+	region := s.region
+	if region == "" {
+		region = "us-east-1"
+	}
+	if err := v4.NewSigner().SignHTTP(ctx, s.credentials, req, s3UnsignedPayload, "s3", region, time.Now()); err != nil {
+		return nil, err
+	}
+	resp, err := GetHttpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3 %s %s returned status %d: %s", method, key, resp.StatusCode, string(respBody))
+	}
+	return resp, nil
+}
+
+func (s *s3MediaStorage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	resp, err := s.do(ctx, http.MethodPut, key, body, size, contentType)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (s *s3MediaStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *s3MediaStorage) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0, "")
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func mediaSignaturePayload(id int64, expires int64) string {
+	return fmt.Sprintf("media:%d:%d", id, expires)
+}
+
+// signedURLExpires 签名链接的过期时间
+func signedURLExpires() int64 {
+	expireSeconds := system_setting.GetMediaStorageSettings().SignedURLExpireSeconds
+	if expireSeconds <= 0 {
+		expireSeconds = 3600
+	}
+	return time.Now().Unix() + int64(expireSeconds)
+}
+
+// verifySignedPayload 校验签名链接的有效期与签名，payload 由过期时间生成
+func verifySignedPayload(payload func(expires int64) string, expiresStr string, signature string) bool {
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil || expires < time.Now().Unix() {
+		return false
+	}
+	expected := common.GenerateHMAC(payload(expires))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// SignMediaURL 生成带过期时间的媒体下载链接
+func SignMediaURL(id int64) string {
+	expires := signedURLExpires()
+	signature := common.GenerateHMAC(mediaSignaturePayload(id, expires))
+	return fmt.Sprintf("%s%s%d?expires=%d&signature=%s",
+		strings.TrimSuffix(system_setting.ServerAddress, "/"), mediaStorageRoutePrefix, id, expires, signature)
+}
+
+// VerifyMediaSignature 校验媒体下载链接的签名与有效期
+func VerifyMediaSignature(id int64, expiresStr string, signature string) bool {
+	return verifySignedPayload(func(expires int64) string {
+		return mediaSignaturePayload(id, expires)
+	}, expiresStr, signature)
+}
+
+func mediaExtFromContentType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	switch mediaType {
+	case "video/mp4":
+		return ".mp4"
+	case "audio/mpeg":
+		return ".mp3"
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	}
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// StoreMedia 将 body 写入当前存储后端并登记 MediaObject。
+// 文件先落到临时文件以确定大小；超过单文件上限或用户剩余配额时返回 ErrMediaQuotaExceeded。
+// 配额为软限制：并发转存时可能略微超出。
+func StoreMedia(ctx context.Context, obj *model.MediaObject, body io.Reader) error {
+	settings := system_setting.GetMediaStorageSettings()
+	limit := settings.MaxFileSizeMB * 1024 * 1024
+	if settings.UserQuotaMB > 0 {
+		usage, err := model.GetUserMediaUsage(obj.UserId)
+		if err != nil {
+			return err
+		}
+		remaining := settings.UserQuotaMB*1024*1024 - usage
+		if limit <= 0 || remaining < limit {
+			limit = remaining
+		}
+		if limit <= 0 {
+			return ErrMediaQuotaExceeded
+		}
+	}
+	if limit <= 0 {
+		limit = 1<<63 - 2
+	}
+
+	tmp, err := os.CreateTemp("", "new-api-media-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	size, err := io.Copy(tmp, io.LimitReader(body, limit+1))
+	if err != nil {
+		return err
+	}
+	if size > limit {
+		return ErrMediaQuotaExceeded
+	}
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if obj.ContentType == "" || obj.ContentType == "application/octet-stream" {
+		head := make([]byte, 512)
+		n, _ := io.ReadFull(tmp, head)
+		obj.ContentType = http.DetectContentType(head[:n])
+		if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	backendName := settings.Backend
+	if backendName == "" {
+		backendName = system_setting.MediaStorageBackendLocal
+	}
+	backend, err := GetMediaStorageBackend(backendName)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	key := fmt.Sprintf("%s/%s/%s%s", obj.Kind, now.Format("2006/01/02"),
+		strings.ReplaceAll(uuid.New().String(), "-", ""), mediaExtFromContentType(obj.ContentType))
+	if backendName == system_setting.MediaStorageBackendS3 && settings.S3Prefix != "" {
+		key = strings.Trim(settings.S3Prefix, "/") + "/" + key
+	}
+	if err = backend.Put(ctx, key, tmp, size, obj.ContentType); err != nil {
+		return fmt.Errorf("failed to store media: %w", err)
+	}
+
+	obj.Backend = backendName
+	obj.StorageKey = key
+	obj.Size = size
+	obj.CreatedAt = now.Unix()
+	if settings.RetentionDays > 0 {
+		obj.ExpiresAt = now.Add(time.Duration(settings.RetentionDays) * 24 * time.Hour).Unix()
+	}
+	if err = obj.Insert(); err != nil {
+		_ = backend.Delete(ctx, key)
+		return err
+	}
+	return nil
+}
+
+// OpenMedia 打开已存储的媒体文件
+func OpenMedia(ctx context.Context, obj *model.MediaObject) (io.ReadCloser, error) {
+	backend, err := GetMediaStorageBackend(obj.Backend)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Get(ctx, obj.StorageKey)
+}
+
+// DeleteMedia 删除存储中的文件及其记录
+func DeleteMedia(ctx context.Context, obj *model.MediaObject) error {
+	backend, err := GetMediaStorageBackend(obj.Backend)
+	if err != nil {
+		return err
+	}
+	if err = backend.Delete(ctx, obj.StorageKey); err != nil {
+		return err
+	}
+	return model.DeleteMediaObjectById(obj.Id)
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/stretchr/testify/require"
+)
+
+func useMediaStorageSettings(t *testing.T, settings system_setting.MediaStorageSettings) {
+	t.Helper()
+	current := system_setting.GetMediaStorageSettings()
+	saved := *current
+	*current = settings
+	t.Cleanup(func() { *current = saved })
+}
+
+func TestStoreMediaLocalQuota(t *testing.T) {
+	truncate(t)
+	useMediaStorageSettings(t, system_setting.MediaStorageSettings{
+		Enabled:       true,
+		Backend:       system_setting.MediaStorageBackendLocal,
+		LocalPath:     t.TempDir(),
+		RetentionDays: 1,
+		UserQuotaMB:   1,
+		MaxFileSizeMB: 10,
+	})
+
+	obj := &model.MediaObject{UserId: 1, TaskId: "task_a", Kind: model.MediaKindVideo, ContentType: "video/mp4"}
+	require.NoError(t, StoreMedia(context.Background(), obj, strings.NewReader(strings.Repeat("a", 600*1024))))
+	require.NotZero(t, obj.Id)
+	require.Equal(t, int64(600*1024), obj.Size)
+	require.NotZero(t, obj.ExpiresAt)
+	require.True(t, strings.HasSuffix(obj.StorageKey, ".mp4"))
+
+	reader, err := OpenMedia(context.Background(), obj)
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	reader.Close()
+	require.NoError(t, err)
+	require.Len(t, content, 600*1024)
+
+	// 剩余配额不足以存下第二个文件
+	second := &model.MediaObject{UserId: 1, Kind: model.MediaKindImage}
+	require.ErrorIs(t, StoreMedia(context.Background(), second, strings.NewReader(strings.Repeat("b", 600*1024))), ErrMediaQuotaExceeded)
+	usage, err := model.GetUserMediaUsage(1)
+	require.NoError(t, err)
+	require.Equal(t, int64(600*1024), usage)
+
+	require.NoError(t, DeleteMedia(context.Background(), obj))
+	_, err = OpenMedia(context.Background(), obj)
+	require.Error(t, err)
+}
+
+func TestStoreMediaS3(t *testing.T) {
+	truncate(t)
+	InitHttpClient()
+	var mu sync.Mutex
+	objects := make(map[string][]byte)
+	// 模拟 MinIO：按路径风格存取对象，并要求请求带 SigV4 签名
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=minio/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			require.Equal(t, strconv.Itoa(len(body)), r.Header.Get("Content-Length"))
+			objects[r.URL.Path] = body
+		case http.MethodGet:
+			body, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(body)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	useMediaStorageSettings(t, system_setting.MediaStorageSettings{
+		Enabled:       true,
+		Backend:       system_setting.MediaStorageBackendS3,
+		S3Endpoint:    server.URL,
+		S3Bucket:      "media",
+		S3AccessKeyId: "minio",
+		S3Secret:      "minio-secret",
+		S3PathStyle:   true,
+		S3Prefix:      "gateway",
+		MaxFileSizeMB: 10,
+	})
+
+	obj := &model.MediaObject{UserId: 2, TaskId: "task_b", Kind: model.MediaKindAudio, SourceURL: "https://cdn.example.com/a.mp3?x=1&y=2"}
+	require.NoError(t, StoreMedia(context.Background(), obj, strings.NewReader("ID3 audio")))
+	require.Zero(t, obj.ExpiresAt)
+	require.True(t, strings.HasPrefix(obj.StorageKey, "gateway/audio/"))
+	require.Contains(t, objects, "/media/"+obj.StorageKey)
+
+	reader, err := OpenMedia(context.Background(), obj)
+	require.NoError(t, err)
+	content, _ := io.ReadAll(reader)
+	reader.Close()
+	require.Equal(t, "ID3 audio", string(content))
+
+	// 响应中的上游链接（含 JSON 转义形式）被替换为签名链接
+	task := &model.Task{TaskID: "task_b", Status: model.TaskStatusSuccess}
+	body := RewriteTaskMediaURLs(task, []byte(`{"audio_url":"https://cdn.example.com/a.mp3?x=1&y=2"}`))
+	require.NotContains(t, string(body), "cdn.example.com")
+	require.Contains(t, string(body), mediaStorageRoutePrefix+strconv.FormatInt(obj.Id, 10))
+
+	require.NoError(t, DeleteMedia(context.Background(), obj))
+	require.Empty(t, objects)
+}
+
+func TestMediaSignature(t *testing.T) {
+	signed := SignMediaURL(42)
+	idx := strings.Index(signed, "?")
+	require.Positive(t, idx)
+	query := signed[idx+1:]
+	var expires, signature string
+	for _, part := range strings.Split(query, "&") {
+		kv := strings.SplitN(part, "=", 2)
+		switch kv[0] {
+		case "expires":
+			expires = kv[1]
+		case "signature":
+			signature = kv[1]
+		}
+	}
+	require.True(t, VerifyMediaSignature(42, expires, signature))
+	require.False(t, VerifyMediaSignature(43, expires, signature))
+	require.False(t, VerifyMediaSignature(42, "1", signature))
+}
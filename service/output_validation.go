@@ -0,0 +1,357 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 流式校验时最多缓存的响应字节数，超出后放弃校验
+const outputValidationMaxCaptureBytes = 8 << 20
+
+// OutputValidator 校验上游返回的工具调用参数与 json_schema 结构化输出。
+// 非流式响应会先缓存，校验通过后再写回客户端；流式响应只能旁路记录，校验结果写入消费日志。
+type OutputValidator struct {
+	c                 *gin.Context
+	info              *relaycommon.RelayInfo
+	toolSchemas       map[string]any
+	responseSchema    any
+	requireJSONObject bool
+
+	original      gin.ResponseWriter
+	headerBackup  http.Header
+	writer        *CaptureWriter
+	captureStream bool
+}
+
+// NewOutputValidator 在渠道或分组开启校验且请求包含工具定义或结构化输出时返回校验器，否则返回 nil
+func NewOutputValidator(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) *OutputValidator {
+	if info == nil || request == nil {
+		return nil
+	}
+	if info.RelayMode != relayconstant.RelayModeChatCompletions || info.RelayFormat != types.RelayFormatOpenAI {
+		return nil
+	}
+	if !info.ChannelOtherSettings.OutputValidationEnabled && !operation_setting.IsOutputValidationEnabledForGroup(info.UsingGroup) {
+		return nil
+	}
+
+	v := &OutputValidator{c: c, info: info}
+	for _, tool := range request.Tools {
+		if tool.Type != "" && tool.Type != "function" {
+			continue
+		}
+		if v.toolSchemas == nil {
+			v.toolSchemas = make(map[string]any)
+		}
+		// 含不支持关键字的 schema 只校验参数是否为合法 JSON
+		v.toolSchemas[tool.Function.Name] = supportedSchemaOrNil(c, tool.Function.Parameters)
+	}
+	if request.ResponseFormat != nil {
+		switch request.ResponseFormat.Type {
+		case "json_schema":
+			var format dto.FormatJsonSchema
+			if err := common.Unmarshal(request.ResponseFormat.JsonSchema, &format); err == nil && format.Schema != nil {
+				v.responseSchema = supportedSchemaOrNil(c, format.Schema)
+			}
+			v.requireJSONObject = true
+		case "json_object":
+			v.requireJSONObject = true
+		}
+	}
+	if len(v.toolSchemas) == 0 && !v.requireJSONObject {
+		return nil
+	}
+	return v
+}
+
+func supportedSchemaOrNil(c *gin.Context, schema any) any {
+	if err := common.CheckJSONSchemaSupported(schema); err != nil {
+		logger.LogWarn(c, fmt.Sprintf("output validation skips schema: %s", err.Error()))
+		return nil
+	}
+	return schema
+}
+
+// Attach 接管 c.Writer，必须在 DoResponse 之前调用，并在之后调用 Finish
+func (v *OutputValidator) Attach() {
+	if v == nil {
+		return
+	}
+	v.captureStream = v.info.IsStream
+	if v.captureStream && !operation_setting.GetOutputValidationSetting().ValidateStream {
+		return
+	}
+	v.original = v.c.Writer
+	v.headerBackup = v.original.Header().Clone()
+	// 非流式时缓存全部输出，流式时透传并旁路复制一份用于校验
+	v.writer = NewCaptureWriter(v.original, v.captureStream, outputValidationMaxCaptureBytes)
+	v.c.Writer = v.writer
+}
+
+// Finish 恢复 c.Writer 并执行校验。非流式校验失败时返回错误，缓存的响应不会发送给客户端。
+func (v *OutputValidator) Finish(relayErr *types.NewAPIError) *types.NewAPIError {
+	if v == nil || v.writer == nil {
+		return relayErr
+	}
+	v.c.Writer = v.original
+	if relayErr != nil {
+		if !v.captureStream {
+			v.restoreHeader()
+		}
+		return relayErr
+	}
+
+	if v.captureStream {
+		if v.writer.Overflow() {
+			return nil
+		}
+		if err := v.validateChoices(parseStreamChoices(v.writer.Bytes())); err != nil {
+			logger.LogWarn(v.c, fmt.Sprintf("stream output validation failed (channel #%d): %s", v.info.ChannelId, err.Error()))
+			v.recordResult("failed", err)
+		} else {
+			v.recordResult("passed", nil)
+		}
+		return nil
+	}
+
+	body := v.writer.Bytes()
+	if err := v.validateResponseBody(body); err != nil {
+		v.restoreHeader()
+		v.recordFailure(err)
+		logger.LogWarn(v.c, fmt.Sprintf("output validation failed (channel #%d): %s", v.info.ChannelId, err.Error()))
+		var ops []types.NewAPIErrorOptions
+		if operation_setting.GetOutputValidationSetting().Action == operation_setting.OutputValidationActionError {
+			ops = append(ops, types.ErrOptionWithSkipRetry())
+		}
+		return types.NewOpenAIError(fmt.Errorf("upstream output failed schema validation: %w", err), types.ErrorCodeOutputValidationFailed, http.StatusBadGateway, ops...)
+	}
+
+	v.recordResult("passed", nil)
+	v.original.WriteHeader(v.writer.CapturedStatus())
+	_, _ = v.original.Write(body)
+	return nil
+}
+
+func (v *OutputValidator) restoreHeader() {
+	header := v.original.Header()
+	for key := range header {
+		delete(header, key)
+	}
+	for key, values := range v.headerBackup {
+		header[key] = values
+	}
+}
+
+func (v *OutputValidator) recordFailure(err error) {
+	failures := common.GetContextKeyStringSlice(v.c, constant.ContextKeyOutputValidationFailures)
+	failures = append(failures, fmt.Sprintf("channel #%d: %s", v.info.ChannelId, err.Error()))
+	common.SetContextKey(v.c, constant.ContextKeyOutputValidationFailures, failures)
+}
+
+func (v *OutputValidator) recordResult(result string, err error) {
+	outcome := map[string]any{
+		"result": result,
+	}
+	if failures := common.GetContextKeyStringSlice(v.c, constant.ContextKeyOutputValidationFailures); len(failures) > 0 {
+		outcome["failed_attempts"] = len(failures)
+		outcome["failures"] = failures
+	}
+	if err != nil {
+		outcome["error"] = err.Error()
+	}
+	common.SetContextKey(v.c, constant.ContextKeyOutputValidationResult, outcome)
+}
+
+type validatedToolCall struct {
+	name      string
+	arguments string
+}
+
+type validatedChoice struct {
+	content      string
+	toolCalls    []validatedToolCall
+	finishReason string
+}
+
+func (v *OutputValidator) validateResponseBody(body []byte) error {
+	choices, err := parseResponseChoices(body)
+	if err != nil {
+		return err
+	}
+	return v.validateChoices(choices)
+}
+
+// parseResponseChoices 解析非流式响应中每个 choice 的内容与工具调用
+func parseResponseChoices(body []byte) ([]validatedChoice, error) {
+	var response dto.OpenAITextResponse
+	if err := common.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("response is not valid JSON: %w", err)
+	}
+	choices := make([]validatedChoice, 0, len(response.Choices))
+	for _, choice := range response.Choices {
+		validated := validatedChoice{
+			content:      choice.Message.StringContent(),
+			finishReason: choice.FinishReason,
+		}
+		for _, toolCall := range choice.Message.ParseToolCalls() {
+			validated.toolCalls = append(validated.toolCalls, validatedToolCall{
+				name:      toolCall.Function.Name,
+				arguments: toolCall.Function.Arguments,
+			})
+		}
+		choices = append(choices, validated)
+	}
+	return choices, nil
+}
+
+func (v *OutputValidator) validateChoices(choices []validatedChoice) error {
+	for i, choice := range choices {
+		// 因长度截断的输出不属于格式错误，跳过校验
+		if choice.finishReason == "length" {
+			continue
+		}
+		for _, toolCall := range choice.toolCalls {
+			if err := v.validateToolCall(toolCall); err != nil {
+				return fmt.Errorf("choice %d: %w", i, err)
+			}
+		}
+		if len(choice.toolCalls) == 0 && v.requireJSONObject && strings.TrimSpace(choice.content) != "" {
+			if err := v.validateStructuredContent(choice.content); err != nil {
+				return fmt.Errorf("choice %d: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (v *OutputValidator) validateToolCall(toolCall validatedToolCall) error {
+	schema, known := v.toolSchemas[toolCall.name]
+	if !known && len(v.toolSchemas) > 0 {
+		return fmt.Errorf("tool %q is not defined in request", toolCall.name)
+	}
+	arguments := strings.TrimSpace(toolCall.arguments)
+	if arguments == "" {
+		arguments = "{}"
+	}
+	var parsed any
+	if err := common.UnmarshalJsonStr(arguments, &parsed); err != nil {
+		return fmt.Errorf("tool %q arguments are not valid JSON: %w", toolCall.name, err)
+	}
+	if schema == nil {
+		return nil
+	}
+	if err := common.ValidateJSONSchema(schema, parsed); err != nil {
+		return fmt.Errorf("tool %q arguments: %w", toolCall.name, err)
+	}
+	return nil
+}
+
+func (v *OutputValidator) validateStructuredContent(content string) error {
+	var parsed any
+	if err := common.UnmarshalJsonStr(strings.TrimSpace(content), &parsed); err != nil {
+		return fmt.Errorf("content is not valid JSON: %w", err)
+	}
+	if _, ok := parsed.(map[string]any); !ok {
+		return fmt.Errorf("content is not a JSON object")
+	}
+	if v.responseSchema == nil {
+		return nil
+	}
+	if err := common.ValidateJSONSchema(v.responseSchema, parsed); err != nil {
+		return fmt.Errorf("content: %w", err)
+	}
+	return nil
+}
+
+// parseStreamChoices 从 SSE 响应中拼接每个 choice 的最终内容与工具调用参数
+func parseStreamChoices(data []byte) []validatedChoice {
+	type streamToolCall struct {
+		name      string
+		arguments strings.Builder
+	}
+	type streamChoice struct {
+		content      strings.Builder
+		toolCalls    map[int]*streamToolCall
+		toolOrder    []int
+		finishReason string
+	}
+	choices := make(map[int]*streamChoice)
+	order := make([]int, 0)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), outputValidationMaxCaptureBytes)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if payload == "" || payload == "[DONE]" {
+			continue
+		}
+		var chunk dto.ChatCompletionsStreamResponse
+		if err := common.UnmarshalJsonStr(payload, &chunk); err != nil {
+			continue
+		}
+		for _, choice := range chunk.Choices {
+			current, ok := choices[choice.Index]
+			if !ok {
+				current = &streamChoice{toolCalls: make(map[int]*streamToolCall)}
+				choices[choice.Index] = current
+				order = append(order, choice.Index)
+			}
+			current.content.WriteString(choice.Delta.GetContentString())
+			for position, toolCall := range choice.Delta.ToolCalls {
+				index := position
+				if toolCall.Index != nil {
+					index = *toolCall.Index
+				}
+				call, ok := current.toolCalls[index]
+				if !ok {
+					call = &streamToolCall{}
+					current.toolCalls[index] = call
+					current.toolOrder = append(current.toolOrder, index)
+				}
+				if toolCall.Function.Name != "" {
+					call.name = toolCall.Function.Name
+				}
+				call.arguments.WriteString(toolCall.Function.Arguments)
+			}
+			if choice.FinishReason != nil && *choice.FinishReason != "" {
+				current.finishReason = *choice.FinishReason
+			}
+		}
+	}
+
+	result := make([]validatedChoice, 0, len(order))
+	for _, index := range order {
+		current := choices[index]
+		validated := validatedChoice{
+			content:      current.content.String(),
+			finishReason: current.finishReason,
+		}
+		for _, toolIndex := range current.toolOrder {
+			call := current.toolCalls[toolIndex]
+			validated.toolCalls = append(validated.toolCalls, validatedToolCall{
+				name:      call.name,
+				arguments: call.arguments.String(),
+			})
+		}
+		result = append(result, validated)
+	}
+	return result
+}
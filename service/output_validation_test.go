@@ -0,0 +1,98 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func newOutputValidationTestRequest(t *testing.T) *dto.GeneralOpenAIRequest {
+	t.Helper()
+	var request dto.GeneralOpenAIRequest
+	require.NoError(t, common.UnmarshalJsonStr(`{
+		"model": "gpt-4o",
+		"tools": [{"type":"function","function":{"name":"get_weather","parameters":{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}}}]
+	}`, &request))
+	return &request
+}
+
+func newOutputValidationTestInfo(isStream bool) *relaycommon.RelayInfo {
+	return &relaycommon.RelayInfo{
+		RelayMode:   relayconstant.RelayModeChatCompletions,
+		RelayFormat: types.RelayFormatOpenAI,
+		IsStream:    isStream,
+		ChannelMeta: &relaycommon.ChannelMeta{
+			ChannelId:            7,
+			ChannelOtherSettings: dto.ChannelOtherSettings{OutputValidationEnabled: true},
+		},
+	}
+}
+
+func TestOutputValidator_NonStreamRejectsInvalidToolArguments(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	validator := NewOutputValidator(c, newOutputValidationTestInfo(false), newOutputValidationTestRequest(t))
+	require.NotNil(t, validator)
+	validator.Attach()
+	c.Header("Content-Length", "999")
+	c.String(http.StatusOK, `{"choices":[{"index":0,"message":{"role":"assistant","tool_calls":[{"id":"1","type":"function","function":{"name":"get_weather","arguments":"{\"town\":\"Paris\"}"}}]},"finish_reason":"tool_calls"}]}`)
+
+	newAPIErr := validator.Finish(nil)
+	require.NotNil(t, newAPIErr)
+	require.Equal(t, types.ErrorCodeOutputValidationFailed, newAPIErr.GetErrorCode())
+	require.Contains(t, newAPIErr.Error(), `missing required property "city"`)
+	require.Empty(t, recorder.Body.String())
+	require.Empty(t, c.Writer.Header().Get("Content-Length"))
+	require.Len(t, common.GetContextKeyStringSlice(c, constant.ContextKeyOutputValidationFailures), 1)
+}
+
+func TestOutputValidator_NonStreamFlushesValidResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	validator := NewOutputValidator(c, newOutputValidationTestInfo(false), newOutputValidationTestRequest(t))
+	validator.Attach()
+	body := `{"choices":[{"index":0,"message":{"role":"assistant","tool_calls":[{"id":"1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]},"finish_reason":"tool_calls"}]}`
+	c.String(http.StatusOK, body)
+
+	require.Nil(t, validator.Finish(nil))
+	require.Equal(t, body, recorder.Body.String())
+	result, ok := common.GetContextKey(c, constant.ContextKeyOutputValidationResult)
+	require.True(t, ok)
+	require.Equal(t, "passed", result.(map[string]any)["result"])
+}
+
+func TestOutputValidator_StreamRecordsFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	validator := NewOutputValidator(c, newOutputValidationTestInfo(true), newOutputValidationTestRequest(t))
+	validator.Attach()
+	stream := "data: {\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"id\":\"1\",\"type\":\"function\",\"function\":{\"name\":\"get_weather\",\"arguments\":\"{\\\"ci\"}}]}}]}\n\n" +
+		"data: {\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"function\":{\"arguments\":\"ty\\\":1}\"}}]},\"finish_reason\":\"tool_calls\"}]}\n\n" +
+		"data: [DONE]\n\n"
+	_, _ = c.Writer.WriteString(stream)
+
+	require.Nil(t, validator.Finish(nil))
+	require.Equal(t, stream, recorder.Body.String())
+	result, ok := common.GetContextKey(c, constant.ContextKeyOutputValidationResult)
+	require.True(t, ok)
+	require.Equal(t, "failed", result.(map[string]any)["result"])
+	require.Contains(t, result.(map[string]any)["error"], "expected type string")
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// shadowTextKeys 响应中承载模型输出文本的字段，覆盖 OpenAI / Claude / Gemini / Responses 的流式与非流式格式
+var shadowTextKeys = map[string]bool{
+	"content":   true,
+	"text":      true,
+	"delta":     true,
+	"arguments": true,
+}
+
+// ExtractResponseText 从客户端收到的响应（JSON 或 SSE）中提取模型输出文本，用于影子流量的输出比较
+func ExtractResponseText(body []byte) string {
+	var builder strings.Builder
+	collect := func(payload []byte) {
+		var value any
+		if err := common.Unmarshal(payload, &value); err != nil {
+			return
+		}
+		collectResponseText(&builder, value, false)
+	}
+	trimmed := bytes.TrimSpace(body)
+	if bytes.HasPrefix(trimmed, []byte("{")) || bytes.HasPrefix(trimmed, []byte("[")) {
+		collect(trimmed)
+		return builder.String()
+	}
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		payload, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:"))
+		if !ok {
+			continue
+		}
+		payload = bytes.TrimSpace(payload)
+		if len(payload) == 0 || string(payload) == "[DONE]" {
+			continue
+		}
+		collect(payload)
+	}
+	return builder.String()
+}
+
+func collectResponseText(builder *strings.Builder, value any, isText bool) {
+	switch v := value.(type) {
+	case string:
+		if isText {
+			builder.WriteString(v)
+			builder.WriteByte(' ')
+		}
+	case []any:
+		for _, item := range v {
+			collectResponseText(builder, item, isText)
+		}
+	case map[string]any:
+		for key, item := range v {
+			collectResponseText(builder, item, shadowTextKeys[key])
+		}
+	}
+}
+
+// textTokenSet 按单词切分，中日韩文字按单字切分
+func textTokenSet(text string) map[string]struct{} {
+	tokens := make(map[string]struct{})
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			tokens[word.String()] = struct{}{}
+			word.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			flush()
+			tokens[string(r)] = struct{}{}
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+// TextSimilarity 两段文本词集合的 Jaccard 相似度，取值 0-1；两段文本都没有可比较内容时返回 -1
+func TextSimilarity(a string, b string) float64 {
+	setA := textTokenSet(a)
+	setB := textTokenSet(b)
+	if len(setA) == 0 && len(setB) == 0 {
+		return -1
+	}
+	intersection := 0
+	for token := range setA {
+		if _, ok := setB[token]; ok {
+			intersection++
+		}
+	}
+	union := len(setA) + len(setB) - intersection
+	return float64(intersection) / float64(union)
+}
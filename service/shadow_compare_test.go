@@ -0,0 +1,36 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractResponseText(t *testing.T) {
+	t.Parallel()
+
+	openAI := `{"choices":[{"message":{"role":"assistant","content":"Hello world"}}],"usage":{"prompt_tokens":1}}`
+	require.Contains(t, ExtractResponseText([]byte(openAI)), "Hello world")
+
+	claudeStream := "event: content_block_delta\n" +
+		`data: {"type":"content_block_delta","delta":{"type":"text_delta","text":"Hello"}}` + "\n\n" +
+		"event: content_block_delta\n" +
+		`data: {"type":"content_block_delta","delta":{"type":"text_delta","text":" world"}}` + "\n\n"
+	text := ExtractResponseText([]byte(claudeStream))
+	require.Contains(t, text, "Hello")
+	require.Contains(t, text, "world")
+	require.NotContains(t, text, "text_delta")
+
+	gemini := `{"candidates":[{"content":{"role":"model","parts":[{"text":"你好"}]}}]}`
+	require.Contains(t, ExtractResponseText([]byte(gemini)), "你好")
+}
+
+func TestTextSimilarity(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, 1.0, TextSimilarity("Hello, World!", "hello world"))
+	require.Equal(t, 0.0, TextSimilarity("foo", "bar"))
+	require.InDelta(t, 0.5, TextSimilarity("a b c", "b c d"), 1e-9)
+	require.InDelta(t, 1.0/3, TextSimilarity("你好", "你们"), 1e-9)
+	require.Equal(t, -1.0, TextSimilarity("", " ... "))
+}
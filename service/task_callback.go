@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+)
+
+var (
+	ErrTaskCallbackInvalidSignature = errors.New("invalid task callback signature")
+	ErrTaskCallbackTaskNotFound     = errors.New("task not found")
+	ErrTaskCallbackNotSupported     = errors.New("task callback is not supported by this platform")
+	ErrTaskCallbackTaskMismatch     = errors.New("task callback does not match the task")
+)
+
+// taskCallbackNormalizer 与 channel.TaskCallbackReceiver 一致，这里单独声明以避免 service -> relay 的循环依赖
+type taskCallbackNormalizer interface {
+	NormalizeTaskCallback(body []byte) []byte
+}
+
+func signTaskCallback(channelId int, taskId string) string {
+	return common.GenerateHMAC(fmt.Sprintf("task_callback:%d:%s", channelId, taskId))
+}
+
+// BuildTaskCallbackURL 生成任务的上游回调地址，未开启回调或未配置服务器地址时返回空字符串
+func BuildTaskCallbackURL(channelId int, taskId string) string {
+	if !system_setting.GetTaskPollingSettings().CallbackEnabled || system_setting.ServerAddress == "" || taskId == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/api/task/callback/%d/%s?signature=%s",
+		strings.TrimRight(system_setting.ServerAddress, "/"), channelId, taskId, signTaskCallback(channelId, taskId))
+}
+
+// GetTaskCallbackDeadline 注册回调的任务在宽限期内不轮询，返回恢复轮询的时间
+func GetTaskCallbackDeadline(now int64) int64 {
+	grace := system_setting.GetTaskPollingSettings().CallbackGraceSeconds
+	if grace <= 0 {
+		return 0
+	}
+	return now + int64(grace)
+}
+
+// HandleTaskCallback 处理上游推送的任务完成通知。
+// 签名只绑定渠道与任务 ID，无法证明回调内容来自上游，因此回调仅作为触发信号：
+// 校验通过后主动向上游查询任务状态，按轮询相同的逻辑推进任务并结算，回调内容本身不参与状态更新
+func HandleTaskCallback(ctx context.Context, channelId int, taskId string, signature string, body []byte) error {
+	expected := signTaskCallback(channelId, taskId)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(signature)) != 1 {
+		return ErrTaskCallbackInvalidSignature
+	}
+	task, exist, err := model.GetByOnlyTaskId(taskId)
+	if err != nil {
+		return err
+	}
+	// 任务可能在提交响应落库前就收到回调，返回错误让上游重试，轮询也会兜底
+	if !exist || task.ChannelId != channelId {
+		return ErrTaskCallbackTaskNotFound
+	}
+	if task.Status == model.TaskStatusSuccess || task.Status == model.TaskStatusFailure {
+		return nil
+	}
+	if task.Platform == constant.TaskPlatformSuno {
+		return ErrTaskCallbackNotSupported
+	}
+	adaptor := GetTaskAdaptorFunc(task.Platform)
+	if adaptor == nil {
+		return ErrTaskCallbackNotSupported
+	}
+	normalizer, ok := adaptor.(taskCallbackNormalizer)
+	if !ok {
+		return ErrTaskCallbackNotSupported
+	}
+	// 回调中可识别出上游任务 ID 时必须与任务记录一致，避免串号的回调触发无关查询
+	if result, parseErr := adaptor.ParseTaskResult(normalizer.NormalizeTaskCallback(body)); parseErr == nil && result != nil &&
+		result.TaskID != "" && result.TaskID != task.GetUpstreamTaskID() {
+		return ErrTaskCallbackTaskMismatch
+	}
+
+	if err = refreshTaskFromUpstream(ctx, task); err != nil {
+		return err
+	}
+	logger.LogInfo(ctx, fmt.Sprintf("task %s refreshed on upstream callback, status: %s", task.TaskID, task.Status))
+	return nil
+}
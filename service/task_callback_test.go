@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockCallbackAdaptor 上游查询返回 upstreamBody；回调内容为 "id:<upstream id>" 或其他任意文本
+type mockCallbackAdaptor struct {
+	mockAdaptor
+	upstreamBody string
+	fetched      int
+}
+
+func (m *mockCallbackAdaptor) FetchTask(string, string, map[string]any, string) (*http.Response, error) {
+	m.fetched++
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(m.upstreamBody))}, nil
+}
+
+func (m *mockCallbackAdaptor) ParseTaskResult(body []byte) (*relaycommon.TaskInfo, error) {
+	if id, ok := strings.CutPrefix(string(body), "id:"); ok {
+		return &relaycommon.TaskInfo{TaskID: id, Status: model.TaskStatusSuccess}, nil
+	}
+	return relaycommon.FailTaskInfo(string(body)), nil
+}
+
+func (m *mockCallbackAdaptor) NormalizeTaskCallback(body []byte) []byte {
+	return body
+}
+
+func TestHandleTaskCallback_FailureRefunds(t *testing.T) {
+	truncate(t)
+	ctx := context.Background()
+
+	adaptor := &mockCallbackAdaptor{upstreamBody: "content rejected"}
+	origin := GetTaskAdaptorFunc
+	GetTaskAdaptorFunc = func(constant.TaskPlatform) TaskPollingAdaptor { return adaptor }
+	t.Cleanup(func() { GetTaskAdaptorFunc = origin })
+
+	const userID, tokenID, channelID = 50, 50, 50
+	const initQuota, preConsumed = 10000, 2000
+	seedUser(t, userID, initQuota)
+	seedToken(t, tokenID, userID, "sk-callback", 5000)
+	seedChannel(t, channelID)
+
+	task := makeTask(userID, channelID, preConsumed, tokenID, BillingSourceWallet, 0)
+	task.Platform = "45"
+	task.PrivateData.UpstreamTaskID = "upstream-1"
+	require.NoError(t, model.DB.Create(task).Error)
+
+	err := HandleTaskCallback(ctx, channelID, task.TaskID, "bad-signature", []byte("content rejected"))
+	require.ErrorIs(t, err, ErrTaskCallbackInvalidSignature)
+
+	err = HandleTaskCallback(ctx, channelID+1, task.TaskID, signTaskCallback(channelID+1, task.TaskID), []byte("content rejected"))
+	require.ErrorIs(t, err, ErrTaskCallbackTaskNotFound)
+
+	sig := signTaskCallback(channelID, task.TaskID)
+	// 回调中的上游任务 ID 与记录不一致时拒绝，不查询上游
+	err = HandleTaskCallback(ctx, channelID, task.TaskID, sig, []byte("id:upstream-other"))
+	require.ErrorIs(t, err, ErrTaskCallbackTaskMismatch)
+	assert.Equal(t, 0, adaptor.fetched)
+
+	// 回调声称成功，但状态以上游查询结果为准
+	require.NoError(t, HandleTaskCallback(ctx, channelID, task.TaskID, sig, []byte("id:upstream-1")))
+	assert.Equal(t, 1, adaptor.fetched)
+
+	var reloaded model.Task
+	require.NoError(t, model.DB.First(&reloaded, task.ID).Error)
+	assert.Equal(t, model.TaskStatus(model.TaskStatusFailure), reloaded.Status)
+	assert.Equal(t, "content rejected", reloaded.FailReason)
+	assert.Equal(t, initQuota+preConsumed, getUserQuota(t, userID))
+
+	// 重复回调不会再次退款
+	require.NoError(t, HandleTaskCallback(ctx, channelID, task.TaskID, sig, []byte("content rejected")))
+	assert.Equal(t, initQuota+preConsumed, getUserQuota(t, userID))
+}
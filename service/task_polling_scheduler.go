@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/go-redis/redis/v8"
+)
+
+const taskPollingLeaderLease = "task_polling_leader"
+
+// 续期时仅当租约仍由自己持有才延长过期时间
+var redisRenewLeaseScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+var taskPollingNodeId = func() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), common.GetRandomString(6))
+}()
+
+// PlatformPollStats 单个平台在本节点上的轮询统计
+type PlatformPollStats struct {
+	Backlog      int64   `json:"backlog"`
+	Claimed      int     `json:"claimed"` // 最近一轮本节点认领的任务数
+	Concurrency  int     `json:"concurrency"`
+	Polled       int64   `json:"polled"`
+	Errors       int64   `json:"errors"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	MaxLatencyMs int64   `json:"max_latency_ms"` // 最近一轮的最大单次轮询耗时
+	LastPollAt   int64   `json:"last_poll_at"`
+
+	totalLatencyMs int64
+}
+
+// TaskPollingStats 本节点的任务轮询状态
+type TaskPollingStats struct {
+	NodeId      string                        `json:"node_id"`
+	Distributed bool                          `json:"distributed"`
+	IsLeader    bool                          `json:"is_leader"`
+	LastCycleAt int64                         `json:"last_cycle_at"`
+	LastCycleMs int64                         `json:"last_cycle_ms"`
+	Backlog     int64                         `json:"backlog"`
+	Platforms   map[string]*PlatformPollStats `json:"platforms"`
+}
+
+var (
+	taskPollingStatsMu sync.RWMutex
+	taskPollingStats   = TaskPollingStats{
+		NodeId:    taskPollingNodeId,
+		Platforms: make(map[string]*PlatformPollStats),
+	}
+)
+
+// GetTaskPollingStats 返回本节点的轮询统计，并附带全局未完成任务积压数
+func GetTaskPollingStats() TaskPollingStats {
+	taskPollingStatsMu.RLock()
+	stats := taskPollingStats
+	stats.Platforms = make(map[string]*PlatformPollStats, len(taskPollingStats.Platforms))
+	for platform, s := range taskPollingStats.Platforms {
+		copied := *s
+		stats.Platforms[platform] = &copied
+	}
+	taskPollingStatsMu.RUnlock()
+
+	stats.Distributed = system_setting.GetTaskPollingSettings().Distributed
+	backlog, err := model.CountUnfinishedTasksByPlatform()
+	if err != nil {
+		return stats
+	}
+	for platform, count := range backlog {
+		s, ok := stats.Platforms[string(platform)]
+		if !ok {
+			s = &PlatformPollStats{}
+			stats.Platforms[string(platform)] = s
+		}
+		s.Backlog = count
+		stats.Backlog += count
+	}
+	return stats
+}
+
+func getPlatformPollStats(platform constant.TaskPlatform) *PlatformPollStats {
+	s, ok := taskPollingStats.Platforms[string(platform)]
+	if !ok {
+		s = &PlatformPollStats{}
+		taskPollingStats.Platforms[string(platform)] = s
+	}
+	return s
+}
+
+func recordTaskPoll(platform constant.TaskPlatform, latency time.Duration, err error) {
+	taskPollingStatsMu.Lock()
+	defer taskPollingStatsMu.Unlock()
+	s := getPlatformPollStats(platform)
+	ms := latency.Milliseconds()
+	s.Polled++
+	s.totalLatencyMs += ms
+	s.AvgLatencyMs = float64(s.totalLatencyMs) / float64(s.Polled)
+	if ms > s.MaxLatencyMs {
+		s.MaxLatencyMs = ms
+	}
+	s.LastPollAt = time.Now().Unix()
+	if err != nil {
+		s.Errors++
+	}
+}
+
+// runTaskPollingCycle 执行一轮轮询：主节点清理超时任务与遗留的占位任务，各节点认领到期任务后按平台并发轮询
+func runTaskPollingCycle(ctx context.Context) {
+	settings := system_setting.GetTaskPollingSettings()
+	if !settings.Distributed && !common.IsMasterNode {
+		return
+	}
+	start := time.Now()
+	common.SysLog("任务进度轮询开始")
+
+	leader := isTaskPollingLeader(ctx, settings)
+	if leader {
+		sweepTimedOutTasks(ctx)
+		cleanupStaleReservedTasks(ctx)
+	}
+
+	tasks := claimDueTasks(ctx, settings, start.Unix())
+	platformTask := make(map[constant.TaskPlatform][]*model.Task)
+	for _, t := range tasks {
+		platformTask[t.Platform] = append(platformTask[t.Platform], t)
+	}
+
+	taskPollingStatsMu.Lock()
+	taskPollingStats.IsLeader = leader
+	for platform, s := range taskPollingStats.Platforms {
+		s.Claimed = len(platformTask[constant.TaskPlatform(platform)])
+		s.MaxLatencyMs = 0
+	}
+	for platform, platformTasks := range platformTask {
+		s := getPlatformPollStats(platform)
+		s.Claimed = len(platformTasks)
+		s.Concurrency = settings.GetConcurrency(string(platform))
+	}
+	taskPollingStatsMu.Unlock()
+
+	var wg sync.WaitGroup
+	for platform, platformTasks := range platformTask {
+		wg.Add(1)
+		gopool.Go(func() {
+			defer wg.Done()
+			pollPlatformTasks(ctx, settings, platform, platformTasks)
+		})
+	}
+	wg.Wait()
+
+	// 本轮轮询结束后再出队，已完成的任务会释放出空位
+	if leader {
+		dispatchQueuedTasks(ctx)
+	}
+
+	taskPollingStatsMu.Lock()
+	taskPollingStats.LastCycleAt = start.Unix()
+	taskPollingStats.LastCycleMs = time.Since(start).Milliseconds()
+	taskPollingStatsMu.Unlock()
+	common.SysLog("任务进度轮询完成")
+}
+
+// isTaskPollingLeader 单节点模式下主节点即为 leader；多节点模式下通过 Redis 或数据库租约选举
+func isTaskPollingLeader(ctx context.Context, settings *system_setting.TaskPollingSettings) bool {
+	if !settings.Distributed {
+		return common.IsMasterNode
+	}
+	ttl := time.Duration(settings.GetInterval("")*3) * time.Second
+	if ttl < 30*time.Second {
+		ttl = 30 * time.Second
+	}
+	var ok bool
+	var err error
+	if common.RedisEnabled {
+		ok, err = tryAcquireRedisLease(ctx, "new-api:lease:"+taskPollingLeaderLease, taskPollingNodeId, ttl)
+	} else {
+		ok, err = model.TryAcquireClusterLease(taskPollingLeaderLease, taskPollingNodeId, ttl)
+	}
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("acquire task polling leader lease failed: %v", err))
+		return false
+	}
+	return ok
+}
+
+func tryAcquireRedisLease(ctx context.Context, key string, owner string, ttl time.Duration) (bool, error) {
+	ok, err := common.RDB.SetNX(ctx, key, owner, ttl).Result()
+	if err != nil || ok {
+		return ok, err
+	}
+	renewed, err := redisRenewLeaseScript.Run(ctx, common.RDB, []string{key}, owner, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return renewed == 1, nil
+}
+
+// claimDueTasks 认领已到轮询时间的任务：以 CAS 推进 next_poll_at，推进成功的任务本轮由本节点负责，
+// 其他节点在 next_poll_at 到期前不会再取到这些任务
+func claimDueTasks(ctx context.Context, settings *system_setting.TaskPollingSettings, now int64) []*model.Task {
+	candidates := model.GetDueUnfinishedTasks(now, constant.TaskQueryLimit)
+	claimed := make([]*model.Task, 0, len(candidates))
+	for _, task := range candidates {
+		next := now + computeTaskPollInterval(settings, task, now)
+		won, err := model.ClaimTaskPoll(task.ID, task.NextPollAt, next)
+		if err != nil {
+			logger.LogError(ctx, fmt.Sprintf("claim task %s for polling failed: %v", task.TaskID, err))
+			continue
+		}
+		if !won {
+			continue
+		}
+		task.NextPollAt = next
+		claimed = append(claimed, task)
+	}
+	return claimed
+}
+
+// computeTaskPollInterval 计算任务的轮询间隔（秒）：提交时间越久间隔越长，上限为 MaxIntervalSeconds
+func computeTaskPollInterval(settings *system_setting.TaskPollingSettings, task *model.Task, now int64) int64 {
+	base := int64(settings.GetInterval(string(task.Platform)))
+	interval := base
+	maxInterval := int64(settings.MaxIntervalSeconds)
+	if maxInterval < base {
+		maxInterval = base
+	}
+	if settings.BackoffAfterMinutes > 0 {
+		submitted := task.SubmitTime
+		if submitted == 0 {
+			submitted = task.CreatedAt
+		}
+		step := int64(settings.BackoffAfterMinutes) * 60
+		for age := now - submitted; age >= step && interval < maxInterval; age -= step {
+			interval *= 2
+		}
+	}
+	if interval > maxInterval {
+		interval = maxInterval
+	}
+	return interval
+}
+
+// pollPlatformTasks 将平台任务拆分为轮询作业，交给固定数量的 worker 并发执行
+func pollPlatformTasks(ctx context.Context, settings *system_setting.TaskPollingSettings, platform constant.TaskPlatform, tasks []*model.Task) {
+	taskChannelM := make(map[int][]string)
+	taskM := make(map[string]*model.Task)
+	nullTaskIds := make([]int64, 0)
+	for _, task := range tasks {
+		upstreamID := task.GetUpstreamTaskID()
+		if upstreamID == "" {
+			// 统计失败的未完成任务
+			nullTaskIds = append(nullTaskIds, task.ID)
+			continue
+		}
+		taskM[upstreamID] = task
+		taskChannelM[task.ChannelId] = append(taskChannelM[task.ChannelId], upstreamID)
+	}
+	if len(nullTaskIds) > 0 {
+		err := model.TaskBulkUpdateByID(nullTaskIds, map[string]any{
+			"status":   "FAILURE",
+			"progress": "100%",
+		})
+		if err != nil {
+			logger.LogError(ctx, fmt.Sprintf("Fix null task_id task error: %v", err))
+		} else {
+			logger.LogInfo(ctx, fmt.Sprintf("Fix null task_id task success: %v", nullTaskIds))
+		}
+	}
+	if len(taskChannelM) == 0 {
+		return
+	}
+
+	var jobs []func()
+	switch platform {
+	case constant.TaskPlatformSuno:
+		for channelId, taskIds := range taskChannelM {
+			jobs = append(jobs, func() {
+				start := time.Now()
+				err := updateSunoTasks(ctx, channelId, taskIds, taskM)
+				recordTaskPoll(platform, time.Since(start), err)
+				if err != nil {
+					logger.LogError(ctx, fmt.Sprintf("渠道 #%d 更新异步任务失败: %s", channelId, err.Error()))
+				}
+			})
+		}
+	default:
+		channelIds := make([]int, 0, len(taskChannelM))
+		for channelId := range taskChannelM {
+			channelIds = append(channelIds, channelId)
+		}
+		sort.Ints(channelIds)
+		for _, channelId := range channelIds {
+			jobs = append(jobs, buildVideoPollJobs(ctx, platform, channelId, taskChannelM[channelId], taskM)...)
+		}
+	}
+	runTaskPollWorkers(jobs, settings.GetConcurrency(string(platform)))
+}
+
+// buildVideoPollJobs 为渠道下的每个视频任务生成轮询作业；渠道不存在时直接将任务置为失败
+func buildVideoPollJobs(ctx context.Context, platform constant.TaskPlatform, channelId int, taskIds []string, taskM map[string]*model.Task) []func() {
+	logger.LogInfo(ctx, fmt.Sprintf("Channel #%d pending video tasks: %d", channelId, len(taskIds)))
+	ch, err := model.CacheGetChannel(channelId)
+	if err != nil {
+		// Collect DB primary key IDs for bulk update (taskIds are upstream IDs, not task_id column values)
+		var failedIDs []int64
+		for _, upstreamID := range taskIds {
+			if t, ok := taskM[upstreamID]; ok {
+				failedIDs = append(failedIDs, t.ID)
+			}
+		}
+		errUpdate := model.TaskBulkUpdateByID(failedIDs, map[string]any{
+			"fail_reason": fmt.Sprintf("Failed to get channel info, channel ID: %d", channelId),
+			"status":      "FAILURE",
+			"progress":    "100%",
+		})
+		if errUpdate != nil {
+			common.SysLog(fmt.Sprintf("UpdateVideoTask error: %v", errUpdate))
+		}
+		logger.LogError(ctx, fmt.Sprintf("Channel #%d failed to update video async tasks: CacheGetChannel failed: %s", channelId, err.Error()))
+		return nil
+	}
+	jobs := make([]func(), 0, len(taskIds))
+	for _, taskId := range taskIds {
+		jobs = append(jobs, func() {
+			// 适配器在 Init 后持有渠道状态，每个作业使用独立实例以便并发
+			adaptor := GetTaskAdaptorFunc(platform)
+			if adaptor == nil {
+				logger.LogError(ctx, fmt.Sprintf("video adaptor not found for platform %s", platform))
+				return
+			}
+			info := &relaycommon.RelayInfo{}
+			info.ChannelMeta = &relaycommon.ChannelMeta{
+				ChannelBaseUrl: ch.GetBaseURL(),
+			}
+			info.ApiKey = ch.Key
+			adaptor.Init(info)
+
+			start := time.Now()
+			err := updateVideoSingleTask(ctx, adaptor, ch, taskId, taskM)
+			recordTaskPoll(platform, time.Since(start), err)
+			if err != nil {
+				logger.LogError(ctx, fmt.Sprintf("Failed to update video task %s: %s", taskId, err.Error()))
+			}
+			// sleep 1 second between each task to avoid hitting rate limits of upstream platforms
+			time.Sleep(1 * time.Second)
+		})
+	}
+	return jobs
+}
+
+func runTaskPollWorkers(jobs []func(), concurrency int) {
+	if len(jobs) == 0 {
+		return
+	}
+	if concurrency > len(jobs) {
+		concurrency = len(jobs)
+	}
+	jobCh := make(chan func())
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		gopool.Go(func() {
+			defer wg.Done()
+			for job := range jobCh {
+				job()
+			}
+		})
+	}
+	for _, job := range jobs {
+		jobCh <- job
+	}
+	close(jobCh)
+	wg.Wait()
+}
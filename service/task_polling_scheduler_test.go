@@ -0,0 +1,37 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeTaskPollInterval_Backoff(t *testing.T) {
+	settings := &system_setting.TaskPollingSettings{
+		IntervalSeconds:         15,
+		MaxIntervalSeconds:      120,
+		BackoffAfterMinutes:     10,
+		PlatformIntervalSeconds: map[string]int{"suno": 30},
+	}
+	now := int64(100000)
+	task := &model.Task{Platform: "45", SubmitTime: now - 60}
+	require.Equal(t, int64(15), computeTaskPollInterval(settings, task, now))
+
+	task.SubmitTime = now - 10*60
+	require.Equal(t, int64(30), computeTaskPollInterval(settings, task, now))
+
+	task.SubmitTime = now - 25*60
+	require.Equal(t, int64(60), computeTaskPollInterval(settings, task, now))
+
+	task.SubmitTime = now - 24*60*60
+	require.Equal(t, int64(120), computeTaskPollInterval(settings, task, now))
+
+	suno := &model.Task{Platform: constant.TaskPlatformSuno, SubmitTime: now}
+	require.Equal(t, int64(30), computeTaskPollInterval(settings, suno, now))
+
+	settings.BackoffAfterMinutes = 0
+	require.Equal(t, int64(15), computeTaskPollInterval(settings, task, now))
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel/task/taskcommon"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// taskDispatchLeaseSeconds 排队任务出队提交期间推迟轮询的时长
+	taskDispatchLeaseSeconds = 300
+	taskQueueBatchSize       = 200
+)
+
+var ErrTaskQueueDisabled = errors.New("task queue is disabled")
+
+// ErrTaskConcurrencyLimit 进行中的任务数已达用户或令牌的上限，其余错误（如数据库错误）不会匹配
+var ErrTaskConcurrencyLimit = errors.New("task concurrency limit reached")
+
+// taskConcurrencyLimitError 保留面向用户的提示，同时可用 errors.Is 匹配 ErrTaskConcurrencyLimit
+type taskConcurrencyLimitError struct {
+	message string
+}
+
+func (e *taskConcurrencyLimitError) Error() string {
+	return e.message
+}
+
+func (e *taskConcurrencyLimitError) Is(target error) bool {
+	return target == ErrTaskConcurrencyLimit
+}
+
+// DispatchQueuedTaskFunc 由 main 包注入，将排队任务保存的原始请求提交到上游并完成计费
+var DispatchQueuedTaskFunc func(ctx context.Context, task *model.Task) error
+
+// CheckTaskConcurrency 检查用户及令牌进行中的任务数，未开启限制或未达上限时返回 nil，
+// 达到上限时返回的错误匹配 ErrTaskConcurrencyLimit
+func CheckTaskConcurrency(userId int, userGroup string, tokenId int, tokenLimit int) error {
+	return checkTaskConcurrencyTx(model.DB, userId, userGroup, tokenId, tokenLimit)
+}
+
+func checkTaskConcurrencyTx(tx *gorm.DB, userId int, userGroup string, tokenId int, tokenLimit int) error {
+	settings := system_setting.GetTaskConcurrencySettings()
+	if !settings.Enabled {
+		return nil
+	}
+	if limit := settings.GetUserLimit(userGroup); limit > 0 {
+		count, err := model.CountInFlightTasksTx(tx, userId, 0)
+		if err != nil {
+			return err
+		}
+		if count >= int64(limit) {
+			return &taskConcurrencyLimitError{message: fmt.Sprintf("进行中的任务数已达上限（%d），请等待已有任务完成后再提交", limit)}
+		}
+	}
+	if limit := settings.GetTokenLimit(tokenLimit); limit > 0 && tokenId != 0 {
+		count, err := model.CountInFlightTasksTx(tx, userId, tokenId)
+		if err != nil {
+			return err
+		}
+		if count >= int64(limit) {
+			return &taskConcurrencyLimitError{message: fmt.Sprintf("该令牌进行中的任务数已达上限（%d），请等待已有任务完成后再提交", limit)}
+		}
+	}
+	return nil
+}
+
+// ReserveTaskSlot 开启并发限制时，在锁定用户的事务中检查进行中的任务数并写入未开始的占位任务，
+// 并发提交不会同时通过检查；占位任务在提交期间推迟轮询。未开启限制时不写入，返回 false；
+// 达到上限时返回的错误匹配 ErrTaskConcurrencyLimit，其余错误为内部错误
+func ReserveTaskSlot(task *model.Task, userGroup string, tokenLimit int) (bool, error) {
+	if !system_setting.GetTaskConcurrencySettings().Enabled {
+		return false, nil
+	}
+	task.Status = model.TaskStatusNotStart
+	task.QueuedAt = 0
+	task.NextPollAt = time.Now().Unix() + taskDispatchLeaseSeconds
+	err := model.DB.Transaction(func(tx *gorm.DB) error {
+		if err := model.LockUserTasksTx(tx, task.UserId); err != nil {
+			return err
+		}
+		if err := checkTaskConcurrencyTx(tx, task.UserId, userGroup, task.TokenId, tokenLimit); err != nil {
+			return err
+		}
+		return tx.Create(task).Error
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CheckTaskQueue 检查超出并发上限的任务能否在网关排队，返回 nil 表示可以排队
+func CheckTaskQueue(userId int) error {
+	settings := system_setting.GetTaskConcurrencySettings()
+	if !settings.QueueEnabled {
+		return ErrTaskQueueDisabled
+	}
+	if settings.MaxQueuedPerUser <= 0 {
+		return nil
+	}
+	count, err := model.CountQueuedTasks(userId)
+	if err != nil {
+		return err
+	}
+	if count >= int64(settings.MaxQueuedPerUser) {
+		return fmt.Errorf("排队中的任务数已达上限（%d），请稍后再提交", settings.MaxQueuedPerUser)
+	}
+	return nil
+}
+
+// cleanupStaleReservedTasks 删除超过提交租约仍未写入上游任务 ID 的占位任务，释放其占用的并发名额
+func cleanupStaleReservedTasks(ctx context.Context) {
+	deleted, err := model.DeleteStaleReservedTasks(time.Now().Unix()-taskDispatchLeaseSeconds, taskQueueBatchSize)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("cleanup stale reserved tasks failed: %v", err))
+		return
+	}
+	if deleted > 0 {
+		logger.LogInfo(ctx, fmt.Sprintf("cleaned up %d stale reserved tasks", deleted))
+	}
+}
+
+// dispatchQueuedTasks 按排队先后提交有空位的任务；同一用户的任务严格先进先出，
+// 队首任务仍无空位时跳过该用户后续的任务
+func dispatchQueuedTasks(ctx context.Context) {
+	if DispatchQueuedTaskFunc == nil {
+		return
+	}
+	tasks := model.GetQueuedTasks(taskQueueBatchSize)
+	if len(tasks) == 0 {
+		return
+	}
+	settings := system_setting.GetTaskConcurrencySettings()
+	blocked := make(map[int]bool)
+	dispatched := 0
+	for _, task := range tasks {
+		if blocked[task.UserId] {
+			continue
+		}
+		now := time.Now().Unix()
+		if settings.QueueTimeoutMinutes > 0 && now-task.QueuedAt > int64(settings.QueueTimeoutMinutes)*60 {
+			failQueuedTask(ctx, task, fmt.Sprintf("排队超时（%d分钟）", settings.QueueTimeoutMinutes))
+			continue
+		}
+		won, err := claimQueuedTaskSlot(task, now+taskDispatchLeaseSeconds)
+		if err != nil {
+			// 无空位或认领出错时都跳过该用户后续的任务，保证先进先出
+			blocked[task.UserId] = true
+			if !errors.Is(err, ErrTaskConcurrencyLimit) {
+				logger.LogError(ctx, fmt.Sprintf("claim queued task %s failed: %v", task.TaskID, err))
+			}
+			continue
+		}
+		if !won {
+			continue
+		}
+		task.QueuedAt = 0
+		task.Status = model.TaskStatusSubmitted
+		task.Progress = taskcommon.ProgressSubmitted
+		task.NextPollAt = now + taskDispatchLeaseSeconds
+
+		if err = DispatchQueuedTaskFunc(ctx, task); err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("dispatch queued task %s failed: %v", task.TaskID, err))
+			failDispatchedTask(ctx, task, fmt.Sprintf("排队任务提交失败：%s", err.Error()))
+			continue
+		}
+		dispatched++
+	}
+	if dispatched > 0 {
+		logger.LogInfo(ctx, fmt.Sprintf("dispatched %d queued tasks", dispatched))
+	}
+}
+
+// claimQueuedTaskSlot 按任务所属用户与令牌的当前配置检查是否有空位，有空位时在同一事务中认领任务；
+// 无空位时返回匹配 ErrTaskConcurrencyLimit 的错误
+func claimQueuedTaskSlot(task *model.Task, nextPollAt int64) (bool, error) {
+	userCache, err := model.GetUserCache(task.UserId)
+	if err != nil {
+		return false, err
+	}
+	tokenLimit := 0
+	if task.TokenId != 0 {
+		if token, err := model.GetTokenById(task.TokenId); err == nil {
+			tokenLimit = token.TaskLimit
+		}
+	}
+	won := false
+	err = model.DB.Transaction(func(tx *gorm.DB) error {
+		if err := model.LockUserTasksTx(tx, task.UserId); err != nil {
+			return err
+		}
+		if err := checkTaskConcurrencyTx(tx, task.UserId, userCache.Group, task.TokenId, tokenLimit); err != nil {
+			return err
+		}
+		claimed, err := model.ClaimQueuedTaskTx(tx, task.ID, task.QueuedAt, nextPollAt)
+		if err != nil {
+			return err
+		}
+		won = claimed
+		return nil
+	})
+	return won, err
+}
+
+// failQueuedTask 排队任务尚未扣费，置为失败即可
+func failQueuedTask(ctx context.Context, task *model.Task, reason string) {
+	queuedAt := task.QueuedAt
+	won, err := model.ClaimQueuedTask(task.ID, queuedAt, 0)
+	if err != nil || !won {
+		return
+	}
+	task.QueuedAt = 0
+	failDispatchedTask(ctx, task, reason)
+}
+
+func failDispatchedTask(ctx context.Context, task *model.Task, reason string) {
+	task.Status = model.TaskStatusFailure
+	task.Progress = taskcommon.ProgressComplete
+	task.FinishTime = time.Now().Unix()
+	task.FailReason = reason
+	if _, err := task.UpdateWithStatus(model.TaskStatusSubmitted); err != nil {
+		common.SysError(fmt.Sprintf("fail queued task %s error: %v", task.TaskID, err))
+	}
+}
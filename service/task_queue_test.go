@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDispatchQueuedTasks_FIFOPerUserWithinLimit(t *testing.T) {
+	truncate(t)
+	seedUser(t, 1, 10000)
+
+	settings := system_setting.GetTaskConcurrencySettings()
+	saved := *settings
+	settings.Enabled = true
+	settings.DefaultUserLimit = 1
+	settings.QueueEnabled = true
+	settings.QueueTimeoutMinutes = 60
+	savedHook := DispatchQueuedTaskFunc
+	t.Cleanup(func() {
+		*settings = saved
+		DispatchQueuedTaskFunc = savedHook
+	})
+
+	var dispatched []string
+	DispatchQueuedTaskFunc = func(ctx context.Context, task *model.Task) error {
+		dispatched = append(dispatched, task.TaskID)
+		return nil
+	}
+
+	running := makeTask(1, 1, 0, 0, "", 0)
+	running.TaskID = "task_running"
+	require.NoError(t, model.DB.Create(running).Error)
+	for i := 1; i <= 2; i++ {
+		queued := makeTask(1, 0, 0, 0, "", 0)
+		queued.TaskID = fmt.Sprintf("task_queued_%d", i)
+		queued.Status = model.TaskStatusQueued
+		queued.QueuedAt = time.Now().Unix()
+		require.NoError(t, model.DB.Create(queued).Error)
+	}
+
+	require.ErrorIs(t, CheckTaskConcurrency(1, "default", 0, 0), ErrTaskConcurrencyLimit)
+	dispatchQueuedTasks(context.Background())
+	require.Empty(t, dispatched)
+
+	require.NoError(t, model.DB.Model(&model.Task{}).Where("task_id = ?", "task_running").
+		Update("status", model.TaskStatusSuccess).Error)
+	dispatchQueuedTasks(context.Background())
+	require.Equal(t, []string{"task_queued_1"}, dispatched)
+
+	count, err := model.CountQueuedTasks(1)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, count)
+}
+
+func TestDispatchQueuedTasks_ExpiresTimedOutTask(t *testing.T) {
+	truncate(t)
+	seedUser(t, 1, 10000)
+
+	settings := system_setting.GetTaskConcurrencySettings()
+	saved := *settings
+	settings.QueueTimeoutMinutes = 1
+	savedHook := DispatchQueuedTaskFunc
+	t.Cleanup(func() {
+		*settings = saved
+		DispatchQueuedTaskFunc = savedHook
+	})
+	DispatchQueuedTaskFunc = func(ctx context.Context, task *model.Task) error {
+		t.Fatalf("expired task %s should not be dispatched", task.TaskID)
+		return nil
+	}
+
+	queued := makeTask(1, 0, 0, 0, "", 0)
+	queued.Status = model.TaskStatusQueued
+	queued.QueuedAt = time.Now().Unix() - 120
+	require.NoError(t, model.DB.Create(queued).Error)
+
+	dispatchQueuedTasks(context.Background())
+
+	reloaded, exist, err := model.GetByOnlyTaskId(queued.TaskID)
+	require.NoError(t, err)
+	require.True(t, exist)
+	require.EqualValues(t, model.TaskStatusFailure, reloaded.Status)
+	require.Zero(t, reloaded.QueuedAt)
+	require.NotEmpty(t, reloaded.FailReason)
+}
+
+func TestReserveTaskSlot_ConcurrentSubmissionsRespectLimit(t *testing.T) {
+	truncate(t)
+	seedUser(t, 1, 10000)
+
+	settings := system_setting.GetTaskConcurrencySettings()
+	saved := *settings
+	settings.Enabled = true
+	settings.DefaultUserLimit = 2
+	t.Cleanup(func() { *settings = saved })
+
+	var reserved atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			task := makeTask(1, 0, 0, 0, "", 0)
+			task.TaskID = fmt.Sprintf("task_reserved_%d", i)
+			if ok, err := ReserveTaskSlot(task, "default", 0); err == nil && ok {
+				reserved.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	require.EqualValues(t, 2, reserved.Load())
+	count, err := model.CountInFlightTasks(1, 0)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, count)
+
+	_, err = ReserveTaskSlot(makeTask(1, 0, 0, 0, "", 0), "default", 0)
+	require.ErrorIs(t, err, ErrTaskConcurrencyLimit)
+}
+
+func TestReserveTaskSlot_InternalErrorIsNotLimit(t *testing.T) {
+	truncate(t)
+	seedUser(t, 1, 10000)
+
+	settings := system_setting.GetTaskConcurrencySettings()
+	saved := *settings
+	settings.Enabled = true
+	settings.DefaultUserLimit = 5
+	t.Cleanup(func() { *settings = saved })
+
+	existing := makeTask(1, 0, 0, 0, "", 0)
+	require.NoError(t, model.DB.Create(existing).Error)
+
+	// 主键冲突导致写入失败，不应被当作达到并发上限
+	duplicate := makeTask(1, 0, 0, 0, "", 0)
+	duplicate.ID = existing.ID
+	_, err := ReserveTaskSlot(duplicate, "default", 0)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrTaskConcurrencyLimit)
+}
+
+func TestCleanupStaleReservedTasks(t *testing.T) {
+	truncate(t)
+	seedUser(t, 1, 10000)
+
+	old := time.Now().Unix() - taskDispatchLeaseSeconds - 60
+	newReserved := func(taskID string, createdAt int64, upstreamID string) {
+		task := makeTask(1, 1, 0, 0, "", 0)
+		task.TaskID = taskID
+		task.Status = model.TaskStatusNotStart
+		task.CreatedAt = createdAt
+		task.PrivateData.UpstreamTaskID = upstreamID
+		require.NoError(t, model.DB.Create(task).Error)
+	}
+	newReserved("task_stranded", old, "")
+	newReserved("task_submitting", time.Now().Unix(), "")
+	newReserved("task_upstream_pending", old, "upstream_1")
+
+	cleanupStaleReservedTasks(context.Background())
+
+	var remaining []string
+	require.NoError(t, model.DB.Model(&model.Task{}).Order("id").Pluck("task_id", &remaining).Error)
+	require.Equal(t, []string{"task_submitting", "task_upstream_pending"}, remaining)
+}
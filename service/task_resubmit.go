@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel/task/taskcommon"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+)
+
+// TaskResubmitResult 重新提交成功后的上游信息
+type TaskResubmitResult struct {
+	ChannelId      int
+	Platform       constant.TaskPlatform
+	UpstreamTaskID string
+	Key            string
+	Data           []byte
+}
+
+// ResubmitTaskFunc 由 main 包注入，将任务保存的原始请求提交到未尝试过的其他渠道
+var ResubmitTaskFunc func(ctx context.Context, task *model.Task, excludeChannelIds []int) (*TaskResubmitResult, error)
+
+// ShouldStoreTaskResubmitRequest 模型配置了重新提交策略时才保存原始请求
+func ShouldStoreTaskResubmitRequest(modelName string) bool {
+	return system_setting.GetTaskResubmitSettings().GetPolicy(modelName) != nil
+}
+
+func shouldResubmitTask(task *model.Task, reason string, timedOut bool) bool {
+	if ResubmitTaskFunc == nil || task.PrivateData.ResubmitRequest == nil {
+		return false
+	}
+	if task.Platform == constant.TaskPlatformSuno || task.Platform == constant.TaskPlatformMidjourney {
+		return false
+	}
+	policy := system_setting.GetTaskResubmitSettings().GetPolicy(task.Properties.OriginModelName)
+	if policy == nil || len(task.PrivateData.Attempts) >= policy.MaxAttempts {
+		return false
+	}
+	if timedOut {
+		return policy.RetryOnTimeout
+	}
+	lowerReason := strings.ToLower(reason)
+	for _, keyword := range policy.ReasonKeywords {
+		if keyword != "" && strings.Contains(lowerReason, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+// tryResubmitFailedTask 在任务即将以 reason 失败时尝试换渠道重新提交，沿用原公开任务 ID 与预扣费。
+// 返回 true 表示任务已由本函数处理（重新提交成功、重新提交失败后已置为失败并退款、或已被其他进程推进），
+// 调用方不应再做失败处理；返回 false 时任务未被修改，调用方按原逻辑处理。
+func tryResubmitFailedTask(ctx context.Context, task *model.Task, fromStatus model.TaskStatus, reason string, timedOut bool) bool {
+	if !shouldResubmitTask(task, reason, timedOut) {
+		return false
+	}
+	now := time.Now().Unix()
+	original := *task
+	original.PrivateData.Attempts = append([]model.TaskAttempt(nil), task.PrivateData.Attempts...)
+
+	// 先以 status + resubmit_count 认领，避免轮询与超时清理并发重复提交；认领期间轮询租约推迟，防止其他节点取到
+	task.PrivateData.Attempts = append(task.PrivateData.Attempts, model.TaskAttempt{
+		ChannelId:      task.ChannelId,
+		UpstreamTaskID: task.GetUpstreamTaskID(),
+		SubmitTime:     task.SubmitTime,
+		FailedAt:       now,
+		Reason:         reason,
+	})
+	task.Status = model.TaskStatusSubmitted
+	task.Progress = taskcommon.ProgressSubmitted
+	task.FailReason = ""
+	task.StartTime = 0
+	task.FinishTime = 0
+	task.NextPollAt = now + 300
+	won, err := task.ClaimResubmit(fromStatus)
+	if err != nil || !won {
+		if err != nil {
+			logger.LogError(ctx, fmt.Sprintf("claim task %s for resubmission failed: %v", task.TaskID, err))
+		}
+		*task = original
+		// 出错时交由下一轮处理；CAS 失败说明任务已被其他进程推进
+		return true
+	}
+
+	excluded := make([]int, 0, len(task.PrivateData.Attempts))
+	for _, attempt := range task.PrivateData.Attempts {
+		excluded = append(excluded, attempt.ChannelId)
+	}
+	result, err := ResubmitTaskFunc(ctx, task, excluded)
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("resubmit task %s failed: %v", task.TaskID, err))
+		failResubmittedTask(ctx, task, reason)
+		return true
+	}
+
+	task.ChannelId = result.ChannelId
+	task.Platform = result.Platform
+	task.PrivateData.UpstreamTaskID = result.UpstreamTaskID
+	task.PrivateData.Key = result.Key
+	task.PrivateData.ResultURL = ""
+	task.Data = result.Data
+	task.SubmitTime = time.Now().Unix()
+	task.NextPollAt = 0
+	if _, err = task.UpdateWithStatus(model.TaskStatusSubmitted); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("save resubmitted task %s failed: %v", task.TaskID, err))
+	}
+	logger.LogInfo(ctx, fmt.Sprintf("task %s resubmitted to channel #%d (attempt %d), previous failure: %s",
+		task.TaskID, result.ChannelId, len(task.PrivateData.Attempts)+1, reason))
+	return true
+}
+
+// failResubmittedTask 重新提交失败时按原失败原因结束任务并退款
+func failResubmittedTask(ctx context.Context, task *model.Task, reason string) {
+	task.Status = model.TaskStatusFailure
+	task.Progress = taskcommon.ProgressComplete
+	task.FinishTime = time.Now().Unix()
+	task.FailReason = reason
+	won, err := task.UpdateWithStatus(model.TaskStatusSubmitted)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("fail resubmitted task %s error: %v", task.TaskID, err))
+		return
+	}
+	if won && task.Quota != 0 {
+		RefundTaskQuota(ctx, task, reason)
+	}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func enableTaskResubmit(t *testing.T, maxAttempts int) {
+	t.Helper()
+	settings := system_setting.GetTaskResubmitSettings()
+	origin := *settings
+	settings.Enabled = true
+	settings.Models = map[string]system_setting.TaskResubmitPolicy{
+		"test-model": {MaxAttempts: maxAttempts, RetryOnTimeout: true},
+	}
+	t.Cleanup(func() { *settings = origin })
+}
+
+func TestTryResubmitFailedTask_MovesToOtherChannel(t *testing.T) {
+	truncate(t)
+	ctx := context.Background()
+	enableTaskResubmit(t, 1)
+
+	var excluded []int
+	origin := ResubmitTaskFunc
+	ResubmitTaskFunc = func(_ context.Context, _ *model.Task, exclude []int) (*TaskResubmitResult, error) {
+		excluded = exclude
+		return &TaskResubmitResult{ChannelId: 61, Platform: "45", UpstreamTaskID: "upstream_2", Data: []byte(`{}`)}, nil
+	}
+	t.Cleanup(func() { ResubmitTaskFunc = origin })
+
+	const userID, tokenID, channelID = 60, 60, 60
+	const initQuota, preConsumed = 10000, 2000
+	seedUser(t, userID, initQuota)
+	seedToken(t, tokenID, userID, "sk-resubmit", 5000)
+	seedChannel(t, channelID)
+
+	task := makeTask(userID, channelID, preConsumed, tokenID, BillingSourceWallet, 0)
+	task.Platform = "45"
+	task.PrivateData.UpstreamTaskID = "upstream_1"
+	task.PrivateData.ResubmitRequest = &model.TaskResubmitRequest{Path: "/v1/video/generations", Body: []byte(`{"model":"test-model"}`)}
+	require.NoError(t, model.DB.Create(task).Error)
+
+	// 非临时性失败不重新提交
+	assert.False(t, tryResubmitFailedTask(ctx, task, model.TaskStatusInProgress, "invalid prompt", false))
+
+	require.True(t, tryResubmitFailedTask(ctx, task, model.TaskStatusInProgress, "server capacity exceeded", false))
+	assert.Equal(t, []int{channelID}, excluded)
+
+	var reloaded model.Task
+	require.NoError(t, model.DB.First(&reloaded, task.ID).Error)
+	assert.Equal(t, model.TaskStatus(model.TaskStatusSubmitted), reloaded.Status)
+	assert.Equal(t, 61, reloaded.ChannelId)
+	assert.Equal(t, "upstream_2", reloaded.GetUpstreamTaskID())
+	require.Len(t, reloaded.PrivateData.Attempts, 1)
+	assert.Equal(t, "upstream_1", reloaded.PrivateData.Attempts[0].UpstreamTaskID)
+	// 重新提交不退款也不重复扣费
+	assert.Equal(t, initQuota, getUserQuota(t, userID))
+
+	// 达到最大次数后交由调用方按失败处理
+	assert.False(t, tryResubmitFailedTask(ctx, &reloaded, model.TaskStatusSubmitted, "server capacity exceeded", false))
+}
+
+func TestTryResubmitFailedTask_SubmitErrorRefunds(t *testing.T) {
+	truncate(t)
+	ctx := context.Background()
+	enableTaskResubmit(t, 2)
+
+	origin := ResubmitTaskFunc
+	ResubmitTaskFunc = func(context.Context, *model.Task, []int) (*TaskResubmitResult, error) {
+		return nil, errors.New("no other available channel")
+	}
+	t.Cleanup(func() { ResubmitTaskFunc = origin })
+
+	const userID, tokenID, channelID = 70, 70, 70
+	const initQuota, preConsumed = 10000, 3000
+	seedUser(t, userID, initQuota)
+	seedToken(t, tokenID, userID, "sk-resubmit-fail", 5000)
+	seedChannel(t, channelID)
+
+	task := makeTask(userID, channelID, preConsumed, tokenID, BillingSourceWallet, 0)
+	task.Platform = constant.TaskPlatform("45")
+	task.PrivateData.ResubmitRequest = &model.TaskResubmitRequest{Path: "/v1/video/generations", Body: []byte(`{}`)}
+	require.NoError(t, model.DB.Create(task).Error)
+
+	require.True(t, tryResubmitFailedTask(ctx, task, model.TaskStatusInProgress, "任务超时（30分钟）", true))
+
+	var reloaded model.Task
+	require.NoError(t, model.DB.First(&reloaded, task.ID).Error)
+	assert.Equal(t, model.TaskStatus(model.TaskStatusFailure), reloaded.Status)
+	assert.Equal(t, "任务超时（30分钟）", reloaded.FailReason)
+	assert.Equal(t, initQuota+preConsumed, getUserQuota(t, userID))
+}
+
+func TestTryResubmitFailedTask_ConcurrentClaimersSubmitOnce(t *testing.T) {
+	truncate(t)
+	ctx := context.Background()
+	enableTaskResubmit(t, 3)
+
+	var submits atomic.Int32
+	origin := ResubmitTaskFunc
+	ResubmitTaskFunc = func(context.Context, *model.Task, []int) (*TaskResubmitResult, error) {
+		submits.Add(1)
+		return &TaskResubmitResult{ChannelId: 81, Platform: "45", UpstreamTaskID: "upstream_2", Data: []byte(`{}`)}, nil
+	}
+	t.Cleanup(func() { ResubmitTaskFunc = origin })
+
+	const userID, tokenID, channelID = 80, 80, 80
+	seedUser(t, userID, 10000)
+	seedToken(t, tokenID, userID, "sk-resubmit-race", 5000)
+	seedChannel(t, channelID)
+
+	task := makeTask(userID, channelID, 2000, tokenID, BillingSourceWallet, 0)
+	task.Platform = "45"
+	task.Status = model.TaskStatusSubmitted
+	task.PrivateData.UpstreamTaskID = "upstream_1"
+	task.PrivateData.ResubmitRequest = &model.TaskResubmitRequest{Path: "/v1/video/generations", Body: []byte(`{"model":"test-model"}`)}
+	require.NoError(t, model.DB.Create(task).Error)
+
+	// 超时清理与轮询失败各自持有同一 SUBMITTED 任务的快照，同时尝试重新提交
+	claimers := make([]*model.Task, 2)
+	for i := range claimers {
+		var snapshot model.Task
+		require.NoError(t, model.DB.First(&snapshot, task.ID).Error)
+		claimers[i] = &snapshot
+	}
+	var wg sync.WaitGroup
+	for i, claimer := range claimers {
+		wg.Add(1)
+		go func(claimer *model.Task, timedOut bool) {
+			defer wg.Done()
+			tryResubmitFailedTask(ctx, claimer, model.TaskStatusSubmitted, "server capacity exceeded", timedOut)
+		}(claimer, i == 0)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), submits.Load())
+	var reloaded model.Task
+	require.NoError(t, model.DB.First(&reloaded, task.ID).Error)
+	assert.Equal(t, 1, reloaded.ResubmitCount)
+	assert.Equal(t, 81, reloaded.ChannelId)
+	require.Len(t, reloaded.PrivateData.Attempts, 1)
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+)
+
+// ErrTaskWaitTimeout 同步等待超时，任务仍由轮询在后台继续完成
+var ErrTaskWaitTimeout = errors.New("task wait timeout")
+
+// WaitTaskResult 同步等待任务到达终态：按间隔重新加载任务（轮询或回调可能已推进），
+// 未完成时主动查询上游，状态更新与轮询共用 CAS 逻辑，结算与退款不会重复。
+// 返回最新任务；超时返回 ErrTaskWaitTimeout，客户端断开返回 ctx.Err()
+func WaitTaskResult(ctx context.Context, task *model.Task, timeout, interval time.Duration) (*model.Task, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return task, ctx.Err()
+		case <-timer.C:
+			return task, ErrTaskWaitTimeout
+		case <-ticker.C:
+		}
+
+		if latest, exist, err := model.GetByOnlyTaskId(task.TaskID); err == nil && exist {
+			task = latest
+		}
+		if isTaskFinished(task) {
+			return task, nil
+		}
+		if err := refreshTaskFromUpstream(ctx, task); err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("wait task %s: fetch upstream failed: %s", task.TaskID, err.Error()))
+			continue
+		}
+		if isTaskFinished(task) {
+			return task, nil
+		}
+	}
+}
+
+func isTaskFinished(task *model.Task) bool {
+	return task.Status == model.TaskStatusSuccess || task.Status == model.TaskStatusFailure
+}
+
+// refreshTaskFromUpstream 查询单个任务的上游状态并更新
+func refreshTaskFromUpstream(ctx context.Context, task *model.Task) error {
+	adaptor := GetTaskAdaptorFunc(task.Platform)
+	if adaptor == nil {
+		return fmt.Errorf("task adaptor not found for platform %s", task.Platform)
+	}
+	ch, err := model.CacheGetChannel(task.ChannelId)
+	if err != nil {
+		return err
+	}
+	info := &relaycommon.RelayInfo{}
+	info.ChannelMeta = &relaycommon.ChannelMeta{
+		ChannelBaseUrl: ch.GetBaseURL(),
+	}
+	info.ApiKey = resolveTaskKey(ch, task)
+	adaptor.Init(info)
+
+	start := time.Now()
+	upstreamID := task.GetUpstreamTaskID()
+	err = updateVideoSingleTask(ctx, adaptor, ch, upstreamID, map[string]*model.Task{upstreamID: task})
+	recordTaskPoll(task.Platform, time.Since(start), err)
+	return err
+}
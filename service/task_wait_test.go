@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockWaitAdaptor struct {
+	mockAdaptor
+	failReason string
+}
+
+func (m *mockWaitAdaptor) FetchTask(string, string, map[string]any, string) (*http.Response, error) {
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(m.failReason))}, nil
+}
+
+func (m *mockWaitAdaptor) ParseTaskResult(body []byte) (*relaycommon.TaskInfo, error) {
+	if len(body) == 0 {
+		return &relaycommon.TaskInfo{Status: model.TaskStatusInProgress, Progress: "30%"}, nil
+	}
+	return relaycommon.FailTaskInfo(string(body)), nil
+}
+
+func TestWaitTaskResult_FailureRefunds(t *testing.T) {
+	truncate(t)
+	ctx := context.Background()
+
+	origin := GetTaskAdaptorFunc
+	GetTaskAdaptorFunc = func(constant.TaskPlatform) TaskPollingAdaptor {
+		return &mockWaitAdaptor{failReason: "content rejected"}
+	}
+	t.Cleanup(func() { GetTaskAdaptorFunc = origin })
+
+	const userID, tokenID, channelID = 60, 60, 60
+	const initQuota, preConsumed = 10000, 2000
+	seedUser(t, userID, initQuota)
+	seedToken(t, tokenID, userID, "sk-wait-failure", 5000)
+	seedChannel(t, channelID)
+
+	task := makeTask(userID, channelID, preConsumed, tokenID, BillingSourceWallet, 0)
+	require.NoError(t, model.DB.Create(task).Error)
+
+	result, err := WaitTaskResult(ctx, task, time.Second, 10*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, model.TaskStatus(model.TaskStatusFailure), result.Status)
+	assert.Equal(t, "content rejected", result.FailReason)
+	assert.Equal(t, initQuota+preConsumed, getUserQuota(t, userID))
+}
+
+func TestWaitTaskResult_Timeout(t *testing.T) {
+	truncate(t)
+	ctx := context.Background()
+
+	origin := GetTaskAdaptorFunc
+	GetTaskAdaptorFunc = func(constant.TaskPlatform) TaskPollingAdaptor { return &mockWaitAdaptor{} }
+	t.Cleanup(func() { GetTaskAdaptorFunc = origin })
+
+	const userID, tokenID, channelID = 61, 61, 61
+	seedUser(t, userID, 10000)
+	seedToken(t, tokenID, userID, "sk-wait-timeout", 5000)
+	seedChannel(t, channelID)
+
+	task := makeTask(userID, channelID, 2000, tokenID, BillingSourceWallet, 0)
+	require.NoError(t, model.DB.Create(task).Error)
+
+	result, err := WaitTaskResult(ctx, task, 50*time.Millisecond, 10*time.Millisecond)
+	require.ErrorIs(t, err, ErrTaskWaitTimeout)
+	assert.Equal(t, model.TaskStatus(model.TaskStatusInProgress), result.Status)
+}
+
+type keyRecordingAdaptor struct {
+	mockWaitAdaptor
+	initKey  string
+	fetchKey string
+}
+
+func (m *keyRecordingAdaptor) Init(info *relaycommon.RelayInfo) { m.initKey = info.ApiKey }
+
+func (m *keyRecordingAdaptor) FetchTask(_ string, key string, _ map[string]any, _ string) (*http.Response, error) {
+	m.fetchKey = key
+	return m.mockWaitAdaptor.FetchTask("", key, nil, "")
+}
+
+func TestRefreshTaskFromUpstream_UsesTaskKey(t *testing.T) {
+	truncate(t)
+	ctx := context.Background()
+
+	adaptor := &keyRecordingAdaptor{}
+	origin := GetTaskAdaptorFunc
+	GetTaskAdaptorFunc = func(constant.TaskPlatform) TaskPollingAdaptor { return adaptor }
+	t.Cleanup(func() { GetTaskAdaptorFunc = origin })
+
+	const userID, tokenID, channelID = 62, 62, 62
+	seedUser(t, userID, 10000)
+	seedToken(t, tokenID, userID, "sk-wait-key", 5000)
+	ch := &model.Channel{Id: channelID, Name: "multi_key_channel", Key: "sk-a\nsk-b", Status: common.ChannelStatusEnabled}
+	ch.ChannelInfo.IsMultiKey = true
+	require.NoError(t, model.DB.Create(ch).Error)
+
+	// 提交时记录了 key 的任务沿用该 key
+	task := makeTask(userID, channelID, 2000, tokenID, BillingSourceWallet, 0)
+	task.PrivateData.Key = "sk-b"
+	require.NoError(t, model.DB.Create(task).Error)
+	require.NoError(t, refreshTaskFromUpstream(ctx, task))
+	assert.Equal(t, "sk-b", adaptor.initKey)
+	assert.Equal(t, "sk-b", adaptor.fetchKey)
+
+	// 未记录 key 的多 key 渠道任务不会把整串 key 发往上游
+	legacy := makeTask(userID, channelID, 2000, tokenID, BillingSourceWallet, 0)
+	legacy.TaskID = "task_legacy_multi_key"
+	require.NoError(t, model.DB.Create(legacy).Error)
+	require.NoError(t, refreshTaskFromUpstream(ctx, legacy))
+	assert.Equal(t, "sk-a", adaptor.initKey)
+	assert.Equal(t, "sk-a", adaptor.fetchKey)
+}
@@ -0,0 +1,380 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+)
+
+// 工具调用模拟：对不支持原生 function calling 的上游，将 tools/tool_choice 改写为系统提示词协议，
+// 再从模型的文本输出中解析出 OpenAI 格式的 tool_calls。
+const (
+	emulatedToolCallOpenTag  = "<tool_call>"
+	emulatedToolCallCloseTag = "</tool_call>"
+)
+
+type emulatedToolCall struct {
+	Name      string `json:"name"`
+	Arguments any    `json:"arguments"`
+}
+
+// ApplyToolEmulation 在渠道开启工具模拟时改写请求，返回是否进行了改写
+func ApplyToolEmulation(info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) bool {
+	if info == nil || info.ChannelMeta == nil || request == nil || !info.ChannelSetting.EmulateTools {
+		return false
+	}
+	hasToolHistory := false
+	for _, message := range request.Messages {
+		if message.Role == "tool" || len(message.ToolCalls) > 0 {
+			hasToolHistory = true
+			break
+		}
+	}
+	if len(request.Tools) == 0 && !hasToolHistory {
+		return false
+	}
+
+	toolPrompt := buildToolEmulationPrompt(request.Tools, request.ToolChoice)
+	request.Messages = convertToolMessagesForEmulation(request.Messages)
+	if toolPrompt != "" {
+		request.Messages = prependSystemPrompt(request.Messages, toolPrompt)
+	}
+	request.Tools = nil
+	request.ToolChoice = nil
+	request.ParallelTooCalls = nil
+	info.ToolEmulated = true
+	return true
+}
+
+func buildToolEmulationPrompt(tools []dto.ToolCallRequest, toolChoice any) string {
+	functions := make([]dto.ToolCallRequest, 0, len(tools))
+	for _, tool := range tools {
+		if tool.Type == "" || tool.Type == "function" {
+			functions = append(functions, tool)
+		}
+	}
+	if len(functions) == 0 {
+		return ""
+	}
+
+	requirement := "Call a tool only when it is needed to answer; otherwise reply normally without any <tool_call> block."
+	switch choice := toolChoice.(type) {
+	case string:
+		switch choice {
+		case "none":
+			return ""
+		case "required", "any":
+			requirement = "You MUST call at least one tool in this reply."
+		}
+	case map[string]any:
+		if function, ok := choice["function"].(map[string]any); ok {
+			if name, ok := function["name"].(string); ok && name != "" {
+				requirement = fmt.Sprintf("You MUST call the tool %q in this reply.", name)
+			}
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("You can call the following tools. To call a tool, reply with one block per call in exactly this format and put nothing else inside the block:\n")
+	sb.WriteString(emulatedToolCallOpenTag + "\n")
+	sb.WriteString(`{"name": "<tool name>", "arguments": {<arguments as a JSON object>}}` + "\n")
+	sb.WriteString(emulatedToolCallCloseTag + "\n")
+	sb.WriteString("Arguments must be valid JSON matching the tool's parameters schema. ")
+	sb.WriteString("Tool results will be returned to you inside <tool_result> blocks.\n")
+	sb.WriteString(requirement)
+	sb.WriteString("\n\nAvailable tools:\n")
+	for _, tool := range functions {
+		sb.WriteString("- ")
+		sb.WriteString(tool.Function.Name)
+		if tool.Function.Description != "" {
+			sb.WriteString(": ")
+			sb.WriteString(tool.Function.Description)
+		}
+		sb.WriteString("\n")
+		if tool.Function.Parameters != nil {
+			if parameters, err := common.Marshal(tool.Function.Parameters); err == nil {
+				sb.WriteString("  parameters: ")
+				sb.Write(parameters)
+				sb.WriteString("\n")
+			}
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// convertToolMessagesForEmulation 将历史中的 assistant tool_calls 与 tool 消息改写为文本协议
+func convertToolMessagesForEmulation(messages []dto.Message) []dto.Message {
+	toolNames := make(map[string]string)
+	converted := make([]dto.Message, 0, len(messages))
+	for _, message := range messages {
+		switch {
+		case message.Role == "assistant" && len(message.ToolCalls) > 0:
+			var sb strings.Builder
+			if text := message.StringContent(); text != "" {
+				sb.WriteString(text)
+				sb.WriteString("\n")
+			}
+			for _, toolCall := range message.ParseToolCalls() {
+				toolNames[toolCall.ID] = toolCall.Function.Name
+				var arguments any = map[string]any{}
+				if strings.TrimSpace(toolCall.Function.Arguments) != "" {
+					if err := common.UnmarshalJsonStr(toolCall.Function.Arguments, &arguments); err != nil {
+						arguments = toolCall.Function.Arguments
+					}
+				}
+				block, _ := common.Marshal(emulatedToolCall{Name: toolCall.Function.Name, Arguments: arguments})
+				sb.WriteString(emulatedToolCallOpenTag + "\n")
+				sb.Write(block)
+				sb.WriteString("\n" + emulatedToolCallCloseTag + "\n")
+			}
+			converted = append(converted, dto.Message{
+				Role:    "assistant",
+				Content: strings.TrimRight(sb.String(), "\n"),
+			})
+		case message.Role == "tool":
+			name := toolNames[message.ToolCallId]
+			if name == "" && message.Name != nil {
+				name = *message.Name
+			}
+			content := fmt.Sprintf("<tool_result name=%q tool_call_id=%q>\n%s\n</tool_result>", name, message.ToolCallId, message.StringContent())
+			// 连续的工具结果合并为一条 user 消息
+			if last := len(converted) - 1; last >= 0 && converted[last].Role == "user" && strings.HasPrefix(converted[last].StringContent(), "<tool_result") {
+				converted[last].SetStringContent(converted[last].StringContent() + "\n" + content)
+				continue
+			}
+			converted = append(converted, dto.Message{Role: "user", Content: content})
+		default:
+			converted = append(converted, message)
+		}
+	}
+	return converted
+}
+
+func prependSystemPrompt(messages []dto.Message, prompt string) []dto.Message {
+	if len(messages) > 0 && (messages[0].Role == "system" || messages[0].Role == "developer") {
+		if messages[0].IsStringContent() {
+			messages[0].SetStringContent(messages[0].StringContent() + "\n\n" + prompt)
+		} else {
+			contents := append(messages[0].ParseContent(), dto.MediaContent{Type: dto.ContentTypeText, Text: prompt})
+			messages[0].SetMediaContent(contents)
+		}
+		return messages
+	}
+	return append([]dto.Message{{Role: "system", Content: prompt}}, messages...)
+}
+
+func newEmulatedToolCallResponse(block string, index int) (dto.ToolCallResponse, bool) {
+	var call emulatedToolCall
+	if err := common.UnmarshalJsonStr(strings.TrimSpace(block), &call); err != nil || call.Name == "" {
+		return dto.ToolCallResponse{}, false
+	}
+	arguments := "{}"
+	switch args := call.Arguments.(type) {
+	case nil:
+	case string:
+		arguments = args
+	default:
+		if data, err := common.Marshal(args); err == nil {
+			arguments = string(data)
+		}
+	}
+	toolCall := dto.ToolCallResponse{
+		ID:   "call_" + common.GetRandomString(24),
+		Type: "function",
+		Function: dto.FunctionResponse{
+			Name:      call.Name,
+			Arguments: arguments,
+		},
+	}
+	toolCall.SetIndex(index)
+	return toolCall, true
+}
+
+// ParseEmulatedToolCalls 从文本中提取 <tool_call> 块，返回剩余文本与解析出的工具调用。
+// 无法解析的块原样保留在文本中。
+func ParseEmulatedToolCalls(text string) (string, []dto.ToolCallResponse) {
+	var toolCalls []dto.ToolCallResponse
+	var remaining strings.Builder
+	rest := text
+	for {
+		start := strings.Index(rest, emulatedToolCallOpenTag)
+		if start < 0 {
+			remaining.WriteString(rest)
+			break
+		}
+		end := strings.Index(rest[start:], emulatedToolCallCloseTag)
+		if end < 0 {
+			remaining.WriteString(rest)
+			break
+		}
+		end += start
+		block := rest[start+len(emulatedToolCallOpenTag) : end]
+		if toolCall, ok := newEmulatedToolCallResponse(block, len(toolCalls)); ok {
+			remaining.WriteString(rest[:start])
+			toolCalls = append(toolCalls, toolCall)
+		} else {
+			remaining.WriteString(rest[:end+len(emulatedToolCallCloseTag)])
+		}
+		rest = rest[end+len(emulatedToolCallCloseTag):]
+	}
+	content := remaining.String()
+	if len(toolCalls) > 0 {
+		content = strings.TrimSpace(content)
+	}
+	return content, toolCalls
+}
+
+// ApplyEmulatedToolCallsToResponse 将非流式响应文本中的工具调用块转换为 tool_calls
+func ApplyEmulatedToolCallsToResponse(response *dto.OpenAITextResponse) {
+	if response == nil {
+		return
+	}
+	for i := range response.Choices {
+		message := &response.Choices[i].Message
+		content, toolCalls := ParseEmulatedToolCalls(message.StringContent())
+		if len(toolCalls) == 0 {
+			continue
+		}
+		// 非流式响应的 tool_calls 不携带 index
+		for j := range toolCalls {
+			toolCalls[j].Index = nil
+		}
+		if content == "" {
+			message.Content = nil
+		} else {
+			message.SetStringContent(content)
+		}
+		message.SetToolCalls(toolCalls)
+		response.Choices[i].FinishReason = "tool_calls"
+	}
+}
+
+// ToolEmulationStreamParser 在流式输出中识别 <tool_call> 块并转换为 tool_calls delta，
+// 可能属于标签开头的文本会暂缓输出，直到能确定其归属。
+type ToolEmulationStreamParser struct {
+	choices map[int]*toolEmulationChoiceState
+}
+
+type toolEmulationChoiceState struct {
+	pending   strings.Builder
+	inBlock   bool
+	toolCount int
+}
+
+func NewToolEmulationStreamParser() *ToolEmulationStreamParser {
+	return &ToolEmulationStreamParser{choices: make(map[int]*toolEmulationChoiceState)}
+}
+
+// ProcessChunk 改写单个流式响应块
+func (p *ToolEmulationStreamParser) ProcessChunk(chunk *dto.ChatCompletionsStreamResponse) {
+	if p == nil || chunk == nil {
+		return
+	}
+	for i := range chunk.Choices {
+		choice := &chunk.Choices[i]
+		state, ok := p.choices[choice.Index]
+		if !ok {
+			state = &toolEmulationChoiceState{}
+			p.choices[choice.Index] = state
+		}
+		text, toolCalls := state.feed(choice.Delta.GetContentString())
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			text += state.flush()
+			if state.toolCount > 0 && *choice.FinishReason == "stop" {
+				finishReason := "tool_calls"
+				choice.FinishReason = &finishReason
+			}
+		}
+		if text == "" {
+			choice.Delta.Content = nil
+		} else {
+			choice.Delta.SetContentString(text)
+		}
+		if len(toolCalls) > 0 {
+			choice.Delta.ToolCalls = append(choice.Delta.ToolCalls, toolCalls...)
+		}
+	}
+}
+
+// ProcessChunkString 改写 JSON 格式的流式响应块，无法解析时原样返回
+func (p *ToolEmulationStreamParser) ProcessChunkString(data string) string {
+	if p == nil || data == "" {
+		return data
+	}
+	var chunk dto.ChatCompletionsStreamResponse
+	if err := common.UnmarshalJsonStr(data, &chunk); err != nil {
+		return data
+	}
+	p.ProcessChunk(&chunk)
+	rewritten, err := common.Marshal(chunk)
+	if err != nil {
+		return data
+	}
+	return string(rewritten)
+}
+
+func (s *toolEmulationChoiceState) feed(delta string) (string, []dto.ToolCallResponse) {
+	s.pending.WriteString(delta)
+	buffer := s.pending.String()
+	var output strings.Builder
+	var toolCalls []dto.ToolCallResponse
+	for {
+		if s.inBlock {
+			end := strings.Index(buffer, emulatedToolCallCloseTag)
+			if end < 0 {
+				break
+			}
+			block := buffer[:end]
+			if toolCall, ok := newEmulatedToolCallResponse(block, s.toolCount); ok {
+				s.toolCount++
+				toolCalls = append(toolCalls, toolCall)
+			} else {
+				output.WriteString(emulatedToolCallOpenTag + block + emulatedToolCallCloseTag)
+			}
+			buffer = buffer[end+len(emulatedToolCallCloseTag):]
+			s.inBlock = false
+			continue
+		}
+		start := strings.Index(buffer, emulatedToolCallOpenTag)
+		if start >= 0 {
+			output.WriteString(buffer[:start])
+			buffer = buffer[start+len(emulatedToolCallOpenTag):]
+			s.inBlock = true
+			continue
+		}
+		// 保留可能是标签开头的尾部文本
+		keep := partialTagSuffixLength(buffer, emulatedToolCallOpenTag)
+		output.WriteString(buffer[:len(buffer)-keep])
+		buffer = buffer[len(buffer)-keep:]
+		break
+	}
+	s.pending.Reset()
+	s.pending.WriteString(buffer)
+	return output.String(), toolCalls
+}
+
+func (s *toolEmulationChoiceState) flush() string {
+	rest := s.pending.String()
+	s.pending.Reset()
+	if s.inBlock {
+		s.inBlock = false
+		return emulatedToolCallOpenTag + rest
+	}
+	return rest
+}
+
+func partialTagSuffixLength(text string, tag string) int {
+	maxLen := len(tag) - 1
+	if maxLen > len(text) {
+		maxLen = len(text)
+	}
+	for length := maxLen; length > 0; length-- {
+		if strings.HasSuffix(text, tag[:length]) {
+			return length
+		}
+	}
+	return 0
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyToolEmulation_RewritesToolsAndHistory(t *testing.T) {
+	t.Parallel()
+
+	var request dto.GeneralOpenAIRequest
+	require.NoError(t, common.UnmarshalJsonStr(`{
+		"model": "llama2",
+		"messages": [
+			{"role": "user", "content": "weather in Paris?"},
+			{"role": "assistant", "content": null, "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Paris\"}"}}]},
+			{"role": "tool", "tool_call_id": "call_1", "content": "sunny"}
+		],
+		"tools": [{"type": "function", "function": {"name": "get_weather", "description": "Get weather", "parameters": {"type": "object"}}}],
+		"tool_choice": "required"
+	}`, &request))
+
+	info := &relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{ChannelSetting: dto.ChannelSettings{EmulateTools: true}}}
+	require.True(t, ApplyToolEmulation(info, &request))
+	require.True(t, info.ToolEmulated)
+	require.Nil(t, request.Tools)
+	require.Nil(t, request.ToolChoice)
+
+	require.Len(t, request.Messages, 4)
+	require.Equal(t, "system", request.Messages[0].Role)
+	require.Contains(t, request.Messages[0].StringContent(), "get_weather: Get weather")
+	require.Contains(t, request.Messages[0].StringContent(), "You MUST call at least one tool")
+	require.Equal(t, "assistant", request.Messages[2].Role)
+	require.Contains(t, request.Messages[2].StringContent(), `<tool_call>`+"\n"+`{"name":"get_weather","arguments":{"city":"Paris"}}`)
+	require.Empty(t, request.Messages[2].ToolCalls)
+	require.Equal(t, "user", request.Messages[3].Role)
+	require.Contains(t, request.Messages[3].StringContent(), `<tool_result name="get_weather" tool_call_id="call_1">`)
+}
+
+func TestApplyEmulatedToolCallsToResponse(t *testing.T) {
+	t.Parallel()
+
+	content := "Let me check.\n<tool_call>\n{\"name\": \"get_weather\", \"arguments\": {\"city\": \"Paris\"}}\n</tool_call>"
+	response := dto.OpenAITextResponse{
+		Choices: []dto.OpenAITextResponseChoice{{
+			Message:      dto.Message{Role: "assistant", Content: content},
+			FinishReason: "stop",
+		}},
+	}
+	ApplyEmulatedToolCallsToResponse(&response)
+
+	choice := response.Choices[0]
+	require.Equal(t, "tool_calls", choice.FinishReason)
+	require.Equal(t, "Let me check.", choice.Message.StringContent())
+	toolCalls := choice.Message.ParseToolCalls()
+	require.Len(t, toolCalls, 1)
+	require.Equal(t, "get_weather", toolCalls[0].Function.Name)
+	require.JSONEq(t, `{"city":"Paris"}`, toolCalls[0].Function.Arguments)
+}
+
+func TestToolEmulationStreamParser(t *testing.T) {
+	t.Parallel()
+
+	parser := NewToolEmulationStreamParser()
+	deltas := []string{"Hi <", "tool_", "call>\n{\"name\":\"get_weather\",", "\"arguments\":{\"city\":\"Paris\"}}\n</tool", "_call>", ""}
+	var text string
+	var toolCalls []dto.ToolCallResponse
+	var finishReason string
+	for i, delta := range deltas {
+		chunk := dto.ChatCompletionsStreamResponse{
+			Choices: []dto.ChatCompletionsStreamResponseChoice{{Index: 0}},
+		}
+		chunk.Choices[0].Delta.SetContentString(delta)
+		if i == len(deltas)-1 {
+			stop := "stop"
+			chunk.Choices[0].FinishReason = &stop
+		}
+		parser.ProcessChunk(&chunk)
+		text += chunk.Choices[0].Delta.GetContentString()
+		toolCalls = append(toolCalls, chunk.Choices[0].Delta.ToolCalls...)
+		if chunk.Choices[0].FinishReason != nil {
+			finishReason = *chunk.Choices[0].FinishReason
+		}
+	}
+
+	require.Equal(t, "Hi ", text)
+	require.Len(t, toolCalls, 1)
+	require.Equal(t, 0, *toolCalls[0].Index)
+	require.Equal(t, "get_weather", toolCalls[0].Function.Name)
+	require.JSONEq(t, `{"city":"Paris"}`, toolCalls[0].Function.Arguments)
+	require.Equal(t, "tool_calls", finishReason)
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ginKeyTrafficSplitSelection = "traffic_split_selection"
+
+// TrafficSplitSelection 请求命中的分流规则与分支
+type TrafficSplitSelection struct {
+	RuleName  string
+	ArmName   string
+	Model     string
+	ChannelId int
+	Sticky    bool
+}
+
+func trafficSplitArmName(arm *operation_setting.TrafficSplitArm) string {
+	if arm.Name != "" {
+		return arm.Name
+	}
+	if arm.Model != "" {
+		return arm.Model
+	}
+	return fmt.Sprintf("channel#%d", arm.ChannelId)
+}
+
+// pickTrafficSplitArm 按权重选择分支。stickyValue 不为空时按其哈希确定性地选择，权重不变时同一用户/会话总是落在同一分支
+func pickTrafficSplitArm(rule *operation_setting.TrafficSplitRule, stickyValue string) *operation_setting.TrafficSplitArm {
+	var total float64
+	for _, arm := range rule.Arms {
+		if arm.Weight > 0 {
+			total += arm.Weight
+		}
+	}
+	if total <= 0 {
+		return nil
+	}
+	var point float64
+	if stickyValue != "" {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(rule.Name + ":" + stickyValue))
+		point = float64(h.Sum64()%10000) / 10000 * total
+	} else {
+		point = rand.Float64() * total
+	}
+	var last *operation_setting.TrafficSplitArm
+	for i := range rule.Arms {
+		arm := &rule.Arms[i]
+		if arm.Weight <= 0 {
+			continue
+		}
+		if point < arm.Weight {
+			return arm
+		}
+		point -= arm.Weight
+		last = arm
+	}
+	return last
+}
+
+// SelectTrafficSplitArm 为请求选择分流分支并写入上下文，未命中规则时返回 nil。
+// 粘性标识复用渠道亲和的 key 来源提取逻辑
+func SelectTrafficSplitArm(c *gin.Context, modelName string, usingGroup string) *TrafficSplitSelection {
+	rule := operation_setting.MatchTrafficSplitRule(usingGroup, modelName)
+	if rule == nil {
+		return nil
+	}
+	stickyValue := ""
+	for _, src := range rule.GetStickyKeySources() {
+		if stickyValue = extractChannelAffinityValue(c, src); stickyValue != "" {
+			break
+		}
+	}
+	arm := pickTrafficSplitArm(rule, stickyValue)
+	if arm == nil {
+		return nil
+	}
+	selection := &TrafficSplitSelection{
+		RuleName:  rule.Name,
+		ArmName:   trafficSplitArmName(arm),
+		Model:     arm.Model,
+		ChannelId: arm.ChannelId,
+		Sticky:    stickyValue != "",
+	}
+	c.Set(ginKeyTrafficSplitSelection, selection)
+	if arm.Model != "" {
+		common.SetContextKey(c, constant.ContextKeyTrafficSplitModel, arm.Model)
+	}
+	return selection
+}
+
+func GetTrafficSplitSelection(c *gin.Context) *TrafficSplitSelection {
+	if c == nil {
+		return nil
+	}
+	value, ok := c.Get(ginKeyTrafficSplitSelection)
+	if !ok {
+		return nil
+	}
+	selection, _ := value.(*TrafficSplitSelection)
+	return selection
+}
+
+// recordTrafficSplitUsage 记录分支成功请求的耗时、额度与上游成本
+func recordTrafficSplitUsage(c *gin.Context, relayInfo *relaycommon.RelayInfo, quota int, cost int) {
+	selection := GetTrafficSplitSelection(c)
+	if selection == nil {
+		return
+	}
+	model.RecordTrafficSplitSuccess(selection.RuleName, selection.ArmName, time.Since(relayInfo.StartTime).Milliseconds(), quota, cost)
+}
+
+// RecordTrafficSplitFailure 记录分支的失败请求，在所有重试结束后调用
+func RecordTrafficSplitFailure(c *gin.Context) {
+	selection := GetTrafficSplitSelection(c)
+	if selection == nil {
+		return
+	}
+	model.RecordTrafficSplitError(selection.RuleName, selection.ArmName)
+}
+
+func AppendTrafficSplitAdminInfo(c *gin.Context, adminInfo map[string]interface{}) {
+	selection := GetTrafficSplitSelection(c)
+	if selection == nil || adminInfo == nil {
+		return
+	}
+	adminInfo["traffic_split"] = map[string]interface{}{
+		"rule":       selection.RuleName,
+		"arm":        selection.ArmName,
+		"model":      selection.Model,
+		"channel_id": selection.ChannelId,
+		"sticky":     selection.Sticky,
+	}
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPickTrafficSplitArm(t *testing.T) {
+	rule := &operation_setting.TrafficSplitRule{
+		Name: "gpt-4o-rollout",
+		Arms: []operation_setting.TrafficSplitArm{
+			{Name: "control", Model: "gpt-4o", Weight: 90},
+			{Name: "disabled", Model: "gpt-4o-mini", Weight: 0},
+			{Name: "candidate", Model: "gpt-4.1", Weight: 10},
+		},
+	}
+
+	// 同一粘性标识总是落在同一分支
+	for i := 0; i < 20; i++ {
+		value := fmt.Sprintf("user-%d", i)
+		first := pickTrafficSplitArm(rule, value)
+		require.NotNil(t, first)
+		require.Equal(t, first, pickTrafficSplitArm(rule, value))
+	}
+
+	counts := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		counts[pickTrafficSplitArm(rule, fmt.Sprintf("conversation-%d", i)).Name]++
+	}
+	require.Zero(t, counts["disabled"])
+	require.InDelta(t, 9000, counts["control"], 300)
+	require.InDelta(t, 1000, counts["candidate"], 300)
+
+	require.Nil(t, pickTrafficSplitArm(&operation_setting.TrafficSplitRule{
+		Arms: []operation_setting.TrafficSplitArm{{Model: "gpt-4o"}},
+	}, ""))
+}
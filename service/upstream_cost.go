@@ -0,0 +1,110 @@
+package service
+
+import (
+	"math"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+)
+
+// UpstreamCostUsage 计算上游成本所需的用量，InputTokens 不包含缓存命中与缓存创建部分
+type UpstreamCostUsage struct {
+	InputTokens      int
+	OutputTokens     int
+	CacheReadTokens  int
+	CacheWriteTokens int
+	Images           int
+	Seconds          float64
+}
+
+// getChannelCostPrice 按 上游模型名 -> 请求模型名 -> 归一化模型名 -> "*" 的顺序查找渠道成本价
+func getChannelCostPrice(relayInfo *relaycommon.RelayInfo) (dto.ChannelCostPrice, bool) {
+	if relayInfo.ChannelMeta == nil || len(relayInfo.ChannelOtherSettings.CostPrices) == 0 {
+		return dto.ChannelCostPrice{}, false
+	}
+	prices := relayInfo.ChannelOtherSettings.CostPrices
+	names := []string{relayInfo.OriginModelName}
+	if relayInfo.UpstreamModelName != "" {
+		names = []string{relayInfo.UpstreamModelName, relayInfo.OriginModelName}
+	}
+	for _, name := range names {
+		if price, ok := prices[name]; ok {
+			return price, true
+		}
+	}
+	for _, name := range names {
+		if price, ok := prices[ratio_setting.FormatMatchingModelName(name)]; ok {
+			return price, true
+		}
+	}
+	price, ok := prices["*"]
+	return price, ok
+}
+
+// computeUpstreamCost 返回以额度为单位的上游成本
+func computeUpstreamCost(price dto.ChannelCostPrice, usage UpstreamCostUsage) int {
+	cacheReadPrice := price.CacheRead
+	if cacheReadPrice == 0 {
+		cacheReadPrice = price.Input
+	}
+	cacheWritePrice := price.CacheWrite
+	if cacheWritePrice == 0 {
+		cacheWritePrice = price.Input
+	}
+	usd := (float64(usage.InputTokens)*price.Input +
+		float64(usage.OutputTokens)*price.Output +
+		float64(usage.CacheReadTokens)*cacheReadPrice +
+		float64(usage.CacheWriteTokens)*cacheWritePrice) / 1_000_000
+	usd += price.PerRequest
+	usd += float64(usage.Images) * price.PerImage
+	usd += usage.Seconds * price.PerSecond
+	if usd <= 0 {
+		return 0
+	}
+	return int(math.Round(usd * common.QuotaPerUnit))
+}
+
+// CalculateUpstreamCost 按渠道配置的成本价计算本次请求的上游成本，未配置成本价时返回 0
+func CalculateUpstreamCost(relayInfo *relaycommon.RelayInfo, usage UpstreamCostUsage) int {
+	if relayInfo == nil {
+		return 0
+	}
+	price, ok := getChannelCostPrice(relayInfo)
+	if !ok {
+		return 0
+	}
+	if usage.Images == 0 {
+		if imageRequest, ok := relayInfo.Request.(*dto.ImageRequest); ok && imageRequest != nil {
+			usage.Images = 1
+			if imageRequest.N != nil && *imageRequest.N > 0 {
+				usage.Images = int(*imageRequest.N)
+			}
+		}
+	}
+	return computeUpstreamCost(price, usage)
+}
+
+// calculateTextUpstreamCost 从文本计费摘要中拆分出未命中缓存的输入、缓存读取与缓存写入 token 计算上游成本
+func calculateTextUpstreamCost(relayInfo *relaycommon.RelayInfo, summary textQuotaSummary) int {
+	if relayInfo.ChannelMeta == nil {
+		return 0
+	}
+	inputTokens := summary.PromptTokens
+	cacheWriteTokens := cacheWriteTokensTotal(summary)
+	// OpenAI 语义的 prompt_tokens 包含缓存部分；Claude 语义与 OpenRouter（已在摘要中扣除）不包含
+	if !summary.IsClaudeUsageSemantic && relayInfo.ChannelType != constant.ChannelTypeOpenRouter {
+		inputTokens -= summary.CacheTokens + cacheWriteTokens
+	}
+	if inputTokens < 0 {
+		inputTokens = 0
+	}
+	return CalculateUpstreamCost(relayInfo, UpstreamCostUsage{
+		InputTokens:      inputTokens,
+		OutputTokens:     summary.CompletionTokens,
+		CacheReadTokens:  summary.CacheTokens,
+		CacheWriteTokens: cacheWriteTokens,
+	})
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeUpstreamCost(t *testing.T) {
+	price := dto.ChannelCostPrice{Input: 2, Output: 8, CacheRead: 0.5, PerRequest: 0.001}
+	cost := computeUpstreamCost(price, UpstreamCostUsage{
+		InputTokens:      1_000_000,
+		OutputTokens:     500_000,
+		CacheReadTokens:  1_000_000,
+		CacheWriteTokens: 1_000_000, // 未配置缓存写入价格，按输入价格计算
+	})
+	require.Equal(t, int((2+4+0.5+2+0.001)*common.QuotaPerUnit), cost)
+
+	require.Equal(t, int(3*common.QuotaPerUnit), computeUpstreamCost(dto.ChannelCostPrice{PerSecond: 0.5}, UpstreamCostUsage{Seconds: 6}))
+	require.Equal(t, 0, computeUpstreamCost(dto.ChannelCostPrice{}, UpstreamCostUsage{InputTokens: 100}))
+}
+
+func TestGetChannelCostPrice(t *testing.T) {
+	info := &relaycommon.RelayInfo{
+		OriginModelName: "gpt-4o",
+		ChannelMeta: &relaycommon.ChannelMeta{
+			UpstreamModelName: "gpt-4o-2024-08-06",
+			ChannelOtherSettings: dto.ChannelOtherSettings{CostPrices: map[string]dto.ChannelCostPrice{
+				"gpt-4o":            {Input: 1},
+				"gpt-4o-2024-08-06": {Input: 2},
+				"*":                 {Input: 3},
+			}},
+		},
+	}
+	price, ok := getChannelCostPrice(info)
+	require.True(t, ok)
+	require.Equal(t, 2.0, price.Input)
+
+	info.UpstreamModelName = "other"
+	info.OriginModelName = "other"
+	price, ok = getChannelCostPrice(info)
+	require.True(t, ok)
+	require.Equal(t, 3.0, price.Input)
+
+	info.ChannelMeta = nil
+	require.Equal(t, 0, CalculateUpstreamCost(info, UpstreamCostUsage{InputTokens: 100}))
+}
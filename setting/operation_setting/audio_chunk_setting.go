@@ -0,0 +1,34 @@
+package operation_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// AudioChunkSetting 长音频转写分片配置：超出上游大小限制的音频在静音处切分后并发转写并合并结果
+type AudioChunkSetting struct {
+	Enabled bool `json:"enabled"`
+	// MaxFileSizeMB 超过该大小的音频文件才会切分，同时作为每个分片的大小上限（Whisper 为 25MB）
+	MaxFileSizeMB int `json:"max_file_size_mb"`
+	// ChunkSeconds 每个分片的目标时长上限（秒）
+	ChunkSeconds int `json:"chunk_seconds"`
+	// SilenceSearchSeconds 在分片末尾向前查找静音切分点的范围（秒）
+	SilenceSearchSeconds int `json:"silence_search_seconds"`
+	// Concurrency 同一请求同时转写的分片数
+	Concurrency int `json:"concurrency"`
+}
+
+var audioChunkSetting = AudioChunkSetting{
+	Enabled:              true,
+	MaxFileSizeMB:        25,
+	ChunkSeconds:         600,
+	SilenceSearchSeconds: 30,
+	Concurrency:          4,
+}
+
+func init() {
+	config.GlobalConfig.Register("audio_chunk_setting", &audioChunkSetting)
+}
+
+func GetAudioChunkSetting() *AudioChunkSetting {
+	return &audioChunkSetting
+}
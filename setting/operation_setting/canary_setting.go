@@ -0,0 +1,72 @@
+package operation_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// CanaryCase 一条金丝雀用例：发送固定提示词，并校验输出是否正确，而不仅是能否连通
+type CanaryCase struct {
+	Name   string `json:"name"`
+	Prompt string `json:"prompt"`
+	Stream bool   `json:"stream"`
+	// MaxTokens 为 0 时使用默认值 256
+	MaxTokens uint `json:"max_tokens"`
+	// ExpectRegex 输出文本需匹配的正则表达式
+	ExpectRegex string `json:"expect_regex"`
+	// ExpectJSONSchema 以 json_schema 结构化输出请求，并校验输出是否符合该 JSON Schema
+	ExpectJSONSchema string `json:"expect_json_schema"`
+	// ExpectToolCall 提供名为该值的工具并要求模型调用，ToolParameters 为工具参数的 JSON Schema，会用于校验调用参数
+	ExpectToolCall string `json:"expect_tool_call"`
+	ToolParameters string `json:"tool_parameters"`
+}
+
+// CanarySuite 对匹配的模型运行的一组用例，Models 中以 * 结尾表示前缀匹配，* 表示所有模型
+type CanarySuite struct {
+	Models []string     `json:"models"`
+	Cases  []CanaryCase `json:"cases"`
+}
+
+type CanarySetting struct {
+	// Enabled 启用后，定时测试渠道时会对连通的渠道运行匹配的金丝雀用例
+	Enabled bool `json:"enabled"`
+	// CheckModelIdentity 校验响应中的 model 字段与请求的上游模型一致（允许带版本后缀）
+	CheckModelIdentity bool `json:"check_model_identity"`
+	// FailureThreshold 连续多少轮校验失败后自动禁用渠道，0 表示不自动禁用
+	FailureThreshold int `json:"failure_threshold"`
+	// HistoryDays 校验记录保留天数
+	HistoryDays int           `json:"history_days"`
+	Suites      []CanarySuite `json:"suites"`
+}
+
+var canarySetting = CanarySetting{
+	Enabled:            false,
+	CheckModelIdentity: true,
+	FailureThreshold:   2,
+	HistoryDays:        7,
+	Suites:             []CanarySuite{},
+}
+
+func init() {
+	config.GlobalConfig.Register("canary_setting", &canarySetting)
+}
+
+func GetCanarySetting() *CanarySetting {
+	return &canarySetting
+}
+
+// MatchCanaryCases 返回适用于模型的全部用例，未启用时返回 nil
+func MatchCanaryCases(model string) []CanaryCase {
+	if !canarySetting.Enabled {
+		return nil
+	}
+	var cases []CanaryCase
+	for _, suite := range canarySetting.Suites {
+		for _, pattern := range suite.Models {
+			if pattern != "" && matchShadowPattern(pattern, model) {
+				cases = append(cases, suite.Cases...)
+				break
+			}
+		}
+	}
+	return cases
+}
@@ -7,15 +7,32 @@ import (
 	"github.com/QuantumNous/new-api/setting/config"
 )
 
+const (
+	LowBalanceActionPriority = "priority" // 降低渠道优先级
+	LowBalanceActionDisable  = "disable"  // 禁用渠道
+)
+
 type MonitorSetting struct {
 	AutoTestChannelEnabled bool    `json:"auto_test_channel_enabled"`
 	AutoTestChannelMinutes float64 `json:"auto_test_channel_minutes"`
+	// 定时查询渠道余额
+	AutoUpdateBalanceEnabled bool    `json:"auto_update_balance_enabled"`
+	AutoUpdateBalanceMinutes float64 `json:"auto_update_balance_minutes"`
+	// 余额低于阈值（美元）时按 LowBalanceAction 处理并通知超级管理员，阈值为 0 时仅在余额耗尽时禁用
+	LowBalanceThreshold float64 `json:"low_balance_threshold"`
+	LowBalanceAction    string  `json:"low_balance_action"`
+	LowBalancePriority  int64   `json:"low_balance_priority"` // 降低优先级时使用的优先级
 }
 
 // 默认配置
 var monitorSetting = MonitorSetting{
-	AutoTestChannelEnabled: false,
-	AutoTestChannelMinutes: 10,
+	AutoTestChannelEnabled:   false,
+	AutoTestChannelMinutes:   10,
+	AutoUpdateBalanceEnabled: false,
+	AutoUpdateBalanceMinutes: 60,
+	LowBalanceThreshold:      0,
+	LowBalanceAction:         LowBalanceActionPriority,
+	LowBalancePriority:       -100,
 }
 
 func init() {
@@ -31,5 +48,12 @@ func GetMonitorSetting() *MonitorSetting {
 			monitorSetting.AutoTestChannelMinutes = float64(frequency)
 		}
 	}
+	if os.Getenv("CHANNEL_UPDATE_FREQUENCY") != "" {
+		frequency, err := strconv.Atoi(os.Getenv("CHANNEL_UPDATE_FREQUENCY"))
+		if err == nil && frequency > 0 {
+			monitorSetting.AutoUpdateBalanceEnabled = true
+			monitorSetting.AutoUpdateBalanceMinutes = float64(frequency)
+		}
+	}
 	return &monitorSetting
 }
@@ -0,0 +1,42 @@
+package operation_setting
+
+import (
+	"slices"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+const (
+	// OutputValidationActionRetry 校验失败时切换下一个渠道重试
+	OutputValidationActionRetry = "retry"
+	// OutputValidationActionError 校验失败时直接返回结构化错误
+	OutputValidationActionError = "error"
+)
+
+// OutputValidationSetting 工具调用参数与 json_schema 结构化输出校验配置
+type OutputValidationSetting struct {
+	// EnabledGroups 开启校验的分组，渠道也可在渠道设置中单独开启
+	EnabledGroups []string `json:"enabled_groups"`
+	// Action 校验失败时的处理方式: retry / error
+	Action string `json:"action"`
+	// ValidateStream 是否校验流式输出（流式响应已发送给客户端，只能记录结果，无法重试）
+	ValidateStream bool `json:"validate_stream"`
+}
+
+var outputValidationSetting = OutputValidationSetting{
+	EnabledGroups:  []string{},
+	Action:         OutputValidationActionRetry,
+	ValidateStream: true,
+}
+
+func init() {
+	config.GlobalConfig.Register("output_validation_setting", &outputValidationSetting)
+}
+
+func GetOutputValidationSetting() *OutputValidationSetting {
+	return &outputValidationSetting
+}
+
+func IsOutputValidationEnabledForGroup(group string) bool {
+	return group != "" && slices.Contains(outputValidationSetting.EnabledGroups, group)
+}
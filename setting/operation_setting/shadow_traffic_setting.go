@@ -0,0 +1,69 @@
+package operation_setting
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// ShadowTrafficRule 将匹配分组/模型的部分真实请求镜像到候选渠道，候选渠道的响应不返回给客户端、不计费
+type ShadowTrafficRule struct {
+	// Group 匹配的分组，空或 * 表示所有分组
+	Group string `json:"group"`
+	// Model 匹配的模型，空或 * 表示所有模型，以 * 结尾表示前缀匹配
+	Model string `json:"model"`
+	// ChannelId 候选渠道 ID
+	ChannelId int `json:"channel_id"`
+	// Percentage 镜像比例 0-100
+	Percentage float64 `json:"percentage"`
+	// CompareOutput 是否计算候选渠道与主渠道输出的相似度
+	CompareOutput bool `json:"compare_output"`
+}
+
+type ShadowTrafficSetting struct {
+	Enabled bool `json:"enabled"`
+	// MaxConcurrency 同时进行的镜像请求上限，超出时丢弃本次镜像
+	MaxConcurrency int                 `json:"max_concurrency"`
+	Rules          []ShadowTrafficRule `json:"rules"`
+}
+
+var shadowTrafficSetting = ShadowTrafficSetting{
+	Enabled:        false,
+	MaxConcurrency: 16,
+	Rules:          []ShadowTrafficRule{},
+}
+
+func init() {
+	config.GlobalConfig.Register("shadow_traffic_setting", &shadowTrafficSetting)
+}
+
+func GetShadowTrafficSetting() *ShadowTrafficSetting {
+	return &shadowTrafficSetting
+}
+
+func matchShadowPattern(pattern string, value string) bool {
+	if pattern == "" || pattern == "*" {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(value, prefix)
+	}
+	return pattern == value
+}
+
+// MatchShadowTrafficRule 返回第一条匹配分组与模型的规则，未启用或无匹配时返回 nil
+func MatchShadowTrafficRule(group string, model string) *ShadowTrafficRule {
+	if !shadowTrafficSetting.Enabled {
+		return nil
+	}
+	for i := range shadowTrafficSetting.Rules {
+		rule := &shadowTrafficSetting.Rules[i]
+		if rule.ChannelId <= 0 || rule.Percentage <= 0 {
+			continue
+		}
+		if matchShadowPattern(rule.Group, group) && matchShadowPattern(rule.Model, model) {
+			return rule
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package operation_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+const (
+	TrafficSplitStickyNone         = ""
+	TrafficSplitStickyUser         = "user"
+	TrafficSplitStickyConversation = "conversation"
+)
+
+// TrafficSplitArm 分流的一个分支
+type TrafficSplitArm struct {
+	// Name 分支名称，用于统计，为空时使用 Model 或渠道 ID
+	Name string `json:"name"`
+	// Model 实际请求的上游模型，为空时沿用请求的模型（仍会应用渠道的模型重定向）
+	Model string `json:"model"`
+	// ChannelId 指定渠道，0 表示按常规方式选择渠道；指定渠道不可用时回退到常规选择
+	ChannelId int `json:"channel_id"`
+	// Weight 分流比例，按所有分支的权重之和归一化
+	Weight float64 `json:"weight"`
+}
+
+// TrafficSplitRule 将公开模型名按比例分流到多个上游模型或渠道（A/B 路由）
+type TrafficSplitRule struct {
+	Name string `json:"name"`
+	// Group 匹配的分组，空或 * 表示所有分组
+	Group string `json:"group"`
+	// Model 匹配的公开模型名，以 * 结尾表示前缀匹配
+	Model string `json:"model"`
+	// StickyBy 粘性维度：user 同一用户固定落在同一分支；conversation 同一会话固定落在同一分支；空表示每个请求独立抽样
+	StickyBy string `json:"sticky_by"`
+	// KeySources 会话标识的来源，与渠道亲和规则的 key_sources 相同，为空时依次尝试 prompt_cache_key 与 metadata.conversation_id
+	KeySources []ChannelAffinityKeySource `json:"key_sources,omitempty"`
+	Arms       []TrafficSplitArm          `json:"arms"`
+}
+
+type TrafficSplitSetting struct {
+	Enabled bool               `json:"enabled"`
+	Rules   []TrafficSplitRule `json:"rules"`
+}
+
+var trafficSplitSetting = TrafficSplitSetting{
+	Enabled: false,
+	Rules:   []TrafficSplitRule{},
+}
+
+var defaultConversationKeySources = []ChannelAffinityKeySource{
+	{Type: "gjson", Path: "prompt_cache_key"},
+	{Type: "gjson", Path: "metadata.conversation_id"},
+}
+
+func init() {
+	config.GlobalConfig.Register("traffic_split_setting", &trafficSplitSetting)
+}
+
+func GetTrafficSplitSetting() *TrafficSplitSetting {
+	return &trafficSplitSetting
+}
+
+// GetStickyKeySources 返回规则用于粘性分流的标识来源
+func (rule *TrafficSplitRule) GetStickyKeySources() []ChannelAffinityKeySource {
+	switch rule.StickyBy {
+	case TrafficSplitStickyUser:
+		return []ChannelAffinityKeySource{{Type: "context_int", Key: "id"}}
+	case TrafficSplitStickyConversation:
+		if len(rule.KeySources) > 0 {
+			return rule.KeySources
+		}
+		return defaultConversationKeySources
+	default:
+		return nil
+	}
+}
+
+// MatchTrafficSplitRule 返回第一条匹配分组与模型且有可用分支的规则，未启用或无匹配时返回 nil
+func MatchTrafficSplitRule(group string, model string) *TrafficSplitRule {
+	if !trafficSplitSetting.Enabled {
+		return nil
+	}
+	for i := range trafficSplitSetting.Rules {
+		rule := &trafficSplitSetting.Rules[i]
+		if rule.Model == "" || len(rule.Arms) == 0 {
+			continue
+		}
+		if matchShadowPattern(rule.Group, group) && matchShadowPattern(rule.Model, model) {
+			return rule
+		}
+	}
+	return nil
+}
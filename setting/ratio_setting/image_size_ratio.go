@@ -0,0 +1,51 @@
+package ratio_setting
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/types"
+)
+
+// imageSizeRatioMap 按模型配置图片尺寸/质量倍率，仅对按次计费的图片模型生效。
+// 内层 key 支持 "size:quality"、"size"、"quality" 与 "default"，按此顺序匹配，例如：
+//
+//	{"imagen-4.0-generate-001": {"1024x1024": 1, "2048x2048": 2}, "gpt-image-1": {"1024x1024:high": 4, "low": 0.25}}
+var imageSizeRatioMap = types.NewRWMap[string, map[string]float64]()
+
+func ImageSizeRatio2JSONString() string {
+	return imageSizeRatioMap.MarshalJSONString()
+}
+
+func UpdateImageSizeRatioByJSONString(jsonStr string) error {
+	return types.LoadFromJsonStringWithCallback(imageSizeRatioMap, jsonStr, InvalidateExposedDataCache)
+}
+
+// GetImageSizeRatio 返回模型在指定尺寸和质量下的倍率，未配置时返回 false
+func GetImageSizeRatio(name string, size string, quality string) (float64, bool) {
+	ratios, ok := imageSizeRatioMap.Get(name)
+	if !ok {
+		ratios, ok = imageSizeRatioMap.Get(FormatMatchingModelName(name))
+	}
+	if !ok || len(ratios) == 0 {
+		return 1, false
+	}
+	size = strings.ToLower(strings.TrimSpace(size))
+	quality = strings.ToLower(strings.TrimSpace(quality))
+	candidates := make([]string, 0, 4)
+	if size != "" && quality != "" {
+		candidates = append(candidates, size+":"+quality)
+	}
+	if size != "" {
+		candidates = append(candidates, size)
+	}
+	if quality != "" {
+		candidates = append(candidates, quality)
+	}
+	candidates = append(candidates, "default")
+	for _, key := range candidates {
+		if ratio, exists := ratios[key]; exists {
+			return ratio, true
+		}
+	}
+	return 1, false
+}
@@ -0,0 +1,71 @@
+package ratio_setting
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/types"
+)
+
+// TimeRatioRule 时段价格倍率，Schedule 为类 cron 的生效时间表达式（见 common.ActiveSchedule）
+type TimeRatioRule struct {
+	Schedule string  `json:"schedule"`
+	Timezone string  `json:"timezone,omitempty"`
+	Ratio    float64 `json:"ratio"`
+}
+
+// modelTimeRatioMap 按模型配置时段价格倍率，按顺序匹配第一条生效的规则，"*" 对未单独配置的模型生效，例如夜间五折：
+//
+//	{"gpt-4o": [{"schedule": "* 0-7 * * *", "timezone": "Asia/Shanghai", "ratio": 0.5}]}
+var modelTimeRatioMap = types.NewRWMap[string, []TimeRatioRule]()
+
+func ModelTimeRatio2JSONString() string {
+	return modelTimeRatioMap.MarshalJSONString()
+}
+
+func UpdateModelTimeRatioByJSONString(jsonStr string) error {
+	if err := CheckModelTimeRatio(jsonStr); err != nil {
+		return err
+	}
+	return types.LoadFromJsonStringWithCallback(modelTimeRatioMap, jsonStr, InvalidateExposedDataCache)
+}
+
+// CheckModelTimeRatio 校验时段价格倍率配置，不修改当前配置
+func CheckModelTimeRatio(jsonStr string) error {
+	rules := make(map[string][]TimeRatioRule)
+	if err := common.UnmarshalJsonStr(jsonStr, &rules); err != nil {
+		return err
+	}
+	for model, modelRules := range rules {
+		for _, rule := range modelRules {
+			if rule.Ratio < 0 {
+				return fmt.Errorf("模型 %s 的时段倍率不能为负数", model)
+			}
+			if _, err := common.ParseActiveSchedule(rule.Schedule, rule.Timezone); err != nil {
+				return fmt.Errorf("模型 %s 的时段格式错误: %w", model, err)
+			}
+		}
+	}
+	return nil
+}
+
+// GetModelTimeRatio 返回模型在给定时间的时段价格倍率，未配置或不在任何时段内时返回 1
+func GetModelTimeRatio(name string, t time.Time) float64 {
+	rules, ok := modelTimeRatioMap.Get(name)
+	if !ok {
+		rules, ok = modelTimeRatioMap.Get(FormatMatchingModelName(name))
+	}
+	if !ok {
+		rules, ok = modelTimeRatioMap.Get("*")
+	}
+	if !ok {
+		return 1
+	}
+	for _, rule := range rules {
+		if common.IsScheduleActive(rule.Schedule, rule.Timezone, t) {
+			return rule.Ratio
+		}
+	}
+	return 1
+}
@@ -0,0 +1,28 @@
+package system_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// ImageTaskSettings 异步生图任务配置：/v1/images/generations?async=true 提交任务后立即返回任务 ID，
+// 同步请求落在异步上游（Kling、Replicate 等）时由网关提交任务并等待结果
+type ImageTaskSettings struct {
+	// SyncWaitTimeoutSeconds 同步请求等待任务完成的最长时间（秒，最少 10 秒），超时返回任务 ID，任务在后台继续由轮询完成
+	SyncWaitTimeoutSeconds int `json:"sync_wait_timeout_seconds"`
+	// SyncPollIntervalSeconds 同步等待期间主动查询上游的间隔（秒）
+	SyncPollIntervalSeconds int `json:"sync_poll_interval_seconds"`
+}
+
+var defaultImageTaskSettings = ImageTaskSettings{
+	SyncWaitTimeoutSeconds:  300,
+	SyncPollIntervalSeconds: 3,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("image_task", &defaultImageTaskSettings)
+}
+
+func GetImageTaskSettings() *ImageTaskSettings {
+	return &defaultImageTaskSettings
+}
@@ -0,0 +1,54 @@
+package system_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+const (
+	MediaStorageBackendLocal = "local"
+	MediaStorageBackendS3    = "s3"
+)
+
+// MediaStorageSettings 生成的视频/图片/音频的持久化存储配置
+type MediaStorageSettings struct {
+	Enabled bool `json:"enabled"`
+	// Backend 存储后端：local 本地文件系统，s3 兼容 S3 协议的对象存储（如 MinIO、R2）
+	Backend string `json:"backend"`
+	// LocalPath 本地存储目录，为空时使用磁盘缓存目录
+	LocalPath string `json:"local_path"`
+
+	S3Endpoint    string `json:"s3_endpoint"` // 例如 https://s3.us-east-1.amazonaws.com 或 http://minio:9000
+	S3Region      string `json:"s3_region"`
+	S3Bucket      string `json:"s3_bucket"`
+	S3AccessKeyId string `json:"s3_access_key_id"`
+	S3Secret      string `json:"s3_secret"`
+	S3PathStyle   bool   `json:"s3_path_style"` // MinIO 等通常需要路径风格
+	S3Prefix      string `json:"s3_prefix"`
+
+	// SignedURLExpireSeconds 签名下载链接的有效期（秒）
+	SignedURLExpireSeconds int `json:"signed_url_expire_seconds"`
+	// RetentionDays 文件保留天数，0 表示永久保留
+	RetentionDays int `json:"retention_days"`
+	// UserQuotaMB 每个用户的存储配额（MB），0 表示不限制；超出配额时保留上游原始链接
+	UserQuotaMB int64 `json:"user_quota_mb"`
+	// MaxFileSizeMB 单个文件大小上限（MB）
+	MaxFileSizeMB int64 `json:"max_file_size_mb"`
+	// StoreImageResponses 将图片接口返回的 b64_json 同样转存，并以签名链接返回
+	StoreImageResponses bool `json:"store_image_responses"`
+}
+
+var defaultMediaStorageSettings = MediaStorageSettings{
+	Enabled:                false,
+	Backend:                MediaStorageBackendLocal,
+	S3Region:               "us-east-1",
+	SignedURLExpireSeconds: 3600,
+	RetentionDays:          30,
+	MaxFileSizeMB:          512,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("media_storage", &defaultMediaStorageSettings)
+}
+
+func GetMediaStorageSettings() *MediaStorageSettings {
+	return &defaultMediaStorageSettings
+}
@@ -0,0 +1,57 @@
+package system_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// TaskConcurrencySettings 限制每个用户、每个令牌同时进行中的异步任务数，超出时拒绝或在网关排队
+type TaskConcurrencySettings struct {
+	Enabled bool `json:"enabled"`
+	// GroupLimits 按用户分组配置每个用户进行中的任务数上限，未配置的分组使用 DefaultUserLimit
+	GroupLimits map[string]int `json:"group_limits"`
+	// DefaultUserLimit 每个用户进行中的任务数上限，0 表示不限制
+	DefaultUserLimit int `json:"default_user_limit"`
+	// DefaultTokenLimit 每个令牌进行中的任务数上限，令牌单独设置了上限时以令牌为准，0 表示不限制
+	DefaultTokenLimit int `json:"default_token_limit"`
+	// QueueEnabled 超出上限时在网关排队，有空位后按用户先进先出提交；关闭时直接拒绝
+	QueueEnabled bool `json:"queue_enabled"`
+	// MaxQueuedPerUser 每个用户最多排队的任务数，超出后拒绝
+	MaxQueuedPerUser int `json:"max_queued_per_user"`
+	// QueueTimeoutMinutes 排队超过该时长仍未提交的任务置为失败，0 表示不超时
+	QueueTimeoutMinutes int `json:"queue_timeout_minutes"`
+}
+
+var defaultTaskConcurrencySettings = TaskConcurrencySettings{
+	Enabled:             false,
+	GroupLimits:         map[string]int{},
+	DefaultUserLimit:    10,
+	DefaultTokenLimit:   0,
+	QueueEnabled:        false,
+	MaxQueuedPerUser:    20,
+	QueueTimeoutMinutes: 60,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("task_concurrency", &defaultTaskConcurrencySettings)
+}
+
+func GetTaskConcurrencySettings() *TaskConcurrencySettings {
+	return &defaultTaskConcurrencySettings
+}
+
+// GetUserLimit 返回用户所在分组的进行中任务数上限，0 表示不限制
+func (s *TaskConcurrencySettings) GetUserLimit(group string) int {
+	if limit, ok := s.GroupLimits[group]; ok {
+		return limit
+	}
+	return s.DefaultUserLimit
+}
+
+// GetTokenLimit 令牌单独设置的上限优先，0 表示不限制
+func (s *TaskConcurrencySettings) GetTokenLimit(tokenLimit int) int {
+	if tokenLimit > 0 {
+		return tokenLimit
+	}
+	return s.DefaultTokenLimit
+}
@@ -0,0 +1,72 @@
+package system_setting
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// TaskPollingSettings 异步任务轮询配置
+type TaskPollingSettings struct {
+	// Distributed 开启后所有节点共同参与轮询，任务通过数据库租约在节点间分配；关闭时仅主节点轮询
+	Distributed bool `json:"distributed"`
+	// IntervalSeconds 基础轮询间隔（秒）
+	IntervalSeconds int `json:"interval_seconds"`
+	// MaxIntervalSeconds 退避后的最大轮询间隔（秒）
+	MaxIntervalSeconds int `json:"max_interval_seconds"`
+	// BackoffAfterMinutes 任务提交超过该时长后开始退避，之后每经过一个该时长轮询间隔翻倍，0 表示不退避
+	BackoffAfterMinutes int `json:"backoff_after_minutes"`
+	// Concurrency 每个平台的默认并发 worker 数
+	Concurrency int `json:"concurrency"`
+	// PlatformConcurrency 按平台覆盖并发数，key 为任务平台（如 suno、渠道类型编号）
+	PlatformConcurrency map[string]int `json:"platform_concurrency"`
+	// PlatformIntervalSeconds 按平台覆盖基础轮询间隔
+	PlatformIntervalSeconds map[string]int `json:"platform_interval_seconds"`
+	// CallbackEnabled 提交任务时向支持回调的上游（Kling、豆包、Vidu）注册完成回调，需配置服务器地址
+	CallbackEnabled bool `json:"callback_enabled"`
+	// CallbackGraceSeconds 注册回调后推迟轮询的时长（秒），超时未收到回调则恢复轮询
+	CallbackGraceSeconds int `json:"callback_grace_seconds"`
+}
+
+var defaultTaskPollingSettings = TaskPollingSettings{
+	Distributed:             false,
+	IntervalSeconds:         15,
+	MaxIntervalSeconds:      120,
+	BackoffAfterMinutes:     10,
+	Concurrency:             4,
+	PlatformConcurrency:     map[string]int{},
+	PlatformIntervalSeconds: map[string]int{},
+	CallbackEnabled:         false,
+	CallbackGraceSeconds:    600,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("task_polling", &defaultTaskPollingSettings)
+}
+
+func GetTaskPollingSettings() *TaskPollingSettings {
+	return &defaultTaskPollingSettings
+}
+
+// GetInterval 返回平台的基础轮询间隔（秒）
+func (s *TaskPollingSettings) GetInterval(platform string) int {
+	if v, ok := s.PlatformIntervalSeconds[strings.ToLower(platform)]; ok && v > 0 {
+		return v
+	}
+	if s.IntervalSeconds > 0 {
+		return s.IntervalSeconds
+	}
+	return 15
+}
+
+// GetConcurrency 返回平台的并发 worker 数
+func (s *TaskPollingSettings) GetConcurrency(platform string) int {
+	if v, ok := s.PlatformConcurrency[strings.ToLower(platform)]; ok && v > 0 {
+		return v
+	}
+	if s.Concurrency > 0 {
+		return s.Concurrency
+	}
+	return 1
+}
@@ -0,0 +1,57 @@
+package system_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// TaskResubmitPolicy 单个模型的自动重新提交策略
+type TaskResubmitPolicy struct {
+	// MaxAttempts 最多重新提交的次数
+	MaxAttempts int `json:"max_attempts"`
+	// RetryOnTimeout 任务超时（sweepTimedOutTasks）时是否重新提交
+	RetryOnTimeout bool `json:"retry_on_timeout"`
+	// ReasonKeywords 失败原因包含任一关键字（不区分大小写）时重新提交，为空时使用全局默认关键字
+	ReasonKeywords []string `json:"reason_keywords"`
+}
+
+// TaskResubmitSettings 异步任务失败后自动换渠道重新提交的配置，仅对配置了策略的模型生效
+type TaskResubmitSettings struct {
+	Enabled bool `json:"enabled"`
+	// Models 按模型名配置策略
+	Models map[string]TaskResubmitPolicy `json:"models"`
+	// DefaultReasonKeywords 视为临时性失败的默认关键字
+	DefaultReasonKeywords []string `json:"default_reason_keywords"`
+}
+
+var defaultTaskResubmitSettings = TaskResubmitSettings{
+	Enabled: false,
+	Models:  map[string]TaskResubmitPolicy{},
+	DefaultReasonKeywords: []string{
+		"capacity", "overload", "busy", "timeout", "timed out", "rate limit", "try again",
+		"temporarily", "unavailable", "moderation", "繁忙", "超时", "稍后",
+	},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("task_resubmit", &defaultTaskResubmitSettings)
+}
+
+func GetTaskResubmitSettings() *TaskResubmitSettings {
+	return &defaultTaskResubmitSettings
+}
+
+// GetPolicy 返回模型的重新提交策略，未开启或未配置时返回 nil
+func (s *TaskResubmitSettings) GetPolicy(modelName string) *TaskResubmitPolicy {
+	if !s.Enabled || modelName == "" {
+		return nil
+	}
+	policy, ok := s.Models[modelName]
+	if !ok || policy.MaxAttempts <= 0 {
+		return nil
+	}
+	if len(policy.ReasonKeywords) == 0 {
+		policy.ReasonKeywords = s.DefaultReasonKeywords
+	}
+	return &policy
+}
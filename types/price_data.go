@@ -6,6 +6,7 @@ type GroupRatioInfo struct {
 	GroupRatio        float64
 	GroupSpecialRatio float64
 	HasSpecialRatio   bool
+	TimeRatio         float64 // 时段价格倍率，已乘入 GroupRatio
 }
 
 type PriceData struct {
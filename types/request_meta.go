@@ -26,7 +26,10 @@ type TokenCountMeta struct {
 	Files         []*FileMeta `json:"files,omitempty"`          // List of files, each with type and content
 	MaxTokens     int         `json:"max_tokens,omitempty"`     // Maximum tokens allowed in the request
 
-	ImagePriceRatio float64 `json:"image_ratio,omitempty"` // Ratio for image size, if applicable
+	ImagePriceRatio float64 `json:"image_ratio,omitempty"`   // Ratio for image size, if applicable
+	ImageSize       string  `json:"image_size,omitempty"`    // Requested image size, used for per-size pricing
+	ImageQuality    string  `json:"image_quality,omitempty"` // Requested image quality, used for per-quality pricing
+	ImageCount      int     `json:"image_count,omitempty"`   // Number of images requested
 	//IsStreaming   bool        `json:"is_streaming,omitempty"`   // Indicates if the request is streaming
 }
 
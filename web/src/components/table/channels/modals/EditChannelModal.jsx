@@ -153,6 +153,8 @@ const EditChannelModal = (props) => {
     pass_through_body_enabled: false,
     system_prompt: '',
     system_prompt_override: false,
+    emulate_tools: false,
+    emulate_completions: false,
     settings: '',
     // 仅 Vertex: 密钥格式（存入 settings.vertex_key_type）
     vertex_key_type: 'json',
@@ -537,6 +539,9 @@ const EditChannelModal = (props) => {
           data.system_prompt = parsedSettings.system_prompt || '';
           data.system_prompt_override =
             parsedSettings.system_prompt_override || false;
+          data.emulate_tools = parsedSettings.emulate_tools || false;
+          data.emulate_completions =
+            parsedSettings.emulate_completions || false;
         } catch (error) {
           console.error('解析渠道设置失败:', error);
           data.force_format = false;
@@ -545,6 +550,9 @@ const EditChannelModal = (props) => {
           data.pass_through_body_enabled = false;
           data.system_prompt = '';
           data.system_prompt_override = false;
+          data.emulate_tools = false;
+        data.emulate_completions = false;
+          data.emulate_completions = false;
         }
       } else {
         data.force_format = false;
@@ -553,6 +561,8 @@ const EditChannelModal = (props) => {
         data.pass_through_body_enabled = false;
         data.system_prompt = '';
         data.system_prompt_override = false;
+        data.emulate_tools = false;
+        data.emulate_completions = false;
       }
 
       if (data.settings) {
@@ -621,6 +631,8 @@ const EditChannelModal = (props) => {
         pass_through_body_enabled: data.pass_through_body_enabled,
         system_prompt: data.system_prompt,
         system_prompt_override: data.system_prompt_override || false,
+        emulate_tools: data.emulate_tools || false,
+        emulate_completions: data.emulate_completions || false,
       });
       initialModelsRef.current = (data.models || [])
         .map((model) => (model || '').trim())
@@ -879,6 +891,9 @@ const EditChannelModal = (props) => {
       pass_through_body_enabled: false,
       system_prompt: '',
       system_prompt_override: false,
+      emulate_tools: false,
+      emulate_completions: false,
+    emulate_completions: false,
     });
     // 重置密钥模式状态
     setKeyMode('append');
@@ -1165,6 +1180,8 @@ const EditChannelModal = (props) => {
       pass_through_body_enabled: localInputs.pass_through_body_enabled || false,
       system_prompt: localInputs.system_prompt || '',
       system_prompt_override: localInputs.system_prompt_override || false,
+      emulate_tools: localInputs.emulate_tools || false,
+      emulate_completions: localInputs.emulate_completions || false,
     };
     localInputs.setting = JSON.stringify(channelExtraSettings);
 
@@ -1216,6 +1233,8 @@ const EditChannelModal = (props) => {
     delete localInputs.pass_through_body_enabled;
     delete localInputs.system_prompt;
     delete localInputs.system_prompt_override;
+    delete localInputs.emulate_tools;
+    delete localInputs.emulate_completions;
     delete localInputs.is_enterprise_account;
     // 顶层的 vertex_key_type 不应发送给后端
     delete localInputs.vertex_key_type;
@@ -2965,6 +2984,32 @@ const EditChannelModal = (props) => {
                       )}
                     />
 
+                    <Form.Switch
+                      field='emulate_tools'
+                      label={t('模拟工具调用')}
+                      checkedText={t('开')}
+                      uncheckedText={t('关')}
+                      onChange={(value) =>
+                        handleChannelSettingsChange('emulate_tools', value)
+                      }
+                      extraText={t(
+                        '上游不支持原生 function calling 时，将 tools 改写为提示词协议，并从输出文本中解析出 tool_calls',
+                      )}
+                    />
+
+                    <Form.Switch
+                      field='emulate_completions'
+                      label={t('模拟 Completions 接口')}
+                      checkedText={t('开')}
+                      uncheckedText={t('关')}
+                      onChange={(value) =>
+                        handleChannelSettingsChange('emulate_completions', value)
+                      }
+                      extraText={t(
+                        '上游不支持 /v1/completions 时，将 prompt 转换为对话请求，并将结果转换回 text_completion 格式',
+                      )}
+                    />
+
                     <Form.Switch
                       field='pass_through_body_enabled'
                       label={t('透传请求体')}